@@ -3,10 +3,13 @@ package api
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/filecoin-project/lotus/build"
+	"github.com/libp2p/go-libp2p-core/metrics"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
 )
 
 type Permission = string
@@ -15,6 +18,10 @@ type Common interface {
 	// Auth
 	AuthVerify(ctx context.Context, token string) ([]Permission, error)
 	AuthNew(ctx context.Context, perms []Permission) ([]byte, error)
+	// AuthList lists tokens minted with AuthNew which weren't revoked
+	AuthList(ctx context.Context) ([]AuthToken, error)
+	// AuthRevoke invalidates the token with the given ID
+	AuthRevoke(ctx context.Context, id string) error
 
 	// network
 
@@ -24,6 +31,24 @@ type Common interface {
 	NetAddrsListen(context.Context) (peer.AddrInfo, error)
 	NetDisconnect(context.Context, peer.ID) error
 
+	// NetPeerAdd pins a peer. Pinned peers are reconnected after disconnects
+	// and node restarts.
+	NetPeerAdd(context.Context, peer.AddrInfo) error
+	NetPeerRemove(context.Context, peer.ID) error
+	// NetPeerProtect excludes a peer from connection trimming, pinning it if
+	// needed
+	NetPeerProtect(ctx context.Context, p peer.ID, protect bool) error
+	NetPeerList(context.Context) ([]PinnedPeer, error)
+	// NetReachability reports whether the node is reachable from the public
+	// internet, as detected with AutoNAT, and which addresses it advertises
+	NetReachability(context.Context) (NetReachability, error)
+	// NetBandwidthStats returns bandwidth used with all peers
+	NetBandwidthStats(ctx context.Context) (metrics.Stats, error)
+	// NetBandwidthStatsByPeer returns bandwidth used with each peer, keyed
+	// by peer ID
+	NetBandwidthStatsByPeer(ctx context.Context) (map[string]metrics.Stats, error)
+	NetBandwidthStatsByProtocol(ctx context.Context) (map[protocol.ID]metrics.Stats, error)
+
 	// ID returns peerID of libp2p node backing this API
 	ID(context.Context) (peer.ID, error)
 
@@ -32,6 +57,40 @@ type Common interface {
 
 	LogList(context.Context) ([]string, error)
 	LogSetLevel(context.Context, string, string) error
+
+	// DatastoreGC reclaims space used by deleted and overwritten entries of
+	// the repo datastore
+	DatastoreGC(context.Context) error
+}
+
+// AuthToken describes an API token minted by the node. The token itself
+// isn't stored.
+type AuthToken struct {
+	ID      string
+	Allow   []Permission
+	Created time.Time
+}
+
+// PinnedPeer is a peer the node keeps connected to
+type PinnedPeer struct {
+	AddrInfo  peer.AddrInfo
+	Protected bool
+}
+
+const (
+	ReachabilityUnknown = "unknown"
+	ReachabilityPublic  = "public"
+	ReachabilityPrivate = "private"
+)
+
+type NetReachability struct {
+	// Status is one of the Reachability* consts
+	Status string
+	// PublicAddr is the address peers could dial the node at, if public
+	PublicAddr string `json:",omitempty"`
+	// Advertised holds the addresses announced to other peers, including
+	// relay addresses
+	Advertised peer.AddrInfo
 }
 
 // Version provides various build-time information
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/filecoin-project/go-address"
@@ -23,6 +24,12 @@ type FullNode interface {
 	// ChainNotify returns channel with chain head updates
 	// First message is guaranteed to be of len == 1, and type == 'current'
 	ChainNotify(context.Context) (<-chan []*store.HeadChange, error)
+	// ChainNotifyReorgs returns a channel reporting each head change as the
+	// list of reverted and applied tipsets, along with the reorg depth
+	ChainNotifyReorgs(context.Context) (<-chan *store.ReorgChange, error)
+	// ChainNotifyConfidence is like ChainNotify, but only reports tipsets
+	// once they have the given number of descendants
+	ChainNotifyConfidence(ctx context.Context, confidence uint64) (<-chan []*store.HeadChange, error)
 	ChainHead(context.Context) (*types.TipSet, error)
 	ChainGetRandomness(context.Context, types.TipSetKey, int64) ([]byte, error)
 	ChainGetBlock(context.Context, cid.Cid) (*types.BlockHeader, error)
@@ -39,7 +46,15 @@ type FullNode interface {
 	ChainGetNode(ctx context.Context, p string) (interface{}, error)
 	ChainGetMessage(context.Context, cid.Cid) (*types.Message, error)
 	ChainGetPath(ctx context.Context, from types.TipSetKey, to types.TipSetKey) ([]*store.HeadChange, error)
-	ChainExport(context.Context, *types.TipSet) (<-chan []byte, error)
+	// ChainExport returns a stream of bytes with a CAR dump of chain data.
+	// State trees are only included for the last nroots epochs.
+	ChainExport(ctx context.Context, nroots uint64, ts *types.TipSet) (<-chan []byte, error)
+	// ChainPrune removes state trees older than the last retain epochs from
+	// the blockstore. Block headers and messages are kept.
+	ChainPrune(ctx context.Context, retain uint64) (*PruneResult, error)
+	// ChainGasTrace re-executes an on-chain message and reports the gas it
+	// used, broken down by operation class
+	ChainGasTrace(ctx context.Context, msg cid.Cid) (*GasProfile, error)
 
 	// syncer
 	SyncState(context.Context) (*SyncState, error)
@@ -47,13 +62,30 @@ type FullNode interface {
 	SyncIncomingBlocks(ctx context.Context) (<-chan *types.BlockHeader, error)
 	SyncMarkBad(ctx context.Context, bcid cid.Cid) error
 	SyncCheckBad(ctx context.Context, bcid cid.Cid) (string, error)
+	// SyncCheckpoint sets a trusted checkpoint tipset. The node will only sync
+	// chains containing it, and won't validate or execute tipsets before it.
+	// The parent state of the checkpoint has to be available locally, e.g.
+	// from an imported snapshot.
+	SyncCheckpoint(ctx context.Context, tsk types.TipSetKey) error
+	SyncGetCheckpoint(ctx context.Context) (*types.TipSet, error)
+	SyncClearCheckpoint(ctx context.Context) error
 
 	// messages
 	MpoolPending(context.Context, *types.TipSet) ([]*types.SignedMessage, error)
+	// MpoolPendingFilter returns the pending messages matching the filter
+	MpoolPendingFilter(context.Context, *MpoolFilter, *types.TipSet) ([]*types.SignedMessage, error)
+	// MpoolStats returns per-sender statistics about the pending messages
+	MpoolStats(context.Context, *types.TipSet) (*MpoolStats, error)
 	MpoolPush(context.Context, *types.SignedMessage) (cid.Cid, error)
-	MpoolPushMessage(context.Context, *types.Message) (*types.SignedMessage, error) // get nonce, sign, push
+	// MpoolPushMessage assigns the next nonce of the sender to the message,
+	// signs and pushes it. Nonces are assigned atomically, so concurrent
+	// pushes from the same address never get the same nonce. The optional spec
+	// prices the message.
+	MpoolPushMessage(context.Context, *types.Message, *MessageSendSpec) (*types.SignedMessage, error)
 	MpoolGetNonce(context.Context, address.Address) (uint64, error)
 	MpoolSub(context.Context) (<-chan MpoolUpdate, error)
+	// MpoolSubFilter streams mpool updates for messages matching the filter
+	MpoolSubFilter(context.Context, *MpoolFilter) (<-chan MpoolUpdate, error)
 
 	// FullNodeStruct
 
@@ -73,6 +105,15 @@ type FullNode interface {
 	WalletSignMessage(context.Context, address.Address, *types.Message) (*types.SignedMessage, error)
 	WalletDefaultAddress(context.Context) (address.Address, error)
 	WalletSetDefault(context.Context, address.Address) error
+	// WalletDefaultAddressFor returns the address to send messages for the
+	// given purpose (see AddrPurpose*) from, the default address is returned
+	// when none was set for the purpose
+	WalletDefaultAddressFor(ctx context.Context, purpose string) (address.Address, error)
+	// WalletDefaultAddresses lists the addresses set for specific purposes
+	WalletDefaultAddresses(context.Context) (map[string]address.Address, error)
+	// WalletSetDefaultFor sets the address used for a purpose (one of
+	// AddrPurposes), address.Undef removes it
+	WalletSetDefaultFor(ctx context.Context, purpose string, addr address.Address) error
 	WalletExport(context.Context, address.Address) (*types.KeyInfo, error)
 	WalletImport(context.Context, *types.KeyInfo) (address.Address, error)
 
@@ -85,7 +126,15 @@ type FullNode interface {
 	ClientListDeals(ctx context.Context) ([]DealInfo, error)
 	ClientHasLocal(ctx context.Context, root cid.Cid) (bool, error)
 	ClientFindData(ctx context.Context, root cid.Cid) ([]QueryOffer, error)
+	// ClientRetrieve retrieves data and writes it to path. Retrievals can run
+	// concurrently. Data already fully in the local blockstore, e.g. after a
+	// retrieval was interrupted while writing it out, isn't paid for again.
+	// Retrievals are not resumable: the provider always sends the DAG from
+	// its root, so an interrupted transfer starts over and is paid again.
 	ClientRetrieve(ctx context.Context, order RetrievalOrder, path string) error
+	// ClientListRetrievals reports the progress of retrievals made since the
+	// node started, only the most recent finished retrievals are kept
+	ClientListRetrievals(ctx context.Context) ([]RetrievalInfo, error)
 	ClientQueryAsk(ctx context.Context, p peer.ID, miner address.Address) (*types.SignedStorageAsk, error)
 
 	// ClientUnimport removes references to the specified file from filestore
@@ -96,11 +145,21 @@ type FullNode interface {
 
 	//ClientListAsks() []Ask
 
-	// if tipset is nil, we'll use heaviest
+	// StateCall executes the message on top of the parent state of the tipset
+	// without changing any state, returning the receipt, the decoded return
+	// value and the execution trace. If tipset is nil, we'll use heaviest.
 	StateCall(context.Context, *types.Message, *types.TipSet) (*MethodCall, error)
 	StateReplay(context.Context, *types.TipSet, cid.Cid) (*ReplayResults, error)
+	// StateReplayTipSet executes all messages included in the tipset,
+	// returning results with execution traces
+	StateReplayTipSet(context.Context, *types.TipSet) ([]*ReplayResults, error)
 	StateGetActor(ctx context.Context, actor address.Address, ts *types.TipSet) (*types.Actor, error)
 	StateReadState(ctx context.Context, act *types.Actor, ts *types.TipSet) (*ActorState, error)
+	// StateReadActor loads the actor at the given address and decodes its state
+	StateReadActor(ctx context.Context, addr address.Address, ts *types.TipSet) (*ActorState, error)
+	// StateDecodeState decodes the state object with the given cid using the
+	// state schema of the actor with the given code
+	StateDecodeState(ctx context.Context, code cid.Cid, head cid.Cid) (interface{}, error)
 	StateListMessages(ctx context.Context, match *types.Message, ts *types.TipSet, toht uint64) ([]cid.Cid, error)
 
 	StateMinerSectors(context.Context, address.Address, *types.TipSet) ([]*ChainSectorInfo, error)
@@ -113,6 +172,15 @@ type FullNode interface {
 	StateMinerFaults(context.Context, address.Address, *types.TipSet) ([]uint64, error)
 	StatePledgeCollateral(context.Context, *types.TipSet) (types.BigInt, error)
 	StateWaitMsg(context.Context, cid.Cid) (*MsgWait, error)
+	// StateSearchMsg looks for a message on chain without waiting for it.
+	// At most lookback epochs are searched (0 for no limit), and the search
+	// gives up after timeout (0 for no timeout). Returns nil when the message
+	// wasn't found.
+	StateSearchMsg(ctx context.Context, msg cid.Cid, lookback uint64, timeout time.Duration) (*MsgWait, error)
+	// StateSubMessages streams messages matching the filter as they are
+	// executed on chain. Messages reverted by a reorg are sent again with
+	// Reverted set.
+	StateSubMessages(context.Context, *MessageFilter) (<-chan []ExecutedMessage, error)
 	StateListMiners(context.Context, *types.TipSet) ([]address.Address, error)
 	StateListActors(context.Context, *types.TipSet) ([]address.Address, error)
 	StateMarketBalance(context.Context, address.Address, *types.TipSet) (actors.StorageParticipantBalance, error)
@@ -121,9 +189,16 @@ type FullNode interface {
 	StateMarketStorageDeal(context.Context, uint64, *types.TipSet) (*actors.OnChainDeal, error)
 	StateLookupID(context.Context, address.Address, *types.TipSet) (address.Address, error)
 	StateChangedActors(context.Context, cid.Cid, cid.Cid) (map[string]types.Actor, error)
+	// StateDiff lists actors created, deleted or modified between the states
+	// of two tipsets. With withState set, changes to decoded actor state
+	// fields are included.
+	StateDiff(ctx context.Context, from, to types.TipSetKey, withState bool) ([]*ActorDiff, error)
 	StateGetReceipt(context.Context, cid.Cid, *types.TipSet) (*types.MessageReceipt, error)
 	StateMinerSectorCount(context.Context, address.Address, *types.TipSet) (MinerSectors, error)
 	StateCompute(context.Context, uint64, []*types.Message, *types.TipSet) (cid.Cid, error)
+	// StateCirculatingSupply reports how much FIL is vested, mined, burnt,
+	// locked and circulating at the given tipset
+	StateCirculatingSupply(context.Context, *types.TipSet) (CirculatingSupply, error)
 
 	MsigGetAvailableBalance(context.Context, address.Address, *types.TipSet) (types.BigInt, error)
 
@@ -173,6 +248,62 @@ type MsgWait struct {
 	TipSet  *types.TipSet
 }
 
+// Purposes of messages, used to select a sender with WalletDefaultAddressFor.
+// Storage miner messages (publishing deals, sealing, PoSt) have no purpose,
+// the actors only accept them from the miner worker.
+const (
+	// AddrPurposeDeals funds storage deals made by the client
+	AddrPurposeDeals = "deals"
+)
+
+// AddrPurposes are the purposes wallet addresses can be set for
+var AddrPurposes = []string{AddrPurposeDeals}
+
+// MessageFilter selects executed messages. Undefined addresses and nil fields
+// match all messages.
+type MessageFilter struct {
+	From     address.Address
+	To       address.Address
+	Method   *uint64
+	ExitCode *uint8
+}
+
+func (f *MessageFilter) MatchesMessage(m *types.Message) bool {
+	if f == nil {
+		return true
+	}
+	if f.From != address.Undef && f.From != m.From {
+		return false
+	}
+	if f.To != address.Undef && f.To != m.To {
+		return false
+	}
+	if f.Method != nil && *f.Method != m.Method {
+		return false
+	}
+	return true
+}
+
+func (f *MessageFilter) MatchesReceipt(r *types.MessageReceipt) bool {
+	if f == nil {
+		return true
+	}
+	return f.ExitCode == nil || *f.ExitCode == r.ExitCode
+}
+
+type ExecutedMessage struct {
+	Cid     cid.Cid
+	Message *types.Message
+	Receipt types.MessageReceipt
+
+	// TipSet is the tipset including the message, its receipt is in the
+	// state of the child tipset at Height+1
+	TipSet types.TipSetKey
+	Height uint64
+
+	Reverted bool
+}
+
 type BlockMessages struct {
 	BlsMessages   []*types.Message
 	SecpkMessages []*types.SignedMessage
@@ -192,10 +323,56 @@ type ChainSectorInfo struct {
 }
 
 type ActorState struct {
+	Code    cid.Cid
+	Head    cid.Cid
+	Nonce   uint64
 	Balance types.BigInt
 	State   interface{}
 }
 
+const (
+	ActorCreated  = "created"
+	ActorDeleted  = "deleted"
+	ActorModified = "modified"
+)
+
+type ActorDiff struct {
+	Address address.Address
+	Change  string
+	Code    cid.Cid
+
+	OldBalance   types.BigInt
+	NewBalance   types.BigInt
+	BalanceDelta types.BigInt
+
+	OldNonce uint64
+	NewNonce uint64
+
+	// StateDiff maps changed top-level actor state fields to their old and
+	// new values, it's only set when requested
+	StateDiff map[string]FieldDiff `json:",omitempty"`
+}
+
+type FieldDiff struct {
+	Old json.RawMessage `json:",omitempty"`
+	New json.RawMessage `json:",omitempty"`
+}
+
+type CirculatingSupply struct {
+	Total types.BigInt
+	// Vested is the part of genesis allocations which finished vesting
+	Vested types.BigInt
+	// Mined is the amount paid out in block rewards
+	Mined types.BigInt
+	Burnt types.BigInt
+	// LockedPledge is the collateral held by miners for their power
+	LockedPledge types.BigInt
+	// LockedDeals is the collateral and payment locked in storage deals
+	LockedDeals types.BigInt
+
+	Circulating types.BigInt
+}
+
 type PCHDir int
 
 const (
@@ -270,15 +447,56 @@ type RetrievalOrder struct {
 	MinerPeerID peer.ID
 }
 
+type RetrievalStatus string
+
+const (
+	RetrievalTransferring RetrievalStatus = "transferring"
+	RetrievalExporting    RetrievalStatus = "exporting"
+	RetrievalComplete     RetrievalStatus = "complete"
+	RetrievalFailed       RetrievalStatus = "failed"
+)
+
+type RetrievalInfo struct {
+	ID     uint64
+	Status RetrievalStatus
+	Error  string
+
+	Root cid.Cid
+	Size uint64
+	Path string
+
+	Client      address.Address
+	Miner       address.Address
+	MinerPeerID peer.ID
+
+	BytesReceived uint64
+	FundsSpent    types.BigInt
+	Total         types.BigInt
+
+	Started time.Time
+}
+
 type ReplayResults struct {
+	MsgCid  cid.Cid
 	Msg     *types.Message
 	Receipt *types.MessageReceipt
 	Error   string
+
+	// ExecutionTrace lists internal sends, gas charges and return values
+	ExecutionTrace *types.ExecutionTrace
 }
 
 type MethodCall struct {
 	types.MessageReceipt
 	Error string
+
+	// Decoded and ExecutionTrace are only set by StateCall
+
+	// Decoded is the return value decoded from CBOR, nil when the method
+	// didn't return a CBOR object
+	Decoded interface{}
+	// ExecutionTrace lists internal sends, gas charges and errors
+	ExecutionTrace *types.ExecutionTrace
 }
 
 type ActiveSync struct {
@@ -293,6 +511,22 @@ type ActiveSync struct {
 	Message string
 }
 
+type GasProfile struct {
+	MsgCid cid.Cid
+	// TipSet is the tipset the message was included in
+	TipSet  types.TipSetKey
+	Receipt *types.MessageReceipt
+	// ByClass maps gas classes (e.g. storage-read, syscall) to gas used
+	ByClass map[string]uint64
+	Trace   *types.ExecutionTrace
+}
+
+type PruneResult struct {
+	Roots  int
+	Blocks int
+	Bytes  uint64
+}
+
 type SyncState struct {
 	ActiveSyncs []ActiveSync
 }
@@ -319,3 +553,69 @@ type MpoolUpdate struct {
 	Type    MpoolChange
 	Message *types.SignedMessage
 }
+
+// MessageSendSpec sets how MpoolPushMessage prices a message
+type MessageSendSpec struct {
+	// MaxFee is the most the message can pay for gas (GasPrice * GasLimit),
+	// nil or 0 doesn't limit the fee
+	MaxFee types.BigInt
+	// GasPremium is the percentage added to the average gas price of pending
+	// messages, which is used when the message gas price is 0
+	GasPremium uint64
+}
+
+// MpoolFilter selects pending messages. Undefined addresses and a nil Method
+// match any message.
+type MpoolFilter struct {
+	From   address.Address
+	To     address.Address
+	Method *uint64
+}
+
+func (f *MpoolFilter) Matches(m *types.Message) bool {
+	if f == nil {
+		return true
+	}
+	if f.From != address.Undef && f.From != m.From {
+		return false
+	}
+	if f.To != address.Undef && f.To != m.To {
+		return false
+	}
+	if f.Method != nil && *f.Method != m.Method {
+		return false
+	}
+	return true
+}
+
+type MpoolStats struct {
+	Count int
+	Size  int
+
+	Senders []MpoolSenderStats
+}
+
+type MpoolSenderStats struct {
+	Address    address.Address
+	StateNonce uint64
+
+	Count int
+	Size  int
+
+	// Past messages have a nonce lower than the actor state nonce, Ready
+	// messages can be included in the next block, Future messages wait for a
+	// nonce gap to be filled
+	Past   int
+	Ready  int
+	Future int
+
+	MinGasPrice types.BigInt
+	MaxGasPrice types.BigInt
+	AvgGasPrice types.BigInt
+
+	GasLimit types.BigInt
+
+	// Error is set when the sender actor couldn't be loaded, the nonce
+	// statistics are then unknown
+	Error string
+}
@@ -2,9 +2,13 @@ package api
 
 import (
 	"context"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-sectorbuilder"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/lotus/chain/types"
 )
 
 // alias because cbor-gen doesn't like non-alias types
@@ -24,7 +28,7 @@ const (
 	CommitWait // waiting for message to land on chain
 	FinalizeSector
 	Proving
-	_ // reserved
+	WaitDeals // waiting for more deals to be added to the sector
 	_
 	_
 
@@ -46,7 +50,7 @@ const (
 	SealCommitFailed
 	CommitFailed
 	PackingFailed
-	_
+	Aborted // sector was aborted by the operator
 	_
 	_
 
@@ -66,12 +70,14 @@ var SectorStates = []string{
 	CommitWait:           "CommitWait",
 	FinalizeSector:       "FinalizeSector",
 	Proving:              "Proving",
+	WaitDeals:            "WaitDeals",
 
 	SealFailed:       "SealFailed",
 	PreCommitFailed:  "PreCommitFailed",
 	SealCommitFailed: "SealCommitFailed",
 	CommitFailed:     "CommitFailed",
 	PackingFailed:    "PackingFailed",
+	Aborted:          "Aborted",
 
 	FailedUnrecoverable: "FailedUnrecoverable",
 
@@ -85,9 +91,20 @@ type StorageMiner interface {
 	Common
 
 	ActorAddress(context.Context) (address.Address, error)
+	// ActorList returns the miner actors served by this API endpoint. The
+	// main endpoint serves all actors, additional actors can be managed
+	// through /rpc/v0/actor/<address>.
+	ActorList(context.Context) ([]address.Address, error)
 
 	ActorSectorSize(context.Context, address.Address) (uint64, error)
 
+	// MiningStatus returns timings of recent block production attempts
+	MiningStatus(context.Context) (*MiningStatus, error)
+
+	// MinerStats summarizes the power, sectors, deals, worker balance and
+	// proving schedule of the miner
+	MinerStats(context.Context) (*MinerStats, error)
+
 	// Temp api for testing
 	PledgeSector(context.Context) error
 
@@ -101,12 +118,66 @@ type StorageMiner interface {
 
 	SectorsUpdate(context.Context, uint64, SectorState) error
 
+	// SectorStartSealing stops adding deals to a sector in the WaitDeals
+	// state and fills the rest of it with pledge pieces
+	SectorStartSealing(context.Context, uint64) error
+
+	// SectorsRetry re-runs the current step of the sealing pipeline of a
+	// sector. Sectors in a failed state are moved back to the state which
+	// failed, sectors waiting for a message wait again. Other sectors can't
+	// be retried.
+	SectorsRetry(context.Context, uint64) error
+
+	// SectorsAbort stops sealing a sector which isn't committed on chain yet
+	SectorsAbort(context.Context, uint64) error
+
 	WorkerStats(context.Context) (sectorbuilder.WorkerStats, error)
 
 	// WorkerQueue registers a remote worker
 	WorkerQueue(context.Context, sectorbuilder.WorkerCfg) (<-chan sectorbuilder.WorkerTask, error)
 
 	WorkerDone(ctx context.Context, task uint64, res sectorbuilder.SealRes) error
+
+	// WorkerProofDevice returns the device remote workers should seal on, an
+	// empty string lets workers use their own setting
+	WorkerProofDevice(context.Context) (string, error)
+
+	// MessagesList returns the messages pushed by the miner, most recent first
+	MessagesList(context.Context) ([]MinerMessage, error)
+	// MessagesRebroadcast pushes a pending message to the network again
+	MessagesRebroadcast(context.Context, cid.Cid) error
+	// MessagesBumpFee replaces a pending message by the same message with a
+	// higher gas price and returns the CID of the new message. Precommit,
+	// commit and fault messages of the sealing pipeline can't be replaced.
+	MessagesBumpFee(ctx context.Context, msg cid.Cid, gasPrice types.BigInt) (cid.Cid, error)
+
+	// CreateBackup writes an archive of the miner keys, metadata and config
+	// to fpath on the miner machine
+	CreateBackup(ctx context.Context, fpath string) error
+}
+
+type MessageStatus string
+
+const (
+	MessagePending  MessageStatus = "pending"
+	MessageLanded   MessageStatus = "landed"
+	MessageFailed   MessageStatus = "failed"
+	MessageReplaced MessageStatus = "replaced"
+)
+
+// MinerMessage is a message pushed by the miner
+type MinerMessage struct {
+	Cid     cid.Cid
+	Kind    string
+	Message *types.SignedMessage
+	Pushed  time.Time
+
+	Status MessageStatus
+	// Height and ExitCode are set once the message landed on chain
+	Height   uint64
+	ExitCode uint8
+	// ReplacedBy is the message with a higher gas price replacing this one
+	ReplacedBy *cid.Cid
 }
 
 type SectorLog struct {
@@ -129,8 +200,13 @@ type SectorInfo struct {
 	Seed     sectorbuilder.SealSeed
 	Retries  uint64
 
+	PreCommitMsg   *cid.Cid
+	CommitMsg      *cid.Cid
+	FaultReportMsg *cid.Cid
+
 	LastErr string
 
+	// Log lists the events and state transitions of the sector, oldest first
 	Log []SectorLog
 }
 
@@ -143,3 +219,66 @@ type SealedRef struct {
 type SealedRefs struct {
 	Refs []SealedRef
 }
+
+// MiningStage is the duration of a single step of block production
+type MiningStage struct {
+	Name     string
+	Duration time.Duration
+}
+
+// MiningAttempt describes an attempt to produce a block at some height
+type MiningAttempt struct {
+	Miner  address.Address
+	Base   types.TipSetKey
+	Height uint64
+	Start  time.Time
+
+	Stages []MiningStage
+	Total  time.Duration
+
+	Won bool
+	// Late is how long after the block timestamp the block was ready to be
+	// published
+	Late  time.Duration
+	Error string
+}
+
+type MiningStatus struct {
+	Addresses []address.Address
+	// Attempts lists recent attempts, oldest first
+	Attempts []MiningAttempt
+}
+
+// MinerStats is a summary of the state of a miner actor
+type MinerStats struct {
+	Miner  address.Address
+	Height uint64
+
+	Power        types.BigInt
+	NetworkPower types.BigInt
+	// PowerShare is the fraction of the network power held by the miner
+	PowerShare float64
+
+	SectorSize       uint64
+	CommittedSectors uint64
+	ProvingSectors   uint64
+	FaultySectors    uint64
+	// SectorStates counts the sectors tracked by the sealing pipeline in
+	// each state
+	SectorStates map[string]int
+
+	// ActiveDeals are on-chain deals in proven sectors, PendingDeals are
+	// published deals which aren't active yet
+	ActiveDeals  int
+	PendingDeals int
+
+	Worker        address.Address
+	WorkerBalance types.BigInt
+
+	// ElectionPeriodStart is 0 when the miner isn't proving
+	ElectionPeriodStart uint64
+	// ProvingDeadline is the epoch after which the miner is slashed if it
+	// hasn't submitted a PoSt, DeadlineIn is the estimated time until then
+	ProvingDeadline uint64
+	DeadlineIn      time.Duration
+}
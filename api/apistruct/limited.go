@@ -0,0 +1,169 @@
+package apistruct
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+type tokenKey int
+
+var tokenCtxKey tokenKey
+
+// WithToken records the token used to authenticate a request. Limits are
+// accounted separately for each token, requests without a token share limits.
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenCtxKey, token)
+}
+
+// Limit restricts calls to a group of methods
+type Limit struct {
+	// Methods is a method name prefix selecting the group, e.g. "Chain"; an
+	// empty prefix matches all methods
+	Methods string
+	// Rate is the number of calls allowed per second, 0 means unlimited
+	Rate  float64
+	Burst int
+	// MaxConcurrent is the max number of calls in progress, 0 means unlimited
+	MaxConcurrent int
+}
+
+type limitKey struct {
+	token string
+	limit int
+}
+
+type limitState struct {
+	rate     *rate.Limiter
+	inflight int
+}
+
+type Limiter struct {
+	limits []Limit
+
+	lk     sync.Mutex
+	states map[limitKey]*limitState
+}
+
+func NewLimiter(limits []Limit) *Limiter {
+	return &Limiter{
+		limits: limits,
+		states: map[limitKey]*limitState{},
+	}
+}
+
+// acquire checks all limits matching the method. On success the returned
+// function must be called when the call completes.
+func (l *Limiter) acquire(ctx context.Context, method string) (func(), error) {
+	token, _ := ctx.Value(tokenCtxKey).(string)
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	var taken []*limitState
+	release := func() {
+		l.lk.Lock()
+		defer l.lk.Unlock()
+
+		l.undo(taken)
+	}
+
+	for i, lim := range l.limits {
+		if !strings.HasPrefix(method, lim.Methods) {
+			continue
+		}
+
+		k := limitKey{token: token, limit: i}
+		st, ok := l.states[k]
+		if !ok {
+			st = &limitState{rate: rate.NewLimiter(rate.Inf, 0)}
+			if lim.Rate > 0 {
+				burst := lim.Burst
+				if burst < 1 {
+					burst = 1
+				}
+				st.rate = rate.NewLimiter(rate.Limit(lim.Rate), burst)
+			}
+			l.states[k] = st
+		}
+
+		if lim.MaxConcurrent > 0 && st.inflight >= lim.MaxConcurrent {
+			l.undo(taken)
+			return nil, xerrors.Errorf("too many concurrent calls to '%s' (limit for '%s*' is %d)", method, lim.Methods, lim.MaxConcurrent)
+		}
+		if !st.rate.Allow() {
+			l.undo(taken)
+			return nil, xerrors.Errorf("rate limit exceeded for '%s' (limit for '%s*' is %g/s)", method, lim.Methods, lim.Rate)
+		}
+
+		st.inflight++
+		taken = append(taken, st)
+	}
+
+	return release, nil
+}
+
+func (l *Limiter) undo(taken []*limitState) {
+	for _, st := range taken {
+		st.inflight--
+	}
+}
+
+func LimitedStorMinerAPI(a api.StorageMiner, l *Limiter) api.StorageMiner {
+	if l == nil {
+		return a
+	}
+
+	var out StorageMinerStruct
+	limitedAny(a, &out.Internal, l)
+	limitedAny(a, &out.CommonStruct.Internal, l)
+	return &out
+}
+
+func LimitedFullAPI(a api.FullNode, l *Limiter) api.FullNode {
+	if l == nil {
+		return a
+	}
+
+	var out FullNodeStruct
+	limitedAny(a, &out.Internal, l)
+	limitedAny(a, &out.CommonStruct.Internal, l)
+	return &out
+}
+
+func limitedAny(in interface{}, out interface{}, l *Limiter) {
+	rint := reflect.ValueOf(out).Elem()
+	ra := reflect.ValueOf(in)
+
+	for f := 0; f < rint.NumField(); f++ {
+		field := rint.Type().Field(f)
+		fn := ra.MethodByName(field.Name)
+
+		rint.Field(f).Set(reflect.MakeFunc(field.Type, func(args []reflect.Value) (results []reflect.Value) {
+			ctx := args[0].Interface().(context.Context)
+
+			release, err := l.acquire(ctx, field.Name)
+			if err == nil {
+				defer release()
+				return fn.Call(args)
+			}
+
+			rerr := reflect.ValueOf(&err).Elem()
+
+			if field.Type.NumOut() == 2 {
+				return []reflect.Value{
+					reflect.Zero(field.Type.Out(0)),
+					rerr,
+				}
+			} else {
+				return []reflect.Value{rerr}
+			}
+		}))
+	}
+}
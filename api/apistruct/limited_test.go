@@ -0,0 +1,99 @@
+package apistruct
+
+import (
+	"context"
+	"testing"
+)
+
+type testAPI struct {
+	block chan struct{}
+}
+
+func (t *testAPI) ChainHead(ctx context.Context) (int, error) {
+	if t.block != nil {
+		<-t.block
+	}
+	return 1, nil
+}
+
+func (t *testAPI) NetPeers(ctx context.Context) error {
+	return nil
+}
+
+type testStruct struct {
+	ChainHead func(ctx context.Context) (int, error)
+	NetPeers  func(ctx context.Context) error
+}
+
+func TestLimiterRate(t *testing.T) {
+	l := NewLimiter([]Limit{{Methods: "Chain", Rate: 0.001, Burst: 2}})
+
+	var out testStruct
+	limitedAny(&testAPI{}, &out, l)
+
+	ctx := WithToken(context.Background(), "a")
+	for i := 0; i < 2; i++ {
+		if _, err := out.ChainHead(ctx); err != nil {
+			t.Fatalf("call %d: %s", i, err)
+		}
+	}
+	if _, err := out.ChainHead(ctx); err == nil {
+		t.Fatal("expected the call above the burst to be rate limited")
+	}
+
+	// other methods and other tokens are limited separately
+	if err := out.NetPeers(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := out.ChainHead(WithToken(context.Background(), "b")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLimiterConcurrency(t *testing.T) {
+	l := NewLimiter([]Limit{{MaxConcurrent: 1}})
+
+	api := &testAPI{block: make(chan struct{})}
+	var out testStruct
+	limitedAny(api, &out, l)
+
+	ctx := context.Background()
+	done := make(chan error)
+	go func() {
+		_, err := out.ChainHead(ctx)
+		done <- err
+	}()
+
+	// wait for the first call to take the slot
+	for {
+		l.lk.Lock()
+		inflight := 0
+		for _, st := range l.states {
+			inflight += st.inflight
+		}
+		l.lk.Unlock()
+		if inflight == 1 {
+			break
+		}
+	}
+
+	if err := out.NetPeers(ctx); err == nil {
+		t.Fatal("expected call over the concurrency limit to fail")
+	}
+
+	close(api.block)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := out.NetPeers(ctx); err != nil {
+		t.Fatalf("expected the slot to be released: %s", err)
+	}
+}
+
+func TestLimitedNilLimiter(t *testing.T) {
+	var a FullNodeStruct
+	if LimitedFullAPI(&a, nil) != &a {
+		t.Fatal("expected API not to be wrapped without a limiter")
+	}
+}
@@ -3,6 +3,7 @@ package apistruct
 import (
 	"context"
 	"reflect"
+	"strings"
 
 	"golang.org/x/xerrors"
 
@@ -25,21 +26,76 @@ const (
 var AllPermissions = []api.Permission{PermRead, PermWrite, PermSign, PermAdmin}
 var defaultPerms = []api.Permission{PermRead}
 
+const (
+	ScopeChainRead  api.Permission = "chain-read"  // Read chain, state and mpool
+	ScopeMpoolPush  api.Permission = "mpool-push"  // Push signed messages only
+	ScopeWalletSign api.Permission = "wallet-sign" // Sign with wallet keys, no key management
+	ScopeMinerAdmin api.Permission = "miner-admin" // Full storage miner API, no token management
+)
+
+// Scope grants access to a fixed set of methods, independently of the
+// coarse permission levels
+type Scope struct {
+	// MaxPerm is the most privileged 'perm' tag the scope can invoke
+	MaxPerm api.Permission
+	// Methods lists granted methods as 'Api.Method', a trailing '*' matches
+	// any method with the given prefix
+	Methods []string
+}
+
+var Scopes = map[api.Permission]Scope{
+	ScopeChainRead: {
+		MaxPerm: PermRead,
+		Methods: []string{"Common.Version", "Common.ID", "FullNode.Chain*", "FullNode.State*", "FullNode.Sync*", "FullNode.Mpool*"},
+	},
+	ScopeMpoolPush: {
+		MaxPerm: PermWrite,
+		Methods: []string{"Common.Version", "FullNode.MpoolPush", "FullNode.MpoolGetNonce"},
+	},
+	ScopeWalletSign: {
+		MaxPerm: PermSign,
+		Methods: []string{"Common.Version", "FullNode.WalletList", "FullNode.WalletHas", "FullNode.WalletDefaultAddress", "FullNode.WalletSign", "FullNode.WalletSignMessage"},
+	},
+	ScopeMinerAdmin: {
+		MaxPerm: PermAdmin,
+		Methods: []string{"Common.Version", "Common.ID", "Common.Net*", "Common.Log*", "StorageMiner.*"},
+	},
+}
+
+var AllScopes = []api.Permission{ScopeChainRead, ScopeMpoolPush, ScopeWalletSign, ScopeMinerAdmin}
+
+// ValidPermission returns true if p is a permission level or a scope
+func ValidPermission(p api.Permission) bool {
+	if _, ok := Scopes[p]; ok {
+		return true
+	}
+	return permLevel(p) >= 0
+}
+
+func permLevel(p api.Permission) int {
+	for i, perm := range AllPermissions {
+		if perm == p {
+			return i
+		}
+	}
+	return -1
+}
+
 func WithPerm(ctx context.Context, perms []api.Permission) context.Context {
 	return context.WithValue(ctx, permCtxKey, perms)
 }
 
 func PermissionedStorMinerAPI(a api.StorageMiner) api.StorageMiner {
 	var out StorageMinerStruct
-	permissionedAny(a, &out.Internal)
-	permissionedAny(a, &out.CommonStruct.Internal)
+	permissionedAny(a, &out.Internal, "StorageMiner")
+	permissionedAny(a, &out.CommonStruct.Internal, "Common")
 	return &out
 }
 
 func PermissionedFullAPI(a api.FullNode) api.FullNode {
 	var out FullNodeStruct
-	permissionedAny(a, &out.Internal)
-	permissionedAny(a, &out.CommonStruct.Internal)
+	permissionedAny(a, &out.Internal, "FullNode")
+	permissionedAny(a, &out.CommonStruct.Internal, "Common")
 	return &out
 }
 
@@ -57,7 +113,30 @@ func HasPerm(ctx context.Context, perm api.Permission) bool {
 	return false
 }
 
-func permissionedAny(in interface{}, out interface{}) {
+// hasScope checks whether any of the scopes in the context grant access to
+// the named method
+func hasScope(ctx context.Context, method string, perm api.Permission) bool {
+	callerPerms, ok := ctx.Value(permCtxKey).([]api.Permission)
+	if !ok {
+		return false
+	}
+
+	for _, callerPerm := range callerPerms {
+		scope, ok := Scopes[callerPerm]
+		if !ok || permLevel(perm) > permLevel(scope.MaxPerm) {
+			continue
+		}
+
+		for _, m := range scope.Methods {
+			if m == method || (strings.HasSuffix(m, "*") && strings.HasPrefix(method, strings.TrimSuffix(m, "*"))) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func permissionedAny(in interface{}, out interface{}, apiName string) {
 	rint := reflect.ValueOf(out).Elem()
 	ra := reflect.ValueOf(in)
 
@@ -81,10 +160,11 @@ func permissionedAny(in interface{}, out interface{}) {
 		}
 
 		fn := ra.MethodByName(field.Name)
+		method := apiName + "." + field.Name
 
 		rint.Field(f).Set(reflect.MakeFunc(field.Type, func(args []reflect.Value) (results []reflect.Value) {
 			ctx := args[0].Interface().(context.Context)
-			if HasPerm(ctx, requiredPerm) {
+			if HasPerm(ctx, requiredPerm) || hasScope(ctx, method, requiredPerm) {
 				return fn.Call(args)
 			}
 
@@ -0,0 +1,80 @@
+package apistruct
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+type permTestAPI struct{}
+
+func (permTestAPI) ChainHead(ctx context.Context) (int, error) {
+	return 1, nil
+}
+
+func (permTestAPI) MpoolPush(ctx context.Context) (int, error) {
+	return 1, nil
+}
+
+func (permTestAPI) WalletSign(ctx context.Context) (int, error) {
+	return 1, nil
+}
+
+type permTestStruct struct {
+	ChainHead  func(ctx context.Context) (int, error) `perm:"read"`
+	MpoolPush  func(ctx context.Context) (int, error) `perm:"write"`
+	WalletSign func(ctx context.Context) (int, error) `perm:"sign"`
+}
+
+func TestScopes(t *testing.T) {
+	var out permTestStruct
+	permissionedAny(permTestAPI{}, &out, "FullNode")
+
+	ctx := WithPerm(context.Background(), []api.Permission{ScopeChainRead})
+
+	if _, err := out.ChainHead(ctx); err != nil {
+		t.Fatalf("expected the scope to grant ChainHead: %s", err)
+	}
+	// matches FullNode.Mpool*, but needs more than the read MaxPerm
+	if _, err := out.MpoolPush(ctx); err == nil {
+		t.Fatal("expected MpoolPush to be above the MaxPerm of the scope")
+	}
+	if _, err := out.WalletSign(ctx); err == nil {
+		t.Fatal("expected WalletSign to be outside of the scope")
+	}
+
+	ctx = WithPerm(context.Background(), []api.Permission{ScopeMpoolPush})
+
+	if _, err := out.MpoolPush(ctx); err != nil {
+		t.Fatalf("expected the scope to grant MpoolPush: %s", err)
+	}
+	// read methods aren't granted just because the scope allows writes
+	if _, err := out.ChainHead(ctx); err == nil {
+		t.Fatal("expected ChainHead to be outside of the scope")
+	}
+
+	// scopes add to permission levels
+	ctx = WithPerm(context.Background(), []api.Permission{PermRead, ScopeMpoolPush})
+
+	if _, err := out.ChainHead(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := out.MpoolPush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := out.WalletSign(ctx); err == nil {
+		t.Fatal("expected WalletSign to need the sign permission")
+	}
+}
+
+func TestScopeMaxPerm(t *testing.T) {
+	for name, scope := range Scopes {
+		if permLevel(scope.MaxPerm) < 0 {
+			t.Errorf("scope %s has an unknown MaxPerm '%s'", name, scope.MaxPerm)
+		}
+		if !ValidPermission(name) {
+			t.Errorf("scope %s isn't a valid permission", name)
+		}
+	}
+}
@@ -2,12 +2,15 @@ package apistruct
 
 import (
 	"context"
+	"time"
 
 	sectorbuilder "github.com/filecoin-project/go-sectorbuilder"
 
 	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/metrics"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/lotus/api"
@@ -23,18 +26,30 @@ type CommonStruct struct {
 	Internal struct {
 		AuthVerify func(ctx context.Context, token string) ([]api.Permission, error) `perm:"read"`
 		AuthNew    func(ctx context.Context, perms []api.Permission) ([]byte, error) `perm:"admin"`
-
-		NetConnectedness func(context.Context, peer.ID) (network.Connectedness, error) `perm:"read"`
-		NetPeers         func(context.Context) ([]peer.AddrInfo, error)                `perm:"read"`
-		NetConnect       func(context.Context, peer.AddrInfo) error                    `perm:"write"`
-		NetAddrsListen   func(context.Context) (peer.AddrInfo, error)                  `perm:"read"`
-		NetDisconnect    func(context.Context, peer.ID) error                          `perm:"write"`
+		AuthList   func(ctx context.Context) ([]api.AuthToken, error)                `perm:"admin"`
+		AuthRevoke func(ctx context.Context, id string) error                        `perm:"admin"`
+
+		NetConnectedness            func(context.Context, peer.ID) (network.Connectedness, error) `perm:"read"`
+		NetPeers                    func(context.Context) ([]peer.AddrInfo, error)                `perm:"read"`
+		NetConnect                  func(context.Context, peer.AddrInfo) error                    `perm:"write"`
+		NetAddrsListen              func(context.Context) (peer.AddrInfo, error)                  `perm:"read"`
+		NetDisconnect               func(context.Context, peer.ID) error                          `perm:"write"`
+		NetPeerAdd                  func(context.Context, peer.AddrInfo) error                    `perm:"admin"`
+		NetPeerRemove               func(context.Context, peer.ID) error                          `perm:"admin"`
+		NetPeerProtect              func(context.Context, peer.ID, bool) error                    `perm:"admin"`
+		NetPeerList                 func(context.Context) ([]api.PinnedPeer, error)               `perm:"read"`
+		NetReachability             func(context.Context) (api.NetReachability, error)            `perm:"read"`
+		NetBandwidthStats           func(context.Context) (metrics.Stats, error)                  `perm:"read"`
+		NetBandwidthStatsByPeer     func(context.Context) (map[string]metrics.Stats, error)       `perm:"read"`
+		NetBandwidthStatsByProtocol func(context.Context) (map[protocol.ID]metrics.Stats, error)  `perm:"read"`
 
 		ID      func(context.Context) (peer.ID, error)     `perm:"read"`
 		Version func(context.Context) (api.Version, error) `perm:"read"`
 
 		LogList     func(context.Context) ([]string, error)     `perm:"write"`
 		LogSetLevel func(context.Context, string, string) error `perm:"write"`
+
+		DatastoreGC func(context.Context) error `perm:"admin"`
 	}
 }
 
@@ -44,6 +59,8 @@ type FullNodeStruct struct {
 
 	Internal struct {
 		ChainNotify            func(context.Context) (<-chan []*store.HeadChange, error)                            `perm:"read"`
+		ChainNotifyReorgs      func(context.Context) (<-chan *store.ReorgChange, error)                             `perm:"read"`
+		ChainNotifyConfidence  func(context.Context, uint64) (<-chan []*store.HeadChange, error)                    `perm:"read"`
 		ChainHead              func(context.Context) (*types.TipSet, error)                                         `perm:"read"`
 		ChainGetRandomness     func(context.Context, types.TipSetKey, int64) ([]byte, error)                        `perm:"read"`
 		ChainGetBlock          func(context.Context, cid.Cid) (*types.BlockHeader, error)                           `perm:"read"`
@@ -60,42 +77,54 @@ type FullNodeStruct struct {
 		ChainGetNode           func(ctx context.Context, p string) (interface{}, error)                             `perm:"read"`
 		ChainGetMessage        func(context.Context, cid.Cid) (*types.Message, error)                               `perm:"read"`
 		ChainGetPath           func(context.Context, types.TipSetKey, types.TipSetKey) ([]*store.HeadChange, error) `perm:"read"`
-		ChainExport            func(context.Context, *types.TipSet) (<-chan []byte, error)                          `perm:"read"`
-
-		SyncState          func(context.Context) (*api.SyncState, error)                `perm:"read"`
-		SyncSubmitBlock    func(ctx context.Context, blk *types.BlockMsg) error         `perm:"write"`
-		SyncIncomingBlocks func(ctx context.Context) (<-chan *types.BlockHeader, error) `perm:"read"`
-		SyncMarkBad        func(ctx context.Context, bcid cid.Cid) error                `perm:"admin"`
-		SyncCheckBad       func(ctx context.Context, bcid cid.Cid) (string, error)      `perm:"read"`
-
-		MpoolPending     func(context.Context, *types.TipSet) ([]*types.SignedMessage, error) `perm:"read"`
-		MpoolPush        func(context.Context, *types.SignedMessage) (cid.Cid, error)         `perm:"write"`
-		MpoolPushMessage func(context.Context, *types.Message) (*types.SignedMessage, error)  `perm:"sign"`
-		MpoolGetNonce    func(context.Context, address.Address) (uint64, error)               `perm:"read"`
-		MpoolSub         func(context.Context) (<-chan api.MpoolUpdate, error)                `perm:"read"`
+		ChainExport            func(context.Context, uint64, *types.TipSet) (<-chan []byte, error)                  `perm:"read"`
+		ChainPrune             func(context.Context, uint64) (*api.PruneResult, error)                              `perm:"admin"`
+		ChainGasTrace          func(context.Context, cid.Cid) (*api.GasProfile, error)                              `perm:"read"`
+
+		SyncState           func(context.Context) (*api.SyncState, error)                `perm:"read"`
+		SyncSubmitBlock     func(ctx context.Context, blk *types.BlockMsg) error         `perm:"write"`
+		SyncIncomingBlocks  func(ctx context.Context) (<-chan *types.BlockHeader, error) `perm:"read"`
+		SyncMarkBad         func(ctx context.Context, bcid cid.Cid) error                `perm:"admin"`
+		SyncCheckBad        func(ctx context.Context, bcid cid.Cid) (string, error)      `perm:"read"`
+		SyncCheckpoint      func(ctx context.Context, tsk types.TipSetKey) error         `perm:"admin"`
+		SyncGetCheckpoint   func(ctx context.Context) (*types.TipSet, error)             `perm:"read"`
+		SyncClearCheckpoint func(ctx context.Context) error                              `perm:"admin"`
+
+		MpoolPending       func(context.Context, *types.TipSet) ([]*types.SignedMessage, error)                      `perm:"read"`
+		MpoolPendingFilter func(context.Context, *api.MpoolFilter, *types.TipSet) ([]*types.SignedMessage, error)    `perm:"read"`
+		MpoolStats         func(context.Context, *types.TipSet) (*api.MpoolStats, error)                             `perm:"read"`
+		MpoolPush          func(context.Context, *types.SignedMessage) (cid.Cid, error)                              `perm:"write"`
+		MpoolPushMessage   func(context.Context, *types.Message, *api.MessageSendSpec) (*types.SignedMessage, error) `perm:"sign"`
+		MpoolGetNonce      func(context.Context, address.Address) (uint64, error)                                    `perm:"read"`
+		MpoolSub           func(context.Context) (<-chan api.MpoolUpdate, error)                                     `perm:"read"`
+		MpoolSubFilter     func(context.Context, *api.MpoolFilter) (<-chan api.MpoolUpdate, error)                   `perm:"read"`
 
 		MinerCreateBlock func(context.Context, address.Address, *types.TipSet, *types.Ticket, *types.EPostProof, []*types.SignedMessage, uint64, uint64) (*types.BlockMsg, error) `perm:"write"`
 
-		WalletNew            func(context.Context, string) (address.Address, error)                               `perm:"write"`
-		WalletHas            func(context.Context, address.Address) (bool, error)                                 `perm:"write"`
-		WalletList           func(context.Context) ([]address.Address, error)                                     `perm:"write"`
-		WalletBalance        func(context.Context, address.Address) (types.BigInt, error)                         `perm:"read"`
-		WalletSign           func(context.Context, address.Address, []byte) (*types.Signature, error)             `perm:"sign"`
-		WalletSignMessage    func(context.Context, address.Address, *types.Message) (*types.SignedMessage, error) `perm:"sign"`
-		WalletDefaultAddress func(context.Context) (address.Address, error)                                       `perm:"write"`
-		WalletSetDefault     func(context.Context, address.Address) error                                         `perm:"admin"`
-		WalletExport         func(context.Context, address.Address) (*types.KeyInfo, error)                       `perm:"admin"`
-		WalletImport         func(context.Context, *types.KeyInfo) (address.Address, error)                       `perm:"admin"`
-
-		ClientImport      func(ctx context.Context, path string) (cid.Cid, error)                                                                                           `perm:"admin"`
-		ClientListImports func(ctx context.Context) ([]api.Import, error)                                                                                                   `perm:"write"`
-		ClientHasLocal    func(ctx context.Context, root cid.Cid) (bool, error)                                                                                             `perm:"write"`
-		ClientFindData    func(ctx context.Context, root cid.Cid) ([]api.QueryOffer, error)                                                                                 `perm:"read"`
-		ClientStartDeal   func(ctx context.Context, data cid.Cid, addr address.Address, miner address.Address, price types.BigInt, blocksDuration uint64) (*cid.Cid, error) `perm:"admin"`
-		ClientGetDealInfo func(context.Context, cid.Cid) (*api.DealInfo, error)                                                                                             `perm:"read"`
-		ClientListDeals   func(ctx context.Context) ([]api.DealInfo, error)                                                                                                 `perm:"write"`
-		ClientRetrieve    func(ctx context.Context, order api.RetrievalOrder, path string) error                                                                            `perm:"admin"`
-		ClientQueryAsk    func(ctx context.Context, p peer.ID, miner address.Address) (*types.SignedStorageAsk, error)                                                      `perm:"read"`
+		WalletNew               func(context.Context, string) (address.Address, error)                               `perm:"write"`
+		WalletHas               func(context.Context, address.Address) (bool, error)                                 `perm:"write"`
+		WalletList              func(context.Context) ([]address.Address, error)                                     `perm:"write"`
+		WalletBalance           func(context.Context, address.Address) (types.BigInt, error)                         `perm:"read"`
+		WalletSign              func(context.Context, address.Address, []byte) (*types.Signature, error)             `perm:"sign"`
+		WalletSignMessage       func(context.Context, address.Address, *types.Message) (*types.SignedMessage, error) `perm:"sign"`
+		WalletDefaultAddress    func(context.Context) (address.Address, error)                                       `perm:"write"`
+		WalletSetDefault        func(context.Context, address.Address) error                                         `perm:"admin"`
+		WalletDefaultAddressFor func(context.Context, string) (address.Address, error)                               `perm:"write"`
+		WalletDefaultAddresses  func(context.Context) (map[string]address.Address, error)                            `perm:"write"`
+		WalletSetDefaultFor     func(context.Context, string, address.Address) error                                 `perm:"admin"`
+		WalletExport            func(context.Context, address.Address) (*types.KeyInfo, error)                       `perm:"admin"`
+		WalletImport            func(context.Context, *types.KeyInfo) (address.Address, error)                       `perm:"admin"`
+
+		ClientImport         func(ctx context.Context, path string) (cid.Cid, error)                                                                                           `perm:"admin"`
+		ClientListImports    func(ctx context.Context) ([]api.Import, error)                                                                                                   `perm:"write"`
+		ClientHasLocal       func(ctx context.Context, root cid.Cid) (bool, error)                                                                                             `perm:"write"`
+		ClientFindData       func(ctx context.Context, root cid.Cid) ([]api.QueryOffer, error)                                                                                 `perm:"read"`
+		ClientStartDeal      func(ctx context.Context, data cid.Cid, addr address.Address, miner address.Address, price types.BigInt, blocksDuration uint64) (*cid.Cid, error) `perm:"admin"`
+		ClientGetDealInfo    func(context.Context, cid.Cid) (*api.DealInfo, error)                                                                                             `perm:"read"`
+		ClientListDeals      func(ctx context.Context) ([]api.DealInfo, error)                                                                                                 `perm:"write"`
+		ClientRetrieve       func(ctx context.Context, order api.RetrievalOrder, path string) error                                                                            `perm:"admin"`
+		ClientListRetrievals func(ctx context.Context) ([]api.RetrievalInfo, error)                                                                                            `perm:"read"`
+		ClientQueryAsk       func(ctx context.Context, p peer.ID, miner address.Address) (*types.SignedStorageAsk, error)                                                      `perm:"read"`
 
 		StateMinerSectors             func(context.Context, address.Address, *types.TipSet) ([]*api.ChainSectorInfo, error)             `perm:"read"`
 		StateMinerProvingSet          func(context.Context, address.Address, *types.TipSet) ([]*api.ChainSectorInfo, error)             `perm:"read"`
@@ -107,10 +136,15 @@ type FullNodeStruct struct {
 		StateMinerFaults              func(context.Context, address.Address, *types.TipSet) ([]uint64, error)                           `perm:"read"`
 		StateCall                     func(context.Context, *types.Message, *types.TipSet) (*api.MethodCall, error)                     `perm:"read"`
 		StateReplay                   func(context.Context, *types.TipSet, cid.Cid) (*api.ReplayResults, error)                         `perm:"read"`
+		StateReplayTipSet             func(context.Context, *types.TipSet) ([]*api.ReplayResults, error)                                `perm:"read"`
 		StateGetActor                 func(context.Context, address.Address, *types.TipSet) (*types.Actor, error)                       `perm:"read"`
 		StateReadState                func(context.Context, *types.Actor, *types.TipSet) (*api.ActorState, error)                       `perm:"read"`
+		StateReadActor                func(context.Context, address.Address, *types.TipSet) (*api.ActorState, error)                    `perm:"read"`
+		StateDecodeState              func(context.Context, cid.Cid, cid.Cid) (interface{}, error)                                      `perm:"read"`
 		StatePledgeCollateral         func(context.Context, *types.TipSet) (types.BigInt, error)                                        `perm:"read"`
 		StateWaitMsg                  func(context.Context, cid.Cid) (*api.MsgWait, error)                                              `perm:"read"`
+		StateSearchMsg                func(context.Context, cid.Cid, uint64, time.Duration) (*api.MsgWait, error)                       `perm:"read"`
+		StateSubMessages              func(context.Context, *api.MessageFilter) (<-chan []api.ExecutedMessage, error)                   `perm:"read"`
 		StateListMiners               func(context.Context, *types.TipSet) ([]address.Address, error)                                   `perm:"read"`
 		StateListActors               func(context.Context, *types.TipSet) ([]address.Address, error)                                   `perm:"read"`
 		StateMarketBalance            func(context.Context, address.Address, *types.TipSet) (actors.StorageParticipantBalance, error)   `perm:"read"`
@@ -119,10 +153,12 @@ type FullNodeStruct struct {
 		StateMarketStorageDeal        func(context.Context, uint64, *types.TipSet) (*actors.OnChainDeal, error)                         `perm:"read"`
 		StateLookupID                 func(ctx context.Context, addr address.Address, ts *types.TipSet) (address.Address, error)        `perm:"read"`
 		StateChangedActors            func(context.Context, cid.Cid, cid.Cid) (map[string]types.Actor, error)                           `perm:"read"`
+		StateDiff                     func(context.Context, types.TipSetKey, types.TipSetKey, bool) ([]*api.ActorDiff, error)           `perm:"read"`
 		StateGetReceipt               func(context.Context, cid.Cid, *types.TipSet) (*types.MessageReceipt, error)                      `perm:"read"`
 		StateMinerSectorCount         func(context.Context, address.Address, *types.TipSet) (api.MinerSectors, error)                   `perm:"read"`
 		StateListMessages             func(ctx context.Context, match *types.Message, ts *types.TipSet, toht uint64) ([]cid.Cid, error) `perm:"read"`
 		StateCompute                  func(context.Context, uint64, []*types.Message, *types.TipSet) (cid.Cid, error)                   `perm:"read"`
+		StateCirculatingSupply        func(context.Context, *types.TipSet) (api.CirculatingSupply, error)                               `perm:"read"`
 
 		MsigGetAvailableBalance func(context.Context, address.Address, *types.TipSet) (types.BigInt, error) `perm:"read"`
 
@@ -153,19 +189,34 @@ type StorageMinerStruct struct {
 
 	Internal struct {
 		ActorAddress    func(context.Context) (address.Address, error)         `perm:"read"`
+		ActorList       func(context.Context) ([]address.Address, error)       `perm:"read"`
 		ActorSectorSize func(context.Context, address.Address) (uint64, error) `perm:"read"`
 
+		MiningStatus func(context.Context) (*api.MiningStatus, error) `perm:"read"`
+		MinerStats   func(context.Context) (*api.MinerStats, error)   `perm:"read"`
+
 		PledgeSector func(context.Context) error `perm:"write"`
 
-		SectorsStatus func(context.Context, uint64) (api.SectorInfo, error)     `perm:"read"`
-		SectorsList   func(context.Context) ([]uint64, error)                   `perm:"read"`
-		SectorsRefs   func(context.Context) (map[string][]api.SealedRef, error) `perm:"read"`
-		SectorsUpdate func(context.Context, uint64, api.SectorState) error      `perm:"write"`
+		SectorsStatus      func(context.Context, uint64) (api.SectorInfo, error)     `perm:"read"`
+		SectorsList        func(context.Context) ([]uint64, error)                   `perm:"read"`
+		SectorsRefs        func(context.Context) (map[string][]api.SealedRef, error) `perm:"read"`
+		SectorsUpdate      func(context.Context, uint64, api.SectorState) error      `perm:"write"`
+		SectorStartSealing func(context.Context, uint64) error                       `perm:"write"`
+		SectorsRetry       func(context.Context, uint64) error                       `perm:"write"`
+		SectorsAbort       func(context.Context, uint64) error                       `perm:"admin"`
 
 		WorkerStats func(context.Context) (sectorbuilder.WorkerStats, error) `perm:"read"`
 
 		WorkerQueue func(ctx context.Context, cfg sectorbuilder.WorkerCfg) (<-chan sectorbuilder.WorkerTask, error) `perm:"admin"` // TODO: worker perm
 		WorkerDone  func(ctx context.Context, task uint64, res sectorbuilder.SealRes) error                         `perm:"admin"`
+
+		WorkerProofDevice func(context.Context) (string, error) `perm:"admin"`
+
+		MessagesList        func(context.Context) ([]api.MinerMessage, error)             `perm:"read"`
+		MessagesRebroadcast func(context.Context, cid.Cid) error                          `perm:"write"`
+		MessagesBumpFee     func(context.Context, cid.Cid, types.BigInt) (cid.Cid, error) `perm:"sign"`
+
+		CreateBackup func(ctx context.Context, fpath string) error `perm:"admin"`
 	}
 }
 
@@ -177,6 +228,14 @@ func (c *CommonStruct) AuthNew(ctx context.Context, perms []api.Permission) ([]b
 	return c.Internal.AuthNew(ctx, perms)
 }
 
+func (c *CommonStruct) AuthList(ctx context.Context) ([]api.AuthToken, error) {
+	return c.Internal.AuthList(ctx)
+}
+
+func (c *CommonStruct) AuthRevoke(ctx context.Context, id string) error {
+	return c.Internal.AuthRevoke(ctx, id)
+}
+
 func (c *CommonStruct) NetConnectedness(ctx context.Context, pid peer.ID) (network.Connectedness, error) {
 	return c.Internal.NetConnectedness(ctx, pid)
 }
@@ -197,6 +256,38 @@ func (c *CommonStruct) NetDisconnect(ctx context.Context, p peer.ID) error {
 	return c.Internal.NetDisconnect(ctx, p)
 }
 
+func (c *CommonStruct) NetPeerAdd(ctx context.Context, p peer.AddrInfo) error {
+	return c.Internal.NetPeerAdd(ctx, p)
+}
+
+func (c *CommonStruct) NetPeerRemove(ctx context.Context, p peer.ID) error {
+	return c.Internal.NetPeerRemove(ctx, p)
+}
+
+func (c *CommonStruct) NetPeerProtect(ctx context.Context, p peer.ID, protect bool) error {
+	return c.Internal.NetPeerProtect(ctx, p, protect)
+}
+
+func (c *CommonStruct) NetPeerList(ctx context.Context) ([]api.PinnedPeer, error) {
+	return c.Internal.NetPeerList(ctx)
+}
+
+func (c *CommonStruct) NetReachability(ctx context.Context) (api.NetReachability, error) {
+	return c.Internal.NetReachability(ctx)
+}
+
+func (c *CommonStruct) NetBandwidthStats(ctx context.Context) (metrics.Stats, error) {
+	return c.Internal.NetBandwidthStats(ctx)
+}
+
+func (c *CommonStruct) NetBandwidthStatsByPeer(ctx context.Context) (map[string]metrics.Stats, error) {
+	return c.Internal.NetBandwidthStatsByPeer(ctx)
+}
+
+func (c *CommonStruct) NetBandwidthStatsByProtocol(ctx context.Context) (map[protocol.ID]metrics.Stats, error) {
+	return c.Internal.NetBandwidthStatsByProtocol(ctx)
+}
+
 // ID implements API.ID
 func (c *CommonStruct) ID(ctx context.Context) (peer.ID, error) {
 	return c.Internal.ID(ctx)
@@ -215,6 +306,10 @@ func (c *CommonStruct) LogSetLevel(ctx context.Context, group, level string) err
 	return c.Internal.LogSetLevel(ctx, group, level)
 }
 
+func (c *CommonStruct) DatastoreGC(ctx context.Context) error {
+	return c.Internal.DatastoreGC(ctx)
+}
+
 func (c *FullNodeStruct) ClientListImports(ctx context.Context) ([]api.Import, error) {
 	return c.Internal.ClientListImports(ctx)
 }
@@ -246,6 +341,10 @@ func (c *FullNodeStruct) ClientRetrieve(ctx context.Context, order api.Retrieval
 	return c.Internal.ClientRetrieve(ctx, order, path)
 }
 
+func (c *FullNodeStruct) ClientListRetrievals(ctx context.Context) ([]api.RetrievalInfo, error) {
+	return c.Internal.ClientListRetrievals(ctx)
+}
+
 func (c *FullNodeStruct) ClientQueryAsk(ctx context.Context, p peer.ID, miner address.Address) (*types.SignedStorageAsk, error) {
 	return c.Internal.ClientQueryAsk(ctx, p, miner)
 }
@@ -254,18 +353,30 @@ func (c *FullNodeStruct) MpoolPending(ctx context.Context, ts *types.TipSet) ([]
 	return c.Internal.MpoolPending(ctx, ts)
 }
 
+func (c *FullNodeStruct) MpoolPendingFilter(ctx context.Context, f *api.MpoolFilter, ts *types.TipSet) ([]*types.SignedMessage, error) {
+	return c.Internal.MpoolPendingFilter(ctx, f, ts)
+}
+
+func (c *FullNodeStruct) MpoolStats(ctx context.Context, ts *types.TipSet) (*api.MpoolStats, error) {
+	return c.Internal.MpoolStats(ctx, ts)
+}
+
 func (c *FullNodeStruct) MpoolPush(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, error) {
 	return c.Internal.MpoolPush(ctx, smsg)
 }
 
-func (c *FullNodeStruct) MpoolPushMessage(ctx context.Context, msg *types.Message) (*types.SignedMessage, error) {
-	return c.Internal.MpoolPushMessage(ctx, msg)
+func (c *FullNodeStruct) MpoolPushMessage(ctx context.Context, msg *types.Message, spec *api.MessageSendSpec) (*types.SignedMessage, error) {
+	return c.Internal.MpoolPushMessage(ctx, msg, spec)
 }
 
 func (c *FullNodeStruct) MpoolSub(ctx context.Context) (<-chan api.MpoolUpdate, error) {
 	return c.Internal.MpoolSub(ctx)
 }
 
+func (c *FullNodeStruct) MpoolSubFilter(ctx context.Context, f *api.MpoolFilter) (<-chan api.MpoolUpdate, error) {
+	return c.Internal.MpoolSubFilter(ctx, f)
+}
+
 func (c *FullNodeStruct) MinerCreateBlock(ctx context.Context, addr address.Address, base *types.TipSet, ticket *types.Ticket, eproof *types.EPostProof, msgs []*types.SignedMessage, height, ts uint64) (*types.BlockMsg, error) {
 	return c.Internal.MinerCreateBlock(ctx, addr, base, ticket, eproof, msgs, height, ts)
 }
@@ -314,6 +425,18 @@ func (c *FullNodeStruct) WalletSetDefault(ctx context.Context, a address.Address
 	return c.Internal.WalletSetDefault(ctx, a)
 }
 
+func (c *FullNodeStruct) WalletDefaultAddressFor(ctx context.Context, purpose string) (address.Address, error) {
+	return c.Internal.WalletDefaultAddressFor(ctx, purpose)
+}
+
+func (c *FullNodeStruct) WalletDefaultAddresses(ctx context.Context) (map[string]address.Address, error) {
+	return c.Internal.WalletDefaultAddresses(ctx)
+}
+
+func (c *FullNodeStruct) WalletSetDefaultFor(ctx context.Context, purpose string, a address.Address) error {
+	return c.Internal.WalletSetDefaultFor(ctx, purpose, a)
+}
+
 func (c *FullNodeStruct) WalletExport(ctx context.Context, a address.Address) (*types.KeyInfo, error) {
 	return c.Internal.WalletExport(ctx, a)
 }
@@ -350,6 +473,14 @@ func (c *FullNodeStruct) ChainNotify(ctx context.Context) (<-chan []*store.HeadC
 	return c.Internal.ChainNotify(ctx)
 }
 
+func (c *FullNodeStruct) ChainNotifyReorgs(ctx context.Context) (<-chan *store.ReorgChange, error) {
+	return c.Internal.ChainNotifyReorgs(ctx)
+}
+
+func (c *FullNodeStruct) ChainNotifyConfidence(ctx context.Context, confidence uint64) (<-chan []*store.HeadChange, error) {
+	return c.Internal.ChainNotifyConfidence(ctx, confidence)
+}
+
 func (c *FullNodeStruct) ChainReadObj(ctx context.Context, obj cid.Cid) ([]byte, error) {
 	return c.Internal.ChainReadObj(ctx, obj)
 }
@@ -382,8 +513,16 @@ func (c *FullNodeStruct) ChainGetPath(ctx context.Context, from types.TipSetKey,
 	return c.Internal.ChainGetPath(ctx, from, to)
 }
 
-func (c *FullNodeStruct) ChainExport(ctx context.Context, ts *types.TipSet) (<-chan []byte, error) {
-	return c.Internal.ChainExport(ctx, ts)
+func (c *FullNodeStruct) ChainExport(ctx context.Context, nroots uint64, ts *types.TipSet) (<-chan []byte, error) {
+	return c.Internal.ChainExport(ctx, nroots, ts)
+}
+
+func (c *FullNodeStruct) ChainPrune(ctx context.Context, retain uint64) (*api.PruneResult, error) {
+	return c.Internal.ChainPrune(ctx, retain)
+}
+
+func (c *FullNodeStruct) ChainGasTrace(ctx context.Context, msg cid.Cid) (*api.GasProfile, error) {
+	return c.Internal.ChainGasTrace(ctx, msg)
 }
 
 func (c *FullNodeStruct) SyncState(ctx context.Context) (*api.SyncState, error) {
@@ -406,6 +545,18 @@ func (c *FullNodeStruct) SyncCheckBad(ctx context.Context, bcid cid.Cid) (string
 	return c.Internal.SyncCheckBad(ctx, bcid)
 }
 
+func (c *FullNodeStruct) SyncCheckpoint(ctx context.Context, tsk types.TipSetKey) error {
+	return c.Internal.SyncCheckpoint(ctx, tsk)
+}
+
+func (c *FullNodeStruct) SyncGetCheckpoint(ctx context.Context) (*types.TipSet, error) {
+	return c.Internal.SyncGetCheckpoint(ctx)
+}
+
+func (c *FullNodeStruct) SyncClearCheckpoint(ctx context.Context) error {
+	return c.Internal.SyncClearCheckpoint(ctx)
+}
+
 func (c *FullNodeStruct) StateMinerSectors(ctx context.Context, addr address.Address, ts *types.TipSet) ([]*api.ChainSectorInfo, error) {
 	return c.Internal.StateMinerSectors(ctx, addr, ts)
 }
@@ -446,6 +597,10 @@ func (c *FullNodeStruct) StateReplay(ctx context.Context, ts *types.TipSet, mc c
 	return c.Internal.StateReplay(ctx, ts, mc)
 }
 
+func (c *FullNodeStruct) StateReplayTipSet(ctx context.Context, ts *types.TipSet) ([]*api.ReplayResults, error) {
+	return c.Internal.StateReplayTipSet(ctx, ts)
+}
+
 func (c *FullNodeStruct) StateGetActor(ctx context.Context, actor address.Address, ts *types.TipSet) (*types.Actor, error) {
 	return c.Internal.StateGetActor(ctx, actor, ts)
 }
@@ -454,10 +609,26 @@ func (c *FullNodeStruct) StateReadState(ctx context.Context, act *types.Actor, t
 	return c.Internal.StateReadState(ctx, act, ts)
 }
 
+func (c *FullNodeStruct) StateReadActor(ctx context.Context, addr address.Address, ts *types.TipSet) (*api.ActorState, error) {
+	return c.Internal.StateReadActor(ctx, addr, ts)
+}
+
+func (c *FullNodeStruct) StateDecodeState(ctx context.Context, code cid.Cid, head cid.Cid) (interface{}, error) {
+	return c.Internal.StateDecodeState(ctx, code, head)
+}
+
 func (c *FullNodeStruct) StatePledgeCollateral(ctx context.Context, ts *types.TipSet) (types.BigInt, error) {
 	return c.Internal.StatePledgeCollateral(ctx, ts)
 }
 
+func (c *FullNodeStruct) StateSearchMsg(ctx context.Context, msg cid.Cid, lookback uint64, timeout time.Duration) (*api.MsgWait, error) {
+	return c.Internal.StateSearchMsg(ctx, msg, lookback, timeout)
+}
+
+func (c *FullNodeStruct) StateSubMessages(ctx context.Context, f *api.MessageFilter) (<-chan []api.ExecutedMessage, error) {
+	return c.Internal.StateSubMessages(ctx, f)
+}
+
 func (c *FullNodeStruct) StateWaitMsg(ctx context.Context, msgc cid.Cid) (*api.MsgWait, error) {
 	return c.Internal.StateWaitMsg(ctx, msgc)
 }
@@ -489,6 +660,10 @@ func (c *FullNodeStruct) StateLookupID(ctx context.Context, addr address.Address
 	return c.Internal.StateLookupID(ctx, addr, ts)
 }
 
+func (c *FullNodeStruct) StateDiff(ctx context.Context, from, to types.TipSetKey, withState bool) ([]*api.ActorDiff, error) {
+	return c.Internal.StateDiff(ctx, from, to, withState)
+}
+
 func (c *FullNodeStruct) StateChangedActors(ctx context.Context, olnstate cid.Cid, newstate cid.Cid) (map[string]types.Actor, error) {
 	return c.Internal.StateChangedActors(ctx, olnstate, newstate)
 }
@@ -505,6 +680,10 @@ func (c *FullNodeStruct) StateCompute(ctx context.Context, height uint64, msgs [
 	return c.Internal.StateCompute(ctx, height, msgs, ts)
 }
 
+func (c *FullNodeStruct) StateCirculatingSupply(ctx context.Context, ts *types.TipSet) (api.CirculatingSupply, error) {
+	return c.Internal.StateCirculatingSupply(ctx, ts)
+}
+
 func (c *FullNodeStruct) MsigGetAvailableBalance(ctx context.Context, a address.Address, ts *types.TipSet) (types.BigInt, error) {
 	return c.Internal.MsigGetAvailableBalance(ctx, a, ts)
 }
@@ -565,10 +744,22 @@ func (c *StorageMinerStruct) ActorAddress(ctx context.Context) (address.Address,
 	return c.Internal.ActorAddress(ctx)
 }
 
+func (c *StorageMinerStruct) ActorList(ctx context.Context) ([]address.Address, error) {
+	return c.Internal.ActorList(ctx)
+}
+
 func (c *StorageMinerStruct) ActorSectorSize(ctx context.Context, addr address.Address) (uint64, error) {
 	return c.Internal.ActorSectorSize(ctx, addr)
 }
 
+func (c *StorageMinerStruct) MiningStatus(ctx context.Context) (*api.MiningStatus, error) {
+	return c.Internal.MiningStatus(ctx)
+}
+
+func (c *StorageMinerStruct) MinerStats(ctx context.Context) (*api.MinerStats, error) {
+	return c.Internal.MinerStats(ctx)
+}
+
 func (c *StorageMinerStruct) PledgeSector(ctx context.Context) error {
 	return c.Internal.PledgeSector(ctx)
 }
@@ -591,6 +782,18 @@ func (c *StorageMinerStruct) SectorsUpdate(ctx context.Context, id uint64, state
 	return c.Internal.SectorsUpdate(ctx, id, state)
 }
 
+func (c *StorageMinerStruct) SectorStartSealing(ctx context.Context, id uint64) error {
+	return c.Internal.SectorStartSealing(ctx, id)
+}
+
+func (c *StorageMinerStruct) SectorsRetry(ctx context.Context, id uint64) error {
+	return c.Internal.SectorsRetry(ctx, id)
+}
+
+func (c *StorageMinerStruct) SectorsAbort(ctx context.Context, id uint64) error {
+	return c.Internal.SectorsAbort(ctx, id)
+}
+
 func (c *StorageMinerStruct) WorkerStats(ctx context.Context) (sectorbuilder.WorkerStats, error) {
 	return c.Internal.WorkerStats(ctx)
 }
@@ -603,6 +806,26 @@ func (c *StorageMinerStruct) WorkerDone(ctx context.Context, task uint64, res se
 	return c.Internal.WorkerDone(ctx, task, res)
 }
 
+func (c *StorageMinerStruct) WorkerProofDevice(ctx context.Context) (string, error) {
+	return c.Internal.WorkerProofDevice(ctx)
+}
+
+func (c *StorageMinerStruct) MessagesList(ctx context.Context) ([]api.MinerMessage, error) {
+	return c.Internal.MessagesList(ctx)
+}
+
+func (c *StorageMinerStruct) MessagesRebroadcast(ctx context.Context, msg cid.Cid) error {
+	return c.Internal.MessagesRebroadcast(ctx, msg)
+}
+
+func (c *StorageMinerStruct) MessagesBumpFee(ctx context.Context, msg cid.Cid, gasPrice types.BigInt) (cid.Cid, error) {
+	return c.Internal.MessagesBumpFee(ctx, msg, gasPrice)
+}
+
+func (c *StorageMinerStruct) CreateBackup(ctx context.Context, fpath string) error {
+	return c.Internal.CreateBackup(ctx, fpath)
+}
+
 var _ api.Common = &CommonStruct{}
 var _ api.FullNode = &FullNodeStruct{}
 var _ api.StorageMiner = &StorageMinerStruct{}
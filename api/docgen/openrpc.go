@@ -0,0 +1,265 @@
+package docgen
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+)
+
+const OpenRPCVersion = "1.2.6"
+
+// Document is an OpenRPC service description, see https://spec.open-rpc.org
+type Document struct {
+	OpenRPC    string     `json:"openrpc"`
+	Info       Info       `json:"info"`
+	Methods    []Method   `json:"methods"`
+	Components Components `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Method struct {
+	Name   string         `json:"name"`
+	Params []ContentDescr `json:"params"`
+	Result *ContentDescr  `json:"result,omitempty"`
+	// Perm is the permission needed to call the method
+	Perm string `json:"x-perm,omitempty"`
+	// Sub is set for methods returning a channel, the result describes a
+	// single value sent on the channel
+	Sub bool `json:"x-subscription,omitempty"`
+}
+
+type ContentDescr struct {
+	Name     string  `json:"name"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is the subset of JSON schema needed to describe API types
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	contextType         = reflect.TypeOf(new(context.Context)).Elem()
+	errorType           = reflect.TypeOf(new(error)).Elem()
+	cidType             = reflect.TypeOf(cid.Cid{})
+	jsonMarshalerType   = reflect.TypeOf(new(json.Marshaler)).Elem()
+	textMarshalerType   = reflect.TypeOf(new(encoding.TextMarshaler)).Elem()
+	schemaForCid        = &Schema{Type: "object", Title: "Cid", Properties: map[string]*Schema{"/": {Type: "string"}}}
+	schemaForMarshalled = &Schema{}
+)
+
+// NewDocument describes the methods of the given API interface. Permission
+// structs (like apistruct.FullNodeStruct) are searched for 'perm' tags on
+// fields of their 'Internal' struct, including embedded structs.
+func NewDocument(title, version string, iface reflect.Type, permStructs ...interface{}) *Document {
+	perms := map[string]string{}
+	for _, ps := range permStructs {
+		collectPerms(reflect.TypeOf(ps), perms)
+	}
+
+	g := &generator{schemas: map[string]*Schema{}}
+	doc := &Document{
+		OpenRPC: OpenRPCVersion,
+		Info:    Info{Title: title, Version: version},
+	}
+
+	for i := 0; i < iface.NumMethod(); i++ {
+		m := iface.Method(i)
+		ft := m.Type
+
+		method := Method{
+			Name:   "Filecoin." + m.Name,
+			Params: []ContentDescr{},
+			Perm:   perms[m.Name],
+		}
+
+		for p := 0; p < ft.NumIn(); p++ {
+			if ft.In(p) == contextType {
+				continue
+			}
+			method.Params = append(method.Params, ContentDescr{
+				Name:     fmt.Sprintf("p%d", len(method.Params)+1),
+				Required: true,
+				Schema:   g.schemaFor(ft.In(p)),
+			})
+		}
+
+		for o := 0; o < ft.NumOut(); o++ {
+			if ft.Out(o) == errorType {
+				continue
+			}
+			rt := ft.Out(o)
+			if rt.Kind() == reflect.Chan {
+				method.Sub = true
+				rt = rt.Elem()
+			}
+			method.Result = &ContentDescr{
+				Name:   m.Name + "Result",
+				Schema: g.schemaFor(rt),
+			}
+		}
+
+		doc.Methods = append(doc.Methods, method)
+	}
+
+	doc.Components.Schemas = g.schemas
+	return doc
+}
+
+func collectPerms(t reflect.Type, out map[string]string) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		switch {
+		case f.Name == "Internal":
+			for j := 0; j < f.Type.NumField(); j++ {
+				mf := f.Type.Field(j)
+				if perm := mf.Tag.Get("perm"); perm != "" {
+					out[mf.Name] = perm
+				}
+			}
+		case f.Anonymous:
+			collectPerms(f.Type, out)
+		}
+	}
+}
+
+type generator struct {
+	schemas map[string]*Schema
+}
+
+func (g *generator) schemaFor(t reflect.Type) *Schema {
+	if t == cidType {
+		return schemaForCid
+	}
+
+	if t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface {
+		pt := reflect.PtrTo(t)
+		if pt.Implements(jsonMarshalerType) {
+			return &Schema{Title: t.String()}
+		}
+		if pt.Implements(textMarshalerType) {
+			return &Schema{Type: "string", Title: t.String()}
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Ptr:
+		return g.schemaFor(t.Elem())
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		return g.structSchema(t)
+	default:
+		return schemaForMarshalled
+	}
+}
+
+// structSchema stores named structs in the components section, which also
+// takes care of recursive types
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	name := t.String()
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if t.Name() != "" {
+		if _, ok := g.schemas[name]; ok {
+			return ref
+		}
+	}
+
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	if t.Name() != "" {
+		g.schemas[name] = s
+	}
+
+	g.addFields(t, s)
+
+	if t.Name() == "" {
+		return s
+	}
+	return ref
+}
+
+func (g *generator) addFields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		name := f.Name
+		if tag := f.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if n := jsonName(tag); n != "" {
+				name = n
+			}
+		}
+
+		ft := f.Type
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(ft, s)
+				continue
+			}
+		}
+
+		s.Properties[name] = g.schemaFor(ft)
+	}
+}
+
+func jsonName(tag string) string {
+	return strings.SplitN(tag, ",", 2)[0]
+}
+
+// Discoverer serves the document through the rpc.discover method
+type Discoverer struct {
+	Doc *Document
+}
+
+func (d *Discoverer) Discover(context.Context) (*Document, error) {
+	return d.Doc, nil
+}
@@ -2,7 +2,6 @@ package build
 
 import (
 	"context"
-	"os"
 	"strings"
 
 	"github.com/filecoin-project/lotus/lib/addrutil"
@@ -12,25 +11,26 @@ import (
 	"github.com/libp2p/go-libp2p-core/peer"
 )
 
+// BuiltinBootstrap returns the builtin bootstrap list of the network this
+// build is for
 func BuiltinBootstrap() ([]peer.AddrInfo, error) {
-	var out []peer.AddrInfo
+	if BootstrapNetwork == "" {
+		return nil, nil
+	}
+	return NetworkBootstrap(BootstrapNetwork)
+}
 
+// NetworkBootstrap returns the builtin bootstrap list of the named network,
+// stored in bootstrap/<network>.pi
+func NetworkBootstrap(network string) ([]peer.AddrInfo, error) {
 	b := rice.MustFindBox("bootstrap")
-	err := b.Walk("", func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return xerrors.Errorf("failed to walk box: %w", err)
-		}
+	spi, err := b.String(network + ".pi")
+	if err != nil {
+		return nil, xerrors.Errorf("no bootstrap list for network '%s': %w", network, err)
+	}
+	if strings.TrimSpace(spi) == "" {
+		return nil, nil
+	}
 
-		if !strings.HasSuffix(path, ".pi") {
-			return nil
-		}
-		spi := b.MustString(path)
-		if spi == "" {
-			return nil
-		}
-		pi, err := addrutil.ParseAddresses(context.TODO(), strings.Split(strings.TrimSpace(spi), "\n"))
-		out = append(out, pi...)
-		return err
-	})
-	return out, err
+	return addrutil.ParseAddresses(context.TODO(), strings.Split(strings.TrimSpace(spi), "\n"))
 }
@@ -0,0 +1,35 @@
+package build
+
+// DrandConfig selects a drand network. Each network build sets DrandNetwork,
+// when it has no servers, randomness is drawn from the ticket chain only.
+type DrandConfig struct {
+	// Servers are the HTTP endpoints of the network, tried in order
+	Servers []string
+
+	// GenesisTime is the unix time of the first round, Period is the number
+	// of seconds between rounds
+	GenesisTime uint64
+	Period      uint64
+
+	// GroupKey is the hex encoded BLS public key of the group, entries not
+	// signed by it are rejected
+	GroupKey string
+}
+
+func (c DrandConfig) Enabled() bool {
+	return len(c.Servers) > 0
+}
+
+// DrandLeagueOfEntropy is the public drand network run by the League of
+// Entropy
+var DrandLeagueOfEntropy = DrandConfig{
+	Servers: []string{
+		"https://api.drand.sh",
+		"https://api2.drand.sh",
+		"https://api3.drand.sh",
+		"https://drand.cloudflare.com",
+	},
+	GenesisTime: 1595431050,
+	Period:      30,
+	GroupKey:    "868f005eb8e6e4ca0a47c8a77ceaa5309a47978a7c71bc5cce96366b5d7a569937c529eeda66c7293784a9402801af31",
+}
@@ -0,0 +1,9 @@
+// +build debug,drand
+
+package build
+
+// Debug networks built with the drand tag mix in the randomness of the League
+// of Entropy network, the network has to be reachable to mine and validate
+func init() {
+	DrandNetwork = DrandLeagueOfEntropy
+}
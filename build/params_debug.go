@@ -8,6 +8,14 @@ func init() {
 
 var SectorSizes = []uint64{1024}
 
+// DrandNetwork is the drand network randomness is mixed with. Debug networks
+// only use tickets, unless built with the drand tag.
+var DrandNetwork = DrandConfig{}
+
+// BootstrapNetwork is the builtin bootstrap list used by default. Debug
+// networks are local and don't bootstrap from public nodes.
+const BootstrapNetwork = ""
+
 // Seconds
 const BlockDelay = 6
 
@@ -103,6 +103,13 @@ const BadBlockCacheSize = 1 << 15
 // 10 block reorg.
 const BlsSignatureCacheSize = 40000
 
+// Size of the cache of verified (signer, message) signatures, shared by the
+// syncer and mpool so that messages are only verified once
+const VerifSigCacheSize = 32000
+
+// Size of the syncer cache of verified block BLS aggregate signatures
+const BlsAggregateCacheSize = 1 << 12
+
 // ///////
 // Limits
 
@@ -6,6 +6,15 @@ var SectorSizes = []uint64{
 	32 << 30,
 }
 
+// DrandNetwork is the drand network randomness is mixed with. The testnet
+// uses tickets only, mixing in a beacon would change the randomness of the
+// running chain.
+var DrandNetwork = DrandConfig{}
+
+// BootstrapNetwork is the builtin bootstrap list used by default,
+// bootstrap/<network>.pi
+const BootstrapNetwork = "testnet"
+
 // Seconds
 const BlockDelay = 45
 
@@ -39,6 +39,21 @@ func (msas MultiSigActorState) canSpend(act *types.Actor, amnt types.BigInt, hei
 	return !minBalance.LessThan(types.BigSub(act.Balance, amnt))
 }
 
+// AmountLocked returns the part of the initial balance which is still
+// vesting at the given height
+func (msas MultiSigActorState) AmountLocked(height uint64) types.BigInt {
+	if msas.UnlockDuration == 0 || height >= msas.StartingBlock+msas.UnlockDuration {
+		return types.NewInt(0)
+	}
+	if height < msas.StartingBlock {
+		return msas.InitialBalance
+	}
+
+	unlocked := types.BigDiv(msas.InitialBalance, types.NewInt(msas.UnlockDuration))
+	unlocked = types.BigMul(unlocked, types.NewInt(height-msas.StartingBlock))
+	return types.BigSub(msas.InitialBalance, unlocked)
+}
+
 func (msas MultiSigActorState) isSigner(addr address.Address) bool {
 	for _, s := range msas.Signers {
 		if s == addr {
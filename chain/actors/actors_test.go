@@ -50,7 +50,7 @@ func setupVMTestEnv(t *testing.T) (*vm.VM, []address.Address, bstore.Blockstore)
 		t.Fatal(err)
 	}
 
-	cs := store.NewChainStore(bs, nil, nil)
+	cs := store.NewChainStore(bs, nil, nil, nil)
 
 	// TODO: should probabaly mock out the randomness bit, nil works for now
 	vm, err := vm.NewVM(stateroot, 1, nil, maddr, cs.Blockstore(), cs.VMSys())
@@ -0,0 +1,36 @@
+package beacon
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrEntryNotAvailable is returned for entries that haven't been fetched from
+// the beacon network yet
+var ErrEntryNotAvailable = errors.New("beacon entry not available yet")
+
+// Entry is the randomness published by a beacon for a round
+type Entry struct {
+	Round uint64
+	Data  []byte
+}
+
+// RandomnessBeacon is an external source of randomness, e.g. a drand network
+type RandomnessBeacon interface {
+	// Entry returns the verified entry of a round. Beacons used for
+	// validation only read entries fetched before and return
+	// ErrEntryNotAvailable for missing entries, so validation never waits
+	// for the network.
+	Entry(ctx context.Context, round uint64) (Entry, error)
+	// RoundAt returns the latest round published at time t
+	RoundAt(t time.Time) uint64
+}
+
+// Prefetcher is implemented by beacons which can fetch a range of entries
+// before they are needed, e.g. before validating a long range of the chain
+type Prefetcher interface {
+	// Prefetch fetches the published entries of rounds from..to which
+	// weren't fetched before
+	Prefetch(ctx context.Context, from, to uint64) error
+}
@@ -0,0 +1,400 @@
+package drand
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	logging "github.com/ipfs/go-log/v2"
+	"go.uber.org/multierr"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/beacon"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sigs"
+	_ "github.com/filecoin-project/lotus/lib/sigs/bls"
+)
+
+var log = logging.Logger("drand")
+
+const (
+	// requestQueue is how many missing rounds can wait for the fetcher
+	requestQueue = 64
+	// prefetchParallel is how many rounds Prefetch fetches at once
+	prefetchParallel = 8
+)
+
+type publicEntry struct {
+	Round             uint64 `json:"round"`
+	Randomness        string `json:"randomness"`
+	Signature         string `json:"signature"`
+	PreviousSignature string `json:"previous_signature"`
+}
+
+// Beacon reads randomness from the HTTP API of a drand network. Entries are
+// verified against the group key of the network and kept in a datastore, so
+// each round is only fetched once.
+type Beacon struct {
+	cfg      build.DrandConfig
+	groupKey address.Address
+
+	client *http.Client
+	ds     datastore.Datastore
+	cache  *lru.ARCCache
+
+	requests chan uint64
+}
+
+func New(cfg build.DrandConfig, ds datastore.Batching) (*Beacon, error) {
+	if !cfg.Enabled() {
+		return nil, xerrors.New("no drand servers configured")
+	}
+	if cfg.Period == 0 {
+		return nil, xerrors.New("drand round period not set")
+	}
+
+	pub, err := hex.DecodeString(cfg.GroupKey)
+	if err != nil {
+		return nil, xerrors.Errorf("decoding drand group key: %w", err)
+	}
+	groupKey, err := address.NewBLSAddress(pub)
+	if err != nil {
+		return nil, xerrors.Errorf("parsing drand group key: %w", err)
+	}
+
+	cache, err := lru.NewARC(1024)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Beacon{
+		cfg:      cfg,
+		groupKey: groupKey,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		ds:       namespace.Wrap(ds, datastore.NewKey("/drand")),
+		cache:    cache,
+		requests: make(chan uint64, requestQueue),
+	}, nil
+}
+
+// NetworkBeacon returns the beacon of the network the node is built for, or
+// nil when the network doesn't use one
+func NetworkBeacon(ds datastore.Batching) (*Beacon, error) {
+	if !build.DrandNetwork.Enabled() {
+		return nil, nil
+	}
+	return New(build.DrandNetwork, ds)
+}
+
+// OfflineBeacon returns the beacon of the network for tools that create or
+// validate chains without a running node, it fetches missing entries on
+// lookup. It returns nil when the network doesn't use a beacon.
+func OfflineBeacon(ds datastore.Batching) (beacon.RandomnessBeacon, error) {
+	b, err := NetworkBeacon(ds)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	return b.Fetching(), nil
+}
+
+// RoundAt returns the latest round published at time t, 0 before the first
+// round
+func (b *Beacon) RoundAt(t time.Time) uint64 {
+	if t.Unix() < int64(b.cfg.GenesisTime) {
+		return 0
+	}
+	return (uint64(t.Unix())-b.cfg.GenesisTime)/b.cfg.Period + 1
+}
+
+func (b *Beacon) roundTime(round uint64) time.Time {
+	return time.Unix(int64(b.cfg.GenesisTime+(round-1)*b.cfg.Period), 0)
+}
+
+// Entry returns the entry of a round fetched before. Missing rounds that are
+// published already are requested from the fetcher started by Run.
+func (b *Beacon) Entry(ctx context.Context, round uint64) (beacon.Entry, error) {
+	if round == 0 {
+		return beacon.Entry{}, xerrors.New("drand rounds start at 1")
+	}
+
+	e, ok, err := b.local(round)
+	if err != nil {
+		return beacon.Entry{}, err
+	}
+	if ok {
+		return e, nil
+	}
+
+	if round <= b.RoundAt(time.Now()) {
+		select {
+		case b.requests <- round:
+		default:
+			// the fetcher is busy, the round is requested again on the next
+			// lookup
+		}
+	}
+
+	return beacon.Entry{}, xerrors.Errorf("drand round %d: %w", round, beacon.ErrEntryNotAvailable)
+}
+
+// Fetch returns the entry of a round, getting it from the network when it
+// wasn't fetched before
+func (b *Beacon) Fetch(ctx context.Context, round uint64) (beacon.Entry, error) {
+	if round == 0 {
+		return beacon.Entry{}, xerrors.New("drand rounds start at 1")
+	}
+
+	e, ok, err := b.local(round)
+	if err != nil {
+		return beacon.Entry{}, err
+	}
+	if ok {
+		return e, nil
+	}
+
+	if round > b.RoundAt(time.Now()) {
+		return beacon.Entry{}, xerrors.Errorf("drand round %d isn't published yet", round)
+	}
+
+	var errs error
+	for _, server := range b.cfg.Servers {
+		e, err := b.fetch(ctx, server, round)
+		if err != nil {
+			log.Warnf("getting drand round %d from %s: %s", round, server, err)
+			errs = multierr.Append(errs, err)
+			continue
+		}
+
+		if err := b.ds.Put(roundKey(round), e.Data); err != nil {
+			return beacon.Entry{}, xerrors.Errorf("storing drand round %d: %w", round, err)
+		}
+		b.cache.Add(round, e)
+		return e, nil
+	}
+
+	return beacon.Entry{}, xerrors.Errorf("getting drand round %d: %w", round, errs)
+}
+
+// Prefetch fetches the published rounds from..to which weren't fetched
+// before, so validating a range of the chain finds all the entries it needs.
+// It stops at the first round which can't be fetched.
+func (b *Beacon) Prefetch(ctx context.Context, from, to uint64) error {
+	if from == 0 {
+		from = 1
+	}
+	if latest := b.RoundAt(time.Now()); to > latest {
+		to = latest
+	}
+	if from > to {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errLk    sync.Mutex
+		firstErr error
+	)
+
+	rounds := make(chan uint64)
+	for i := 0; i < prefetchParallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for round := range rounds {
+				if _, err := b.Fetch(ctx, round); err != nil {
+					errLk.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					errLk.Unlock()
+					cancel()
+				}
+			}
+		}()
+	}
+
+	log.Infow("prefetching drand rounds", "from", from, "to", to)
+
+feed:
+	for round := from; round <= to; round++ {
+		select {
+		case rounds <- round:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(rounds)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// Run fetches rounds as they are published and the rounds Entry missed, until
+// ctx is cancelled
+func (b *Beacon) Run(ctx context.Context) {
+	for {
+		latest := b.RoundAt(time.Now())
+		if latest > 0 {
+			if _, err := b.Fetch(ctx, latest); err != nil && ctx.Err() == nil {
+				log.Errorf("fetching latest drand round: %s", err)
+			}
+		}
+
+		next := time.NewTimer(time.Until(b.roundTime(latest + 1)))
+		select {
+		case round := <-b.requests:
+			next.Stop()
+			if _, err := b.Fetch(ctx, round); err != nil && ctx.Err() == nil {
+				log.Errorf("fetching requested drand round: %s", err)
+			}
+		case <-next.C:
+		case <-ctx.Done():
+			next.Stop()
+			return
+		}
+	}
+}
+
+// Fetching returns a view of the beacon which fetches missing entries on
+// lookup, for use without a fetcher
+func (b *Beacon) Fetching() beacon.RandomnessBeacon {
+	return fetchingBeacon{b}
+}
+
+type fetchingBeacon struct {
+	*Beacon
+}
+
+func (b fetchingBeacon) Entry(ctx context.Context, round uint64) (beacon.Entry, error) {
+	return b.Fetch(ctx, round)
+}
+
+func roundKey(round uint64) datastore.Key {
+	return datastore.NewKey(fmt.Sprint(round))
+}
+
+// local returns an entry fetched before, entries are verified before they
+// are stored
+func (b *Beacon) local(round uint64) (beacon.Entry, bool, error) {
+	if e, ok := b.cache.Get(round); ok {
+		return e.(beacon.Entry), true, nil
+	}
+
+	data, err := b.ds.Get(roundKey(round))
+	switch err {
+	case nil:
+	case datastore.ErrNotFound:
+		return beacon.Entry{}, false, nil
+	default:
+		return beacon.Entry{}, false, xerrors.Errorf("loading drand round %d: %w", round, err)
+	}
+
+	e := beacon.Entry{
+		Round: round,
+		Data:  data,
+	}
+	b.cache.Add(round, e)
+	return e, true, nil
+}
+
+func (b *Beacon) fetch(ctx context.Context, server string, round uint64) (beacon.Entry, error) {
+	pe, err := b.get(ctx, server, round)
+	if err != nil {
+		return beacon.Entry{}, err
+	}
+
+	return b.verify(round, pe)
+}
+
+// get reads the entry of a round from a server without verifying it
+func (b *Beacon) get(ctx context.Context, server string, round uint64) (*publicEntry, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/public/%d", strings.TrimSuffix(server, "/"), round), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, xerrors.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	var pe publicEntry
+	if err := json.NewDecoder(resp.Body).Decode(&pe); err != nil {
+		return nil, xerrors.Errorf("decoding entry: %w", err)
+	}
+
+	return &pe, nil
+}
+
+// verify checks that an entry is the one the group signed for the round
+func (b *Beacon) verify(round uint64, pe *publicEntry) (beacon.Entry, error) {
+	if pe.Round != round {
+		return beacon.Entry{}, xerrors.Errorf("got round %d", pe.Round)
+	}
+
+	sig, err := hex.DecodeString(pe.Signature)
+	if err != nil {
+		return beacon.Entry{}, xerrors.Errorf("decoding signature: %w", err)
+	}
+	prev, err := hex.DecodeString(pe.PreviousSignature)
+	if err != nil {
+		return beacon.Entry{}, xerrors.Errorf("decoding previous signature: %w", err)
+	}
+	rand, err := hex.DecodeString(pe.Randomness)
+	if err != nil {
+		return beacon.Entry{}, xerrors.Errorf("decoding randomness: %w", err)
+	}
+
+	if err := sigs.Verify(&types.Signature{Type: types.KTBLS, Data: sig}, b.groupKey, signedMessage(round, prev)); err != nil {
+		return beacon.Entry{}, xerrors.Errorf("verifying signature of round %d: %w", round, err)
+	}
+
+	h := sha256.Sum256(sig)
+	if !bytes.Equal(h[:], rand) {
+		return beacon.Entry{}, xerrors.Errorf("randomness of round %d doesn't match its signature", round)
+	}
+
+	return beacon.Entry{
+		Round: round,
+		Data:  rand,
+	}, nil
+}
+
+// signedMessage is what the group signs for a round, the previous signature
+// chains each round to the one before
+func signedMessage(round uint64, prevSig []byte) []byte {
+	var rb [8]byte
+	binary.BigEndian.PutUint64(rb[:], round)
+
+	h := sha256.New()
+	h.Write(prevSig)
+	h.Write(rb[:])
+	return h.Sum(nil)
+}
+
+var _ beacon.RandomnessBeacon = &Beacon{}
+var _ beacon.Prefetcher = &Beacon{}
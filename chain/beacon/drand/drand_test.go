@@ -0,0 +1,264 @@
+package drand
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/beacon"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sigs"
+)
+
+type testGroup struct {
+	priv []byte
+	pub  []byte
+}
+
+func newTestGroup(t *testing.T) testGroup {
+	priv, err := sigs.Generate(types.KTBLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := sigs.ToPublic(types.KTBLS, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testGroup{priv: priv, pub: pub}
+}
+
+// sig returns the signatures of a round and the round before
+func (g testGroup) sig(t *testing.T, round uint64) ([]byte, []byte) {
+	prev := []byte("genesis")
+	var sig []byte
+	for r := uint64(1); r <= round; r++ {
+		s, err := sigs.Sign(types.KTBLS, g.priv, signedMessage(r, prev))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = s.Data
+		if r < round {
+			prev = sig
+		}
+	}
+	return sig, prev
+}
+
+// serve serves the entries of a group, counting the requests
+func (g testGroup) serve(t *testing.T, hits *int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(hits, 1)
+
+		round, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/public/"), 10, 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sig, prev := g.sig(t, round)
+		rand := sha256.Sum256(sig)
+		_ = json.NewEncoder(w).Encode(publicEntry{
+			Round:             round,
+			Randomness:        hex.EncodeToString(rand[:]),
+			Signature:         hex.EncodeToString(sig),
+			PreviousSignature: hex.EncodeToString(prev),
+		})
+	}))
+}
+
+func testConfig(g testGroup, servers ...string) build.DrandConfig {
+	return build.DrandConfig{
+		Servers:     servers,
+		GenesisTime: uint64(time.Now().Unix()) - 100,
+		Period:      1,
+		GroupKey:    hex.EncodeToString(g.pub),
+	}
+}
+
+func TestFetchVerifiesEntries(t *testing.T) {
+	group := newTestGroup(t)
+
+	var liarHits, hits int64
+	liar := newTestGroup(t).serve(t, &liarHits)
+	defer liar.Close()
+	honest := group.serve(t, &hits)
+	defer honest.Close()
+
+	b, err := New(testConfig(group, liar.URL, honest.URL), datastore.NewMapDatastore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := b.Fetch(context.Background(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, _ := group.sig(t, 5)
+	expect := sha256.Sum256(sig)
+	if e.Round != 5 || hex.EncodeToString(e.Data) != hex.EncodeToString(expect[:]) {
+		t.Fatalf("expected the entry of the honest server, got round %d data %x", e.Round, e.Data)
+	}
+	if liarHits != 1 || hits != 1 {
+		t.Fatalf("expected each server to be asked once, got %d and %d", liarHits, hits)
+	}
+
+	// the verified entry is kept
+	if _, err := b.Fetch(context.Background(), 5); err != nil {
+		t.Fatal(err)
+	}
+	if hits != 1 {
+		t.Fatalf("expected the entry to be fetched once, got %d requests", hits)
+	}
+
+	b, err = New(testConfig(group, liar.URL), datastore.NewMapDatastore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Fetch(context.Background(), 5); err == nil {
+		t.Fatal("expected entries not signed by the group to be rejected")
+	}
+}
+
+func TestEntryDoesntFetch(t *testing.T) {
+	group := newTestGroup(t)
+
+	var hits int64
+	srv := group.serve(t, &hits)
+	defer srv.Close()
+
+	ds := datastore.NewMapDatastore()
+	b, err := New(testConfig(group, srv.URL), ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Entry(context.Background(), 7); !xerrors.Is(err, beacon.ErrEntryNotAvailable) {
+		t.Fatalf("expected ErrEntryNotAvailable, got %v", err)
+	}
+	if n := atomic.LoadInt64(&hits); n != 0 {
+		t.Fatalf("expected Entry not to fetch, got %d requests", n)
+	}
+
+	// the fetcher gets the missed round
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := b.Entry(context.Background(), 7)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("round wasn't fetched: %s", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// fetched entries are kept in the datastore
+	b, err = New(testConfig(group, srv.URL), ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Entry(context.Background(), 7); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	group := newTestGroup(t)
+
+	var hits int64
+	srv := group.serve(t, &hits)
+	defer srv.Close()
+
+	b, err := New(testConfig(group, srv.URL), datastore.NewMapDatastore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Fetch(context.Background(), 12); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Prefetch(context.Background(), 10, 20); err != nil {
+		t.Fatal(err)
+	}
+
+	for round := uint64(10); round <= 20; round++ {
+		if _, err := b.Entry(context.Background(), round); err != nil {
+			t.Fatalf("round %d wasn't prefetched: %s", round, err)
+		}
+	}
+	if n := atomic.LoadInt64(&hits); n != 11 {
+		t.Fatalf("expected each round to be fetched once, got %d requests", n)
+	}
+
+	// rounds which aren't published yet are skipped
+	latest := b.RoundAt(time.Now())
+	if err := b.Prefetch(context.Background(), latest+10, latest+1000); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt64(&hits); n != 11 {
+		t.Fatalf("expected unpublished rounds not to be requested, got %d requests", n)
+	}
+}
+
+// TestLeagueOfEntropy checks that rounds of the public drand network verify
+// with the BLS implementation used for chain signatures
+func TestLeagueOfEntropy(t *testing.T) {
+	if testing.Short() {
+		t.Skip("needs access to the drand network")
+	}
+
+	cfg := build.DrandLeagueOfEntropy
+	b, err := New(cfg, datastore.NewMapDatastore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	round := b.RoundAt(time.Now()) - 10
+	var pe *publicEntry
+	for _, server := range cfg.Servers {
+		pe, err = b.get(ctx, server, round)
+		if err == nil {
+			break
+		}
+		t.Logf("getting round %d from %s: %s", round, server, err)
+	}
+	if pe == nil {
+		t.Skip("drand network not reachable")
+	}
+
+	e, err := b.verify(round, pe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Round != round || len(e.Data) != sha256.Size {
+		t.Fatalf("unexpected entry for round %d: round %d, %d bytes", round, e.Round, len(e.Data))
+	}
+
+	// the signature covers the previous one
+	tampered := *pe
+	tampered.PreviousSignature = pe.Signature
+	if _, err := b.verify(round, &tampered); err == nil {
+		t.Fatal("expected a signature over another message to be rejected")
+	}
+}
@@ -24,6 +24,7 @@ import (
 	sectorbuilder "github.com/filecoin-project/go-sectorbuilder"
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/beacon/drand"
 	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
@@ -184,7 +185,12 @@ func NewGenerator() (*ChainGen, error) {
 		return nil, xerrors.Errorf("make genesis block failed: %w", err)
 	}
 
-	cs := store.NewChainStore(bs, ds, sys)
+	rb, err := drand.OfflineBeacon(ds)
+	if err != nil {
+		return nil, xerrors.Errorf("creating randomness beacon: %w", err)
+	}
+
+	cs := store.NewChainStore(bs, ds, sys, rb)
 
 	genfb := &types.FullBlock{Header: genb.Genesis}
 	gents := store.NewFullTipSet([]*types.FullBlock{genfb})
@@ -497,28 +503,28 @@ type ProofInput struct {
 	vrfout  []byte
 }
 
-func IsRoundWinner(ctx context.Context, ts *types.TipSet, round int64, miner address.Address, epp ElectionPoStProver, a MiningCheckAPI) (*ProofInput, error) {
+// electionInputs computes the election VRF of the miner for the round on top
+// of ts, and loads the proving set the candidates are generated from. The
+// proving set is empty when the miner has no sectors.
+func electionInputs(ctx context.Context, ts *types.TipSet, round int64, miner address.Address, a MiningCheckAPI) ([]byte, []ffi.PublicSectorInfo, error) {
 	r, err := a.ChainGetRandomness(ctx, ts.Key(), round-build.EcRandomnessLookback)
 	if err != nil {
-		return nil, xerrors.Errorf("chain get randomness: %w", err)
+		return nil, nil, xerrors.Errorf("chain get randomness: %w", err)
 	}
 
 	mworker, err := a.StateMinerWorker(ctx, miner, ts)
 	if err != nil {
-		return nil, xerrors.Errorf("failed to get miner worker: %w", err)
+		return nil, nil, xerrors.Errorf("failed to get miner worker: %w", err)
 	}
 
 	vrfout, err := ComputeVRF(ctx, a.WalletSign, mworker, miner, DSepElectionPost, r)
 	if err != nil {
-		return nil, xerrors.Errorf("failed to compute VRF: %w", err)
+		return nil, nil, xerrors.Errorf("failed to compute VRF: %w", err)
 	}
 
 	pset, err := a.StateMinerProvingSet(ctx, miner, ts)
 	if err != nil {
-		return nil, xerrors.Errorf("failed to load proving set for miner: %w", err)
-	}
-	if len(pset) == 0 {
-		return nil, nil
+		return nil, nil, xerrors.Errorf("failed to load proving set for miner: %w", err)
 	}
 
 	var sinfos []ffi.PublicSectorInfo
@@ -530,6 +536,40 @@ func IsRoundWinner(ctx context.Context, ts *types.TipSet, round int64, miner add
 			CommR:    commRa,
 		})
 	}
+
+	return vrfout, sinfos, nil
+}
+
+// PrecomputeCandidates generates the election PoSt candidates of the miner for
+// a later round, on top of ts. The candidates only depend on the election
+// randomness, which is taken EcRandomnessLookback rounds before the round, and
+// on the proving set. Provers which keep the candidates they generated reuse
+// them when the round is mined, even on top of another tipset, as long as the
+// randomness and the proving set didn't change.
+func PrecomputeCandidates(ctx context.Context, ts *types.TipSet, round int64, miner address.Address, epp ElectionPoStProver, a MiningCheckAPI) error {
+	vrfout, sinfos, err := electionInputs(ctx, ts, round, miner, a)
+	if err != nil {
+		return err
+	}
+	if len(sinfos) == 0 {
+		return nil
+	}
+
+	hvrf := sha256.Sum256(vrfout)
+	if _, err := epp.GenerateCandidates(ctx, sectorbuilder.NewSortedPublicSectorInfo(sinfos), hvrf[:]); err != nil {
+		return xerrors.Errorf("failed to generate electionPoSt candidates: %w", err)
+	}
+	return nil
+}
+
+func IsRoundWinner(ctx context.Context, ts *types.TipSet, round int64, miner address.Address, epp ElectionPoStProver, a MiningCheckAPI) (*ProofInput, error) {
+	vrfout, sinfos, err := electionInputs(ctx, ts, round, miner, a)
+	if err != nil {
+		return nil, err
+	}
+	if len(sinfos) == 0 {
+		return nil, nil
+	}
 	sectors := sectorbuilder.NewSortedPublicSectorInfo(sinfos)
 
 	hvrf := sha256.Sum256(vrfout)
@@ -3,6 +3,7 @@ package gen
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
 
 	amt "github.com/filecoin-project/go-amt-ipld"
@@ -264,6 +265,9 @@ type GenMinerCfg struct {
 	MinerAddrs []address.Address
 
 	PeerIDs []peer.ID
+
+	// NetworkName is mixed into the genesis ticket when set
+	NetworkName string
 }
 
 func mustEnc(i cbg.CBORMarshaler) []byte {
@@ -568,8 +572,9 @@ func MakeGenesisBlock(bs bstore.Blockstore, sys *types.VMSyscalls, balances map[
 		return nil, xerrors.Errorf("flush state tree failed: %w", err)
 	}
 
-	// temp chainstore
-	cs := store.NewChainStore(bs, datastore.NewMapDatastore(), sys)
+	// temp chainstore, it has no genesis to find beacon rounds from, the
+	// genesis state doesn't use randomness past the genesis anyway
+	cs := store.NewChainStore(bs, datastore.NewMapDatastore(), sys, nil)
 	stateroot, deals, err := SetupStorageMiners(ctx, cs, stateroot, gmcfg)
 	if err != nil {
 		return nil, xerrors.Errorf("setup storage miners failed: %w", err)
@@ -609,6 +614,10 @@ func MakeGenesisBlock(bs bstore.Blockstore, sys *types.VMSyscalls, balances map[
 	genesisticket := &types.Ticket{
 		VRFProof: []byte("vrf proof0000000vrf proof0000000"),
 	}
+	if gmcfg.NetworkName != "" {
+		nt := sha256.Sum256([]byte("lotus genesis " + gmcfg.NetworkName))
+		genesisticket.VRFProof = nt[:]
+	}
 
 	b := &types.BlockHeader{
 		Miner:  actors.InitAddress,
@@ -62,7 +62,7 @@ func (fm *FundMgr) EnsureAvailable(ctx context.Context, addr address.Address, am
 		GasPrice: types.NewInt(0),
 		GasLimit: types.NewInt(1000000),
 		Method:   actors.SMAMethods.AddBalance,
-	})
+	}, nil)
 	if err != nil {
 		return err
 	}
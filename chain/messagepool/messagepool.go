@@ -41,6 +41,14 @@ var (
 	ErrNotEnoughFunds = errors.New("not enough funds to execute transaction")
 
 	ErrInvalidToAddr = errors.New("message had invalid to address")
+
+	ErrGasPriceTooLow = errors.New("gas price below the mpool minimum")
+
+	ErrTooManyPendingMessages = errors.New("too many pending messages for actor")
+
+	ErrMpoolFull = errors.New("mpool is full and the message gas price is too low")
+
+	ErrReplaceByFeeTooLow = errors.New("gas price too low to replace the pending message")
 )
 
 const (
@@ -49,8 +57,34 @@ const (
 	localMsgsDs = "/mpool/local"
 
 	localUpdates = "update"
+
+	// replaceByFeePercent is the gas price, in percent of the gas price of a
+	// pending message, a message needs to replace it
+	replaceByFeePercent = 125
 )
 
+// ReplaceByFeeMinimum returns the lowest gas price a message can have to
+// replace a pending message with the given gas price
+func ReplaceByFeeMinimum(gasPrice types.BigInt) types.BigInt {
+	return types.BigDiv(types.BigMul(gasPrice, types.NewInt(replaceByFeePercent)), types.NewInt(100))
+}
+
+// Config holds the limits the message pool enforces on incoming messages
+type Config struct {
+	// MaxPendingPerActor caps how many pending messages a single remote sender
+	// can have in the pool. Messages from local addresses are not limited.
+	MaxPendingPerActor int
+
+	// MinGasPrice is the lowest gas price a message must pay to be accepted
+	MinGasPrice types.BigInt
+
+	// SizeLimitHigh is the number of pending remote messages above which the
+	// pool starts evicting the lowest gas price messages, down to
+	// SizeLimitLow. Messages from local addresses are not counted or evicted.
+	SizeLimitHigh int
+	SizeLimitLow  int
+}
+
 type MessagePool struct {
 	lk sync.Mutex
 
@@ -60,17 +94,19 @@ type MessagePool struct {
 	localAddrs map[address.Address]struct{}
 
 	pending map[address.Address]*msgSet
+	// size is the number of messages in pending
+	size int
 
 	curTsLk sync.Mutex // DO NOT LOCK INSIDE lk
 	curTs   *types.TipSet
 
 	api Provider
 
-	minGasPrice types.BigInt
-
-	maxTxPoolSize int
+	cfgLk sync.RWMutex
+	cfg   *Config
 
 	blsSigCache *lru.TwoQueueCache
+	sigCache    *sigs.Cache
 
 	changes *lps.PubSub
 
@@ -89,15 +125,17 @@ func newMsgSet() *msgSet {
 }
 
 func (ms *msgSet) add(m *types.SignedMessage) error {
+	if exms, has := ms.msgs[m.Message.Nonce]; has {
+		if m.Cid() != exms.Cid() {
+			if minPrice := ReplaceByFeeMinimum(exms.Message.GasPrice); m.Message.GasPrice.LessThan(minPrice) {
+				return xerrors.Errorf("message from %s with nonce %d already in mpool, replacing it needs a gas price of at least %s: %w", m.Message.From, m.Message.Nonce, minPrice, ErrReplaceByFeeTooLow)
+			}
+			log.Infof("replacing message %s with nonce %d by %s with a higher gas price", exms.Cid(), m.Message.Nonce, m.Cid())
+		}
+	}
 	if len(ms.msgs) == 0 || m.Message.Nonce >= ms.nextNonce {
 		ms.nextNonce = m.Message.Nonce + 1
 	}
-	if _, has := ms.msgs[m.Message.Nonce]; has {
-		if m.Cid() != ms.msgs[m.Message.Nonce].Cid() {
-			log.Error("Add with duplicate nonce")
-			return xerrors.Errorf("message to %s with nonce %d already in mpool", m.Message.To, m.Message.Nonce)
-		}
-	}
 	ms.msgs[m.Message.Nonce] = m
 
 	return nil
@@ -151,19 +189,27 @@ func (mpp *mpoolProvider) LoadTipSet(tsk types.TipSetKey) (*types.TipSet, error)
 	return mpp.sm.ChainStore().LoadTipSet(tsk)
 }
 
-func New(api Provider, ds dtypes.MetadataDS) (*MessagePool, error) {
+// New creates a message pool. The config is applied before local messages
+// persisted by a previous run are loaded back, a nil config means no limits.
+func New(api Provider, ds dtypes.MetadataDS, sigCache *sigs.Cache, cfg *Config) (*MessagePool, error) {
 	cache, _ := lru.New2Q(build.BlsSignatureCacheSize)
 	mp := &MessagePool{
-		closer:        make(chan struct{}),
-		repubTk:       time.NewTicker(build.BlockDelay * 10 * time.Second),
-		localAddrs:    make(map[address.Address]struct{}),
-		pending:       make(map[address.Address]*msgSet),
-		minGasPrice:   types.NewInt(0),
-		maxTxPoolSize: 5000,
-		blsSigCache:   cache,
-		changes:       lps.New(50),
-		localMsgs:     namespace.Wrap(ds, datastore.NewKey(localMsgsDs)),
-		api:           api,
+		closer:      make(chan struct{}),
+		repubTk:     time.NewTicker(build.BlockDelay * 10 * time.Second),
+		localAddrs:  make(map[address.Address]struct{}),
+		pending:     make(map[address.Address]*msgSet),
+		cfg:         &Config{MinGasPrice: types.NewInt(0)}, // no limits until configured
+		blsSigCache: cache,
+		sigCache:    sigCache,
+		changes:     lps.New(50),
+		localMsgs:   namespace.Wrap(ds, datastore.NewKey(localMsgsDs)),
+		api:         api,
+	}
+
+	if cfg != nil {
+		if err := mp.SetConfig(cfg); err != nil {
+			return nil, xerrors.Errorf("setting mpool config: %w", err)
+		}
 	}
 
 	if err := mp.loadLocal(); err != nil {
@@ -183,75 +229,105 @@ func New(api Provider, ds dtypes.MetadataDS) (*MessagePool, error) {
 	return mp, nil
 }
 
+func (mp *MessagePool) GetConfig() *Config {
+	mp.cfgLk.RLock()
+	defer mp.cfgLk.RUnlock()
+	cfg := *mp.cfg
+	return &cfg
+}
+
+func (mp *MessagePool) SetConfig(cfg *Config) error {
+	if cfg.SizeLimitLow > cfg.SizeLimitHigh {
+		return xerrors.Errorf("mpool low size limit (%d) is larger than the high limit (%d)", cfg.SizeLimitLow, cfg.SizeLimitHigh)
+	}
+	if cfg.MinGasPrice.Nil() {
+		cfg.MinGasPrice = types.NewInt(0)
+	}
+
+	mp.cfgLk.Lock()
+	defer mp.cfgLk.Unlock()
+	mp.cfg = cfg
+	return nil
+}
+
 func (mp *MessagePool) Close() error {
 	close(mp.closer)
 	return nil
 }
 
 func (mp *MessagePool) repubLocal() {
+	// republish anything we loaded from the datastore right away, there is a
+	// good chance it was dropped by the network while we were offline
+	if err := mp.republishPendingMessages(); err != nil {
+		log.Errorf("errors while republishing: %+v", err)
+	}
+
 	for {
 		select {
 		case <-mp.repubTk.C:
-			mp.lk.Lock()
-
-			msgsForAddr := make(map[address.Address][]*types.SignedMessage)
-			for a := range mp.localAddrs {
-				msgsForAddr[a] = mp.pendingFor(a)
+			if err := mp.republishPendingMessages(); err != nil {
+				log.Errorf("errors while republishing: %+v", err)
 			}
+		case <-mp.closer:
+			mp.repubTk.Stop()
+			return
+		}
+	}
+}
 
-			mp.lk.Unlock()
+func (mp *MessagePool) republishPendingMessages() error {
+	mp.lk.Lock()
 
-			var errout error
-			outputMsgs := []*types.SignedMessage{}
+	msgsForAddr := make(map[address.Address][]*types.SignedMessage)
+	for a := range mp.localAddrs {
+		msgsForAddr[a] = mp.pendingFor(a)
+	}
 
-			for a, msgs := range msgsForAddr {
-				a, err := mp.api.StateGetActor(a, nil)
-				if err != nil {
-					errout = multierr.Append(errout, xerrors.Errorf("could not get actor state: %w", err))
-					continue
-				}
+	mp.lk.Unlock()
 
-				curNonce := a.Nonce
-				for _, m := range msgs {
-					if m.Message.Nonce < curNonce {
-						continue
-					}
-					if m.Message.Nonce != curNonce {
-						break
-					}
-					outputMsgs = append(outputMsgs, m)
-					curNonce++
-				}
+	var errout error
+	outputMsgs := []*types.SignedMessage{}
 
-			}
+	for a, msgs := range msgsForAddr {
+		a, err := mp.api.StateGetActor(a, nil)
+		if err != nil {
+			errout = multierr.Append(errout, xerrors.Errorf("could not get actor state: %w", err))
+			continue
+		}
 
-			if len(outputMsgs) != 0 {
-				log.Infow("republishing local messages", "n", len(outputMsgs))
+		curNonce := a.Nonce
+		for _, m := range msgs {
+			if m.Message.Nonce < curNonce {
+				continue
+			}
+			if m.Message.Nonce != curNonce {
+				break
 			}
+			outputMsgs = append(outputMsgs, m)
+			curNonce++
+		}
 
-			for _, msg := range outputMsgs {
-				msgb, err := msg.Serialize()
-				if err != nil {
-					errout = multierr.Append(errout, xerrors.Errorf("could not serialize: %w", err))
-					continue
-				}
+	}
 
-				err = mp.api.PubSubPublish(msgTopic, msgb)
-				if err != nil {
-					errout = multierr.Append(errout, xerrors.Errorf("could not publish: %w", err))
-					continue
-				}
-			}
+	if len(outputMsgs) != 0 {
+		log.Infow("republishing local messages", "n", len(outputMsgs))
+	}
 
-			if errout != nil {
-				log.Errorf("errors while republishing: %+v", errout)
-			}
-		case <-mp.closer:
-			mp.repubTk.Stop()
-			return
+	for _, msg := range outputMsgs {
+		msgb, err := msg.Serialize()
+		if err != nil {
+			errout = multierr.Append(errout, xerrors.Errorf("could not serialize: %w", err))
+			continue
+		}
+
+		err = mp.api.PubSubPublish(msgTopic, msgb)
+		if err != nil {
+			errout = multierr.Append(errout, xerrors.Errorf("could not publish: %w", err))
+			continue
 		}
 	}
 
+	return errout
 }
 
 func (mp *MessagePool) addLocal(m *types.SignedMessage, msgb []byte) error {
@@ -264,13 +340,25 @@ func (mp *MessagePool) addLocal(m *types.SignedMessage, msgb []byte) error {
 	return nil
 }
 
+// removeLocal drops a locally originated message from the datastore once it
+// no longer needs to be republished. Must be called with mp.lk held.
+func (mp *MessagePool) removeLocal(m *types.SignedMessage) {
+	if _, local := mp.localAddrs[m.Message.From]; !local {
+		return
+	}
+
+	if err := mp.localMsgs.Delete(datastore.NewKey(string(m.Cid().Bytes()))); err != nil {
+		log.Errorf("removing local message %s: %+v", m.Cid(), err)
+	}
+}
+
 func (mp *MessagePool) Push(m *types.SignedMessage) (cid.Cid, error) {
 	msgb, err := m.Serialize()
 	if err != nil {
 		return cid.Undef, err
 	}
 
-	if err := mp.Add(m); err != nil {
+	if err := mp.add(m, true); err != nil {
 		return cid.Undef, err
 	}
 
@@ -285,12 +373,19 @@ func (mp *MessagePool) Push(m *types.SignedMessage) (cid.Cid, error) {
 }
 
 func (mp *MessagePool) Add(m *types.SignedMessage) error {
+	return mp.add(m, false)
+}
+
+// add adds a message to the pool. Local messages aren't subject to the
+// remote sender limits, and their sender is marked local once the message
+// is added.
+func (mp *MessagePool) add(m *types.SignedMessage, local bool) error {
 	mp.curTsLk.Lock()
 	defer mp.curTsLk.Unlock()
-	return mp.addTs(m, mp.curTs)
+	return mp.addTs(m, mp.curTs, local)
 }
 
-func (mp *MessagePool) addTs(m *types.SignedMessage, curTs *types.TipSet) error {
+func (mp *MessagePool) addTs(m *types.SignedMessage, curTs *types.TipSet, local bool) error {
 	// big messages are bad, anti DOS
 	if m.Size() > 32*1024 {
 		return xerrors.Errorf("mpool message too large (%dB): %w", m.Size(), ErrMessageTooBig)
@@ -304,7 +399,13 @@ func (mp *MessagePool) addTs(m *types.SignedMessage, curTs *types.TipSet) error
 		return ErrMessageValueTooHigh
 	}
 
-	if err := sigs.Verify(&m.Signature, m.Message.From, m.Message.Cid().Bytes()); err != nil {
+	cfg := mp.GetConfig()
+
+	if m.Message.GasPrice.LessThan(cfg.MinGasPrice) {
+		return xerrors.Errorf("gas price %s is lower than %s: %w", m.Message.GasPrice, cfg.MinGasPrice, ErrGasPriceTooLow)
+	}
+
+	if err := mp.sigCache.Verify(&m.Signature, m.Message.From, m.Message.Cid().Bytes()); err != nil {
 		log.Warnf("mpooladd signature verification failed: %s", err)
 		return err
 	}
@@ -330,7 +431,109 @@ func (mp *MessagePool) addTs(m *types.SignedMessage, curTs *types.TipSet) error
 	mp.lk.Lock()
 	defer mp.lk.Unlock()
 
-	return mp.addLocked(m)
+	if !local {
+		_, local = mp.localAddrs[m.Message.From]
+	}
+
+	if !local && cfg.MaxPendingPerActor > 0 {
+		mset, ok := mp.pending[m.Message.From]
+		if ok && len(mset.msgs) >= cfg.MaxPendingPerActor {
+			if _, replacing := mset.msgs[m.Message.Nonce]; !replacing {
+				return xerrors.Errorf("sender %s has %d pending messages: %w", m.Message.From, len(mset.msgs), ErrTooManyPendingMessages)
+			}
+		}
+	}
+
+	if err := mp.addLocked(m); err != nil {
+		return err
+	}
+
+	if local {
+		// local messages don't count towards the size limits
+		mp.localAddrs[m.Message.From] = struct{}{}
+		return nil
+	}
+
+	if evicted := mp.pruneLocked(cfg, m); evicted {
+		return xerrors.Errorf("message %s evicted right after being added: %w", m.Cid(), ErrMpoolFull)
+	}
+	return nil
+}
+
+// remoteSizeLocked returns the number of pending messages from remote
+// senders. Must be called with mp.lk held.
+func (mp *MessagePool) remoteSizeLocked() int {
+	size := mp.size
+	for a := range mp.localAddrs {
+		if mset, ok := mp.pending[a]; ok {
+			size -= len(mset.msgs)
+		}
+	}
+	return size
+}
+
+// pruneLocked evicts the lowest gas price remote messages once the remote
+// messages grow past the configured high watermark, down to the low
+// watermark, so the pool is only scanned once every SizeLimitHigh-SizeLimitLow
+// remote additions. Evicting a message also evicts all messages with a higher
+// nonce from the same sender, as those can't be included without it. Returns
+// whether the incoming message was evicted. Must be called with mp.lk held.
+func (mp *MessagePool) pruneLocked(cfg *Config, incoming *types.SignedMessage) bool {
+	if cfg.SizeLimitHigh <= 0 || mp.size <= cfg.SizeLimitHigh {
+		return false
+	}
+
+	remote := mp.remoteSizeLocked()
+	if remote <= cfg.SizeLimitHigh {
+		return false
+	}
+
+	var candidates []*types.SignedMessage
+	for a, mset := range mp.pending {
+		if _, local := mp.localAddrs[a]; local {
+			continue
+		}
+		for _, m := range mset.msgs {
+			candidates = append(candidates, m)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if c := types.BigCmp(candidates[i].Message.GasPrice, candidates[j].Message.GasPrice); c != 0 {
+			return c < 0
+		}
+		return candidates[i].Message.Nonce > candidates[j].Message.Nonce
+	})
+
+	var evicted int
+	var evictedIncoming bool
+	for _, m := range candidates {
+		if remote <= cfg.SizeLimitLow {
+			break
+		}
+
+		mset, ok := mp.pending[m.Message.From]
+		if !ok {
+			continue
+		}
+		if _, ok := mset.msgs[m.Message.Nonce]; !ok {
+			continue // already evicted along with a lower nonce
+		}
+
+		for nonce, pm := range mset.msgs {
+			if nonce >= m.Message.Nonce {
+				if pm.Cid() == incoming.Cid() {
+					evictedIncoming = true
+				}
+				mp.removeLocked(m.Message.From, nonce)
+				evicted++
+				remote--
+			}
+		}
+	}
+
+	log.Infow("pruned mpool", "evicted", evicted, "remaining", mp.size)
+	return evictedIncoming
 }
 
 func (mp *MessagePool) addSkipChecks(m *types.SignedMessage) error {
@@ -362,8 +565,15 @@ func (mp *MessagePool) addLocked(m *types.SignedMessage) error {
 		mp.pending[m.Message.From] = mset
 	}
 
+	exms, replacing := mset.msgs[m.Message.Nonce]
 	if err := mset.add(m); err != nil {
-		log.Error(err)
+		return err
+	}
+	if !replacing {
+		mp.size++
+	} else if exms.Cid() != m.Cid() {
+		// the replaced message can't be included anymore, don't republish it
+		mp.removeLocal(exms)
 	}
 
 	mp.changes.Pub(api.MpoolUpdate{
@@ -486,6 +696,10 @@ func (mp *MessagePool) Remove(from address.Address, nonce uint64) {
 	mp.lk.Lock()
 	defer mp.lk.Unlock()
 
+	mp.removeLocked(from, nonce)
+}
+
+func (mp *MessagePool) removeLocked(from address.Address, nonce uint64) {
 	mset, ok := mp.pending[from]
 	if !ok {
 		return
@@ -496,6 +710,9 @@ func (mp *MessagePool) Remove(from address.Address, nonce uint64) {
 			Type:    api.MpoolRemove,
 			Message: m,
 		}, localUpdates)
+
+		mp.removeLocal(m)
+		mp.size--
 	}
 
 	// NB: This deletes any message with the given nonce. This makes sense
@@ -519,6 +736,14 @@ func (mp *MessagePool) Remove(from address.Address, nonce uint64) {
 	}
 }
 
+// PendingCount returns the number of messages in the pool
+func (mp *MessagePool) PendingCount() int {
+	mp.lk.Lock()
+	defer mp.lk.Unlock()
+
+	return mp.size
+}
+
 func (mp *MessagePool) Pending() ([]*types.SignedMessage, *types.TipSet) {
 	mp.curTsLk.Lock()
 	defer mp.curTsLk.Unlock()
@@ -621,6 +846,11 @@ func (mp *MessagePool) HeadChange(revert []*types.TipSet, apply []*types.TipSet)
 		for _, msg := range s {
 			if err := mp.addSkipChecks(msg); err != nil {
 				log.Errorf("Failed to readd message from reorg to mpool: %s", err)
+				continue
+			}
+
+			if err := mp.readdLocal(msg); err != nil {
+				log.Errorf("Failed to persist reverted local message: %s", err)
 			}
 		}
 	}
@@ -628,6 +858,24 @@ func (mp *MessagePool) HeadChange(revert []*types.TipSet, apply []*types.TipSet)
 	return nil
 }
 
+// readdLocal persists a reverted message again if it originated from this node,
+// so that it keeps getting republished until it lands on chain again.
+func (mp *MessagePool) readdLocal(m *types.SignedMessage) error {
+	mp.lk.Lock()
+	defer mp.lk.Unlock()
+
+	if _, local := mp.localAddrs[m.Message.From]; !local {
+		return nil
+	}
+
+	msgb, err := m.Serialize()
+	if err != nil {
+		return err
+	}
+
+	return mp.addLocal(m, msgb)
+}
+
 func (mp *MessagePool) MessagesForBlocks(blks []*types.BlockHeader) ([]*types.SignedMessage, error) {
 	out := make([]*types.SignedMessage, 0)
 
@@ -708,12 +956,18 @@ func (mp *MessagePool) loadLocal() error {
 			return xerrors.Errorf("unmarshaling local message: %w", err)
 		}
 
-		if err := mp.Add(&sm); err != nil {
-			if xerrors.Is(err, ErrNonceTooLow) {
-				continue // todo: drop the message from local cache (if above certain confidence threshold)
+		if err := mp.add(&sm, true); err != nil {
+			if xerrors.Is(err, ErrNonceTooLow) || xerrors.Is(err, ErrReplaceByFeeTooLow) {
+				// the message (or one replacing it) already made it on chain,
+				// or it was replaced by fee, no need to keep republishing it
+				if err := mp.localMsgs.Delete(datastore.NewKey(r.Key)); err != nil {
+					log.Errorf("removing stale local message: %+v", err)
+				}
+				continue
 			}
 
 			log.Errorf("adding local message: %+v", err)
+			continue
 		}
 	}
 
@@ -1,6 +1,7 @@
 package messagepool
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -13,6 +14,7 @@ import (
 	_ "github.com/filecoin-project/lotus/lib/sigs/secp"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
 )
 
 type testMpoolApi struct {
@@ -122,6 +124,27 @@ func assertNonce(t *testing.T, mp *MessagePool, addr address.Address, val uint64
 	}
 }
 
+func mkMessageWithGasPrice(t *testing.T, from, to address.Address, nonce uint64, gasPrice uint64, w *wallet.Wallet) *types.SignedMessage {
+	t.Helper()
+	msg := &types.Message{
+		To:       to,
+		From:     from,
+		Value:    types.NewInt(1),
+		Nonce:    nonce,
+		GasLimit: types.NewInt(1),
+		GasPrice: types.NewInt(gasPrice),
+	}
+
+	sig, err := w.Sign(context.TODO(), from, msg.Cid().Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &types.SignedMessage{
+		Message:   *msg,
+		Signature: *sig,
+	}
+}
+
 func mustAdd(t *testing.T, mp *MessagePool, msg *types.SignedMessage) {
 	t.Helper()
 	if err := mp.Add(msg); err != nil {
@@ -139,7 +162,7 @@ func TestMessagePool(t *testing.T) {
 
 	ds := datastore.NewMapDatastore()
 
-	mp, err := New(tma, ds)
+	mp, err := New(tma, ds, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -180,7 +203,7 @@ func TestRevertMessages(t *testing.T) {
 
 	ds := datastore.NewMapDatastore()
 
-	mp, err := New(tma, ds)
+	mp, err := New(tma, ds, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -225,3 +248,460 @@ func TestRevertMessages(t *testing.T) {
 	}
 
 }
+
+func TestLocalMessagesPersisted(t *testing.T) {
+	tma := newTestMpoolApi()
+
+	w, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds := datastore.NewMapDatastore()
+
+	mp, err := New(tma, ds, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := mock.MkBlock(nil, 1, 1)
+
+	sender, err := w.GenerateKey(types.KTBLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := mock.Address(1001)
+
+	var msgs []*types.SignedMessage
+	for i := 0; i < 2; i++ {
+		msgs = append(msgs, mock.MkMessage(sender, target, uint64(i), w))
+	}
+
+	tma.setStateNonce(sender, 0)
+	for _, m := range msgs {
+		if _, err := mp.Push(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a fresh mpool on the same datastore should pick the messages back up
+	if err := mp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mp, err = New(tma, ds, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, _ := mp.Pending()
+	if len(p) != 2 {
+		t.Fatalf("expected two messages after reload, got %d", len(p))
+	}
+
+	tma.setBlockMessages(a, msgs[0])
+	tma.applyBlock(t, a)
+
+	has, err := mp.localMsgs.Has(datastore.NewKey(string(msgs[0].Cid().Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has {
+		t.Fatal("expected included message to be removed from the local store")
+	}
+
+	has, err = mp.localMsgs.Has(datastore.NewKey(string(msgs[1].Cid().Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !has {
+		t.Fatal("expected pending message to still be in the local store")
+	}
+}
+
+func TestLocalMessagesReloadedPastLimits(t *testing.T) {
+	tma := newTestMpoolApi()
+
+	w, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds := datastore.NewMapDatastore()
+	cfg := &Config{
+		MaxPendingPerActor: 2,
+		MinGasPrice:        types.NewInt(0),
+		SizeLimitHigh:      3,
+		SizeLimitLow:       2,
+	}
+
+	mp, err := New(tma, ds, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender, err := w.GenerateKey(types.KTBLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := mock.Address(1001)
+
+	tma.setStateNonce(sender, 0)
+	for i := 0; i < 4; i++ {
+		if _, err := mp.Push(mock.MkMessage(sender, target, uint64(i), w)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// after a restart with the same limits none of our own messages should
+	// be rejected or evicted
+	if err := mp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mp, err = New(tma, ds, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, _ := mp.Pending()
+	if len(p) != 4 {
+		t.Fatalf("expected all 4 local messages after reload, got %d", len(p))
+	}
+	assertNonce(t, mp, sender, 4)
+}
+
+func TestPendingLimits(t *testing.T) {
+	tma := newTestMpoolApi()
+
+	w, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds := datastore.NewMapDatastore()
+
+	mp, err := New(tma, ds, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mp.SetConfig(&Config{
+		MaxPendingPerActor: 3,
+		MinGasPrice:        types.NewInt(0),
+		SizeLimitHigh:      4,
+		SizeLimitLow:       2,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := w.GenerateKey(types.KTBLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := w.GenerateKey(types.KTBLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := mock.Address(1001)
+
+	for i := 0; i < 3; i++ {
+		mustAdd(t, mp, mock.MkMessage(a, target, uint64(i), w))
+	}
+
+	if err := mp.Add(mock.MkMessage(a, target, 3, w)); !xerrors.Is(err, ErrTooManyPendingMessages) {
+		t.Fatalf("expected ErrTooManyPendingMessages, got %v", err)
+	}
+
+	mustAdd(t, mp, mock.MkMessage(b, target, 0, w))
+
+	// with equal gas prices the highest nonces go first, including the one
+	// just added
+	if err := mp.Add(mock.MkMessage(b, target, 1, w)); !xerrors.Is(err, ErrMpoolFull) {
+		t.Fatalf("expected ErrMpoolFull, got %v", err)
+	}
+
+	p, _ := mp.Pending()
+	if len(p) != 2 {
+		t.Fatalf("expected mpool to be pruned down to 2 messages, got %d", len(p))
+	}
+
+	for _, m := range p {
+		if m.Message.Nonce != 0 {
+			t.Fatalf("expected only the lowest nonce messages to survive, got nonce %d", m.Message.Nonce)
+		}
+	}
+}
+
+func TestMinGasPrice(t *testing.T) {
+	tma := newTestMpoolApi()
+
+	w, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mp, err := New(tma, datastore.NewMapDatastore(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mp.SetConfig(&Config{
+		MinGasPrice: types.NewInt(10),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := w.GenerateKey(types.KTBLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := mock.Address(1001)
+
+	if err := mp.Add(mkMessageWithGasPrice(t, a, target, 0, 9, w)); !xerrors.Is(err, ErrGasPriceTooLow) {
+		t.Fatalf("expected ErrGasPriceTooLow, got %v", err)
+	}
+
+	mustAdd(t, mp, mkMessageWithGasPrice(t, a, target, 0, 10, w))
+
+	if n := mp.PendingCount(); n != 1 {
+		t.Fatalf("expected 1 pending message, got %d", n)
+	}
+}
+
+func TestPruneLowestGasPrice(t *testing.T) {
+	tma := newTestMpoolApi()
+
+	w, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mp, err := New(tma, datastore.NewMapDatastore(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mp.SetConfig(&Config{
+		MinGasPrice:   types.NewInt(0),
+		SizeLimitHigh: 3,
+		SizeLimitLow:  2,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	target := mock.Address(1001)
+	var senders []address.Address
+	for i := 0; i < 4; i++ {
+		a, err := w.GenerateKey(types.KTBLS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		senders = append(senders, a)
+	}
+
+	mustAdd(t, mp, mkMessageWithGasPrice(t, senders[0], target, 0, 5, w))
+	mustAdd(t, mp, mkMessageWithGasPrice(t, senders[1], target, 0, 1, w))
+	mustAdd(t, mp, mkMessageWithGasPrice(t, senders[2], target, 0, 3, w))
+
+	// above the high watermark, the two cheapest messages are evicted
+	mustAdd(t, mp, mkMessageWithGasPrice(t, senders[3], target, 0, 4, w))
+
+	if n := mp.PendingCount(); n != 2 {
+		t.Fatalf("expected 2 pending messages, got %d", n)
+	}
+
+	p, _ := mp.Pending()
+	for _, m := range p {
+		if m.Message.From != senders[0] && m.Message.From != senders[3] {
+			t.Fatalf("expected only the highest gas price messages to remain, got one with gas price %s", m.Message.GasPrice)
+		}
+	}
+
+	// a message cheaper than everything in a full pool is rejected
+	mustAdd(t, mp, mkMessageWithGasPrice(t, senders[1], target, 0, 6, w))
+	if err := mp.Add(mkMessageWithGasPrice(t, senders[2], target, 0, 2, w)); !xerrors.Is(err, ErrMpoolFull) {
+		t.Fatalf("expected ErrMpoolFull, got %v", err)
+	}
+}
+
+func TestReplaceByFee(t *testing.T) {
+	tma := newTestMpoolApi()
+
+	w, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mp, err := New(tma, datastore.NewMapDatastore(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender, err := w.GenerateKey(types.KTSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := mock.Address(1001)
+
+	orig := mkMessageWithGasPrice(t, sender, target, 0, 100, w)
+	mustAdd(t, mp, orig)
+	// adding the same message again is a no-op
+	mustAdd(t, mp, orig)
+
+	if err := mp.Add(mkMessageWithGasPrice(t, sender, target, 0, 124, w)); !xerrors.Is(err, ErrReplaceByFeeTooLow) {
+		t.Fatalf("expected ErrReplaceByFeeTooLow, got %v", err)
+	}
+
+	p, _ := mp.Pending()
+	if len(p) != 1 || p[0].Cid() != orig.Cid() {
+		t.Fatalf("expected the original message to stay pending")
+	}
+
+	repl := mkMessageWithGasPrice(t, sender, target, 0, 125, w)
+	mustAdd(t, mp, repl)
+
+	p, _ = mp.Pending()
+	if len(p) != 1 || p[0].Cid() != repl.Cid() {
+		t.Fatalf("expected the replacement to be the only pending message")
+	}
+	if n := mp.PendingCount(); n != 1 {
+		t.Fatalf("expected 1 pending message, got %d", n)
+	}
+	assertNonce(t, mp, sender, 1)
+}
+
+func TestReplaceByFeeLocal(t *testing.T) {
+	tma := newTestMpoolApi()
+
+	w, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds := datastore.NewMapDatastore()
+
+	mp, err := New(tma, ds, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender, err := w.GenerateKey(types.KTSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := mock.Address(1001)
+
+	orig := mkMessageWithGasPrice(t, sender, target, 0, 100, w)
+	if _, err := mp.Push(orig); err != nil {
+		t.Fatal(err)
+	}
+
+	repl := mkMessageWithGasPrice(t, sender, target, 0, 125, w)
+	if _, err := mp.Push(repl); err != nil {
+		t.Fatal(err)
+	}
+
+	has, err := mp.localMsgs.Has(datastore.NewKey(string(orig.Cid().Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has {
+		t.Fatal("expected replaced message to be removed from the local store")
+	}
+
+	// only the replacement is picked back up after a restart
+	if err := mp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mp, err = New(tma, ds, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, _ := mp.Pending()
+	if len(p) != 1 || p[0].Cid() != repl.Cid() {
+		t.Fatalf("expected the replacement to be the only pending message after reload")
+	}
+}
+
+func TestFailedPushNotLocal(t *testing.T) {
+	tma := newTestMpoolApi()
+
+	w, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mp, err := New(tma, datastore.NewMapDatastore(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mp.SetConfig(&Config{
+		MaxPendingPerActor: 1,
+		MinGasPrice:        types.NewInt(10),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := w.GenerateKey(types.KTBLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := mock.Address(1001)
+
+	if _, err := mp.Push(mkMessageWithGasPrice(t, a, target, 0, 9, w)); !xerrors.Is(err, ErrGasPriceTooLow) {
+		t.Fatalf("expected ErrGasPriceTooLow, got %v", err)
+	}
+
+	// the rejected push doesn't lift the remote sender limits
+	mustAdd(t, mp, mkMessageWithGasPrice(t, a, target, 0, 10, w))
+	if err := mp.Add(mkMessageWithGasPrice(t, a, target, 1, 10, w)); !xerrors.Is(err, ErrTooManyPendingMessages) {
+		t.Fatalf("expected ErrTooManyPendingMessages, got %v", err)
+	}
+}
+
+func TestLocalMessagesNotCountedForPruning(t *testing.T) {
+	tma := newTestMpoolApi()
+
+	w, err := wallet.NewWallet(wallet.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mp, err := New(tma, datastore.NewMapDatastore(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mp.SetConfig(&Config{
+		MinGasPrice:   types.NewInt(0),
+		SizeLimitHigh: 2,
+		SizeLimitLow:  1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	local, err := w.GenerateKey(types.KTBLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := w.GenerateKey(types.KTBLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := mock.Address(1001)
+
+	for i := 0; i < 3; i++ {
+		if _, err := mp.Push(mkMessageWithGasPrice(t, local, target, uint64(i), 1, w)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the pool is over the limits, but only with local messages
+	mustAdd(t, mp, mkMessageWithGasPrice(t, remote, target, 0, 5, w))
+	mustAdd(t, mp, mkMessageWithGasPrice(t, remote, target, 1, 5, w))
+
+	if n := mp.PendingCount(); n != 5 {
+		t.Fatalf("expected 5 pending messages, got %d", n)
+	}
+}
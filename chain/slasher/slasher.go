@@ -0,0 +1,200 @@
+package slasher
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-address"
+	logging "github.com/ipfs/go-log/v2"
+	"go.uber.org/multierr"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sigs"
+)
+
+var log = logging.Logger("slasher")
+
+// PushMessageFunc signs and submits a message, like MpoolPushMessage
+type PushMessageFunc func(context.Context, *types.Message) (*types.SignedMessage, error)
+
+// WorkerFunc returns the worker address of a miner in the state a block
+// with the given parents was mined on
+type WorkerFunc func(ctx context.Context, miner address.Address, parents types.TipSetKey) (address.Address, error)
+
+// HeadHeightFunc returns the height of the current heaviest tipset
+type HeadHeightFunc func() uint64
+
+type minerHeight struct {
+	miner  address.Address
+	height uint64
+}
+
+type minerParents struct {
+	miner   address.Address
+	parents types.TipSetKey
+}
+
+// Slasher watches block headers for consensus faults and reports them to
+// the storage power actor. The reporter receives the slasher reward.
+type Slasher struct {
+	reporter address.Address
+	push     PushMessageFunc
+	worker   WorkerFunc
+	head     HeadHeightFunc
+
+	byHeight  map[minerHeight]*types.BlockHeader
+	byParents map[minerParents]*types.BlockHeader
+	reported  map[minerHeight]struct{}
+
+	// maxHeight is the height of the highest checked block, capped at the
+	// height of the head, blocks older than finality below it are forgotten
+	maxHeight uint64
+}
+
+func New(reporter address.Address, push PushMessageFunc, worker WorkerFunc, head HeadHeightFunc) *Slasher {
+	return &Slasher{
+		reporter: reporter,
+		push:     push,
+		worker:   worker,
+		head:     head,
+
+		byHeight:  map[minerHeight]*types.BlockHeader{},
+		byParents: map[minerParents]*types.BlockHeader{},
+		reported:  map[minerHeight]struct{}{},
+	}
+}
+
+// Run checks blocks from the channel until it is closed or ctx is cancelled
+func (s *Slasher) Run(ctx context.Context, blocks <-chan *types.BlockHeader) {
+	for {
+		select {
+		case b, ok := <-blocks:
+			if !ok {
+				return
+			}
+			if err := s.check(ctx, b); err != nil {
+				log.Errorf("checking block %s: %+v", b.Cid(), err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Slasher) check(ctx context.Context, b *types.BlockHeader) error {
+	hk := minerHeight{miner: b.Miner, height: b.Height}
+	if other, ok := s.byHeight[hk]; ok && other.Cid() == b.Cid() {
+		return nil
+	}
+
+	// only blocks signed by the miner worker can prove a fault, anyone can
+	// gossip a header with a made up miner
+	if err := s.validate(ctx, b); err != nil {
+		return xerrors.Errorf("validating block: %w", err)
+	}
+
+	// a validly signed block can still claim any height, only the chain
+	// moves the pruning boundary forward
+	height := b.Height
+	if head := s.head(); height > head {
+		height = head
+	}
+	if height > s.maxHeight {
+		s.maxHeight = height
+		s.prune()
+	}
+
+	var err error
+
+	if other, ok := s.byHeight[hk]; ok {
+		log.Warnw("double-mining fault", "miner", b.Miner, "height", b.Height, "block1", other.Cid(), "block2", b.Cid())
+		err = multierr.Append(err, s.report(ctx, other, b))
+	} else {
+		s.byHeight[hk] = b
+	}
+
+	pk := minerParents{miner: b.Miner, parents: types.NewTipSetKey(b.Parents...)}
+	if other, ok := s.byParents[pk]; ok && other.Height != b.Height {
+		// the power actor doesn't arbitrate time-offset faults yet, reporting
+		// them would only waste gas until it does
+		log.Warnw("time-offset mining fault", "miner", b.Miner, "block1", other.Cid(), "height1", other.Height, "block2", b.Cid(), "height2", b.Height)
+	} else if !ok {
+		s.byParents[pk] = b
+	}
+
+	return err
+}
+
+func (s *Slasher) validate(ctx context.Context, b *types.BlockHeader) error {
+	worker, err := s.worker(ctx, b.Miner, types.NewTipSetKey(b.Parents...))
+	if err != nil {
+		return xerrors.Errorf("getting worker of miner %s: %w", b.Miner, err)
+	}
+
+	if err := sigs.CheckBlockSignature(b, ctx, worker); err != nil {
+		return xerrors.Errorf("checking block signature: %w", err)
+	}
+
+	return nil
+}
+
+// report submits a double-mining fault, once per miner and height
+func (s *Slasher) report(ctx context.Context, b1, b2 *types.BlockHeader) error {
+	fk := minerHeight{miner: b1.Miner, height: b1.Height}
+	if _, ok := s.reported[fk]; ok {
+		return nil
+	}
+
+	params, aerr := actors.SerializeParams(&actors.ArbitrateConsensusFaultParams{
+		Block1: b1,
+		Block2: b2,
+	})
+	if aerr != nil {
+		return xerrors.Errorf("serializing params: %w", aerr)
+	}
+
+	smsg, err := s.push(ctx, &types.Message{
+		To:       actors.StoragePowerAddress,
+		From:     s.reporter,
+		Value:    types.NewInt(0),
+		GasPrice: types.NewInt(0),
+		GasLimit: types.NewInt(1000000),
+		Method:   actors.SPAMethods.ArbitrateConsensusFault,
+		Params:   params,
+	})
+	if err != nil {
+		return xerrors.Errorf("pushing fault report: %w", err)
+	}
+
+	// only mark the fault as reported once the message is out, so that a
+	// failed push is retried with the next faulty block
+	s.reported[fk] = struct{}{}
+	log.Infow("reported consensus fault", "miner", b1.Miner, "message", smsg.Cid())
+	return nil
+}
+
+// prune forgets blocks older than finality
+func (s *Slasher) prune() {
+	if s.maxHeight < build.Finality {
+		return
+	}
+	min := s.maxHeight - build.Finality
+
+	for k := range s.byHeight {
+		if k.height < min {
+			delete(s.byHeight, k)
+		}
+	}
+	for k, b := range s.byParents {
+		if b.Height < min {
+			delete(s.byParents, k)
+		}
+	}
+	for k := range s.reported {
+		if k.height < min {
+			delete(s.reported, k)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package slasher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sigs"
+	_ "github.com/filecoin-project/lotus/lib/sigs/secp"
+)
+
+type testMiner struct {
+	addr   address.Address
+	worker address.Address
+	pk     []byte
+}
+
+func newTestMiner(t *testing.T, id uint64) *testMiner {
+	maddr, err := address.NewIDAddress(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := sigs.Generate(types.KTSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := sigs.ToPublic(types.KTSecp256k1, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker, err := address.NewSecp256k1Address(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testMiner{addr: maddr, worker: worker, pk: pk}
+}
+
+func fakeCid(t *testing.T, s int) cid.Cid {
+	t.Helper()
+	c, err := cid.NewPrefixV1(cid.Raw, mh.IDENTITY).Sum([]byte(fmt.Sprintf("%d", s)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func (m *testMiner) block(t *testing.T, height uint64, parent int, ts uint64) *types.BlockHeader {
+	t.Helper()
+	c := fakeCid(t, 1)
+	b := &types.BlockHeader{
+		Miner:                 m.addr,
+		Parents:               []cid.Cid{fakeCid(t, parent)},
+		ParentWeight:          types.NewInt(0),
+		Height:                height,
+		ParentStateRoot:       c,
+		ParentMessageReceipts: c,
+		Messages:              c,
+		BLSAggregate:          types.Signature{Type: types.KTBLS},
+		Timestamp:             ts,
+	}
+
+	sb, err := b.SigningBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.BlockSig, err = sigs.Sign(types.KTSecp256k1, m.pk, sb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+type testPusher struct {
+	fail bool
+	msgs []*types.Message
+}
+
+func (p *testPusher) push(ctx context.Context, msg *types.Message) (*types.SignedMessage, error) {
+	if p.fail {
+		return nil, errors.New("push failed")
+	}
+	p.msgs = append(p.msgs, msg)
+	return &types.SignedMessage{Message: *msg}, nil
+}
+
+func newTestSlasher(t *testing.T, m *testMiner) (*Slasher, *testPusher) {
+	reporter, err := address.NewIDAddress(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &testPusher{}
+	worker := func(ctx context.Context, miner address.Address, parents types.TipSetKey) (address.Address, error) {
+		if miner != m.addr {
+			return address.Undef, errors.New("not a miner")
+		}
+		return m.worker, nil
+	}
+	head := func() uint64 {
+		return 1 << 20
+	}
+	return New(reporter, p.push, worker, head), p
+}
+
+func TestDoubleMining(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMiner(t, 1000)
+	s, p := newTestSlasher(t, m)
+
+	if err := s.check(ctx, m.block(t, 10, 1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.msgs) != 0 {
+		t.Fatal("expected no report for a single block")
+	}
+
+	if err := s.check(ctx, m.block(t, 10, 1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.msgs) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(p.msgs))
+	}
+	if p.msgs[0].Method != actors.SPAMethods.ArbitrateConsensusFault {
+		t.Fatalf("unexpected method %d", p.msgs[0].Method)
+	}
+
+	// the miner was already reported
+	if err := s.check(ctx, m.block(t, 10, 1, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.msgs) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(p.msgs))
+	}
+}
+
+func TestDoubleMiningReportedPerHeight(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMiner(t, 1000)
+	s, p := newTestSlasher(t, m)
+
+	for _, h := range []uint64{10, 20} {
+		if err := s.check(ctx, m.block(t, h, int(h), 1)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.check(ctx, m.block(t, h, int(h), 2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(p.msgs) != 2 {
+		t.Fatalf("expected a report for each height, got %d", len(p.msgs))
+	}
+
+	// reports older than finality are forgotten along with the blocks
+	if err := s.check(ctx, m.block(t, 20+build.Finality, 30, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.reported) != 1 {
+		t.Fatalf("expected 1 remembered report after pruning, got %d", len(s.reported))
+	}
+}
+
+func TestFutureBlockDoesNotPrune(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMiner(t, 1000)
+	s, p := newTestSlasher(t, m)
+	s.head = func() uint64 {
+		return 20
+	}
+
+	if err := s.check(ctx, m.block(t, 10, 1, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a block far above the head doesn't make the first one older than
+	// finality
+	if err := s.check(ctx, m.block(t, 10+2*build.Finality, 2, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if s.maxHeight != 20 {
+		t.Fatalf("expected the max height to be capped at the head, got %d", s.maxHeight)
+	}
+
+	if err := s.check(ctx, m.block(t, 10, 1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.msgs) != 1 {
+		t.Fatalf("expected the double-mining fault to be reported, got %d reports", len(p.msgs))
+	}
+}
+
+func TestTimeOffsetMining(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMiner(t, 1000)
+	s, p := newTestSlasher(t, m)
+
+	if err := s.check(ctx, m.block(t, 10, 1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.check(ctx, m.block(t, 11, 1, 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	// detected, but the power actor can't arbitrate them yet
+	if len(p.msgs) != 0 {
+		t.Fatalf("expected no reports, got %d", len(p.msgs))
+	}
+}
+
+func TestInvalidBlocksIgnored(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMiner(t, 1000)
+	s, p := newTestSlasher(t, m)
+
+	// signed by someone other than the worker
+	other := newTestMiner(t, 1000)
+	if err := s.check(ctx, other.block(t, 10, 1, 1)); err == nil {
+		t.Fatal("expected an error for a block with a bad signature")
+	}
+
+	// not a miner at all
+	unknown := newTestMiner(t, 1001)
+	if err := s.check(ctx, unknown.block(t, 10, 1, 1)); err == nil {
+		t.Fatal("expected an error for a block from an unknown miner")
+	}
+
+	if err := s.check(ctx, m.block(t, 10, 1, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.msgs) != 0 {
+		t.Fatalf("invalid blocks must not be reported, got %d reports", len(p.msgs))
+	}
+}
+
+func TestReportRetriedAfterPushFailure(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMiner(t, 1000)
+	s, p := newTestSlasher(t, m)
+
+	if err := s.check(ctx, m.block(t, 10, 1, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	p.fail = true
+	if err := s.check(ctx, m.block(t, 10, 1, 2)); err == nil {
+		t.Fatal("expected the push error")
+	}
+
+	p.fail = false
+	if err := s.check(ctx, m.block(t, 10, 1, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.msgs) != 1 {
+		t.Fatalf("expected the fault to be reported after the failed push, got %d reports", len(p.msgs))
+	}
+}
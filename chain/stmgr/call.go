@@ -1,10 +1,12 @@
 package stmgr
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 
 	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
 	"go.opencensus.io/trace"
 	"golang.org/x/xerrors"
 
@@ -16,6 +18,16 @@ import (
 )
 
 func (sm *StateManager) CallRaw(ctx context.Context, msg *types.Message, bstate cid.Cid, r vm.Rand, bheight uint64) (*api.MethodCall, error) {
+	sm.pruneLk.RLock()
+	defer sm.pruneLk.RUnlock()
+
+	return sm.callRaw(ctx, msg, bstate, r, bheight, false)
+}
+
+// callRaw executes msg on top of bstate. Callers have to hold pruneLk, it
+// isn't taken here so that state computation, which already holds it, can
+// make calls without locking again.
+func (sm *StateManager) callRaw(ctx context.Context, msg *types.Message, bstate cid.Cid, r vm.Rand, bheight uint64, tracing bool) (*api.MethodCall, error) {
 	ctx, span := trace.StartSpan(ctx, "statemanager.CallRaw")
 	defer span.End()
 
@@ -23,6 +35,9 @@ func (sm *StateManager) CallRaw(ctx context.Context, msg *types.Message, bstate
 	if err != nil {
 		return nil, xerrors.Errorf("failed to set up vm: %w", err)
 	}
+	if tracing {
+		vmi.EnableTracing()
+	}
 
 	if msg.GasLimit == types.EmptyInt {
 		msg.GasLimit = types.NewInt(10000000000)
@@ -60,14 +75,29 @@ func (sm *StateManager) CallRaw(ctx context.Context, msg *types.Message, bstate
 		errs = ret.ActorErr.Error()
 		log.Warnf("chain call failed: %s", ret.ActorErr)
 	}
-	return &api.MethodCall{
+	mc := &api.MethodCall{
 		MessageReceipt: ret.MessageReceipt,
 		Error:          errs,
-	}, nil
-
+	}
+	if tracing {
+		mc.Decoded = decodeReturn(ret.Return)
+		mc.ExecutionTrace = ret.ExecutionTrace
+	}
+	return mc, nil
 }
 
 func (sm *StateManager) Call(ctx context.Context, msg *types.Message, ts *types.TipSet) (*api.MethodCall, error) {
+	return sm.call(ctx, msg, ts, false)
+}
+
+// CallWithTrace is like Call, but also records the execution trace of the
+// message and decodes its return value. Tracing slows execution down,
+// internal calls should use Call.
+func (sm *StateManager) CallWithTrace(ctx context.Context, msg *types.Message, ts *types.TipSet) (*api.MethodCall, error) {
+	return sm.call(ctx, msg, ts, true)
+}
+
+func (sm *StateManager) call(ctx context.Context, msg *types.Message, ts *types.TipSet, tracing bool) (*api.MethodCall, error) {
 	if ts == nil {
 		ts = sm.cs.GetHeaviestTipSet()
 	}
@@ -76,7 +106,30 @@ func (sm *StateManager) Call(ctx context.Context, msg *types.Message, ts *types.
 
 	r := store.NewChainRand(sm.cs, ts.Cids(), ts.Height())
 
-	return sm.CallRaw(ctx, msg, state, r, ts.Height())
+	sm.pruneLk.RLock()
+	defer sm.pruneLk.RUnlock()
+
+	return sm.callRaw(ctx, msg, state, r, ts.Height(), tracing)
+}
+
+// decodeReturn decodes a return value encoded as a CBOR object. Some actor
+// methods return raw bytes instead, which are only accepted when they happen
+// to be a complete, canonically encoded CBOR object.
+func decodeReturn(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+
+	var out interface{}
+	if err := cbor.DecodeInto(b, &out); err != nil {
+		return nil
+	}
+
+	enc, err := cbor.DumpObject(out)
+	if err != nil || !bytes.Equal(enc, b) {
+		return nil
+	}
+	return out
 }
 
 var errHaltExecution = fmt.Errorf("halt")
@@ -85,6 +138,9 @@ func (sm *StateManager) Replay(ctx context.Context, ts *types.TipSet, mcid cid.C
 	var outm *types.Message
 	var outr *vm.ApplyRet
 
+	sm.pruneLk.RLock()
+	defer sm.pruneLk.RUnlock()
+
 	_, _, err := sm.computeTipSetState(ctx, ts.Blocks(), func(c cid.Cid, m *types.Message, ret *vm.ApplyRet) error {
 		if c == mcid {
 			outm = m
@@ -99,3 +155,34 @@ func (sm *StateManager) Replay(ctx context.Context, ts *types.TipSet, mcid cid.C
 
 	return outm, outr, nil
 }
+
+// ReplayTipSet executes the messages included in ts and returns the results
+// of each, along with execution traces
+func (sm *StateManager) ReplayTipSet(ctx context.Context, ts *types.TipSet) ([]*api.ReplayResults, error) {
+	var out []*api.ReplayResults
+
+	sm.pruneLk.RLock()
+	defer sm.pruneLk.RUnlock()
+
+	_, _, err := sm.computeTipSetState(ctx, ts.Blocks(), func(c cid.Cid, m *types.Message, ret *vm.ApplyRet) error {
+		var errstr string
+		if ret.ActorErr != nil {
+			errstr = ret.ActorErr.Error()
+		}
+
+		rct := ret.MessageReceipt
+		out = append(out, &api.ReplayResults{
+			MsgCid:         c,
+			Msg:            m,
+			Receipt:        &rct,
+			Error:          errstr,
+			ExecutionTrace: ret.ExecutionTrace,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("executing tipset: %w", err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,208 @@
+package stmgr
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/build"
+)
+
+type PruneResult struct {
+	// Roots is the number of state trees (and receipt sets) pruned
+	Roots int
+	// Blocks is the number of blocks removed from the blockstore
+	Blocks int
+	// Bytes is the amount of reclaimed space
+	Bytes uint64
+}
+
+// PruneState removes state trees and receipts of tipsets older than retain
+// epochs from the blockstore. Block headers and messages are kept. Message
+// execution is paused while pruning, writes to the chain blockstore only
+// while unreachable state is deleted.
+// Only state between the boundary of the previous run and the new one is
+// visited for removal.
+func (sm *StateManager) PruneState(ctx context.Context, retain uint64) (*PruneResult, error) {
+	if retain < build.Finality {
+		return nil, xerrors.Errorf("must retain at least %d epochs of state, asked for %d", build.Finality, retain)
+	}
+
+	sm.pruneLk.Lock()
+	defer sm.pruneLk.Unlock()
+
+	bs := sm.cs.Blockstore()
+	head := sm.cs.GetHeaviestTipSet()
+	if head.Height() <= retain {
+		return &PruneResult{}, nil
+	}
+	boundary := head.Height() - retain
+
+	prunedTo, err := sm.cs.GetPrunedHeight()
+	if err != nil {
+		return nil, err
+	}
+	if prunedTo >= boundary {
+		return &PruneResult{}, nil
+	}
+
+	// everything reachable from retained state has to stay, including
+	// computed states not yet referenced by any block header, and the
+	// genesis state
+	keep := cid.NewSet()
+	var old []cid.Cid
+
+	sm.stlk.Lock()
+	for _, roots := range sm.stCache {
+		for _, c := range roots {
+			if err := walkState(bs, c, keep, nil); err != nil {
+				sm.stlk.Unlock()
+				return nil, xerrors.Errorf("marking cached state: %w", err)
+			}
+		}
+	}
+	sm.stlk.Unlock()
+
+	gen, err := sm.cs.GetGenesis()
+	if err != nil {
+		return nil, xerrors.Errorf("loading genesis: %w", err)
+	}
+	for _, c := range []cid.Cid{gen.ParentStateRoot, gen.ParentMessageReceipts} {
+		if err := walkState(bs, c, keep, nil); err != nil {
+			return nil, xerrors.Errorf("marking genesis state: %w", err)
+		}
+	}
+
+	for ts := head; ts.Height() >= prunedTo; {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		for _, b := range ts.Blocks() {
+			roots := []cid.Cid{b.ParentStateRoot, b.ParentMessageReceipts}
+			if ts.Height() >= boundary {
+				for _, c := range roots {
+					if err := walkState(bs, c, keep, nil); err != nil {
+						return nil, xerrors.Errorf("marking state at height %d: %w", ts.Height(), err)
+					}
+				}
+				continue
+			}
+
+			old = append(old, roots...)
+		}
+
+		if ts.Height() == 0 {
+			break
+		}
+
+		var err error
+		ts, err = sm.cs.LoadTipSet(ts.Parents())
+		if err != nil {
+			return nil, xerrors.Errorf("loading parent tipset: %w", err)
+		}
+	}
+
+	// new state is only written by message execution, which is paused, so
+	// the marked set stays valid. Other writers are only held off while
+	// deleting.
+	defer sm.cs.GCLocker().GCLock().Unlock()
+
+	// anything reachable from old roots and not from retained ones can go
+	res := &PruneResult{}
+	seen := cid.NewSet()
+	for _, root := range old {
+		if keep.Has(root) || !seen.Visit(root) {
+			continue
+		}
+
+		var removed int
+		err := walkState(bs, root, seen, func(c cid.Cid) error {
+			if keep.Has(c) {
+				return errSkip
+			}
+
+			blk, err := bs.Get(c)
+			if err != nil {
+				return err
+			}
+
+			if err := bs.DeleteBlock(c); err != nil {
+				return err
+			}
+
+			removed++
+			res.Bytes += uint64(len(blk.RawData()))
+			return nil
+		})
+		if err != nil {
+			return nil, xerrors.Errorf("pruning state root %s: %w", root, err)
+		}
+
+		if removed > 0 {
+			res.Roots++
+			res.Blocks += removed
+		}
+	}
+
+	if err := sm.cs.SetPrunedHeight(boundary); err != nil {
+		return nil, err
+	}
+
+	log.Infow("pruned chain state", "from", prunedTo, "boundary", boundary, "roots", res.Roots, "blocks", res.Blocks, "bytes", res.Bytes)
+	return res, nil
+}
+
+var errSkip = xerrors.New("skip")
+
+// walkState visits the DAG under root, calling cb for every node before its
+// links are read. Nodes already in the visited set, missing nodes and nodes
+// for which cb returns errSkip are not descended into.
+func walkState(bs blockstore.Blockstore, root cid.Cid, visited *cid.Set, cb func(cid.Cid) error) error {
+	if root.Prefix().Codec != cid.DagCBOR {
+		visited.Add(root)
+		return nil
+	}
+
+	blk, err := bs.Get(root)
+	if err == blockstore.ErrNotFound {
+		// pruned before
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	visited.Add(root)
+
+	if cb != nil {
+		if err := cb(root); err != nil {
+			if err == errSkip {
+				return nil
+			}
+			return err
+		}
+	}
+
+	links, err := cbg.ScanForLinks(bytes.NewReader(blk.RawData()))
+	if err != nil {
+		return xerrors.Errorf("scanning for links (cid=%s): %w", root, err)
+	}
+
+	for _, c := range links {
+		if visited.Has(c) {
+			continue
+		}
+		if err := walkState(bs, c, visited, cb); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
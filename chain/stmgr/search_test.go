@@ -0,0 +1,120 @@
+package stmgr_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/lotus/chain/gen"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/types"
+	_ "github.com/filecoin-project/lotus/lib/sigs/bls"
+	_ "github.com/filecoin-project/lotus/lib/sigs/secp"
+)
+
+func TestSearchForMessageLookback(t *testing.T) {
+	cg, err := gen.NewGenerator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tipsets []*gen.MinedTipSet
+	for i := 0; i < 6; i++ {
+		mts, err := cg.NextTipSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tipsets = append(tipsets, mts)
+	}
+
+	cs := cg.ChainStore()
+	head := tipsets[len(tipsets)-1].TipSet.TipSet()
+	if err := cs.SetHead(head); err != nil {
+		t.Fatal(err)
+	}
+	sm := stmgr.NewStateManager(cs)
+	ctx := context.TODO()
+
+	// executed, and its receipt stored, by the next tipset
+	m := tipsets[1].Messages[0]
+	mcid := m.Cid()
+	if m.Signature.Type == types.KTBLS {
+		mcid = m.Message.Cid()
+	}
+	execTs := tipsets[2].TipSet.TipSet()
+
+	ts, r, err := sm.SearchForMessage(ctx, mcid, stmgr.LookbackNoLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts == nil || !ts.Equals(execTs) || r == nil {
+		t.Fatalf("expected message to be found in tipset at height %d", execTs.Height())
+	}
+
+	ts, r, err = sm.SearchForMessage(ctx, mcid, head.Height()-execTs.Height())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts == nil || r == nil {
+		t.Fatal("expected message within the lookback to be found")
+	}
+
+	// older than the lookback
+	ts, r, err = sm.SearchForMessage(ctx, mcid, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts != nil || r != nil {
+		t.Fatal("expected message older than the lookback not to be found")
+	}
+}
+
+func TestSearchForMessageCancelled(t *testing.T) {
+	cg, err := gen.NewGenerator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var head *types.TipSet
+	var first *types.SignedMessage
+	for i := 0; i < 4; i++ {
+		mts, err := cg.NextTipSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		head = mts.TipSet.TipSet()
+		if first == nil {
+			first = mts.Messages[0]
+		}
+	}
+
+	cs := cg.ChainStore()
+	if err := cs.SetHead(head); err != nil {
+		t.Fatal(err)
+	}
+	sm := stmgr.NewStateManager(cs)
+
+	// a message reusing the nonce of one on chain, finding the other message
+	// when walking back makes the search fail
+	msg := first.Message
+	msg.Value = types.NewInt(1000)
+	mcid, err := cs.PutMessage(&msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := sm.SearchForMessage(context.TODO(), mcid, stmgr.LookbackNoLimit); err == nil {
+		t.Fatal("expected the search to find the conflicting message")
+	}
+
+	// a search that timed out reports the message as not found
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	ts, r, err := sm.SearchForMessage(ctx, mcid, stmgr.LookbackNoLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts != nil || r != nil {
+		t.Fatal("expected a cancelled search not to find the message")
+	}
+}
@@ -32,6 +32,13 @@ type StateManager struct {
 	compWait map[string]chan struct{}
 	stlk     sync.Mutex
 	newVM    func(cid.Cid, uint64, vm.Rand, address.Address, blockstore.Blockstore, *types.VMSyscalls) (*vm.VM, error)
+
+	// pruneLk is held for writing while old state is being pruned. Anything
+	// executing messages holds it for reading, state computation until the
+	// result is cached. Callers of computeTipSetState and callRaw have to
+	// hold it, it is only taken at the entry points as taking the read lock
+	// again would deadlock behind a waiting PruneState.
+	pruneLk sync.RWMutex
 }
 
 func NewStateManager(cs *store.ChainStore) *StateManager {
@@ -80,6 +87,12 @@ func (sm *StateManager) TipSetState(ctx context.Context, ts *types.TipSet) (st c
 	ch := make(chan struct{})
 	sm.compWait[ck] = ch
 
+	sm.stlk.Unlock()
+
+	// pruning treats cached states as retained, so it has to wait until the
+	// computed state is in the cache
+	sm.pruneLk.RLock()
+
 	defer func() {
 		sm.stlk.Lock()
 		delete(sm.compWait, ck)
@@ -87,11 +100,10 @@ func (sm *StateManager) TipSetState(ctx context.Context, ts *types.TipSet) (st c
 			sm.stCache[ck] = []cid.Cid{st, rec}
 		}
 		sm.stlk.Unlock()
+		sm.pruneLk.RUnlock()
 		close(ch)
 	}()
 
-	sm.stlk.Unlock()
-
 	if ts.Height() == 0 {
 		// NB: This is here because the process that executes blocks requires that the
 		// block miner reference a valid miner in the state tree. Unless we create some
@@ -108,6 +120,17 @@ func (sm *StateManager) TipSetState(ctx context.Context, ts *types.TipSet) (st c
 	return st, rec, nil
 }
 
+// SetTrustedState records the state and receipts resulting from executing ts
+// without running its messages, for tipsets whose children are trusted, e.g.
+// because they are covered by a checkpoint. TipSetState returns them from then
+// on.
+func (sm *StateManager) SetTrustedState(ts *types.TipSet, st, rec cid.Cid) {
+	sm.stlk.Lock()
+	defer sm.stlk.Unlock()
+
+	sm.stCache[cidsToKey(ts.Cids())] = []cid.Cid{st, rec}
+}
+
 func (sm *StateManager) computeTipSetState(ctx context.Context, blks []*types.BlockHeader, cb func(cid.Cid, *types.Message, *vm.ApplyRet) error) (cid.Cid, cid.Cid, error) {
 	ctx, span := trace.StartSpan(ctx, "computeTipSetState")
 	defer span.End()
@@ -147,6 +170,12 @@ func (sm *StateManager) computeTipSetState(ctx context.Context, blks []*types.Bl
 		return cid.Undef, cid.Undef, xerrors.Errorf("instantiating VM failed: %w", err)
 	}
 
+	if cb != nil {
+		// callbacks are only passed when inspecting execution, make the
+		// results as detailed as possible
+		vmi.EnableTracing()
+	}
+
 	netact, err := vmi.StateTree().GetActor(actors.NetworkAddress)
 	if err != nil {
 		return cid.Undef, cid.Undef, xerrors.Errorf("failed to get network actor: %w", err)
@@ -159,7 +188,7 @@ func (sm *StateManager) computeTipSetState(ctx context.Context, blks []*types.Bl
 		}
 		vmi.SetBlockMiner(b.Miner)
 
-		owner, err := GetMinerOwner(ctx, sm, pstate, b.Miner)
+		owner, err := getMinerOwner(ctx, sm, pstate, b.Miner)
 		if err != nil {
 			return cid.Undef, cid.Undef, xerrors.Errorf("failed to get owner for miner %s: %w", b.Miner, err)
 		}
@@ -386,7 +415,15 @@ func (sm *StateManager) GetReceipt(ctx context.Context, msg cid.Cid, ts *types.T
 		return nil, fmt.Errorf("failed to load message: %w", err)
 	}
 
-	r, err := sm.tipsetExecutedMessage(ts, msg, m.VMMessage())
+	_, r, err := sm.cs.LookupMsgOnChain(ctx, msg, ts)
+	if err != nil {
+		return nil, xerrors.Errorf("looking up message in index: %w", err)
+	}
+	if r != nil {
+		return r, nil
+	}
+
+	r, err = sm.tipsetExecutedMessage(ts, msg, m.VMMessage())
 	if err != nil {
 		return nil, err
 	}
@@ -395,7 +432,7 @@ func (sm *StateManager) GetReceipt(ctx context.Context, msg cid.Cid, ts *types.T
 		return r, nil
 	}
 
-	_, r, err = sm.searchBackForMsg(ctx, ts, m)
+	_, r, err = sm.searchBackForMsg(ctx, ts, m, LookbackNoLimit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to look back through chain for message: %w", err)
 	}
@@ -427,7 +464,15 @@ func (sm *StateManager) WaitForMessage(ctx context.Context, mcid cid.Cid) (*type
 		return nil, nil, fmt.Errorf("expected current head on SHC stream (got %s)", head[0].Type)
 	}
 
-	r, err := sm.tipsetExecutedMessage(head[0].Val, mcid, msg.VMMessage())
+	its, r, err := sm.cs.LookupMsgOnChain(ctx, mcid, head[0].Val)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("looking up message in index: %w", err)
+	}
+	if r != nil {
+		return its, r, nil
+	}
+
+	r, err = sm.tipsetExecutedMessage(head[0].Val, mcid, msg.VMMessage())
 	if err != nil {
 		return nil, nil, err
 	}
@@ -440,7 +485,7 @@ func (sm *StateManager) WaitForMessage(ctx context.Context, mcid cid.Cid) (*type
 	var backRcp *types.MessageReceipt
 	backSearchWait := make(chan struct{})
 	go func() {
-		fts, r, err := sm.searchBackForMsg(ctx, head[0].Val, msg)
+		fts, r, err := sm.searchBackForMsg(ctx, head[0].Val, msg, LookbackNoLimit)
 		if err != nil {
 			log.Warnf("failed to look back through chain for message: %w", err)
 			return
@@ -482,11 +527,58 @@ func (sm *StateManager) WaitForMessage(ctx context.Context, mcid cid.Cid) (*type
 	}
 }
 
-func (sm *StateManager) searchBackForMsg(ctx context.Context, from *types.TipSet, m store.ChainMsg) (*types.TipSet, *types.MessageReceipt, error) {
+// LookbackNoLimit makes message searches go back to genesis
+const LookbackNoLimit = 0
+
+// SearchForMessage looks up the tipset in which the message was executed and
+// its receipt, without waiting. Messages executed more than lookback epochs
+// before the head aren't reported, and older chain history isn't searched.
+// Both results are nil when the message wasn't found, or the search was
+// cancelled.
+func (sm *StateManager) SearchForMessage(ctx context.Context, mcid cid.Cid, lookback uint64) (*types.TipSet, *types.MessageReceipt, error) {
+	head := sm.cs.GetHeaviestTipSet()
+
+	ts, r, err := sm.cs.LookupMsgOnChain(ctx, mcid, head)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("looking up message in index: %w", err)
+	}
+	if r != nil {
+		if ts.Height() < lookbackLimit(head, lookback) {
+			return nil, nil, nil
+		}
+		return ts, r, nil
+	}
+
+	msg, err := sm.cs.GetCMessage(mcid)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to load message: %w", err)
+	}
+
+	r, err = sm.tipsetExecutedMessage(head, mcid, msg.VMMessage())
+	if err != nil {
+		return nil, nil, err
+	}
+	if r != nil {
+		return head, r, nil
+	}
+
+	return sm.searchBackForMsg(ctx, head, msg, lookback)
+}
+
+// lookbackLimit returns the lowest height searched for messages from head
+func lookbackLimit(head *types.TipSet, lookback uint64) uint64 {
+	if lookback == LookbackNoLimit || head.Height() <= lookback {
+		return 0
+	}
+	return head.Height() - lookback
+}
+
+func (sm *StateManager) searchBackForMsg(ctx context.Context, from *types.TipSet, m store.ChainMsg, lookback uint64) (*types.TipSet, *types.MessageReceipt, error) {
+	limit := lookbackLimit(from, lookback)
 
 	cur := from
 	for {
-		if cur.Height() == 0 {
+		if cur.Height() == 0 || cur.Height() <= limit {
 			// it ain't here!
 			return nil, nil, nil
 		}
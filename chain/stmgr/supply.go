@@ -0,0 +1,154 @@
+package stmgr
+
+import (
+	"bytes"
+	"context"
+
+	hamt "github.com/ipfs/go-hamt-ipld"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/state"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// GetCirculatingSupply computes the FIL supply breakdown from actor state at
+// the given tipset
+func GetCirculatingSupply(ctx context.Context, sm *StateManager, ts *types.TipSet) (api.CirculatingSupply, error) {
+	if ts == nil {
+		ts = sm.cs.GetHeaviestTipSet()
+	}
+
+	gen, err := sm.cs.GetGenesis()
+	if err != nil {
+		return api.CirculatingSupply{}, xerrors.Errorf("loading genesis: %w", err)
+	}
+
+	st, _, err := sm.TipSetState(ctx, ts)
+	if err != nil {
+		return api.CirculatingSupply{}, xerrors.Errorf("computing tipset state: %w", err)
+	}
+
+	cst := hamt.CSTFromBstore(sm.cs.Blockstore())
+
+	// block rewards are paid out of the network actor, whatever it held
+	// at genesis was not allocated
+	genState, err := state.LoadStateTree(cst, gen.ParentStateRoot)
+	if err != nil {
+		return api.CirculatingSupply{}, xerrors.Errorf("loading genesis state: %w", err)
+	}
+	genNet, err := genState.GetActor(actors.NetworkAddress)
+	if err != nil {
+		return api.CirculatingSupply{}, xerrors.Errorf("loading genesis network actor: %w", err)
+	}
+
+	stree, err := state.LoadStateTree(cst, st)
+	if err != nil {
+		return api.CirculatingSupply{}, xerrors.Errorf("loading state tree: %w", err)
+	}
+	netAct, err := stree.GetActor(actors.NetworkAddress)
+	if err != nil {
+		return api.CirculatingSupply{}, xerrors.Errorf("loading network actor: %w", err)
+	}
+	burnt, err := stree.GetActor(actors.BurntFundsAddress)
+	if err != nil {
+		return api.CirculatingSupply{}, xerrors.Errorf("loading burnt funds actor: %w", err)
+	}
+
+	vesting := types.NewInt(0)
+	pledge := types.NewInt(0)
+
+	root, err := hamt.LoadNode(ctx, cst, st)
+	if err != nil {
+		return api.CirculatingSupply{}, err
+	}
+
+	err = root.ForEach(ctx, func(k string, val interface{}) error {
+		var act types.Actor
+		if err := act.UnmarshalCBOR(bytes.NewReader(val.(*cbg.Deferred).Raw)); err != nil {
+			return err
+		}
+
+		switch act.Code {
+		case actors.MultisigCodeCid:
+			var msas actors.MultiSigActorState
+			if err := cst.Get(ctx, act.Head, &msas); err != nil {
+				return xerrors.Errorf("loading multisig state: %w", err)
+			}
+			vesting = types.BigAdd(vesting, msas.AmountLocked(ts.Height()))
+		case actors.StorageMinerCodeCid, actors.StorageMiner2CodeCid:
+			var mas actors.StorageMinerActorState
+			if err := cst.Get(ctx, act.Head, &mas); err != nil {
+				return xerrors.Errorf("loading miner state: %w", err)
+			}
+			locked := actors.CollateralForPower(mas.Power)
+			if act.Balance.LessThan(locked) {
+				locked = act.Balance
+			}
+			pledge = types.BigAdd(pledge, locked)
+		}
+		return nil
+	})
+	if err != nil {
+		return api.CirculatingSupply{}, xerrors.Errorf("iterating actors: %w", err)
+	}
+
+	deals, err := lockedDealFunds(ctx, cst, stree)
+	if err != nil {
+		return api.CirculatingSupply{}, err
+	}
+
+	total := types.FromFil(build.TotalFilecoin)
+	vested := types.BigSub(types.BigSub(total, genNet.Balance), vesting)
+	mined := types.BigSub(genNet.Balance, netAct.Balance)
+
+	circ := types.BigAdd(vested, mined)
+	circ = types.BigSub(circ, burnt.Balance)
+	circ = types.BigSub(circ, pledge)
+	circ = types.BigSub(circ, deals)
+
+	return api.CirculatingSupply{
+		Total:        total,
+		Vested:       vested,
+		Mined:        mined,
+		Burnt:        burnt.Balance,
+		LockedPledge: pledge,
+		LockedDeals:  deals,
+		Circulating:  circ,
+	}, nil
+}
+
+func lockedDealFunds(ctx context.Context, cst *hamt.CborIpldStore, stree *state.StateTree) (types.BigInt, error) {
+	mact, err := stree.GetActor(actors.StorageMarketAddress)
+	if err != nil {
+		return types.EmptyInt, xerrors.Errorf("loading market actor: %w", err)
+	}
+
+	var mst actors.StorageMarketState
+	if err := cst.Get(ctx, mact.Head, &mst); err != nil {
+		return types.EmptyInt, xerrors.Errorf("loading market state: %w", err)
+	}
+
+	nd, err := hamt.LoadNode(ctx, cst, mst.Balances)
+	if err != nil {
+		return types.EmptyInt, xerrors.Errorf("loading market balances: %w", err)
+	}
+
+	locked := types.NewInt(0)
+	err = nd.ForEach(ctx, func(k string, val interface{}) error {
+		var b actors.StorageParticipantBalance
+		if err := b.UnmarshalCBOR(bytes.NewReader(val.(*cbg.Deferred).Raw)); err != nil {
+			return err
+		}
+		locked = types.BigAdd(locked, b.Locked)
+		return nil
+	})
+	if err != nil {
+		return types.EmptyInt, xerrors.Errorf("iterating market balances: %w", err)
+	}
+
+	return locked, nil
+}
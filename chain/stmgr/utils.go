@@ -53,11 +53,19 @@ func GetMinerWorkerRaw(ctx context.Context, sm *StateManager, st cid.Cid, maddr
 }
 
 func GetMinerOwner(ctx context.Context, sm *StateManager, st cid.Cid, maddr address.Address) (address.Address, error) {
-	recp, err := sm.CallRaw(ctx, &types.Message{
+	sm.pruneLk.RLock()
+	defer sm.pruneLk.RUnlock()
+
+	return getMinerOwner(ctx, sm, st, maddr)
+}
+
+// getMinerOwner is GetMinerOwner for callers already holding pruneLk
+func getMinerOwner(ctx context.Context, sm *StateManager, st cid.Cid, maddr address.Address) (address.Address, error) {
+	recp, err := sm.callRaw(ctx, &types.Message{
 		To:     maddr,
 		From:   maddr,
 		Method: actors.MAMethods.GetOwner,
-	}, st, nil, 0)
+	}, st, nil, 0, false)
 	if err != nil {
 		return address.Undef, xerrors.Errorf("callRaw failed: %w", err)
 	}
@@ -341,6 +349,9 @@ func ComputeState(ctx context.Context, sm *StateManager, height uint64, msgs []*
 		return cid.Undef, err
 	}
 
+	sm.pruneLk.RLock()
+	defer sm.pruneLk.RUnlock()
+
 	fstate, err := sm.handleStateForks(ctx, base, height, ts.Height())
 	if err != nil {
 		return cid.Undef, err
@@ -0,0 +1,31 @@
+package store
+
+import (
+	block "github.com/ipfs/go-block-format"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+// gcBlockstore holds the pin lock of the chain GC locker for every write, so
+// nothing is written to the chain blockstore while blocks are being deleted
+// from it (state pruning, splitstore compaction)
+type gcBlockstore struct {
+	bstore.Blockstore
+
+	gcl bstore.GCLocker
+}
+
+func (bs *gcBlockstore) Put(blk block.Block) error {
+	defer bs.gcl.PinLock().Unlock()
+	return bs.Blockstore.Put(blk)
+}
+
+func (bs *gcBlockstore) PutMany(blks []block.Block) error {
+	defer bs.gcl.PinLock().Unlock()
+	return bs.Blockstore.PutMany(blks)
+}
+
+// GCLocker returns the lock guarding the chain blockstore against concurrent
+// writes and deletions. Anything deleting chain data has to hold its GC lock.
+func (cs *ChainStore) GCLocker() bstore.GCLocker {
+	return cs.gcl
+}
@@ -0,0 +1,331 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/ipfs/go-cid"
+	dstore "github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var msgIndexPrefix = dstore.NewKey("/chain/msgindex")
+
+// tsIndexPrefix maps heights to the key of the tipset at that height on the
+// current chain, for the heights covered by the message index
+var tsIndexPrefix = dstore.NewKey("/chain/tsindex")
+
+// msgIndexBackfillKey holds the key of the lowest tipset indexed by the
+// backfill, or is empty once the backfill reached genesis
+var msgIndexBackfillKey = dstore.NewKey("/chain/msgindex-backfill")
+
+// backfillCheckpointInterval is the number of tipsets indexed between
+// persisting backfill progress
+const backfillCheckpointInterval = 100
+
+// MsgLocation points at the receipt of an executed message
+type MsgLocation struct {
+	// TipSet is the key of the tipset whose parent receipts contain the
+	// receipt for the message, i.e. the tipset following the one the message
+	// was included in
+	TipSet types.TipSetKey
+	Height uint64
+	// Index is the index of the receipt in the parent receipts AMT
+	Index int
+}
+
+func msgIndexKey(c cid.Cid) dstore.Key {
+	return msgIndexPrefix.ChildString(c.String())
+}
+
+func tsIndexKey(h uint64) dstore.Key {
+	return tsIndexPrefix.ChildString(strconv.FormatUint(h, 10))
+}
+
+// indexHeadChange keeps the message index in sync with the current chain.
+// Messages included in reverted tipsets are removed from the index, and
+// messages executed by applied tipsets are added.
+func (cs *ChainStore) indexHeadChange(rev, app []*types.TipSet) error {
+	for _, ts := range rev {
+		if err := cs.unindexTipSet(ts); err != nil {
+			return xerrors.Errorf("unindexing messages of tipset %s: %w", ts.Key(), err)
+		}
+	}
+
+	for _, ts := range app {
+		if err := cs.indexTipSet(ts); err != nil {
+			return xerrors.Errorf("indexing messages of tipset %s: %w", ts.Key(), err)
+		}
+	}
+
+	return nil
+}
+
+// executedMessages returns the messages whose receipts are stored in ts
+func (cs *ChainStore) executedMessages(ts *types.TipSet) ([]ChainMsg, error) {
+	if ts.Height() == 0 {
+		return nil, nil
+	}
+
+	pts, err := cs.LoadTipSet(ts.Parents())
+	if err != nil {
+		return nil, xerrors.Errorf("loading parent tipset: %w", err)
+	}
+
+	return cs.MessagesForTipset(pts)
+}
+
+func (cs *ChainStore) indexTipSet(ts *types.TipSet) error {
+	msgs, err := cs.executedMessages(ts)
+	if err != nil {
+		return err
+	}
+
+	for i, m := range msgs {
+		if err := cs.IndexMsg(m.Cid(), &MsgLocation{
+			TipSet: ts.Key(),
+			Height: ts.Height(),
+			Index:  i,
+		}); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(ts.Key())
+	if err != nil {
+		return xerrors.Errorf("marshaling tipset key: %w", err)
+	}
+	if err := cs.ds.Put(tsIndexKey(ts.Height()), data); err != nil {
+		return xerrors.Errorf("writing tipset index entry: %w", err)
+	}
+
+	return nil
+}
+
+func (cs *ChainStore) unindexTipSet(ts *types.TipSet) error {
+	msgs, err := cs.executedMessages(ts)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range msgs {
+		loc, err := cs.getMsgLocation(m.Cid())
+		if err != nil {
+			return err
+		}
+
+		// the message may have been executed again on the new chain already
+		if loc == nil || loc.TipSet != ts.Key() {
+			continue
+		}
+
+		if err := cs.ds.Delete(msgIndexKey(m.Cid())); err != nil && err != dstore.ErrNotFound {
+			return xerrors.Errorf("deleting index entry: %w", err)
+		}
+	}
+
+	// the new chain may have a null round at this height
+	tsk, err := cs.getIndexedTipSet(ts.Height())
+	if err != nil {
+		return err
+	}
+	if tsk != nil && *tsk == ts.Key() {
+		if err := cs.ds.Delete(tsIndexKey(ts.Height())); err != nil && err != dstore.ErrNotFound {
+			return xerrors.Errorf("deleting tipset index entry: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// getIndexedTipSet returns the key of the tipset at height h on the current
+// chain, or nil when the height isn't indexed
+func (cs *ChainStore) getIndexedTipSet(h uint64) (*types.TipSetKey, error) {
+	data, err := cs.ds.Get(tsIndexKey(h))
+	if err == dstore.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("reading tipset index entry: %w", err)
+	}
+
+	var tsk types.TipSetKey
+	if err := json.Unmarshal(data, &tsk); err != nil {
+		return nil, xerrors.Errorf("unmarshaling tipset index entry: %w", err)
+	}
+
+	return &tsk, nil
+}
+
+// IndexMsg records the location of the receipt for the given message
+func (cs *ChainStore) IndexMsg(mcid cid.Cid, loc *MsgLocation) error {
+	data, err := json.Marshal(loc)
+	if err != nil {
+		return xerrors.Errorf("marshaling index entry: %w", err)
+	}
+
+	if err := cs.ds.Put(msgIndexKey(mcid), data); err != nil {
+		return xerrors.Errorf("writing index entry: %w", err)
+	}
+
+	return nil
+}
+
+func (cs *ChainStore) getMsgLocation(mcid cid.Cid) (*MsgLocation, error) {
+	data, err := cs.ds.Get(msgIndexKey(mcid))
+	if err == dstore.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("reading index entry: %w", err)
+	}
+
+	var loc MsgLocation
+	if err := json.Unmarshal(data, &loc); err != nil {
+		return nil, xerrors.Errorf("unmarshaling index entry: %w", err)
+	}
+
+	return &loc, nil
+}
+
+// LookupMsg returns the tipset in which the message was executed along with
+// its receipt. Both are nil when the message isn't in the index.
+func (cs *ChainStore) LookupMsg(mcid cid.Cid) (*types.TipSet, *types.MessageReceipt, error) {
+	loc, err := cs.getMsgLocation(mcid)
+	if err != nil || loc == nil {
+		return nil, nil, err
+	}
+
+	ts, err := cs.LoadTipSet(loc.TipSet)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("loading indexed tipset: %w", err)
+	}
+
+	r, err := cs.GetParentReceipt(ts.Blocks()[0], loc.Index)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("loading indexed receipt: %w", err)
+	}
+
+	return ts, r, nil
+}
+
+// LookupMsgOnChain is LookupMsg limited to the chain ending at head. Index
+// entries for tipsets that aren't ancestors of head, like ones written for a
+// fork the node has since left, are ignored. When head is on the current
+// chain ancestry is checked against the tipset index, otherwise the chain is
+// walked back from head.
+func (cs *ChainStore) LookupMsgOnChain(ctx context.Context, mcid cid.Cid, head *types.TipSet) (*types.TipSet, *types.MessageReceipt, error) {
+	ts, r, err := cs.LookupMsg(mcid)
+	if err != nil || ts == nil {
+		return nil, nil, err
+	}
+
+	if ts.Height() > head.Height() {
+		return nil, nil, nil
+	}
+
+	onChain, err := cs.isIndexed(head)
+	if err != nil {
+		return nil, nil, err
+	}
+	if onChain {
+		tsk, err := cs.getIndexedTipSet(ts.Height())
+		if err != nil {
+			return nil, nil, err
+		}
+		if tsk != nil {
+			if *tsk != ts.Key() {
+				return nil, nil, nil
+			}
+			return ts, r, nil
+		}
+	}
+
+	anc, err := cs.GetTipsetByHeight(ctx, ts.Height(), head)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("looking up tipset at indexed height: %w", err)
+	}
+	if !anc.Equals(ts) {
+		return nil, nil, nil
+	}
+
+	return ts, r, nil
+}
+
+// isIndexed returns whether ts is in the tipset index, i.e. on the current
+// chain as far as the index knows
+func (cs *ChainStore) isIndexed(ts *types.TipSet) (bool, error) {
+	tsk, err := cs.getIndexedTipSet(ts.Height())
+	if err != nil {
+		return false, err
+	}
+
+	return tsk != nil && *tsk == ts.Key(), nil
+}
+
+// BackfillMsgIndex indexes the messages of the chain ending at head which
+// were executed before the index existed. Tipsets applied after the index was
+// introduced are indexed as they arrive, so the backfill only runs once, and
+// resumes where it stopped when interrupted.
+func (cs *ChainStore) BackfillMsgIndex(ctx context.Context, head *types.TipSet) error {
+	ts := head
+	data, err := cs.ds.Get(msgIndexBackfillKey)
+	switch err {
+	case nil:
+		var tsk types.TipSetKey
+		if err := json.Unmarshal(data, &tsk); err != nil {
+			return xerrors.Errorf("unmarshaling backfill progress: %w", err)
+		}
+		if tsk == (types.TipSetKey{}) {
+			return nil // done
+		}
+		if ts, err = cs.LoadTipSet(tsk); err != nil {
+			return xerrors.Errorf("loading backfill progress tipset: %w", err)
+		}
+	case dstore.ErrNotFound:
+	default:
+		return xerrors.Errorf("loading backfill progress: %w", err)
+	}
+
+	saveProgress := func(tsk types.TipSetKey) error {
+		data, err := json.Marshal(tsk)
+		if err != nil {
+			return err
+		}
+		return cs.ds.Put(msgIndexBackfillKey, data)
+	}
+
+	log.Infow("backfilling message index", "from", ts.Height())
+
+	for i := 1; ts.Height() > 0; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := cs.indexTipSet(ts); err != nil {
+			return xerrors.Errorf("indexing messages of tipset %s: %w", ts.Key(), err)
+		}
+
+		pts, err := cs.LoadTipSet(ts.Parents())
+		if err != nil {
+			return xerrors.Errorf("loading parent tipset: %w", err)
+		}
+		ts = pts
+
+		if i%backfillCheckpointInterval == 0 {
+			if err := saveProgress(ts.Key()); err != nil {
+				return xerrors.Errorf("saving backfill progress: %w", err)
+			}
+		}
+	}
+
+	if err := saveProgress(types.TipSetKey{}); err != nil {
+		return xerrors.Errorf("saving backfill progress: %w", err)
+	}
+
+	log.Info("message index backfill done")
+	return nil
+}
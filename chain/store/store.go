@@ -8,9 +8,11 @@ import (
 	"encoding/json"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/beacon"
 	"github.com/filecoin-project/lotus/chain/state"
 	"github.com/filecoin-project/lotus/chain/vm"
 	"go.opencensus.io/trace"
@@ -39,10 +41,13 @@ import (
 var log = logging.Logger("chainstore")
 
 var chainHeadKey = dstore.NewKey("head")
+var checkpointKey = dstore.NewKey("/chain/checkpoint")
+var prunedHeightKey = dstore.NewKey("/chain/prunedHeight")
 
 type ChainStore struct {
-	bs bstore.Blockstore
-	ds dstore.Datastore
+	bs  bstore.Blockstore
+	ds  dstore.Datastore
+	gcl bstore.GCLocker
 
 	heaviestLk sync.Mutex
 	heaviest   *types.TipSet
@@ -60,19 +65,27 @@ type ChainStore struct {
 	tsCache *lru.ARCCache
 
 	vmcalls *types.VMSyscalls
+
+	beacon beacon.RandomnessBeacon
 }
 
-func NewChainStore(bs bstore.Blockstore, ds dstore.Batching, vmcalls *types.VMSyscalls) *ChainStore {
+// NewChainStore creates a chain store. When rb isn't nil, GetRandomness mixes
+// ticket randomness with the entries of the beacon, so all nodes of a network
+// have to use the same beacon.
+func NewChainStore(bs bstore.Blockstore, ds dstore.Batching, vmcalls *types.VMSyscalls, rb beacon.RandomnessBeacon) *ChainStore {
 	c, _ := lru.NewARC(2048)
 	tsc, _ := lru.NewARC(4096)
+	gcl := bstore.NewGCLocker()
 	cs := &ChainStore{
-		bs:       bs,
+		bs:       &gcBlockstore{Blockstore: bs, gcl: gcl},
 		ds:       ds,
+		gcl:      gcl,
 		bestTips: pubsub.New(64),
 		tipsets:  make(map[uint64][]cid.Cid),
 		mmCache:  c,
 		tsCache:  tsc,
 		vmcalls:  vmcalls,
+		beacon:   rb,
 	}
 
 	cs.reorgCh = cs.reorgWorker(context.TODO())
@@ -97,10 +110,25 @@ func NewChainStore(bs bstore.Blockstore, ds dstore.Batching, vmcalls *types.VMSy
 		}
 
 		cs.bestTips.Pub(notif, "headchange")
+
+		rc := &ReorgChange{
+			Revert: rev,
+			Apply:  app,
+		}
+		if len(rev) > 0 {
+			anc, err := cs.LoadTipSet(rev[len(rev)-1].Parents())
+			if err != nil {
+				return xerrors.Errorf("loading common ancestor: %w", err)
+			}
+			rc.Depth = rev[0].Height() - anc.Height()
+		}
+		cs.bestTips.Pub(rc, "reorg")
+
 		return nil
 	}
 
-	cs.headChangeNotifs = append(cs.headChangeNotifs, hcnf)
+	// the message index has to be up to date before subscribers are notified
+	cs.headChangeNotifs = append(cs.headChangeNotifs, cs.indexHeadChange, hcnf)
 
 	return cs
 }
@@ -143,6 +171,73 @@ func (cs *ChainStore) writeHead(ts *types.TipSet) error {
 	return nil
 }
 
+// GetCheckpoint returns the key of the trusted checkpoint tipset. The key is
+// empty when no checkpoint is set.
+func (cs *ChainStore) GetCheckpoint() (types.TipSetKey, error) {
+	data, err := cs.ds.Get(checkpointKey)
+	if err == dstore.ErrNotFound {
+		return types.TipSetKey{}, nil
+	}
+	if err != nil {
+		return types.TipSetKey{}, xerrors.Errorf("failed to load checkpoint from datastore: %w", err)
+	}
+
+	var tsk types.TipSetKey
+	if err := json.Unmarshal(data, &tsk); err != nil {
+		return types.TipSetKey{}, xerrors.Errorf("failed to unmarshal stored checkpoint: %w", err)
+	}
+
+	return tsk, nil
+}
+
+func (cs *ChainStore) SetCheckpoint(tsk types.TipSetKey) error {
+	data, err := json.Marshal(tsk)
+	if err != nil {
+		return xerrors.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	if err := cs.ds.Put(checkpointKey, data); err != nil {
+		return xerrors.Errorf("failed to write checkpoint to datastore: %w", err)
+	}
+
+	return nil
+}
+
+func (cs *ChainStore) RemoveCheckpoint() error {
+	if err := cs.ds.Delete(checkpointKey); err != nil && err != dstore.ErrNotFound {
+		return xerrors.Errorf("failed to remove checkpoint from datastore: %w", err)
+	}
+	return nil
+}
+
+// GetPrunedHeight returns the height below which state was already pruned,
+// zero if state was never pruned
+func (cs *ChainStore) GetPrunedHeight() (uint64, error) {
+	data, err := cs.ds.Get(prunedHeightKey)
+	if err == dstore.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, xerrors.Errorf("failed to load pruned height from datastore: %w", err)
+	}
+	if len(data) != 8 {
+		return 0, xerrors.Errorf("stored pruned height has invalid length %d", len(data))
+	}
+
+	return binary.BigEndian.Uint64(data), nil
+}
+
+func (cs *ChainStore) SetPrunedHeight(h uint64) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, h)
+
+	if err := cs.ds.Put(prunedHeightKey, data); err != nil {
+		return xerrors.Errorf("failed to write pruned height to datastore: %w", err)
+	}
+
+	return nil
+}
+
 const (
 	HCRevert  = "revert"
 	HCApply   = "apply"
@@ -194,6 +289,124 @@ func (cs *ChainStore) SubHeadChanges(ctx context.Context) chan []*HeadChange {
 	return out
 }
 
+// ReorgChange describes a single head change. Revert lists the tipsets
+// removed from the chain, starting at the old head, Apply the tipsets added,
+// ending at the new head.
+type ReorgChange struct {
+	Revert []*types.TipSet
+	Apply  []*types.TipSet
+	// Depth is the number of epochs rolled back to the common ancestor of
+	// the old and new heads, zero when the head was only extended
+	Depth uint64
+}
+
+// SubReorgs returns a channel of head changes reported as whole reorgs,
+// letting subscribers tell chain extensions from forks.
+func (cs *ChainStore) SubReorgs(ctx context.Context) chan *ReorgChange {
+	subch := cs.bestTips.Sub("reorg")
+
+	out := make(chan *ReorgChange, 16)
+
+	go func() {
+		defer close(out)
+		var unsubOnce sync.Once
+
+		for {
+			select {
+			case val, ok := <-subch:
+				if !ok {
+					log.Warn("reorg sub exit loop")
+					return
+				}
+				if len(out) > 0 {
+					log.Warnf("reorg sub is slow, has %d buffered entries", len(out))
+				}
+				select {
+				case out <- val.(*ReorgChange):
+				case <-ctx.Done():
+				}
+			case <-ctx.Done():
+				unsubOnce.Do(func() {
+					go cs.bestTips.Unsub(subch)
+				})
+			}
+		}
+	}()
+	return out
+}
+
+// SubHeadChangesConfidence works like SubHeadChanges, but only reports
+// tipsets once they have the given number of descendants on the heaviest
+// chain. Reorgs shallower than confidence are never observed.
+func (cs *ChainStore) SubHeadChangesConfidence(ctx context.Context, confidence uint64) chan []*HeadChange {
+	if confidence == 0 {
+		return cs.SubHeadChanges(ctx)
+	}
+
+	sub := cs.SubHeadChanges(ctx)
+	out := make(chan []*HeadChange, 16)
+
+	go func() {
+		defer close(out)
+
+		var last *types.TipSet
+		for changes := range sub {
+			head := changes[len(changes)-1].Val
+			if changes[len(changes)-1].Type == HCRevert {
+				// head was set back to an ancestor, which is the parent of
+				// the oldest reverted tipset
+				p, err := cs.LoadTipSet(head.Parents())
+				if err != nil {
+					log.Errorf("confidence sub: loading parent tipset: %s", err)
+					continue
+				}
+				head = p
+			}
+
+			confident, err := cs.confidentAncestor(head, confidence)
+			if err != nil {
+				log.Errorf("confidence sub: %s", err)
+				continue
+			}
+
+			var notif []*HeadChange
+			switch {
+			case last == nil:
+				notif = []*HeadChange{{Type: HCCurrent, Val: confident}}
+			case last.Equals(confident):
+				continue
+			default:
+				notif, err = cs.GetPath(ctx, last.Key(), confident.Key())
+				if err != nil {
+					log.Errorf("confidence sub: %s", err)
+					continue
+				}
+			}
+			last = confident
+
+			select {
+			case out <- notif:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// confidentAncestor walks back confidence tipsets from ts, stopping at genesis
+func (cs *ChainStore) confidentAncestor(ts *types.TipSet, confidence uint64) (*types.TipSet, error) {
+	for i := uint64(0); i < confidence && ts.Height() > 0; i++ {
+		p, err := cs.LoadTipSet(ts.Parents())
+		if err != nil {
+			return nil, xerrors.Errorf("loading parent of %s: %w", ts.Key(), err)
+		}
+		ts = p
+	}
+	return ts, nil
+}
+
 func (cs *ChainStore) SubscribeHeadChanges(f func(rev, app []*types.TipSet) error) {
 	cs.headChangeNotifs = append(cs.headChangeNotifs, f)
 }
@@ -865,10 +1078,78 @@ func drawRandomness(t *types.Ticket, round int64) []byte {
 }
 
 func (cs *ChainStore) GetRandomness(ctx context.Context, blks []cid.Cid, round int64) ([]byte, error) {
-	_, span := trace.StartSpan(ctx, "store.GetRandomness")
+	ctx, span := trace.StartSpan(ctx, "store.GetRandomness")
 	defer span.End()
 	span.AddAttributes(trace.Int64Attribute("round", round))
 
+	rand, err := cs.ticketRandomness(blks, round)
+	if err != nil {
+		return nil, err
+	}
+	if cs.beacon == nil || round < 0 {
+		return rand, nil
+	}
+
+	return cs.mixBeacon(ctx, rand, round)
+}
+
+// PrefetchBeacon fetches the beacon entries mixed into the randomness of
+// rounds from..to, when the beacon supports it, so validating that range of
+// the chain doesn't fail on each entry which wasn't fetched yet
+func (cs *ChainStore) PrefetchBeacon(ctx context.Context, from, to int64) error {
+	p, ok := cs.beacon.(beacon.Prefetcher)
+	if !ok {
+		return nil
+	}
+	if from < 0 {
+		from = 0
+	}
+
+	gen, err := cs.GetGenesis()
+	if err != nil {
+		return xerrors.Errorf("getting genesis: %w", err)
+	}
+
+	last := cs.beaconRound(gen, to)
+	if last == 0 {
+		return nil
+	}
+	return p.Prefetch(ctx, cs.beaconRound(gen, from), last)
+}
+
+// beaconRound returns the latest beacon round published at the time of the
+// round, 0 before the first beacon entry
+func (cs *ChainStore) beaconRound(gen *types.BlockHeader, round int64) uint64 {
+	return cs.beacon.RoundAt(time.Unix(int64(gen.Timestamp)+round*build.BlockDelay, 0))
+}
+
+// mixBeacon mixes randomness with the latest beacon entry published at the
+// time of the round
+func (cs *ChainStore) mixBeacon(ctx context.Context, rand []byte, round int64) ([]byte, error) {
+	gen, err := cs.GetGenesis()
+	if err != nil {
+		return nil, xerrors.Errorf("getting genesis: %w", err)
+	}
+
+	br := cs.beaconRound(gen, round)
+	if br == 0 {
+		// the round is before the first beacon entry
+		return rand, nil
+	}
+
+	e, err := cs.beacon.Entry(ctx, br)
+	if err != nil {
+		return nil, xerrors.Errorf("getting beacon entry for round %d: %w", round, err)
+	}
+
+	h := sha256.New()
+	h.Write(rand)
+	h.Write(e.Data)
+	return h.Sum(nil), nil
+}
+
+func (cs *ChainStore) ticketRandomness(blks []cid.Cid, round int64) ([]byte, error) {
+
 	for {
 		nts, err := cs.LoadTipSet(types.NewTipSetKey(blks...))
 		if err != nil {
@@ -924,21 +1205,29 @@ func (cs *ChainStore) GetTipsetByHeight(ctx context.Context, h uint64, ts *types
 	}
 }
 
-func recurseLinks(bs blockstore.Blockstore, root cid.Cid, in []cid.Cid) ([]cid.Cid, error) {
+func recurseLinks(bs blockstore.Blockstore, walked *cid.Set, root cid.Cid, in []cid.Cid) ([]cid.Cid, error) {
+	if root.Prefix().Codec != cid.DagCBOR {
+		return in, nil
+	}
+
 	data, err := bs.Get(root)
 	if err != nil {
-		return nil, err
+		return nil, xerrors.Errorf("recurse links get (%s) failed: %w", root, err)
 	}
 
 	top, err := cbg.ScanForLinks(bytes.NewReader(data.RawData()))
 	if err != nil {
-		return nil, err
+		return nil, xerrors.Errorf("scanning for links failed: %w", err)
 	}
 
-	in = append(in, top...)
 	for _, c := range top {
+		if !walked.Visit(c) {
+			continue
+		}
+
+		in = append(in, c)
 		var err error
-		in, err = recurseLinks(bs, c, in)
+		in, err = recurseLinks(bs, walked, c, in)
 		if err != nil {
 			return nil, err
 		}
@@ -947,40 +1236,70 @@ func recurseLinks(bs blockstore.Blockstore, root cid.Cid, in []cid.Cid) ([]cid.C
 	return in, nil
 }
 
-func (cs *ChainStore) Export(ctx context.Context, ts *types.TipSet, w io.Writer) error {
+// Export writes the chain ending at ts as a CAR file. All block headers and
+// messages are included, state trees and receipts only for the genesis block
+// and for the last inclRecentRoots epochs.
+func (cs *ChainStore) Export(ctx context.Context, ts *types.TipSet, inclRecentRoots uint64, w io.Writer) error {
 	if ts == nil {
 		ts = cs.GetHeaviestTipSet()
 	}
+
+	headers := cid.NewSet()
+	for _, c := range ts.Cids() {
+		headers.Add(c)
+	}
+
+	walked := cid.NewSet()
+	walk := func(root cid.Cid, out []*format.Link) ([]*format.Link, error) {
+		if !walked.Visit(root) {
+			return out, nil
+		}
+		out = append(out, &format.Link{Cid: root})
+
+		cids, err := recurseLinks(cs.bs, walked, root, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range cids {
+			out = append(out, &format.Link{Cid: c})
+		}
+		return out, nil
+	}
+
 	bsrv := blockservice.New(cs.bs, nil)
 	dserv := dag.NewDAGService(bsrv)
 	return car.WriteCarWithWalker(ctx, dserv, ts.Cids(), w, func(nd format.Node) ([]*format.Link, error) {
+		if !headers.Has(nd.Cid()) {
+			// messages and state are enumerated in full from their block header
+			return nil, nil
+		}
+
 		var b types.BlockHeader
 		if err := b.UnmarshalCBOR(bytes.NewBuffer(nd.RawData())); err != nil {
-			return nil, err
+			return nil, xerrors.Errorf("unmarshaling block header (cid=%s): %w", nd.Cid(), err)
 		}
 
 		var out []*format.Link
 		for _, p := range b.Parents {
+			headers.Add(p)
 			out = append(out, &format.Link{Cid: p})
 		}
 
-		cids, err := recurseLinks(cs.bs, b.Messages, nil)
+		out, err := walk(b.Messages, out)
 		if err != nil {
-			return nil, err
+			return nil, xerrors.Errorf("walking messages (cid=%s): %w", b.Messages, err)
 		}
 
-		for _, c := range cids {
-			out = append(out, &format.Link{Cid: c})
-		}
-
-		if b.Height == 0 {
-			cids, err := recurseLinks(cs.bs, b.ParentStateRoot, nil)
+		if b.Height == 0 || b.Height+inclRecentRoots > ts.Height() {
+			out, err = walk(b.ParentStateRoot, out)
 			if err != nil {
-				return nil, err
+				return nil, xerrors.Errorf("walking state root (cid=%s): %w", b.ParentStateRoot, err)
 			}
 
-			for _, c := range cids {
-				out = append(out, &format.Link{Cid: c})
+			out, err = walk(b.ParentMessageReceipts, out)
+			if err != nil {
+				return nil, xerrors.Errorf("walking receipts (cid=%s): %w", b.ParentMessageReceipts, err)
 			}
 		}
 
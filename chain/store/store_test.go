@@ -3,11 +3,14 @@ package store_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/gen"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
+	_ "github.com/filecoin-project/lotus/lib/sigs/bls"
+	_ "github.com/filecoin-project/lotus/lib/sigs/secp"
 	"github.com/filecoin-project/lotus/node/repo"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 )
@@ -55,7 +58,7 @@ func BenchmarkGetRandomness(b *testing.B) {
 
 	bs := blockstore.NewBlockstore(bds)
 
-	cs := store.NewChainStore(bs, mds, nil)
+	cs := store.NewChainStore(bs, mds, nil, nil)
 
 	b.ResetTimer()
 
@@ -66,3 +69,203 @@ func BenchmarkGetRandomness(b *testing.B) {
 		}
 	}
 }
+
+func TestMsgIndex(t *testing.T) {
+	cg, err := gen.NewGenerator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tipsets []*gen.MinedTipSet
+	for i := 0; i < 4; i++ {
+		mts, err := cg.NextTipSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tipsets = append(tipsets, mts)
+	}
+
+	cs := cg.ChainStore()
+	ctx := context.TODO()
+	head := tipsets[3].TipSet.TipSet()
+
+	// messages are executed, and their receipts stored, by the next tipset
+	m := tipsets[1].Messages[0]
+	mcid := m.Cid()
+	if m.Signature.Type == types.KTBLS {
+		mcid = m.Message.Cid()
+	}
+	execTs := tipsets[2].TipSet.TipSet()
+
+	ts, _, err := cs.LookupMsgOnChain(ctx, mcid, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts != nil {
+		t.Fatal("expected message not to be indexed before the backfill")
+	}
+
+	if err := cs.BackfillMsgIndex(ctx, head); err != nil {
+		t.Fatal(err)
+	}
+
+	ts, r, err := cs.LookupMsgOnChain(ctx, mcid, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts == nil || !ts.Equals(execTs) || r == nil {
+		t.Fatalf("expected message to be found in tipset at height %d", execTs.Height())
+	}
+
+	// not executed yet on a chain ending before the executing tipset
+	ts, _, err = cs.LookupMsgOnChain(ctx, mcid, tipsets[1].TipSet.TipSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts != nil {
+		t.Fatal("expected message not to be found on a shorter chain")
+	}
+
+	// heads that aren't indexed yet are checked by walking the chain
+	next, err := cg.NextTipSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts, _, err = cs.LookupMsgOnChain(ctx, mcid, next.TipSet.TipSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts == nil || !ts.Equals(execTs) {
+		t.Fatal("expected message to be found from an unindexed head")
+	}
+
+	// the backfill only runs once
+	if err := cs.BackfillMsgIndex(ctx, head); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startAtGenesis makes sure the chain has a head, head changes are only
+// reported once there is one
+func startAtGenesis(t *testing.T, cg *gen.ChainGen) {
+	cs := cg.ChainStore()
+	if cs.GetHeaviestTipSet() != nil {
+		return
+	}
+
+	gts, err := types.NewTipSet([]*types.BlockHeader{cg.Genesis()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.SetHead(gts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSubReorgs(t *testing.T) {
+	cg, err := gen.NewGenerator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tipsets []*types.TipSet
+	for i := 0; i < 2; i++ {
+		mts, err := cg.NextTipSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tipsets = append(tipsets, mts.TipSet.TipSet())
+	}
+
+	cs := cg.ChainStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	startAtGenesis(t, cg)
+
+	sub := cs.SubReorgs(ctx)
+	next := func() *store.ReorgChange {
+		select {
+		case rc := <-sub:
+			return rc
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for reorg notification")
+			return nil
+		}
+	}
+
+	// extending the chain doesn't revert anything
+	if err := cs.SetHead(tipsets[1]); err != nil {
+		t.Fatal(err)
+	}
+	rc := next()
+	if len(rc.Revert) != 0 || rc.Depth != 0 {
+		t.Fatalf("expected no reverts, got %d at depth %d", len(rc.Revert), rc.Depth)
+	}
+	if len(rc.Apply) != 2 || !rc.Apply[1].Equals(tipsets[1]) {
+		t.Fatalf("expected 2 applied tipsets ending at the new head, got %d", len(rc.Apply))
+	}
+
+	// going back to an ancestor reverts everything above it
+	if err := cs.SetHead(tipsets[0]); err != nil {
+		t.Fatal(err)
+	}
+	rc = next()
+	if len(rc.Apply) != 0 || len(rc.Revert) != 1 || !rc.Revert[0].Equals(tipsets[1]) {
+		t.Fatalf("expected the old head to be reverted, got %d reverts and %d applies", len(rc.Revert), len(rc.Apply))
+	}
+	if expect := tipsets[1].Height() - tipsets[0].Height(); rc.Depth != expect {
+		t.Fatalf("expected depth %d, got %d", expect, rc.Depth)
+	}
+}
+
+func TestSubHeadChangesConfidence(t *testing.T) {
+	cg, err := gen.NewGenerator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tipsets []*types.TipSet
+	for i := 0; i < 3; i++ {
+		mts, err := cg.NextTipSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tipsets = append(tipsets, mts.TipSet.TipSet())
+	}
+
+	cs := cg.ChainStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	startAtGenesis(t, cg)
+
+	sub := cs.SubHeadChangesConfidence(ctx, 2)
+	next := func() []*store.HeadChange {
+		select {
+		case hc := <-sub:
+			return hc
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for head change")
+			return nil
+		}
+	}
+
+	hc := next()
+	if len(hc) != 1 || hc[0].Type != store.HCCurrent || hc[0].Val.Height() != 0 {
+		t.Fatal("expected the genesis to be reported as current")
+	}
+
+	// tipsets are reported once they have two descendants
+	for _, ts := range tipsets {
+		if err := cs.SetHead(ts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hc = next()
+	if len(hc) != 1 || hc[0].Type != store.HCApply || !hc[0].Val.Equals(tipsets[0]) {
+		t.Fatalf("expected only the first tipset to be applied, got %d changes", len(hc))
+	}
+}
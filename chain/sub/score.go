@@ -0,0 +1,133 @@
+package sub
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// ScoreParams configure how peers are scored based on the validity of the
+// pubsub messages they forward
+type ScoreParams struct {
+	// Penalties are subtracted from the score of a peer for each invalid
+	// message on the topic, topics without a penalty aren't scored
+	Penalties map[string]float64
+	// ValidReward is added for each valid message, up to MaxScore
+	ValidReward float64
+	MaxScore    float64
+
+	// Decay is the fraction of the score kept after each DecayInterval
+	Decay         float64
+	DecayInterval time.Duration
+
+	// Messages from peers scoring below GraylistThreshold are dropped without
+	// validation
+	GraylistThreshold float64
+	// Peers scoring below BlacklistThreshold are blacklisted for the lifetime
+	// of the node, 0 disables blacklisting
+	BlacklistThreshold float64
+}
+
+// PeerScorer keeps track of the behaviour of pubsub peers
+type PeerScorer struct {
+	params ScoreParams
+	ps     *pubsub.PubSub
+
+	lk     sync.Mutex
+	scores map[peer.ID]float64
+}
+
+func NewPeerScorer(params ScoreParams, ps *pubsub.PubSub) *PeerScorer {
+	return &PeerScorer{
+		params: params,
+		ps:     ps,
+		scores: map[peer.ID]float64{},
+	}
+}
+
+// Run decays peer scores until ctx is cancelled
+func (s *PeerScorer) Run(ctx context.Context) {
+	if s.params.DecayInterval <= 0 {
+		return
+	}
+
+	tick := time.NewTicker(s.params.DecayInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			s.decay()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *PeerScorer) decay() {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	for p, score := range s.scores {
+		score *= s.params.Decay
+		if score > -1 && score < 1 {
+			delete(s.scores, p)
+			continue
+		}
+		s.scores[p] = score
+	}
+}
+
+func (s *PeerScorer) Score(p peer.ID) float64 {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	return s.scores[p]
+}
+
+// Scores returns the current score of all peers with a non-zero score
+func (s *PeerScorer) Scores() map[peer.ID]float64 {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	out := make(map[peer.ID]float64, len(s.scores))
+	for p, score := range s.scores {
+		out[p] = score
+	}
+	return out
+}
+
+func (s *PeerScorer) graylisted(p peer.ID) bool {
+	return s.Score(p) < s.params.GraylistThreshold
+}
+
+func (s *PeerScorer) valid(p peer.ID) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	score := s.scores[p] + s.params.ValidReward
+	if score > s.params.MaxScore {
+		score = s.params.MaxScore
+	}
+	s.scores[p] = score
+}
+
+func (s *PeerScorer) invalid(p peer.ID, topic string) {
+	penalty, ok := s.params.Penalties[topic]
+	if !ok {
+		return
+	}
+
+	s.lk.Lock()
+	score := s.scores[p] - penalty
+	s.scores[p] = score
+	s.lk.Unlock()
+
+	if s.params.BlacklistThreshold != 0 && score < s.params.BlacklistThreshold {
+		log.Warnw("blacklisting pubsub peer", "peer", p, "score", score)
+		s.ps.BlacklistPeer(p)
+	}
+}
@@ -0,0 +1,107 @@
+package sub
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sigs"
+)
+
+// Validator checks a message received on a pubsub topic. Messages failing
+// validation are neither delivered nor forwarded to other peers.
+type Validator func(ctx context.Context, from peer.ID, msg *pubsub.Message) error
+
+// BlockValidators are run on messages received on the blocks topic
+type BlockValidators []Validator
+
+// MessageValidators are run on messages received on the messages topic
+type MessageValidators []Validator
+
+func DefaultBlockValidators() BlockValidators {
+	return BlockValidators{ValidateBlockMsg}
+}
+
+func DefaultMessageValidators(sigCache *sigs.Cache) MessageValidators {
+	return MessageValidators{SignedMessageValidator(sigCache)}
+}
+
+// TopicValidator combines validators into a pubsub validator which scores
+// the peers messages are received from. Messages published by the node
+// itself are validated, but don't affect scores.
+func TopicValidator(topic string, self peer.ID, scorer *PeerScorer, validators []Validator) pubsub.Validator {
+	return func(ctx context.Context, from peer.ID, msg *pubsub.Message) bool {
+		if from != self && scorer != nil && scorer.graylisted(from) {
+			log.Debugw("dropping pubsub message from graylisted peer", "topic", topic, "peer", from)
+			return false
+		}
+
+		for _, v := range validators {
+			if err := v(ctx, from, msg); err != nil {
+				log.Warnw("invalid pubsub message", "topic", topic, "peer", from, "error", err)
+				if from != self && scorer != nil {
+					scorer.invalid(from, topic)
+				}
+				return false
+			}
+		}
+
+		if from != self && scorer != nil {
+			scorer.valid(from)
+		}
+		return true
+	}
+}
+
+func ValidateBlockMsg(ctx context.Context, from peer.ID, msg *pubsub.Message) error {
+	blk, err := types.DecodeBlockMsg(msg.GetData())
+	if err != nil {
+		return xerrors.Errorf("decoding block: %w", err)
+	}
+
+	if len(blk.BlsMessages)+len(blk.SecpkMessages) > build.BlockMessageLimit {
+		return xerrors.Errorf("block has too many messages (%d)", len(blk.BlsMessages)+len(blk.SecpkMessages))
+	}
+
+	if blk.Header.Timestamp > uint64(time.Now().Unix()+build.AllowableClockDrift) {
+		return xerrors.Errorf("block was from the future (%d)", blk.Header.Timestamp)
+	}
+
+	return nil
+}
+
+// SignedMessageValidator performs the stateless checks done by the message
+// pool. The signature check result is cached, so it isn't repeated when the
+// message is added to the pool.
+func SignedMessageValidator(sigCache *sigs.Cache) Validator {
+	return func(ctx context.Context, from peer.ID, msg *pubsub.Message) error {
+		m, err := types.DecodeSignedMessage(msg.GetData())
+		if err != nil {
+			return xerrors.Errorf("decoding message: %w", err)
+		}
+
+		if m.Size() > 32*1024 {
+			return xerrors.Errorf("message too large (%dB)", m.Size())
+		}
+
+		if m.Message.To == address.Undef {
+			return xerrors.New("message had invalid to address")
+		}
+
+		if !m.Message.Value.LessThan(types.TotalFilecoinInt) {
+			return xerrors.New("message value exceeds total filecoin supply")
+		}
+
+		if err := sigCache.Verify(&m.Signature, m.Message.From, m.Message.Cid().Bytes()); err != nil {
+			return xerrors.Errorf("verifying message signature: %w", err)
+		}
+
+		return nil
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"runtime"
 	"sync"
 	"time"
 
@@ -13,7 +14,7 @@ import (
 	bls "github.com/filecoin-project/filecoin-ffi"
 	amt "github.com/filecoin-project/go-amt-ipld"
 	sectorbuilder "github.com/filecoin-project/go-sectorbuilder"
-	"github.com/hashicorp/go-multierror"
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/ipfs/go-cid"
 	dstore "github.com/ipfs/go-datastore"
 	hamt "github.com/ipfs/go-hamt-ipld"
@@ -24,6 +25,7 @@ import (
 	cbg "github.com/whyrusleeping/cbor-gen"
 	"github.com/whyrusleeping/pubsub"
 	"go.opencensus.io/trace"
+	"go.uber.org/multierr"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-address"
@@ -31,6 +33,7 @@ import (
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/beacon"
 	"github.com/filecoin-project/lotus/chain/blocksync"
 	"github.com/filecoin-project/lotus/chain/gen"
 	"github.com/filecoin-project/lotus/chain/state"
@@ -69,9 +72,23 @@ type Syncer struct {
 	incoming *pubsub.PubSub
 
 	receiptTracker *blockReceiptTracker
+
+	// block BLS aggregates known to be valid, keyed by blsAggregateKey
+	blsVerified *lru.ARCCache
+
+	// signatures verified by the syncer, the mpool and pubsub validators
+	sigCache *sigs.Cache
+
+	cpLk sync.Mutex
+	// cpLoaded is set once the checkpoint key was read from the datastore or
+	// set from the config
+	cpLoaded bool
+	cpKey    types.TipSetKey
+	// cpTs caches the resolved checkpoint tipset
+	cpTs *types.TipSet
 }
 
-func NewSyncer(sm *stmgr.StateManager, bsync *blocksync.BlockSync, connmgr connmgr.ConnManager, self peer.ID) (*Syncer, error) {
+func NewSyncer(sm *stmgr.StateManager, bsync *blocksync.BlockSync, connmgr connmgr.ConnManager, self peer.ID, sigCache *sigs.Cache) (*Syncer, error) {
 	gen, err := sm.ChainStore().GetGenesis()
 	if err != nil {
 		return nil, err
@@ -82,6 +99,11 @@ func NewSyncer(sm *stmgr.StateManager, bsync *blocksync.BlockSync, connmgr connm
 		return nil, err
 	}
 
+	blsVerified, err := lru.NewARC(build.BlsAggregateCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &Syncer{
 		bad:            NewBadBlockCache(),
 		Genesis:        gent,
@@ -91,6 +113,8 @@ func NewSyncer(sm *stmgr.StateManager, bsync *blocksync.BlockSync, connmgr connm
 		self:           self,
 		receiptTracker: newBlockReceiptTracker(),
 		connmgr:        connmgr,
+		blsVerified:    blsVerified,
+		sigCache:       sigCache,
 
 		incoming: pubsub.New(50),
 	}
@@ -432,8 +456,16 @@ func (syncer *Syncer) Sync(ctx context.Context, maybeHead *types.TipSet) error {
 	return nil
 }
 
+// isPermanent returns whether the validation error doesn't go away with time.
+// Validation errors may combine errors of several checks, the error is
+// permanent as soon as one of them isn't temporal.
 func isPermanent(err error) bool {
-	return !errors.Is(err, ErrTemporal)
+	for _, e := range multierr.Errors(err) {
+		if !errors.Is(e, ErrTemporal) {
+			return true
+		}
+	}
+	return false
 }
 
 func (syncer *Syncer) ValidateTipSet(ctx context.Context, fts *store.FullTipSet) error {
@@ -447,14 +479,32 @@ func (syncer *Syncer) ValidateTipSet(ctx context.Context, fts *store.FullTipSet)
 		return nil
 	}
 
-	for _, b := range fts.Blocks {
-		if err := syncer.ValidateBlock(ctx, b); err != nil {
-			if isPermanent(err) {
-				syncer.bad.Add(b.Cid(), err.Error())
+	// blocks in a tipset share the parent state, validate them concurrently
+	futures := make([]async.ErrorFuture, len(fts.Blocks))
+	for i, b := range fts.Blocks {
+		b := b
+		futures[i] = async.Err(func() error {
+			if err := syncer.ValidateBlock(ctx, b); err != nil {
+				if isPermanent(err) {
+					syncer.bad.Add(b.Cid(), err.Error())
+				}
+				return xerrors.Errorf("validating block %s: %w", b.Cid(), err)
 			}
-			return xerrors.Errorf("validating block %s: %w", b.Cid(), err)
+			return nil
+		})
+	}
+
+	var merr error
+	for _, fut := range futures {
+		if err := fut.AwaitContext(ctx); err != nil {
+			merr = multierr.Append(merr, err)
 		}
+	}
+	if merr != nil {
+		return merr
+	}
 
+	for _, b := range fts.Blocks {
 		if err := syncer.sm.ChainStore().AddToTipSetTracker(b.Header); err != nil {
 			return xerrors.Errorf("failed to add validated header to tipset tracker: %w", err)
 		}
@@ -492,6 +542,15 @@ func (syncer *Syncer) minerIsValid(ctx context.Context, maddr address.Address, b
 
 var ErrTemporal = errors.New("temporal error")
 
+// beaconTemporal marks errors caused by beacon entries that weren't fetched
+// yet as temporal, the entries are fetched in the background
+func beaconTemporal(err error) error {
+	if xerrors.Is(err, beacon.ErrEntryNotAvailable) {
+		return xerrors.Errorf("%s: %w", err, ErrTemporal)
+	}
+	return err
+}
+
 // Should match up with 'Semantical Validation' in validation.md in the spec
 func (syncer *Syncer) ValidateBlock(ctx context.Context, b *types.FullBlock) error {
 	ctx, span := trace.StartSpan(ctx, "validateBlock")
@@ -581,7 +640,7 @@ func (syncer *Syncer) ValidateBlock(ctx context.Context, b *types.FullBlock) err
 	// Stuff that needs stateroot / worker address
 	stateroot, precp, err := syncer.sm.TipSetState(ctx, baseTs)
 	if err != nil {
-		return xerrors.Errorf("get tipsetstate(%d, %s) failed: %w", h.Height, h.Parents, err)
+		return xerrors.Errorf("get tipsetstate(%d, %s) failed: %w", h.Height, h.Parents, beaconTemporal(err))
 	}
 
 	if stateroot != h.ParentStateRoot {
@@ -645,7 +704,7 @@ func (syncer *Syncer) ValidateBlock(ctx context.Context, b *types.FullBlock) err
 	var merr error
 	for _, fut := range await {
 		if err := fut.AwaitContext(ctx); err != nil {
-			merr = multierror.Append(merr, err)
+			merr = multierr.Append(merr, err)
 		}
 	}
 
@@ -655,7 +714,7 @@ func (syncer *Syncer) ValidateBlock(ctx context.Context, b *types.FullBlock) err
 func (syncer *Syncer) VerifyElectionPoStProof(ctx context.Context, h *types.BlockHeader, baseTs *types.TipSet, waddr address.Address) error {
 	rand, err := syncer.sm.ChainStore().GetRandomness(ctx, baseTs.Cids(), int64(h.Height-build.EcRandomnessLookback))
 	if err != nil {
-		return xerrors.Errorf("failed to get randomness for verifying election proof: %w", err)
+		return xerrors.Errorf("failed to get randomness for verifying election proof: %w", beaconTemporal(err))
 	}
 
 	if err := VerifyElectionPoStVRF(ctx, h.EPostProof.PostRand, rand, waddr, h.Miner); err != nil {
@@ -708,7 +767,9 @@ func (syncer *Syncer) VerifyElectionPoStProof(ctx context.Context, h *types.Bloc
 }
 
 func (syncer *Syncer) checkBlockMessages(ctx context.Context, b *types.FullBlock, baseTs *types.TipSet) error {
-	{
+	// signatures don't depend on message order, check them while the
+	// nonces and balances are being validated
+	blsCheck := async.Err(func() error {
 		var sigCids []cid.Cid // this is what we get for people not wanting the marshalcbor method on the cid type
 		var pubks []bls.PublicKey
 
@@ -723,17 +784,22 @@ func (syncer *Syncer) checkBlockMessages(ctx context.Context, b *types.FullBlock
 			pubks = append(pubks, pubk)
 		}
 
-		if err := syncer.verifyBlsAggregate(ctx, b.Header.BLSAggregate, sigCids, pubks); err != nil {
+		if err := syncer.verifyBlockBlsAggregate(ctx, b.Header, sigCids, pubks); err != nil {
 			return xerrors.Errorf("bls aggregate signature was invalid: %w", err)
 		}
-	}
+		return nil
+	})
+
+	secpkCheck := async.Err(func() error {
+		return syncer.verifySecpkSignatures(ctx, b.SecpkMessages, baseTs)
+	})
 
 	nonces := make(map[address.Address]uint64)
 	balances := make(map[address.Address]types.BigInt)
 
 	stateroot, _, err := syncer.sm.TipSetState(ctx, baseTs)
 	if err != nil {
-		return err
+		return beaconTemporal(err)
 	}
 
 	cst := hamt.CSTFromBstore(syncer.store.Blockstore())
@@ -787,15 +853,6 @@ func (syncer *Syncer) checkBlockMessages(ctx context.Context, b *types.FullBlock
 			return xerrors.Errorf("block had invalid secpk message at index %d: %w", i, err)
 		}
 
-		kaddr, err := syncer.sm.ResolveToKeyAddress(ctx, m.Message.From, baseTs)
-		if err != nil {
-			return xerrors.Errorf("failed to resolve key addr: %w", err)
-		}
-
-		if err := sigs.Verify(&m.Signature, kaddr, m.Message.Cid().Bytes()); err != nil {
-			return xerrors.Errorf("secpk message %s has invalid signature: %w", m.Cid(), err)
-		}
-
 		c := cbg.CborCid(m.Cid())
 		secpkCids = append(secpkCids, &c)
 	}
@@ -822,6 +879,57 @@ func (syncer *Syncer) checkBlockMessages(ctx context.Context, b *types.FullBlock
 		return fmt.Errorf("messages didnt match message root in header")
 	}
 
+	if err := blsCheck.AwaitContext(ctx); err != nil {
+		return err
+	}
+
+	return secpkCheck.AwaitContext(ctx)
+}
+
+// verifySecpkSignatures checks message signatures across a pool of workers,
+// one per CPU
+func (syncer *Syncer) verifySecpkSignatures(ctx context.Context, msgs []*types.SignedMessage, baseTs *types.TipSet) error {
+	_, span := trace.StartSpan(ctx, "syncer.verifySecpkSignatures")
+	defer span.End()
+	span.AddAttributes(
+		trace.Int64Attribute("msgCount", int64(len(msgs))),
+	)
+
+	workers := runtime.NumCPU()
+	if workers > len(msgs) {
+		workers = len(msgs)
+	}
+
+	errs := make([]error, len(msgs))
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(msgs); i += workers {
+				m := msgs[i]
+
+				kaddr, err := syncer.sm.ResolveToKeyAddress(ctx, m.Message.From, baseTs)
+				if err != nil {
+					errs[i] = xerrors.Errorf("failed to resolve key addr: %w", err)
+					continue
+				}
+
+				if err := syncer.sigCache.Verify(&m.Signature, kaddr, m.Message.Cid().Bytes()); err != nil {
+					errs[i] = xerrors.Errorf("secpk message %s has invalid signature: %w", m.Cid(), err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return xerrors.Errorf("block had invalid secpk message at index %d: %w", i, err)
+		}
+	}
+
 	return nil
 }
 
@@ -855,6 +963,64 @@ func (syncer *Syncer) verifyBlsAggregate(ctx context.Context, sig types.Signatur
 	return nil
 }
 
+// blsAggregateKey identifies a block BLS aggregate along with the public keys
+// the message senders resolved to in the block's parent state. The same
+// messages and aggregate can't be trusted under a different resolution.
+func blsAggregateKey(h *types.BlockHeader, pubks []bls.PublicKey) string {
+	hasher := sha256.New()
+	hasher.Write(h.Messages.Bytes())  // nolint:errcheck
+	hasher.Write(h.BLSAggregate.Data) // nolint:errcheck
+	for _, pk := range pubks {
+		hasher.Write(pk[:]) // nolint:errcheck
+	}
+	return string(hasher.Sum(nil))
+}
+
+// verifyBlockBlsAggregate checks the BLS aggregate of a block, skipping the
+// expensive pairing check when the aggregate was verified before, or when
+// the signatures of all messages were verified separately, e.g. by the mpool
+func (syncer *Syncer) verifyBlockBlsAggregate(ctx context.Context, h *types.BlockHeader, msgs []cid.Cid, pubks []bls.PublicKey) error {
+	k := blsAggregateKey(h, pubks)
+	if syncer.blsVerified.Contains(k) {
+		return nil
+	}
+
+	if !syncer.aggregateFromVerified(h.BLSAggregate, msgs, pubks) {
+		if err := syncer.verifyBlsAggregate(ctx, h.BLSAggregate, msgs, pubks); err != nil {
+			return err
+		}
+	}
+
+	syncer.blsVerified.Add(k, struct{}{})
+	return nil
+}
+
+// aggregateFromVerified returns true when the aggregate of already verified
+// message signatures equals sig
+func (syncer *Syncer) aggregateFromVerified(sig types.Signature, msgs []cid.Cid, pubks []bls.PublicKey) bool {
+	if len(msgs) == 0 {
+		return false
+	}
+
+	blsSigs := make([]bls.Signature, len(msgs))
+	for i, m := range msgs {
+		addr, err := address.NewBLSAddress(pubks[i][:])
+		if err != nil {
+			return false
+		}
+
+		msig, ok := syncer.sigCache.Verified(addr, m.Bytes())
+		if !ok || msig.Type != types.KTBLS {
+			return false
+		}
+
+		copy(blsSigs[i][:], msig.Data)
+	}
+
+	agg := bls.Aggregate(blsSigs)
+	return bytes.Equal(agg[:], sig.Data)
+}
+
 type syncStateKey struct{}
 
 func extractSyncState(ctx context.Context) *SyncerState {
@@ -987,9 +1153,41 @@ loop:
 		blockSet = append(blockSet, fork...)
 	}
 
+	if err := syncer.checkCheckpoint(ctx, from, blockSet); err != nil {
+		return nil, err
+	}
+
 	return blockSet, nil
 }
 
+// checkCheckpoint makes sure that a chain reaching past the checkpoint height
+// contains the checkpoint
+func (syncer *Syncer) checkCheckpoint(ctx context.Context, from *types.TipSet, blockSet []*types.TipSet) error {
+	cp, err := syncer.Checkpoint(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting checkpoint: %w", err)
+	}
+	if cp == nil || from.Height() < cp.Height() {
+		return nil
+	}
+
+	var below bool
+	for _, ts := range blockSet {
+		if ts.Equals(cp) {
+			return nil
+		}
+		if ts.Height() <= cp.Height() {
+			below = true
+		}
+	}
+
+	if below {
+		return xerrors.Errorf("synced chain (%s - %d) forks off before checkpoint %s (%d)", from.Cids(), from.Height(), cp.Cids(), cp.Height())
+	}
+
+	return nil
+}
+
 var ErrForkTooLong = fmt.Errorf("fork longer than threshold")
 
 func (syncer *Syncer) syncFork(ctx context.Context, from *types.TipSet, to *types.TipSet) ([]*types.TipSet, error) {
@@ -1031,7 +1229,73 @@ func (syncer *Syncer) syncMessagesAndCheckState(ctx context.Context, headers []*
 	ss := extractSyncState(ctx)
 	ss.SetHeight(0)
 
+	// if the checkpoint is part of the synced chain we trust everything up to it
+	var trusted *types.TipSet
+	cp, err := syncer.Checkpoint(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting checkpoint: %w", err)
+	}
+	if cp != nil {
+		for _, ts := range headers {
+			if ts.Equals(cp) {
+				trusted = cp
+				log.Infow("skipping validation up to checkpoint", "height", cp.Height(), "tipset", types.LogCids(cp.Cids()))
+				break
+			}
+		}
+	}
+
+	if trusted != nil {
+		// the state of a tipset below the checkpoint is the parent state of
+		// its (trusted) child, so history doesn't have to be re-executed. Only
+		// the checkpoint itself is executed once its child is validated, on
+		// top of its parent state, which has to be available locally, e.g.
+		// from an imported snapshot
+		if headers[0].Height() > trusted.Height() {
+			has, err := syncer.store.Blockstore().Has(trusted.ParentState())
+			if err != nil {
+				return xerrors.Errorf("checking for checkpoint parent state: %w", err)
+			}
+			if !has {
+				return xerrors.Errorf("parent state %s of checkpoint %s isn't available locally, import a snapshot containing it first", trusted.ParentState(), trusted.Cids())
+			}
+		}
+
+		for _, ts := range headers {
+			if ts.Height() > trusted.Height() || ts.Height() == 0 {
+				continue
+			}
+
+			pts, err := syncer.store.LoadTipSet(ts.Parents())
+			if err != nil {
+				return xerrors.Errorf("loading parent of checkpointed tipset %s: %w", ts.Cids(), err)
+			}
+			syncer.sm.SetTrustedState(pts, ts.ParentState(), ts.Blocks()[0].ParentMessageReceipts)
+		}
+	}
+
+	// fetch the beacon entries of the election randomness up front, when
+	// catching up validating each tipset would otherwise wait for its entry
+	low := headers[len(headers)-1].Height()
+	if trusted != nil && trusted.Height() > low {
+		low = trusted.Height()
+	}
+	if err := syncer.store.PrefetchBeacon(ctx, int64(low)-build.EcRandomnessLookback, int64(headers[0].Height())); err != nil {
+		log.Warnf("prefetching beacon entries: %s", err)
+	}
+
 	return syncer.iterFullTipsets(ctx, headers, func(ctx context.Context, fts *store.FullTipSet) error {
+		if trusted != nil && fts.TipSet().Height() <= trusted.Height() {
+			for _, b := range fts.Blocks {
+				if err := syncer.sm.ChainStore().AddToTipSetTracker(b.Header); err != nil {
+					return xerrors.Errorf("failed to add checkpointed header to tipset tracker: %w", err)
+				}
+			}
+
+			ss.SetHeight(fts.TipSet().Height())
+			return nil
+		}
+
 		log.Debugw("validating tipset", "height", fts.TipSet().Height(), "size", len(fts.TipSet().Cids()))
 		if err := syncer.ValidateTipSet(ctx, fts); err != nil {
 			log.Errorf("failed to validate tipset: %+v", err)
@@ -1194,6 +1458,123 @@ func (syncer *Syncer) State() []SyncerState {
 	return out
 }
 
+// Checkpoint returns the trusted checkpoint tipset, fetching its headers from
+// the network if they aren't available locally yet. Returns nil when no
+// checkpoint is set.
+func (syncer *Syncer) Checkpoint(ctx context.Context) (*types.TipSet, error) {
+	syncer.cpLk.Lock()
+	if !syncer.cpLoaded {
+		tsk, err := syncer.store.GetCheckpoint()
+		if err != nil {
+			syncer.cpLk.Unlock()
+			return nil, err
+		}
+		syncer.cpKey = tsk
+		syncer.cpLoaded = true
+	}
+	tsk, cp := syncer.cpKey, syncer.cpTs
+	syncer.cpLk.Unlock()
+
+	if tsk == (types.TipSetKey{}) || cp != nil {
+		return cp, nil
+	}
+
+	cp, err := syncer.loadCheckpoint(ctx, tsk)
+	if err != nil {
+		return nil, err
+	}
+
+	syncer.cpLk.Lock()
+	defer syncer.cpLk.Unlock()
+	if syncer.cpKey == tsk {
+		syncer.cpTs = cp
+	}
+
+	return cp, nil
+}
+
+// loadCheckpoint loads the checkpoint tipset, fetching and persisting its
+// headers if they aren't available locally
+func (syncer *Syncer) loadCheckpoint(ctx context.Context, tsk types.TipSetKey) (*types.TipSet, error) {
+	cp, err := syncer.store.LoadTipSet(tsk)
+	if err == nil {
+		return cp, nil
+	}
+
+	blks, err := syncer.Bsync.GetBlocks(ctx, tsk, 1)
+	if err != nil {
+		return nil, xerrors.Errorf("fetching checkpoint tipset %s: %w", tsk, err)
+	}
+
+	if err := syncer.store.PersistBlockHeaders(blks[0].Blocks()...); err != nil {
+		return nil, xerrors.Errorf("persisting checkpoint headers: %w", err)
+	}
+
+	return blks[0], nil
+}
+
+func (syncer *Syncer) setCheckpointKey(tsk types.TipSetKey) {
+	syncer.cpLk.Lock()
+	defer syncer.cpLk.Unlock()
+
+	syncer.cpKey = tsk
+	syncer.cpTs = nil
+	syncer.cpLoaded = true
+}
+
+// SetConfigCheckpoint sets a checkpoint which, unlike ones set with
+// SetCheckpoint, isn't persisted. The checkpoint configured for the node takes
+// precedence over a stored one, and stops applying as soon as it's removed
+// from the config.
+func (syncer *Syncer) SetConfigCheckpoint(tsk types.TipSetKey) {
+	syncer.setCheckpointKey(tsk)
+}
+
+// SetCheckpoint makes the syncer trust the chain up to the given tipset.
+// Chains that don't contain the checkpoint are rejected, and tipsets up to the
+// checkpoint are accepted without being fully validated.
+func (syncer *Syncer) SetCheckpoint(ctx context.Context, tsk types.TipSetKey) error {
+	if tsk == (types.TipSetKey{}) {
+		return xerrors.Errorf("checkpoint tipset key can't be empty")
+	}
+
+	cp, err := syncer.loadCheckpoint(ctx, tsk)
+	if err != nil {
+		return xerrors.Errorf("resolving checkpoint: %w", err)
+	}
+
+	// check the new checkpoint before storing it, so a rejected one doesn't
+	// replace the one already set
+	head := syncer.store.GetHeaviestTipSet()
+	if head != nil && head.Height() >= cp.Height() {
+		onChain, err := syncer.store.GetTipsetByHeight(ctx, cp.Height(), head)
+		if err != nil {
+			return xerrors.Errorf("looking up tipset at checkpoint height: %w", err)
+		}
+
+		if !onChain.Equals(cp) {
+			return xerrors.Errorf("checkpoint %s is not on the current chain (have %s at height %d)", cp.Cids(), onChain.Cids(), cp.Height())
+		}
+	}
+
+	if err := syncer.store.SetCheckpoint(tsk); err != nil {
+		return err
+	}
+
+	syncer.cpLk.Lock()
+	defer syncer.cpLk.Unlock()
+	syncer.cpKey = tsk
+	syncer.cpTs = cp
+	syncer.cpLoaded = true
+
+	return nil
+}
+
+func (syncer *Syncer) RemoveCheckpoint() error {
+	syncer.setCheckpointKey(types.TipSetKey{})
+	return syncer.store.RemoveCheckpoint()
+}
+
 func (syncer *Syncer) MarkBad(blk cid.Cid) {
 	syncer.bad.Add(blk, "manually marked bad")
 }
@@ -365,6 +365,32 @@ func TestSyncSimple(t *testing.T) {
 	tu.compareSourceState(client)
 }
 
+func TestSyncCheckpoint(t *testing.T) {
+	H := 20
+	tu := prepSyncTest(t, H)
+
+	client := tu.addClientNode()
+
+	require.NoError(t, tu.mn.LinkAll())
+	tu.connect(client, source)
+
+	cp := tu.blocks[H/2].TipSet()
+	require.NoError(t, tu.nds[client].SyncCheckpoint(tu.ctx, cp.Key()))
+
+	got, err := tu.nds[client].SyncGetCheckpoint(tu.ctx)
+	require.NoError(t, err)
+	require.True(t, got.Equals(cp))
+
+	tu.waitUntilSync(source, client)
+	tu.compareSourceState(client)
+
+	require.NoError(t, tu.nds[client].SyncClearCheckpoint(tu.ctx))
+
+	got, err = tu.nds[client].SyncGetCheckpoint(tu.ctx)
+	require.NoError(t, err)
+	require.Nil(t, got)
+}
+
 func TestSyncMining(t *testing.T) {
 	H := 50
 	tu := prepSyncTest(t, H)
@@ -0,0 +1,52 @@
+package types
+
+import (
+	"time"
+)
+
+// ExecutionTrace describes a single invocation made while executing a
+// message, along with all the invocations it made in turn
+type ExecutionTrace struct {
+	Msg        *Message
+	MsgRct     *MessageReceipt
+	Error      string
+	Duration   time.Duration
+	GasCharges []*GasTrace
+
+	Subcalls []*ExecutionTrace
+}
+
+// Gas charge classes
+const (
+	GasClassMessage      = "message"
+	GasClassStorageRead  = "storage-read"
+	GasClassStorageWrite = "storage-write"
+	GasClassSyscall      = "syscall"
+	GasClassCompute      = "compute"
+)
+
+// GasTrace is a single gas charge made during an invocation
+type GasTrace struct {
+	// Class is the kind of operation the gas was charged for
+	Class string
+	// Location is the function which charged the gas
+	Location string
+	Amount   uint64
+}
+
+// GasBreakdown sums the gas charged by the invocation and all its subcalls
+// by operation class
+func (et *ExecutionTrace) GasBreakdown() map[string]uint64 {
+	out := map[string]uint64{}
+	et.addGas(out)
+	return out
+}
+
+func (et *ExecutionTrace) addGas(out map[string]uint64) {
+	for _, gc := range et.GasCharges {
+		out[gc.Class] += gc.Amount
+	}
+	for _, sub := range et.Subcalls {
+		sub.addGas(out)
+	}
+}
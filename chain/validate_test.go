@@ -0,0 +1,66 @@
+package chain
+
+import (
+	"testing"
+
+	bls "github.com/filecoin-project/filecoin-ffi"
+	"github.com/ipfs/go-cid"
+	"go.uber.org/multierr"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/beacon"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestIsPermanent(t *testing.T) {
+	permanent := xerrors.New("invalid signature")
+	temporal := xerrors.Errorf("block was from the future: %w", ErrTemporal)
+
+	if !isPermanent(permanent) {
+		t.Error("expected plain error to be permanent")
+	}
+	if isPermanent(temporal) {
+		t.Error("expected wrapped ErrTemporal not to be permanent")
+	}
+	if !isPermanent(multierr.Append(permanent, xerrors.New("bad ticket"))) {
+		t.Error("expected combined permanent errors to be permanent")
+	}
+	if !isPermanent(multierr.Append(temporal, permanent)) {
+		t.Error("expected temporal error combined with a permanent one to be permanent")
+	}
+	if isPermanent(multierr.Append(temporal, xerrors.Errorf("ticket: %w", ErrTemporal))) {
+		t.Error("expected combined temporal errors not to be permanent")
+	}
+}
+
+func TestBeaconTemporal(t *testing.T) {
+	missing := xerrors.Errorf("getting beacon entry: %w", beacon.ErrEntryNotAvailable)
+	if isPermanent(beaconTemporal(xerrors.Errorf("computing state: %w", missing))) {
+		t.Error("expected missing beacon entries not to be permanent")
+	}
+	if !isPermanent(beaconTemporal(xerrors.New("invalid signature"))) {
+		t.Error("expected other errors to stay permanent")
+	}
+}
+
+func TestBlsAggregateKey(t *testing.T) {
+	msgs, err := cid.Decode("bafyreicmaj5hhoy5mgqvamfhgexxyergw7hdeshizghodwkjg6qmpoco7i")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &types.BlockHeader{
+		Messages:     msgs,
+		BLSAggregate: types.Signature{Type: types.KTBLS, Data: []byte("aggregate")},
+	}
+
+	var pk1, pk2 bls.PublicKey
+	pk1[0] = 1
+	pk2[0] = 2
+
+	if blsAggregateKey(h, []bls.PublicKey{pk1}) == blsAggregateKey(h, []bls.PublicKey{pk2}) {
+		t.Fatal("expected keys of the same aggregate with different signer keys to differ")
+	}
+	if blsAggregateKey(h, []bls.PublicKey{pk1}) != blsAggregateKey(h, []bls.PublicKey{pk1}) {
+		t.Fatal("expected keys to be deterministic")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"runtime"
+	"time"
 
 	block "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
@@ -62,6 +64,9 @@ type VMContext struct {
 
 	// address that started invoke chain
 	origin address.Address
+
+	// execution trace of this invocation, only set when tracing is enabled
+	trace *types.ExecutionTrace
 }
 
 // Message is the message that kicked off the current invocation
@@ -105,7 +110,7 @@ func (vmc *VMContext) GetHead() cid.Cid {
 }
 
 func (vmc *VMContext) Commit(oldh, newh cid.Cid) aerrors.ActorError {
-	if err := vmc.ChargeGas(gasCommit); err != nil {
+	if err := vmc.chargeGas(types.GasClassStorageWrite, gasCommit); err != nil {
 		return aerrors.Wrap(err, "out of gas")
 	}
 	if vmc.sroot != oldh {
@@ -165,7 +170,26 @@ func (vmc *VMContext) GasUsed() types.BigInt {
 	return vmc.gasUsed
 }
 
+// ChargeGas charges gas for computation done by actors
 func (vmc *VMContext) ChargeGas(amount uint64) aerrors.ActorError {
+	return vmc.charge(types.GasClassCompute, amount)
+}
+
+func (vmc *VMContext) chargeGas(class string, amount uint64) aerrors.ActorError {
+	return vmc.charge(class, amount)
+}
+
+// charge must only be called by ChargeGas and chargeGas, so that the
+// location recorded in traces is the function charging gas
+func (vmc *VMContext) charge(class string, amount uint64) aerrors.ActorError {
+	if vmc.trace != nil {
+		vmc.trace.GasCharges = append(vmc.trace.GasCharges, &types.GasTrace{
+			Class:    class,
+			Location: callerName(3),
+			Amount:   amount,
+		})
+	}
+
 	toUse := types.NewInt(amount)
 	vmc.gasUsed = types.BigAdd(vmc.gasUsed, toUse)
 	if vmc.gasUsed.GreaterThan(vmc.gasAvailable) {
@@ -185,7 +209,7 @@ func (vmc *VMContext) StateTree() (types.StateTree, aerrors.ActorError) {
 const GasVerifySignature = 50
 
 func (vmctx *VMContext) VerifySignature(sig *types.Signature, act address.Address, data []byte) aerrors.ActorError {
-	if err := vmctx.ChargeGas(GasVerifySignature); err != nil {
+	if err := vmctx.chargeGas(types.GasClassSyscall, GasVerifySignature); err != nil {
 		return err
 	}
 
@@ -250,19 +274,19 @@ type hBlocks interface {
 var _ hBlocks = (*gasChargingBlocks)(nil)
 
 type gasChargingBlocks struct {
-	chargeGas func(uint64) aerrors.ActorError
+	chargeGas func(string, uint64) aerrors.ActorError
 	under     hBlocks
 }
 
 func (bs *gasChargingBlocks) GetBlock(ctx context.Context, c cid.Cid) (block.Block, error) {
-	if err := bs.chargeGas(gasGetObj); err != nil {
+	if err := bs.chargeGas(types.GasClassStorageRead, gasGetObj); err != nil {
 		return nil, err
 	}
 	blk, err := bs.under.GetBlock(ctx, c)
 	if err != nil {
 		return nil, aerrors.Escalate(err, "failed to get block from blockstore")
 	}
-	if err := bs.chargeGas(uint64(len(blk.RawData())) * gasGetPerByte); err != nil {
+	if err := bs.chargeGas(types.GasClassStorageRead, uint64(len(blk.RawData()))*gasGetPerByte); err != nil {
 		return nil, err
 	}
 
@@ -270,7 +294,7 @@ func (bs *gasChargingBlocks) GetBlock(ctx context.Context, c cid.Cid) (block.Blo
 }
 
 func (bs *gasChargingBlocks) AddBlock(blk block.Block) error {
-	if err := bs.chargeGas(gasPutObj + uint64(len(blk.RawData()))*gasPutPerByte); err != nil {
+	if err := bs.chargeGas(types.GasClassStorageWrite, gasPutObj+uint64(len(blk.RawData()))*gasPutPerByte); err != nil {
 		return err
 	}
 	if err := bs.under.AddBlock(blk); err != nil {
@@ -294,7 +318,7 @@ func (vm *VM) makeVMContext(ctx context.Context, sroot cid.Cid, msg *types.Messa
 		gasAvailable: msg.GasLimit,
 	}
 	vmc.cst = &hamt.CborIpldStore{
-		Blocks: &gasChargingBlocks{vmc.ChargeGas, vm.cst.Blocks},
+		Blocks: &gasChargingBlocks{vmc.chargeGas, vm.cst.Blocks},
 		Atlas:  vm.cst.Atlas,
 	}
 	return vmc
@@ -309,6 +333,9 @@ type VM struct {
 	blockMiner  address.Address
 	inv         *invoker
 	rand        Rand
+	tracing     bool
+	// trace of the message last applied with ApplyMessage
+	lastTrace *types.ExecutionTrace
 
 	Syscalls *types.VMSyscalls
 }
@@ -341,10 +368,74 @@ type Rand interface {
 type ApplyRet struct {
 	types.MessageReceipt
 	ActorErr aerrors.ActorError
+
+	// ExecutionTrace is only set when tracing is enabled on the VM
+	ExecutionTrace *types.ExecutionTrace
+}
+
+// EnableTracing makes the VM record execution traces of applied messages.
+// Tracing slows execution down, it's meant for replaying messages.
+func (vm *VM) EnableTracing() {
+	vm.tracing = true
+}
+
+// callerName returns the name of the function skip frames up the stack
+func callerName(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip)
+	if !ok {
+		return "unknown"
+	}
+
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return "unknown"
+	}
+	return fn.Name()
 }
 
 func (vm *VM) send(ctx context.Context, msg *types.Message, parent *VMContext,
-	gasCharge uint64) ([]byte, aerrors.ActorError, *VMContext) {
+	gasCharge uint64) (ret []byte, aerr aerrors.ActorError, vmctx *VMContext) {
+
+	var et *types.ExecutionTrace
+	if vm.tracing {
+		et = &types.ExecutionTrace{Msg: msg}
+		if parent != nil && parent.trace != nil {
+			parent.trace.Subcalls = append(parent.trace.Subcalls, et)
+		}
+		if gasCharge > 0 {
+			et.GasCharges = append(et.GasCharges, &types.GasTrace{
+				Class:    types.GasClassMessage,
+				Location: callerName(2),
+				Amount:   gasCharge,
+			})
+		}
+
+		start := time.Now()
+		var startGas types.BigInt
+		if parent != nil {
+			startGas = parent.gasUsed
+		} else {
+			startGas = types.NewInt(0)
+		}
+
+		defer func() {
+			et.Duration = time.Since(start)
+			et.MsgRct = &types.MessageReceipt{
+				ExitCode: aerrors.RetCode(aerr),
+				Return:   ret,
+				GasUsed:  types.NewInt(gasCharge),
+			}
+			if vmctx != nil {
+				et.MsgRct.GasUsed = types.BigSub(vmctx.gasUsed, startGas)
+			}
+			if aerr != nil {
+				et.Error = aerr.Error()
+			}
+			if parent == nil {
+				vm.lastTrace = et
+			}
+		}()
+	}
 
 	st := vm.cstate
 	fromActor, err := st.GetActor(msg.From)
@@ -371,7 +462,8 @@ func (vm *VM) send(ctx context.Context, msg *types.Message, parent *VMContext,
 		gasUsed = types.BigAdd(parent.gasUsed, gasUsed)
 		origin = parent.origin
 	}
-	vmctx := vm.makeVMContext(ctx, toActor.Head, msg, origin, gasUsed)
+	vmctx = vm.makeVMContext(ctx, toActor.Head, msg, origin, gasUsed)
+	vmctx.trace = et
 	if parent != nil {
 		defer func() {
 			parent.gasUsed = vmctx.gasUsed
@@ -379,7 +471,7 @@ func (vm *VM) send(ctx context.Context, msg *types.Message, parent *VMContext,
 	}
 
 	if types.BigCmp(msg.Value, types.NewInt(0)) != 0 {
-		if aerr := vmctx.ChargeGas(gasFundTransfer); aerr != nil {
+		if aerr := vmctx.chargeGas(types.GasClassStorageWrite, gasFundTransfer); aerr != nil {
 			return nil, aerrors.Wrap(aerr, "sending funds"), nil
 		}
 
@@ -505,13 +597,25 @@ func (vm *VM) ApplyMessage(ctx context.Context, msg *types.Message) (*ApplyRet,
 		return nil, xerrors.Errorf("gas handling math is wrong")
 	}
 
+	rct := types.MessageReceipt{
+		ExitCode: errcode,
+		Return:   ret,
+		GasUsed:  gasUsed,
+	}
+
+	var et *types.ExecutionTrace
+	if vm.tracing {
+		et, vm.lastTrace = vm.lastTrace, nil
+		if et != nil {
+			// gas used by the message includes the failure penalty
+			et.MsgRct = &rct
+		}
+	}
+
 	return &ApplyRet{
-		MessageReceipt: types.MessageReceipt{
-			ExitCode: errcode,
-			Return:   ret,
-			GasUsed:  gasUsed,
-		},
-		ActorErr: actorErr,
+		MessageReceipt: rct,
+		ActorErr:       actorErr,
+		ExecutionTrace: et,
 	}, nil
 }
 
@@ -646,7 +750,7 @@ func (vm *VM) Invoke(act *types.Actor, vmctx *VMContext, method uint64, params [
 	defer func() {
 		vmctx.ctx = oldCtx
 	}()
-	if err := vmctx.ChargeGas(gasInvoke); err != nil {
+	if err := vmctx.chargeGas(types.GasClassCompute, gasInvoke); err != nil {
 		return nil, aerrors.Wrap(err, "invokeing")
 	}
 	ret, err := vm.inv.Invoke(act, vmctx, method, params)
@@ -19,6 +19,8 @@ var log = logging.Logger("wallet")
 const (
 	KNamePrefix = "wallet-"
 	KDefault    = "default"
+	// KDefaultPrefix prefixes the names of per-purpose default keys
+	KDefaultPrefix = KDefault + "-"
 )
 
 type Wallet struct {
@@ -140,7 +142,11 @@ func (w *Wallet) GetDefault() (address.Address, error) {
 	w.lk.Lock()
 	defer w.lk.Unlock()
 
-	ki, err := w.keystore.Get(KDefault)
+	return w.getDefault(KDefault)
+}
+
+func (w *Wallet) getDefault(name string) (address.Address, error) {
+	ki, err := w.keystore.Get(name)
 	if err != nil {
 		return address.Undef, xerrors.Errorf("failed to get default key: %w", err)
 	}
@@ -153,22 +159,86 @@ func (w *Wallet) GetDefault() (address.Address, error) {
 	return k.Address, nil
 }
 
+// GetDefaultFor returns the default address for purpose, falling back to the
+// wallet default when none was set
+func (w *Wallet) GetDefaultFor(purpose string) (address.Address, error) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	a, err := w.getDefault(KDefaultPrefix + purpose)
+	if xerrors.Is(err, types.ErrKeyInfoNotFound) {
+		return w.getDefault(KDefault)
+	}
+	return a, err
+}
+
+// ListDefaults returns the per-purpose default addresses
+func (w *Wallet) ListDefaults() (map[string]address.Address, error) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	all, err := w.keystore.List()
+	if err != nil {
+		return nil, xerrors.Errorf("listing keystore: %w", err)
+	}
+
+	out := map[string]address.Address{}
+	for _, name := range all {
+		if !strings.HasPrefix(name, KDefaultPrefix) {
+			continue
+		}
+
+		a, err := w.getDefault(name)
+		if err != nil {
+			return nil, err
+		}
+		out[strings.TrimPrefix(name, KDefaultPrefix)] = a
+	}
+
+	return out, nil
+}
+
 func (w *Wallet) SetDefault(a address.Address) error {
 	w.lk.Lock()
 	defer w.lk.Unlock()
 
+	return w.setDefault(KDefault, a)
+}
+
+// SetDefaultFor sets the default address for purpose, an undefined address
+// removes it
+func (w *Wallet) SetDefaultFor(purpose string, a address.Address) error {
+	if purpose == "" {
+		return xerrors.New("no purpose given")
+	}
+
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	if a == address.Undef {
+		err := w.keystore.Delete(KDefaultPrefix + purpose)
+		if err != nil && !xerrors.Is(err, types.ErrKeyInfoNotFound) {
+			return err
+		}
+		return nil
+	}
+
+	return w.setDefault(KDefaultPrefix+purpose, a)
+}
+
+func (w *Wallet) setDefault(name string, a address.Address) error {
 	ki, err := w.keystore.Get(KNamePrefix + a.String())
 	if err != nil {
 		return err
 	}
 
-	if err := w.keystore.Delete(KDefault); err != nil {
+	if err := w.keystore.Delete(name); err != nil {
 		if !xerrors.Is(err, types.ErrKeyInfoNotFound) {
 			log.Warnf("failed to unregister current default key: %s", err)
 		}
 	}
 
-	if err := w.keystore.Put(KDefault, ki); err != nil {
+	if err := w.keystore.Put(name, ki); err != nil {
 		return err
 	}
 
@@ -3,6 +3,9 @@ package cli
 import (
 	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
 
 	"gopkg.in/urfave/cli.v2"
 
@@ -14,6 +17,8 @@ var authCmd = &cli.Command{
 	Usage: "Manage RPC permissions",
 	Subcommands: []*cli.Command{
 		authCreateAdminToken,
+		authListTokens,
+		authRevokeToken,
 	},
 }
 
@@ -23,7 +28,7 @@ var authCreateAdminToken = &cli.Command{
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name:  "perm",
-			Usage: "permission to assign to the token, one of: read, write, sign, admin",
+			Usage: "permission to assign to the token, one of: read, write, sign, admin, or a scope: chain-read, mpool-push, wallet-sign, miner-admin",
 		},
 	},
 
@@ -41,6 +46,16 @@ var authCreateAdminToken = &cli.Command{
 		}
 
 		perm := cctx.String("perm")
+		if _, ok := apistruct.Scopes[perm]; ok {
+			token, err := napi.AuthNew(ctx, []string{perm})
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(token))
+			return nil
+		}
+
 		idx := 0
 		for i, p := range apistruct.AllPermissions {
 			if perm == p {
@@ -49,7 +64,7 @@ var authCreateAdminToken = &cli.Command{
 		}
 
 		if idx == 0 {
-			return fmt.Errorf("--perm flag has to be one of: %s", apistruct.AllPermissions)
+			return fmt.Errorf("--perm flag has to be one of: %s, %s", apistruct.AllPermissions, apistruct.AllScopes)
 		}
 
 		// slice on [:idx] so for example: 'sign' gives you [read, write, sign]
@@ -64,3 +79,50 @@ var authCreateAdminToken = &cli.Command{
 		return nil
 	},
 }
+
+var authListTokens = &cli.Command{
+	Name:  "list-tokens",
+	Usage: "List revocable tokens",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := GetAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		toks, err := napi.AuthList(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "ID\tPermissions\tCreated\n")
+		for _, tok := range toks {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", tok.ID, strings.Join(tok.Allow, ","), tok.Created.Format("2006-01-02 15:04:05"))
+		}
+		return w.Flush()
+	},
+}
+
+var authRevokeToken = &cli.Command{
+	Name:      "revoke-token",
+	Usage:     "Revoke a token",
+	ArgsUsage: "<token id>",
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := GetAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		if cctx.Args().Len() != 1 {
+			return errors.New("must pass the id of the token to revoke")
+		}
+
+		return napi.AuthRevoke(ctx, cctx.Args().First())
+	},
+}
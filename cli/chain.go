@@ -7,15 +7,19 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 
+	"github.com/docker/go-units"
 	cid "github.com/ipfs/go-cid"
 	"golang.org/x/xerrors"
 	"gopkg.in/urfave/cli.v2"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/actors"
 	types "github.com/filecoin-project/lotus/chain/types"
 )
@@ -33,6 +37,9 @@ var chainCmd = &cli.Command{
 		chainGetCmd,
 		chainBisectCmd,
 		chainExportCmd,
+		chainPruneCmd,
+		chainGCCmd,
+		chainGasTraceCmd,
 		slashConsensusFault,
 	},
 }
@@ -518,6 +525,10 @@ var chainExportCmd = &cli.Command{
 		&cli.StringFlag{
 			Name: "tipset",
 		},
+		&cli.Uint64Flag{
+			Name:  "recent-stateroots",
+			Usage: "include state trees for this many recent epochs, making the export usable as a snapshot",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
@@ -531,6 +542,11 @@ var chainExportCmd = &cli.Command{
 			return fmt.Errorf("must specify filename to export chain to")
 		}
 
+		rsrs := cctx.Uint64("recent-stateroots")
+		if cctx.IsSet("recent-stateroots") && rsrs < build.Finality {
+			return fmt.Errorf("\"recent-stateroots\" has to be at least %d", build.Finality)
+		}
+
 		fi, err := os.Create(cctx.Args().First())
 		if err != nil {
 			return err
@@ -542,7 +558,7 @@ var chainExportCmd = &cli.Command{
 			return err
 		}
 
-		stream, err := api.ChainExport(ctx, ts)
+		stream, err := api.ChainExport(ctx, rsrs, ts)
 		if err != nil {
 			return err
 		}
@@ -558,6 +574,121 @@ var chainExportCmd = &cli.Command{
 	},
 }
 
+var chainPruneCmd = &cli.Command{
+	Name:  "prune",
+	Usage: "remove old state trees from the blockstore, keeping block headers and messages",
+	Flags: []cli.Flag{
+		&cli.Uint64Flag{
+			Name:  "retain",
+			Usage: "number of recent epochs to keep state for",
+			Value: build.Finality,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		retain := cctx.Uint64("retain")
+		if retain < build.Finality {
+			return fmt.Errorf("\"retain\" has to be at least %d", build.Finality)
+		}
+
+		res, err := api.ChainPrune(ctx, retain)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Pruned %d state roots (%d blocks), reclaimed %s\n", res.Roots, res.Blocks, units.BytesSize(float64(res.Bytes)))
+		return nil
+	},
+}
+
+var chainGCCmd = &cli.Command{
+	Name:  "gc",
+	Usage: "reclaim datastore space used by deleted and overwritten entries, e.g. after pruning",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		start := time.Now()
+		if err := api.DatastoreGC(ctx); err != nil {
+			return err
+		}
+
+		fmt.Printf("Datastore garbage collection took %s\n", time.Since(start).Truncate(time.Millisecond))
+		return nil
+	},
+}
+
+var chainGasTraceCmd = &cli.Command{
+	Name:      "gas-trace",
+	Usage:     "Show how gas was spent executing an on-chain message",
+	ArgsUsage: "[messageCid]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "verbose",
+			Usage: "print every gas charge along with the call tree",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if !cctx.Args().Present() {
+			return fmt.Errorf("must specify message cid")
+		}
+
+		mcid, err := cid.Decode(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("parsing message cid: %w", err)
+		}
+
+		gp, err := api.ChainGasTrace(ctx, mcid)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Message: %s\n", gp.MsgCid)
+		fmt.Printf("Included in: %s\n", gp.TipSet)
+		fmt.Printf("Exit code: %d\n", gp.Receipt.ExitCode)
+		fmt.Printf("Gas used: %s (limit %s)\n\n", gp.Receipt.GasUsed, gp.Trace.Msg.GasLimit)
+
+		classes := make([]string, 0, len(gp.ByClass))
+		for c := range gp.ByClass {
+			classes = append(classes, c)
+		}
+		sort.Strings(classes)
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Class\tGas\n")
+		for _, c := range classes {
+			fmt.Fprintf(w, "%s\t%d\n", c, gp.ByClass[c])
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if cctx.Bool("verbose") {
+			fmt.Println()
+			printExecutionTrace(gp.Trace, 0)
+		}
+
+		return nil
+	},
+}
+
 var slashConsensusFault = &cli.Command{
 	Name:  "slash-consensus",
 	Usage: "Report consensus fault",
@@ -609,7 +740,7 @@ var slashConsensusFault = &cli.Command{
 			Params:   params,
 		}
 
-		smsg, err := api.MpoolPushMessage(ctx, msg)
+		smsg, err := api.MpoolPushMessage(ctx, msg, nil)
 		if err != nil {
 			return err
 		}
@@ -29,6 +29,7 @@ var clientCmd = &cli.Command{
 		clientRetrieveCmd,
 		clientQueryAskCmd,
 		clientListDeals,
+		clientListRetrievals,
 	},
 }
 
@@ -115,7 +116,7 @@ var clientDealCmd = &cli.Command{
 			return err
 		}
 
-		a, err := api.WalletDefaultAddress(ctx)
+		a, err := api.WalletDefaultAddressFor(ctx, lapi.AddrPurposeDeals)
 		if err != nil {
 			return err
 		}
@@ -364,3 +365,33 @@ var clientListDeals = &cli.Command{
 		return w.Flush()
 	},
 }
+
+var clientListRetrievals = &cli.Command{
+	Name:  "list-retrievals",
+	Usage: "List retrievals made since the node started",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		rets, err := api.ClientListRetrievals(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "ID\tRoot\tMiner\tStatus\tReceived\tSpent\tTotal\tPath\n")
+		for _, r := range rets {
+			status := string(r.Status)
+			if r.Error != "" {
+				status = fmt.Sprintf("%s: %s", r.Status, r.Error)
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d/%d\t%s\t%s\t%s\n", r.ID, r.Root, r.Miner, status, r.BytesReceived, r.Size, types.FIL(r.FundsSpent), types.FIL(r.Total), r.Path)
+		}
+		return w.Flush()
+	},
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -168,6 +169,11 @@ func GetStorageMinerAPI(ctx *cli.Context) (api.StorageMiner, jsonrpc.ClientClose
 		return nil, nil, err
 	}
 
+	// additional miner actors are served on separate endpoints
+	if act := ctx.String("miner-actor"); act != "" {
+		addr += "/actor/" + act
+	}
+
 	return client.NewStorageMinerRPC(addr, headers)
 }
 
@@ -196,6 +202,29 @@ func ReqContext(cctx *cli.Context) context.Context {
 	return ctx
 }
 
+// OutputFlag selects the output format of commands which support
+// structured output
+var OutputFlag = &cli.StringFlag{
+	Name:  "output",
+	Usage: "output format of commands which support it: text or json",
+	Value: "text",
+}
+
+// OutputJSON returns whether the command output should be printed as json
+func OutputJSON(cctx *cli.Context) bool {
+	return cctx.String("output") == "json"
+}
+
+// PrintJSON prints v as indented json
+func PrintJSON(v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
 var Commands = []*cli.Command{
 	authCmd,
 	chainCmd,
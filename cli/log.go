@@ -20,7 +20,7 @@ var logList = &cli.Command{
 	Name:  "list",
 	Usage: "List log systems",
 	Action: func(cctx *cli.Context) error {
-		api, closer, err := GetFullNodeAPI(cctx)
+		api, closer, err := GetAPI(cctx)
 		if err != nil {
 			return err
 		}
@@ -51,6 +51,11 @@ var logSetLevel = &cli.Command{
 
    eg) log set-level --system chain --system blocksync debug
 
+   When run through lotus-storage-miner, the levels of the miner process are
+   changed, e.g. to debug the fallback PoSt scheduler:
+
+   eg) lotus-storage-miner log set-level --system storageminer debug
+
    Available Levels:
    debug
    info
@@ -70,7 +75,7 @@ var logSetLevel = &cli.Command{
 		},
 	},
 	Action: func(cctx *cli.Context) error {
-		api, closer, err := GetFullNodeAPI(cctx)
+		api, closer, err := GetAPI(cctx)
 		if err != nil {
 			return err
 		}
@@ -3,12 +3,15 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"text/tabwriter"
 
+	"github.com/docker/go-units"
 	"golang.org/x/xerrors"
 	"gopkg.in/urfave/cli.v2"
 
 	"github.com/filecoin-project/go-address"
-	"github.com/filecoin-project/lotus/chain/types"
+	lapi "github.com/filecoin-project/lotus/api"
 )
 
 var mpoolCmd = &cli.Command{
@@ -21,9 +24,48 @@ var mpoolCmd = &cli.Command{
 	},
 }
 
+var mpoolFilterFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "from",
+		Usage: "only show messages sent from this address",
+	},
+	&cli.StringFlag{
+		Name:  "to",
+		Usage: "only show messages sent to this address",
+	},
+	&cli.Int64Flag{
+		Name:  "method",
+		Usage: "only show messages calling this method number",
+		Value: -1,
+	},
+}
+
+func mpoolFilterFromFlags(cctx *cli.Context) (*lapi.MpoolFilter, error) {
+	var err error
+	filter := &lapi.MpoolFilter{}
+	if from := cctx.String("from"); from != "" {
+		filter.From, err = address.NewFromString(from)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing from address: %w", err)
+		}
+	}
+	if to := cctx.String("to"); to != "" {
+		filter.To, err = address.NewFromString(to)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing to address: %w", err)
+		}
+	}
+	if method := cctx.Int64("method"); method >= 0 {
+		m := uint64(method)
+		filter.Method = &m
+	}
+	return filter, nil
+}
+
 var mpoolPending = &cli.Command{
 	Name:  "pending",
 	Usage: "Get pending messages",
+	Flags: mpoolFilterFlags,
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
 		if err != nil {
@@ -33,11 +75,20 @@ var mpoolPending = &cli.Command{
 
 		ctx := ReqContext(cctx)
 
-		msgs, err := api.MpoolPending(ctx, nil)
+		filter, err := mpoolFilterFromFlags(cctx)
 		if err != nil {
 			return err
 		}
 
+		msgs, err := api.MpoolPendingFilter(ctx, filter, nil)
+		if err != nil {
+			return err
+		}
+
+		if OutputJSON(cctx) {
+			return PrintJSON(msgs)
+		}
+
 		for _, msg := range msgs {
 			out, err := json.MarshalIndent(msg, "", "  ")
 			if err != nil {
@@ -53,6 +104,7 @@ var mpoolPending = &cli.Command{
 var mpoolSub = &cli.Command{
 	Name:  "sub",
 	Usage: "Subscibe to mpool changes",
+	Flags: mpoolFilterFlags,
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
 		if err != nil {
@@ -62,14 +114,22 @@ var mpoolSub = &cli.Command{
 
 		ctx := ReqContext(cctx)
 
-		sub, err := api.MpoolSub(ctx)
+		filter, err := mpoolFilterFromFlags(cctx)
+		if err != nil {
+			return err
+		}
+
+		sub, err := api.MpoolSubFilter(ctx, filter)
 		if err != nil {
 			return err
 		}
 
 		for {
 			select {
-			case update := <-sub:
+			case update, ok := <-sub:
+				if !ok {
+					return nil
+				}
 				out, err := json.MarshalIndent(update, "", "  ")
 				if err != nil {
 					return err
@@ -82,10 +142,6 @@ var mpoolSub = &cli.Command{
 	},
 }
 
-type statBucket struct {
-	msgs map[uint64]*types.SignedMessage
-}
-
 var mpoolStat = &cli.Command{
 	Name:  "stat",
 	Usage: "print mempool stats",
@@ -98,58 +154,28 @@ var mpoolStat = &cli.Command{
 
 		ctx := ReqContext(cctx)
 
-		ts, err := api.ChainHead(ctx)
-		if err != nil {
-			return xerrors.Errorf("getting chain head: %w", err)
-		}
-
-		msgs, err := api.MpoolPending(ctx, nil)
+		stats, err := api.MpoolStats(ctx, nil)
 		if err != nil {
 			return err
 		}
 
-		buckets := map[address.Address]*statBucket{}
-
-		for _, v := range msgs {
-			bkt, ok := buckets[v.Message.From]
-			if !ok {
-				bkt = &statBucket{
-					msgs: map[uint64]*types.SignedMessage{},
-				}
-				buckets[v.Message.From] = bkt
-			}
-
-			bkt.msgs[v.Message.Nonce] = v
-		}
-		for a, bkt := range buckets {
-			act, err := api.StateGetActor(ctx, a, ts)
-			if err != nil {
-				fmt.Printf("%s, err: %s\n", a, err)
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Sender\tNonce\tPast\tReady\tFuture\tSize\tMinGasPrice\tAvgGasPrice\tMaxGasPrice\tGasLimit\n")
+		for _, ss := range stats.Senders {
+			if ss.Error != "" {
+				fmt.Fprintf(w, "%s\t%s\n", ss.Address, ss.Error)
 				continue
 			}
-
-			cur := act.Nonce
-			for {
-				_, ok := bkt.msgs[cur]
-				if !ok {
-					break
-				}
-				cur++
-			}
-
-			past := 0
-			future := 0
-			for _, m := range bkt.msgs {
-				if m.Message.Nonce < act.Nonce {
-					past++
-				}
-				if m.Message.Nonce > cur {
-					future++
-				}
-			}
-
-			fmt.Printf("%s, past: %d, cur: %d, future: %d\n", a, past, cur-act.Nonce, future)
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\t%s\t%s\t%s\t%s\n",
+				ss.Address, ss.StateNonce, ss.Past, ss.Ready, ss.Future,
+				units.BytesSize(float64(ss.Size)),
+				ss.MinGasPrice, ss.AvgGasPrice, ss.MaxGasPrice, ss.GasLimit)
 		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		fmt.Printf("\nTotal: %d messages from %d senders, %s\n", stats.Count, len(stats.Senders), units.BytesSize(float64(stats.Size)))
 
 		return nil
 	},
@@ -113,7 +113,7 @@ var msigCreateCmd = &cli.Command{
 		}
 
 		// send the message out to the network
-		smsg, err := api.MpoolPushMessage(ctx, &msg)
+		smsg, err := api.MpoolPushMessage(ctx, &msg, nil)
 		if err != nil {
 			return err
 		}
@@ -294,7 +294,7 @@ var msigProposeCmd = &cli.Command{
 			GasPrice: types.NewInt(1),
 		}
 
-		smsg, err := api.MpoolPushMessage(ctx, msg)
+		smsg, err := api.MpoolPushMessage(ctx, msg, nil)
 		if err != nil {
 			return err
 		}
@@ -379,7 +379,7 @@ var msigApproveCmd = &cli.Command{
 			GasPrice: types.NewInt(1),
 		}
 
-		smsg, err := api.MpoolPushMessage(ctx, msg)
+		smsg, err := api.MpoolPushMessage(ctx, msg, nil)
 		if err != nil {
 			return err
 		}
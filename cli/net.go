@@ -2,11 +2,19 @@ package cli
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"sort"
 	"strings"
+	"text/tabwriter"
 
+	"github.com/libp2p/go-libp2p-core/metrics"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"golang.org/x/xerrors"
 	"gopkg.in/urfave/cli.v2"
 
+	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/lib/addrutil"
 )
 
@@ -18,12 +26,20 @@ var netCmd = &cli.Command{
 		netConnect,
 		netListen,
 		netId,
+		netReachability,
+		netBandwidth,
 	},
 }
 
 var netPeers = &cli.Command{
 	Name:  "peers",
 	Usage: "Print peers",
+	Subcommands: []*cli.Command{
+		netPeersAdd,
+		netPeersRemove,
+		netPeersProtect,
+		netPeersPinned,
+	},
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetAPI(cctx)
 		if err != nil {
@@ -48,6 +64,126 @@ var netPeers = &cli.Command{
 	},
 }
 
+var netPeersAdd = &cli.Command{
+	Name:      "add",
+	Usage:     "Pin a peer, keeping it connected across restarts",
+	ArgsUsage: "<peer multiaddr>",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		pis, err := addrutil.ParseAddresses(ctx, cctx.Args().Slice())
+		if err != nil {
+			return err
+		}
+
+		for _, pi := range pis {
+			if err := api.NetPeerAdd(ctx, pi); err != nil {
+				return xerrors.Errorf("adding peer %s: %w", pi.ID, err)
+			}
+			fmt.Printf("pinned %s\n", pi.ID)
+		}
+
+		return nil
+	},
+}
+
+var netPeersRemove = &cli.Command{
+	Name:      "remove",
+	Usage:     "Unpin a peer",
+	ArgsUsage: "<peer ID>",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		for _, s := range cctx.Args().Slice() {
+			pid, err := peer.IDB58Decode(s)
+			if err != nil {
+				return xerrors.Errorf("parsing peer ID: %w", err)
+			}
+
+			if err := api.NetPeerRemove(ctx, pid); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+var netPeersProtect = &cli.Command{
+	Name:      "protect",
+	Usage:     "Protect a peer from connection trimming",
+	ArgsUsage: "<peer ID>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "remove",
+			Usage: "remove protection instead",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		for _, s := range cctx.Args().Slice() {
+			pid, err := peer.IDB58Decode(s)
+			if err != nil {
+				return xerrors.Errorf("parsing peer ID: %w", err)
+			}
+
+			if err := api.NetPeerProtect(ctx, pid, !cctx.Bool("remove")); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+var netPeersPinned = &cli.Command{
+	Name:  "pinned",
+	Usage: "List pinned peers",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		pinned, err := api.NetPeerList(ctx)
+		if err != nil {
+			return err
+		}
+
+		sort.Slice(pinned, func(i, j int) bool {
+			return strings.Compare(string(pinned[i].AddrInfo.ID), string(pinned[j].AddrInfo.ID)) > 0
+		})
+
+		for _, p := range pinned {
+			prot := ""
+			if p.Protected {
+				prot = " (protected)"
+			}
+			fmt.Printf("%s%s\n", p.AddrInfo, prot)
+		}
+
+		return nil
+	},
+}
+
 var netListen = &cli.Command{
 	Name:  "listen",
 	Usage: "List listen addresses",
@@ -123,3 +259,117 @@ var netId = &cli.Command{
 		return nil
 	},
 }
+
+var netReachability = &cli.Command{
+	Name:  "reachability",
+	Usage: "Print information about the NAT status of the node",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		r, err := api.NetReachability(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("AutoNAT status: ", r.Status)
+		if r.PublicAddr != "" {
+			fmt.Println("Public address: ", r.PublicAddr)
+		}
+
+		fmt.Println("Advertised addresses:")
+		for _, a := range r.Advertised.Addrs {
+			fmt.Printf("\t%s/p2p/%s\n", a, r.Advertised.ID)
+		}
+
+		return nil
+	},
+}
+
+var netBandwidth = &cli.Command{
+	Name:  "bandwidth",
+	Usage: "Print bandwidth usage information",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "by-peer",
+			Usage: "list bandwidth usage by peer",
+		},
+		&cli.BoolFlag{
+			Name:  "by-protocol",
+			Usage: "list bandwidth usage by protocol",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		w := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
+
+		switch {
+		case cctx.Bool("by-peer"):
+			bw, err := api.NetBandwidthStatsByPeer(ctx)
+			if err != nil {
+				return err
+			}
+
+			var peers []string
+			for p := range bw {
+				peers = append(peers, p)
+			}
+			sort.Slice(peers, func(i, j int) bool {
+				return bw[peers[i]].TotalIn+bw[peers[i]].TotalOut > bw[peers[j]].TotalIn+bw[peers[j]].TotalOut
+			})
+
+			fmt.Fprintln(w, "Peer\tTotalIn\tTotalOut\tRateIn\tRateOut")
+			for _, p := range peers {
+				printBandwidth(w, p, bw[p])
+			}
+		case cctx.Bool("by-protocol"):
+			bw, err := api.NetBandwidthStatsByProtocol(ctx)
+			if err != nil {
+				return err
+			}
+
+			var protos []string
+			for p := range bw {
+				protos = append(protos, string(p))
+			}
+			sort.Strings(protos)
+
+			fmt.Fprintln(w, "Protocol\tTotalIn\tTotalOut\tRateIn\tRateOut")
+			for _, p := range protos {
+				name := p
+				if name == "" {
+					name = "<unknown>"
+				}
+				printBandwidth(w, name, bw[protocol.ID(p)])
+			}
+		default:
+			s, err := api.NetBandwidthStats(ctx)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(w, "Segment\tTotalIn\tTotalOut\tRateIn\tRateOut")
+			printBandwidth(w, "Total", s)
+		}
+
+		return w.Flush()
+	},
+}
+
+func printBandwidth(w io.Writer, name string, s metrics.Stats) {
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s/s\t%s/s\n", name,
+		types.NewInt(uint64(s.TotalIn)).SizeStr(),
+		types.NewInt(uint64(s.TotalOut)).SizeStr(),
+		types.NewInt(uint64(s.RateIn)).SizeStr(),
+		types.NewInt(uint64(s.RateOut)).SizeStr())
+}
@@ -1,12 +1,14 @@
 package cli
 
 import (
+	"fmt"
+
 	"github.com/docker/go-units"
-	paramfetch "github.com/filecoin-project/go-paramfetch"
 	"golang.org/x/xerrors"
 	"gopkg.in/urfave/cli.v2"
 
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/lib/proofparams"
 )
 
 var fetchParamCmd = &cli.Command{
@@ -17,6 +19,21 @@ var fetchParamCmd = &cli.Command{
 			Name:  "proving-params",
 			Usage: "download params used creating proofs for given size, i.e. 32GiB",
 		},
+		&cli.StringFlag{
+			Name:    "param-dir",
+			EnvVars: []string{proofparams.DirEnv},
+			Usage:   "parameter cache directory, shared with the miner and its workers",
+			Value:   proofparams.DefaultDir,
+		},
+		&cli.IntFlag{
+			Name:  "parallel",
+			Usage: "number of files to download at once",
+			Value: proofparams.DefaultParallel,
+		},
+		&cli.BoolFlag{
+			Name:  "check",
+			Usage: "only verify the parameters in the cache, don't download anything",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		sectorSizeInt, err := units.RAMInBytes(cctx.String("proving-params"))
@@ -24,7 +41,20 @@ var fetchParamCmd = &cli.Command{
 			return err
 		}
 		sectorSize := uint64(sectorSizeInt)
-		err = paramfetch.GetParams(build.ParametersJson(), sectorSize)
+
+		if err := proofparams.SetDir(cctx.String("param-dir")); err != nil {
+			return err
+		}
+
+		if cctx.Bool("check") {
+			if err := proofparams.Check(build.ParametersJson(), sectorSize); err != nil {
+				return xerrors.Errorf("checking proof parameters: %w", err)
+			}
+			fmt.Printf("Parameters in %s are ok\n", proofparams.Dir())
+			return nil
+		}
+
+		err = proofparams.Fetch(ReqContext(cctx), build.ParametersJson(), sectorSize, cctx.Int("parallel"))
 		if err != nil {
 			return xerrors.Errorf("fetching proof parameters: %w", err)
 		}
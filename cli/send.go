@@ -4,7 +4,9 @@ import (
 	"fmt"
 
 	"github.com/filecoin-project/go-address"
+	lapi "github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/types"
+	"golang.org/x/xerrors"
 	"gopkg.in/urfave/cli.v2"
 )
 
@@ -17,6 +19,14 @@ var sendCmd = &cli.Command{
 			Name:  "source",
 			Usage: "optionally specify the account to send funds from",
 		},
+		&cli.StringFlag{
+			Name:  "max-fee",
+			Usage: "refuse to send when the gas fee would be higher than this (in attoFIL)",
+		},
+		&cli.Uint64Flag{
+			Name:  "gas-premium",
+			Usage: "percentage to pay above the average gas price of pending messages",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
@@ -66,7 +76,17 @@ var sendCmd = &cli.Command{
 			GasPrice: types.NewInt(0),
 		}
 
-		_, err = api.MpoolPushMessage(ctx, msg)
+		spec := &lapi.MessageSendSpec{
+			GasPremium: cctx.Uint64("gas-premium"),
+		}
+		if cctx.IsSet("max-fee") {
+			spec.MaxFee, err = types.BigFromString(cctx.String("max-fee"))
+			if err != nil {
+				return xerrors.Errorf("parsing max fee: %w", err)
+			}
+		}
+
+		_, err = api.MpoolPushMessage(ctx, msg, spec)
 		if err != nil {
 			return err
 		}
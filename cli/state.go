@@ -6,11 +6,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
 	actors "github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/miner"
@@ -48,6 +51,11 @@ var stateCmd = &cli.Command{
 		stateCallCmd,
 		stateGetDealSetCmd,
 		stateWaitMsgCmd,
+		stateSearchMsgCmd,
+		stateSubMessagesCmd,
+		stateDiffCmd,
+		stateCirculatingSupplyCmd,
+		stateDecodeStateCmd,
 	},
 }
 
@@ -57,7 +65,11 @@ func parseTipSetString(cctx *cli.Context) ([]cid.Cid, error) {
 		return nil, nil
 	}
 
-	strs := strings.Split(ts, ",")
+	return parseCidList(ts)
+}
+
+func parseCidList(s string) ([]cid.Cid, error) {
+	strs := strings.Split(s, ",")
 
 	var cids []cid.Cid
 	for _, s := range strs {
@@ -120,6 +132,10 @@ var statePowerCmd = &cli.Command{
 			return err
 		}
 
+		if OutputJSON(cctx) {
+			return PrintJSON(power)
+		}
+
 		tp := power.TotalPower
 		if cctx.Args().Present() {
 			mp := power.MinerPower
@@ -164,6 +180,10 @@ var stateSectorsCmd = &cli.Command{
 			return err
 		}
 
+		if OutputJSON(cctx) {
+			return PrintJSON(sectors)
+		}
+
 		for _, s := range sectors {
 			fmt.Printf("%d: %x %x\n", s.SectorID, s.CommR, s.CommD)
 		}
@@ -203,6 +223,10 @@ var stateProvingSetCmd = &cli.Command{
 			return err
 		}
 
+		if OutputJSON(cctx) {
+			return PrintJSON(sectors)
+		}
+
 		for _, s := range sectors {
 			fmt.Printf("%d: %x %x\n", s.SectorID, s.CommR, s.CommD)
 		}
@@ -214,6 +238,12 @@ var stateProvingSetCmd = &cli.Command{
 var stateReplaySetCmd = &cli.Command{
 	Name:  "replay",
 	Usage: "Replay a particular message within a tipset",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "trace",
+			Usage: "print the execution trace of the message",
+		},
+	},
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() < 2 {
 			fmt.Println("usage: <tipset> <message cid>")
@@ -273,10 +303,38 @@ var stateReplaySetCmd = &cli.Command{
 			fmt.Printf("Error message: %q\n", res.Error)
 		}
 
+		if cctx.Bool("trace") && res.ExecutionTrace != nil {
+			fmt.Println()
+			fmt.Println("Execution trace:")
+			printExecutionTrace(res.ExecutionTrace, 1)
+		}
+
 		return nil
 	},
 }
 
+func printExecutionTrace(et *types.ExecutionTrace, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	fmt.Printf("%s%s -> %s (method %d, value %s)\n", indent, et.Msg.From, et.Msg.To, et.Msg.Method, types.FIL(et.Msg.Value))
+	if et.MsgRct != nil {
+		fmt.Printf("%s  exit %d, gas used %s, took %s\n", indent, et.MsgRct.ExitCode, et.MsgRct.GasUsed, et.Duration)
+		if len(et.MsgRct.Return) > 0 {
+			fmt.Printf("%s  return: %x\n", indent, et.MsgRct.Return)
+		}
+	}
+	if et.Error != "" {
+		fmt.Printf("%s  error: %s\n", indent, et.Error)
+	}
+	for _, gc := range et.GasCharges {
+		fmt.Printf("%s  gas %d (%s): %s\n", indent, gc.Amount, gc.Class, gc.Location)
+	}
+
+	for _, sub := range et.Subcalls {
+		printExecutionTrace(sub, depth+1)
+	}
+}
+
 var statePledgeCollateralCmd = &cli.Command{
 	Name:  "pledge-collateral",
 	Usage: "Get minimum miner pledge collateral",
@@ -299,6 +357,10 @@ var statePledgeCollateralCmd = &cli.Command{
 			return err
 		}
 
+		if OutputJSON(cctx) {
+			return PrintJSON(coll)
+		}
+
 		fmt.Println(types.FIL(coll))
 		return nil
 	},
@@ -367,6 +429,10 @@ var stateListMinersCmd = &cli.Command{
 			return err
 		}
 
+		if OutputJSON(cctx) {
+			return PrintJSON(miners)
+		}
+
 		for _, m := range miners {
 			fmt.Println(m.String())
 		}
@@ -397,6 +463,10 @@ var stateListActorsCmd = &cli.Command{
 			return err
 		}
 
+		if OutputJSON(cctx) {
+			return PrintJSON(actors)
+		}
+
 		for _, a := range actors {
 			fmt.Println(a.String())
 		}
@@ -436,6 +506,10 @@ var stateGetActorCmd = &cli.Command{
 			return err
 		}
 
+		if OutputJSON(cctx) {
+			return PrintJSON(a)
+		}
+
 		fmt.Printf("Address:\t%s\n", addr)
 		fmt.Printf("Balance:\t%s\n", types.FIL(a.Balance))
 		fmt.Printf("Nonce:\t\t%d\n", a.Nonce)
@@ -514,6 +588,10 @@ var stateSectorSizeCmd = &cli.Command{
 			return err
 		}
 
+		if OutputJSON(cctx) {
+			return PrintJSON(ssize)
+		}
+
 		fmt.Printf("%d\n", ssize)
 		return nil
 	},
@@ -522,6 +600,12 @@ var stateSectorSizeCmd = &cli.Command{
 var stateReadStateCmd = &cli.Command{
 	Name:  "read-state",
 	Usage: "View a json representation of an actors state",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "actor",
+			Usage: "also print actor code, head, nonce and balance",
+		},
+	},
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
 		if err != nil {
@@ -545,17 +629,17 @@ var stateReadStateCmd = &cli.Command{
 			return err
 		}
 
-		act, err := api.StateGetActor(ctx, addr, ts)
+		as, err := api.StateReadActor(ctx, addr, ts)
 		if err != nil {
 			return err
 		}
 
-		as, err := api.StateReadState(ctx, act, ts)
-		if err != nil {
-			return err
+		var out interface{} = as.State
+		if cctx.Bool("actor") {
+			out = as
 		}
 
-		data, err := json.MarshalIndent(as.State, "", "  ")
+		data, err := json.MarshalIndent(out, "", "  ")
 		if err != nil {
 			return err
 		}
@@ -743,6 +827,10 @@ var stateWaitMsgCmd = &cli.Command{
 			return err
 		}
 
+		if OutputJSON(cctx) {
+			return PrintJSON(mw)
+		}
+
 		fmt.Printf("message was executed in tipset: %s", mw.TipSet.Cids())
 		fmt.Printf("Exit Code: %d", mw.Receipt.ExitCode)
 		fmt.Printf("Gas Used: %s", mw.Receipt.GasUsed)
@@ -751,6 +839,129 @@ var stateWaitMsgCmd = &cli.Command{
 	},
 }
 
+var stateSearchMsgCmd = &cli.Command{
+	Name:  "search-msg",
+	Usage: "Search for a message on chain without waiting for it",
+	Flags: []cli.Flag{
+		&cli.Uint64Flag{
+			Name:  "lookback",
+			Usage: "number of epochs to search back (0 searches the whole chain)",
+			Value: 2 * build.Finality,
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "give up searching after this long",
+			Value: 30 * time.Second,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return fmt.Errorf("must specify message cid to search for")
+		}
+
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		msg, err := cid.Decode(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		mw, err := api.StateSearchMsg(ctx, msg, cctx.Uint64("lookback"), cctx.Duration("timeout"))
+		if err != nil {
+			return err
+		}
+
+		if mw == nil {
+			return fmt.Errorf("message %s not found", msg)
+		}
+
+		if OutputJSON(cctx) {
+			return PrintJSON(mw)
+		}
+
+		fmt.Printf("message was executed in tipset: %s\n", mw.TipSet.Cids())
+		fmt.Printf("Exit Code: %d\n", mw.Receipt.ExitCode)
+		fmt.Printf("Gas Used: %s\n", mw.Receipt.GasUsed)
+		fmt.Printf("Return: %x\n", mw.Receipt.Return)
+		return nil
+	},
+}
+
+var stateSubMessagesCmd = &cli.Command{
+	Name:  "sub-msgs",
+	Usage: "Stream messages as they are executed on chain",
+	Flags: append([]cli.Flag{
+		&cli.IntFlag{
+			Name:  "exit-code",
+			Usage: "only show messages which returned this exit code",
+			Value: -1,
+		},
+	}, mpoolFilterFlags...),
+	Action: func(cctx *cli.Context) error {
+		mf, err := mpoolFilterFromFlags(cctx)
+		if err != nil {
+			return err
+		}
+		filter := &api.MessageFilter{
+			From:   mf.From,
+			To:     mf.To,
+			Method: mf.Method,
+		}
+		if ec := cctx.Int("exit-code"); ec >= 0 {
+			if ec > 255 {
+				return xerrors.Errorf("exit code %d out of range", ec)
+			}
+			e := uint8(ec)
+			filter.ExitCode = &e
+		}
+
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		sub, err := api.StateSubMessages(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		for {
+			select {
+			case msgs, ok := <-sub:
+				if !ok {
+					return nil
+				}
+				for _, em := range msgs {
+					if OutputJSON(cctx) {
+						if err := PrintJSON(em); err != nil {
+							return err
+						}
+						continue
+					}
+
+					status := "executed"
+					if em.Reverted {
+						status = "reverted"
+					}
+					fmt.Printf("%d %s %s: %s -> %s method %d, exit code %d\n",
+						em.Height, status, em.Cid, em.Message.From, em.Message.To, em.Message.Method, em.Receipt.ExitCode)
+				}
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	},
+}
+
 var stateCallCmd = &cli.Command{
 	Name:  "call",
 	Usage: "Invoke a method on an actor locally",
@@ -770,6 +981,10 @@ var stateCallCmd = &cli.Command{
 			Usage: "specify how to parse output (auto, raw, addr, big)",
 			Value: "auto",
 		},
+		&cli.BoolFlag{
+			Name:  "trace",
+			Usage: "print the execution trace, it's always printed when the call fails",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() < 2 {
@@ -832,10 +1047,31 @@ var stateCallCmd = &cli.Command{
 			return fmt.Errorf("state call failed: %s", err)
 		}
 
+		if OutputJSON(cctx) {
+			return PrintJSON(ret)
+		}
+
+		if (cctx.Bool("trace") || ret.ExitCode != 0) && ret.ExecutionTrace != nil {
+			fmt.Println("Execution trace:")
+			printExecutionTrace(ret.ExecutionTrace, 1)
+			fmt.Println()
+		}
+
+		fmt.Printf("gas used: %s\n", ret.GasUsed)
+
 		if ret.ExitCode != 0 {
 			return fmt.Errorf("invocation failed (exit: %d): %s", ret.ExitCode, ret.Error)
 		}
 
+		if ret.Decoded != nil && cctx.String("ret") == "auto" {
+			out, err := json.MarshalIndent(ret.Decoded, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Printf("return: %s\n", out)
+			return nil
+		}
+
 		s, err := formatOutput(cctx.String("ret"), ret.Return)
 		if err != nil {
 			return fmt.Errorf("failed to format output: %s", err)
@@ -956,3 +1192,168 @@ func parseParamsForMethod(act cid.Cid, method uint64, args []string) ([]byte, er
 	}
 	return buf.Bytes(), nil
 }
+
+var stateDiffCmd = &cli.Command{
+	Name:      "diff",
+	Usage:     "List actors changed between the states of two tipsets",
+	ArgsUsage: "[fromTipset] [toTipset]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "state",
+			Usage: "include changes to decoded actor state fields",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the diff as json",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		if cctx.Args().Len() != 2 {
+			return fmt.Errorf("must pass two tipsets (comma separated block cids)")
+		}
+
+		from, err := parseCidList(cctx.Args().Get(0))
+		if err != nil {
+			return xerrors.Errorf("parsing from tipset: %w", err)
+		}
+
+		to, err := parseCidList(cctx.Args().Get(1))
+		if err != nil {
+			return xerrors.Errorf("parsing to tipset: %w", err)
+		}
+
+		diff, err := api.StateDiff(ctx, types.NewTipSetKey(from...), types.NewTipSetKey(to...), cctx.Bool("state"))
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") || OutputJSON(cctx) {
+			return PrintJSON(diff)
+		}
+
+		for _, d := range diff {
+			fmt.Printf("%s %s (code %s)\n", d.Change, d.Address, d.Code)
+			if d.BalanceDelta.Sign() != 0 {
+				fmt.Printf("\tBalance: %s -> %s (%s)\n", types.FIL(d.OldBalance), types.FIL(d.NewBalance), types.FIL(d.BalanceDelta))
+			}
+			if d.OldNonce != d.NewNonce {
+				fmt.Printf("\tNonce: %d -> %d\n", d.OldNonce, d.NewNonce)
+			}
+
+			fields := make([]string, 0, len(d.StateDiff))
+			for f := range d.StateDiff {
+				fields = append(fields, f)
+			}
+			sort.Strings(fields)
+
+			for _, f := range fields {
+				fmt.Printf("\t%s: %s -> %s\n", f, d.StateDiff[f].Old, d.StateDiff[f].New)
+			}
+		}
+
+		return nil
+	},
+}
+
+var stateCirculatingSupplyCmd = &cli.Command{
+	Name:  "circulating-supply",
+	Usage: "Get the current circulating supply of filecoin",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		ts, err := loadTipSet(ctx, cctx, api)
+		if err != nil {
+			return err
+		}
+
+		cs, err := api.StateCirculatingSupply(ctx, ts)
+		if err != nil {
+			return err
+		}
+
+		if OutputJSON(cctx) {
+			return PrintJSON(cs)
+		}
+
+		fmt.Printf("Total:          %s FIL\n", types.FIL(cs.Total))
+		fmt.Printf("Vested:         %s FIL\n", types.FIL(cs.Vested))
+		fmt.Printf("Mined:          %s FIL\n", types.FIL(cs.Mined))
+		fmt.Printf("Burnt:          %s FIL\n", types.FIL(cs.Burnt))
+		fmt.Printf("Locked pledge:  %s FIL\n", types.FIL(cs.LockedPledge))
+		fmt.Printf("Locked deals:   %s FIL\n", types.FIL(cs.LockedDeals))
+		fmt.Printf("Circulating:    %s FIL\n", types.FIL(cs.Circulating))
+
+		return nil
+	},
+}
+
+var actorCodeNames = map[string]cid.Cid{
+	"account":  actors.AccountCodeCid,
+	"cron":     actors.CronCodeCid,
+	"power":    actors.StoragePowerCodeCid,
+	"market":   actors.StorageMarketCodeCid,
+	"miner":    actors.StorageMinerCodeCid,
+	"miner2":   actors.StorageMiner2CodeCid,
+	"multisig": actors.MultisigCodeCid,
+	"init":     actors.InitCodeCid,
+	"paych":    actors.PaymentChannelCodeCid,
+}
+
+var stateDecodeStateCmd = &cli.Command{
+	Name:      "decode-state",
+	Usage:     "Decode an actor state object into json",
+	ArgsUsage: "[actorType|codeCid] [stateCid]",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := ReqContext(cctx)
+
+		if cctx.Args().Len() != 2 {
+			return fmt.Errorf("must pass actor type (or code cid) and state object cid")
+		}
+
+		code, ok := actorCodeNames[cctx.Args().Get(0)]
+		if !ok {
+			code, err = cid.Decode(cctx.Args().Get(0))
+			if err != nil {
+				return xerrors.Errorf("unknown actor type, and not a valid code cid: %w", err)
+			}
+		}
+
+		head, err := cid.Decode(cctx.Args().Get(1))
+		if err != nil {
+			return xerrors.Errorf("parsing state cid: %w", err)
+		}
+
+		st, err := api.StateDecodeState(ctx, code, head)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(st, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+
+		return nil
+	},
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain"
+	"github.com/filecoin-project/lotus/chain/types"
 )
 
 var syncCmd = &cli.Command{
@@ -21,6 +22,7 @@ var syncCmd = &cli.Command{
 		syncWaitCmd,
 		syncMarkBadCmd,
 		syncCheckBadCmd,
+		syncCheckpointCmd,
 	},
 }
 
@@ -151,6 +153,55 @@ var syncCheckBadCmd = &cli.Command{
 	},
 }
 
+var syncCheckpointCmd = &cli.Command{
+	Name:      "checkpoint",
+	Usage:     "Get, set or clear the trusted checkpoint tipset",
+	ArgsUsage: "[blockCid1 blockCid2...]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "clear",
+			Usage: "remove the current checkpoint",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		napi, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		if cctx.Bool("clear") {
+			return napi.SyncClearCheckpoint(ctx)
+		}
+
+		if !cctx.Args().Present() {
+			cp, err := napi.SyncGetCheckpoint(ctx)
+			if err != nil {
+				return err
+			}
+			if cp == nil {
+				fmt.Println("no checkpoint set")
+				return nil
+			}
+
+			fmt.Printf("%s (%d)\n", cp.Cids(), cp.Height())
+			return nil
+		}
+
+		var cids []cid.Cid
+		for _, s := range cctx.Args().Slice() {
+			c, err := cid.Decode(s)
+			if err != nil {
+				return fmt.Errorf("failed to decode input as a cid: %s", err)
+			}
+			cids = append(cids, c)
+		}
+
+		return napi.SyncCheckpoint(ctx, types.NewTipSetKey(cids...))
+	},
+}
+
 func SyncWait(ctx context.Context, napi api.FullNode) error {
 	for {
 		state, err := napi.SyncState(ctx)
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/filecoin-project/go-address"
@@ -110,9 +111,21 @@ var walletBalance = &cli.Command{
 	},
 }
 
+var walletPurposeFlag = &cli.StringFlag{
+	Name:  "purpose",
+	Usage: "address used for sending messages for a purpose (deals)",
+}
+
 var walletGetDefault = &cli.Command{
 	Name:  "default",
 	Usage: "Get default wallet address",
+	Flags: []cli.Flag{
+		walletPurposeFlag,
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "list the addresses set for each purpose",
+		},
+	},
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
 		if err != nil {
@@ -121,7 +134,30 @@ var walletGetDefault = &cli.Command{
 		defer closer()
 		ctx := ReqContext(cctx)
 
-		addr, err := api.WalletDefaultAddress(ctx)
+		if cctx.Bool("all") {
+			addrs, err := api.WalletDefaultAddresses(ctx)
+			if err != nil {
+				return err
+			}
+
+			purposes := make([]string, 0, len(addrs))
+			for purpose := range addrs {
+				purposes = append(purposes, purpose)
+			}
+			sort.Strings(purposes)
+
+			for _, purpose := range purposes {
+				fmt.Printf("%s: %s\n", purpose, addrs[purpose])
+			}
+			return nil
+		}
+
+		var addr address.Address
+		if purpose := cctx.String("purpose"); purpose != "" {
+			addr, err = api.WalletDefaultAddressFor(ctx, purpose)
+		} else {
+			addr, err = api.WalletDefaultAddress(ctx)
+		}
 		if err != nil {
 			return err
 		}
@@ -132,8 +168,15 @@ var walletGetDefault = &cli.Command{
 }
 
 var walletSetDefault = &cli.Command{
-	Name:  "set-default",
-	Usage: "Set default wallet address",
+	Name:      "set-default",
+	Usage:     "Set default wallet address",
+	ArgsUsage: "[address]",
+	Description: `With --purpose, the address is only used for sending messages for that
+   purpose. Passing no address with --purpose goes back to using the default
+   address.`,
+	Flags: []cli.Flag{
+		walletPurposeFlag,
+	},
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := GetFullNodeAPI(cctx)
 		if err != nil {
@@ -142,6 +185,11 @@ var walletSetDefault = &cli.Command{
 		defer closer()
 		ctx := ReqContext(cctx)
 
+		purpose := cctx.String("purpose")
+		if purpose != "" && !cctx.Args().Present() {
+			return api.WalletSetDefaultFor(ctx, purpose, address.Undef)
+		}
+
 		if !cctx.Args().Present() {
 			return fmt.Errorf("must pass address to set as default")
 		}
@@ -151,6 +199,9 @@ var walletSetDefault = &cli.Command{
 			return err
 		}
 
+		if purpose != "" {
+			return api.WalletSetDefaultFor(ctx, purpose, addr)
+		}
 		return api.WalletSetDefault(ctx, addr)
 	},
 }
@@ -15,7 +15,6 @@ import (
 
 	"github.com/docker/go-units"
 	ffi "github.com/filecoin-project/filecoin-ffi"
-	paramfetch "github.com/filecoin-project/go-paramfetch"
 	"github.com/ipfs/go-datastore"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/mitchellh/go-homedir"
@@ -27,6 +26,7 @@ import (
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/genesis"
+	"github.com/filecoin-project/lotus/lib/proofparams"
 )
 
 var log = logging.Logger("lotus-bench")
@@ -41,6 +41,13 @@ type BenchResults struct {
 	PostEProofHot          time.Duration
 	VerifyEPostCold        time.Duration
 	VerifyEPostHot         time.Duration
+
+	PostFallbackProof  time.Duration
+	VerifyFallbackPost time.Duration
+
+	// ProvingWindow is the time the miner has to submit a fallback PoSt
+	// after it starts computing it
+	ProvingWindow time.Duration
 }
 
 type SealingResult struct {
@@ -155,7 +162,7 @@ func main() {
 				}
 			}
 
-			if err := paramfetch.GetParams(build.ParametersJson(), sectorSize); err != nil {
+			if err := proofparams.Fetch(context.TODO(), build.ParametersJson(), sectorSize, proofparams.DefaultParallel); err != nil {
 				return xerrors.Errorf("getting params: %w", err)
 			}
 			sb, err := sectorbuilder.New(cfg, mds)
@@ -326,6 +333,24 @@ func main() {
 			}
 			verifypost2 := time.Now()
 
+			log.Info("computing fallback post snark")
+			fcandidates, fproof, err := sb.GenerateFallbackPoSt(sinfos, challenge, []uint64{})
+			if err != nil {
+				return xerrors.Errorf("generating fallback post: %w", err)
+			}
+
+			fpost := time.Now()
+
+			ok, err = sectorbuilder.ProofVerifier.VerifyFallbackPost(context.TODO(), sectorSize, sinfos, challenge[:], fproof, fcandidates, maddr, 0)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				log.Error("fallback post verification failed")
+			}
+
+			verifyfpost := time.Now()
+
 			bo := BenchResults{
 				SectorSize:     cfg.SectorSize,
 				SealingResults: sealTimings,
@@ -335,6 +360,10 @@ func main() {
 				PostEProofHot:          epost2.Sub(epost1),
 				VerifyEPostCold:        verifypost1.Sub(epost2),
 				VerifyEPostHot:         verifypost2.Sub(verifypost1),
+
+				PostFallbackProof:  fpost.Sub(verifypost2),
+				VerifyFallbackPost: verifyfpost.Sub(fpost),
+				ProvingWindow:      (build.SlashablePowerDelay - build.FallbackPoStDelay) * build.BlockDelay * time.Second,
 			} // TODO: optionally write this as json to a file
 
 			if c.Bool("json-out") {
@@ -360,6 +389,9 @@ func main() {
 				fmt.Printf("compute epost proof (hot): %s\n", bo.PostEProofHot)
 				fmt.Printf("verify epost proof (cold): %s\n", bo.VerifyEPostCold)
 				fmt.Printf("verify epost proof (hot): %s\n", bo.VerifyEPostHot)
+				fmt.Printf("compute fallback post proof: %s\n", bo.PostFallbackProof)
+				fmt.Printf("verify fallback post proof: %s\n", bo.VerifyFallbackPost)
+				fmt.Printf("proving window: %s (headroom: %.1fx)\n", bo.ProvingWindow, float64(bo.ProvingWindow)/float64(bo.PostFallbackProof))
 			}
 			return nil
 		},
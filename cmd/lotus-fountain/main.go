@@ -187,7 +187,7 @@ func (h *handler) send(w http.ResponseWriter, r *http.Request) {
 
 		GasPrice: types.NewInt(0),
 		GasLimit: types.NewInt(1000),
-	})
+	}, nil)
 	if err != nil {
 		w.WriteHeader(400)
 		w.Write([]byte(err.Error()))
@@ -253,7 +253,7 @@ func (h *handler) mkminer(w http.ResponseWriter, r *http.Request) {
 
 		GasPrice: types.NewInt(0),
 		GasLimit: types.NewInt(1000),
-	})
+	}, nil)
 	if err != nil {
 		w.WriteHeader(400)
 		w.Write([]byte("pushfunds: " + err.Error()))
@@ -285,7 +285,7 @@ func (h *handler) mkminer(w http.ResponseWriter, r *http.Request) {
 		GasPrice: types.NewInt(0),
 	}
 
-	signed, err := h.api.MpoolPushMessage(r.Context(), createStorageMinerMsg)
+	signed, err := h.api.MpoolPushMessage(r.Context(), createStorageMinerMsg, nil)
 	if err != nil {
 		w.WriteHeader(400)
 		w.Write([]byte(err.Error()))
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// GatewayAPI exposes the subset of the full node API which is safe to serve
+// publicly. Queries against tipsets older than the lookback cap are refused.
+// Objects which can't be tied to a height, like messages and raw IPLD blocks,
+// can't be served by CID.
+type GatewayAPI struct {
+	api api.FullNode
+
+	lookbackCap uint64
+	maxTimeout  time.Duration
+}
+
+// checkTipSet replaces a tipset sent by the client with the node's tipset for
+// the same key, so that the height checked against the lookback cap can't be
+// forged, and checks that it's within the cap. A nil tipset stands for the
+// chain head.
+func (a *GatewayAPI) checkTipSet(ctx context.Context, ts *types.TipSet) (*types.TipSet, error) {
+	if ts == nil {
+		return nil, nil
+	}
+
+	ts, err := a.api.ChainGetTipSet(ctx, ts.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("loading tipset: %w", err)
+	}
+
+	if err := a.checkHeight(ctx, ts.Height()); err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
+
+func (a *GatewayAPI) checkHeight(ctx context.Context, h uint64) error {
+	head, err := a.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	if h+a.lookbackCap < head.Height() {
+		return xerrors.Errorf("tipset at height %d is older than the allowed lookback of %d epochs", h, a.lookbackCap)
+	}
+
+	return nil
+}
+
+func (a *GatewayAPI) Version(ctx context.Context) (api.Version, error) {
+	return a.api.Version(ctx)
+}
+
+func (a *GatewayAPI) ChainNotify(ctx context.Context) (<-chan []*store.HeadChange, error) {
+	return a.api.ChainNotify(ctx)
+}
+
+func (a *GatewayAPI) ChainHead(ctx context.Context) (*types.TipSet, error) {
+	return a.api.ChainHead(ctx)
+}
+
+func (a *GatewayAPI) ChainGetTipSet(ctx context.Context, tsk types.TipSetKey) (*types.TipSet, error) {
+	ts, err := a.api.ChainGetTipSet(ctx, tsk)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.checkHeight(ctx, ts.Height()); err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
+
+func (a *GatewayAPI) ChainGetTipSetByHeight(ctx context.Context, h uint64, ts *types.TipSet) (*types.TipSet, error) {
+	if err := a.checkHeight(ctx, h); err != nil {
+		return nil, err
+	}
+
+	ts, err := a.checkTipSet(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.api.ChainGetTipSetByHeight(ctx, h, ts)
+}
+
+func (a *GatewayAPI) ChainGetBlock(ctx context.Context, c cid.Cid) (*types.BlockHeader, error) {
+	blk, err := a.api.ChainGetBlock(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.checkHeight(ctx, blk.Height); err != nil {
+		return nil, err
+	}
+
+	return blk, nil
+}
+
+func (a *GatewayAPI) ChainGetBlockMessages(ctx context.Context, c cid.Cid) (*api.BlockMessages, error) {
+	if _, err := a.ChainGetBlock(ctx, c); err != nil {
+		return nil, err
+	}
+
+	return a.api.ChainGetBlockMessages(ctx, c)
+}
+
+func (a *GatewayAPI) MpoolPush(ctx context.Context, sm *types.SignedMessage) (cid.Cid, error) {
+	return a.api.MpoolPush(ctx, sm)
+}
+
+func (a *GatewayAPI) MpoolGetNonce(ctx context.Context, addr address.Address) (uint64, error) {
+	return a.api.MpoolGetNonce(ctx, addr)
+}
+
+func (a *GatewayAPI) WalletBalance(ctx context.Context, addr address.Address) (types.BigInt, error) {
+	return a.api.WalletBalance(ctx, addr)
+}
+
+func (a *GatewayAPI) StateGetActor(ctx context.Context, addr address.Address, ts *types.TipSet) (*types.Actor, error) {
+	ts, err := a.checkTipSet(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.api.StateGetActor(ctx, addr, ts)
+}
+
+func (a *GatewayAPI) StateLookupID(ctx context.Context, addr address.Address, ts *types.TipSet) (address.Address, error) {
+	ts, err := a.checkTipSet(ctx, ts)
+	if err != nil {
+		return address.Undef, err
+	}
+
+	return a.api.StateLookupID(ctx, addr, ts)
+}
+
+func (a *GatewayAPI) StateMinerPower(ctx context.Context, addr address.Address, ts *types.TipSet) (api.MinerPower, error) {
+	ts, err := a.checkTipSet(ctx, ts)
+	if err != nil {
+		return api.MinerPower{}, err
+	}
+
+	return a.api.StateMinerPower(ctx, addr, ts)
+}
+
+func (a *GatewayAPI) StateMarketBalance(ctx context.Context, addr address.Address, ts *types.TipSet) (actors.StorageParticipantBalance, error) {
+	ts, err := a.checkTipSet(ctx, ts)
+	if err != nil {
+		return actors.StorageParticipantBalance{}, err
+	}
+
+	return a.api.StateMarketBalance(ctx, addr, ts)
+}
+
+// StateGetReceipt only searches for the message up to the lookback cap
+func (a *GatewayAPI) StateGetReceipt(ctx context.Context, msg cid.Cid, ts *types.TipSet) (*types.MessageReceipt, error) {
+	ts, err := a.checkTipSet(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	mw, err := a.api.StateSearchMsg(ctx, msg, a.lookbackCap, a.maxTimeout)
+	if err != nil || mw == nil {
+		return nil, err
+	}
+
+	if ts != nil {
+		// the message has to be executed on the chain ending at ts
+		if mw.TipSet.Height() > ts.Height() {
+			return nil, nil
+		}
+		anc, err := a.api.ChainGetTipSetByHeight(ctx, mw.TipSet.Height(), ts)
+		if err != nil {
+			return nil, xerrors.Errorf("checking message tipset ancestry: %w", err)
+		}
+		if !anc.Equals(mw.TipSet) {
+			return nil, nil
+		}
+	}
+
+	return &mw.Receipt, nil
+}
+
+func (a *GatewayAPI) StateSearchMsg(ctx context.Context, msg cid.Cid, lookback uint64, timeout time.Duration) (*api.MsgWait, error) {
+	if lookback == 0 || lookback > a.lookbackCap {
+		lookback = a.lookbackCap
+	}
+	if timeout <= 0 || timeout > a.maxTimeout {
+		timeout = a.maxTimeout
+	}
+
+	return a.api.StateSearchMsg(ctx, msg, lookback, timeout)
+}
+
+// StateWaitMsg waits for the message for at most the max timeout. Instead of
+// letting the node search the whole chain, the gateway searches up to the
+// lookback cap each time the head changes.
+func (a *GatewayAPI) StateWaitMsg(ctx context.Context, msg cid.Cid) (*api.MsgWait, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.maxTimeout)
+	defer cancel()
+
+	notifs, err := a.api.ChainNotify(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("subscribing to head changes: %w", err)
+	}
+
+	for {
+		mw, err := a.api.StateSearchMsg(ctx, msg, a.lookbackCap, a.maxTimeout)
+		if err != nil || mw != nil {
+			return mw, err
+		}
+
+		select {
+		case _, ok := <-notifs:
+			if !ok {
+				return nil, xerrors.New("head change subscription closed")
+			}
+		case <-ctx.Done():
+			return nil, xerrors.Errorf("waiting for message: %w", ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apistruct"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+)
+
+type testNode struct {
+	tipsets []*types.TipSet
+
+	found    *api.MsgWait
+	lookback uint64
+	notifs   chan []*store.HeadChange
+}
+
+func newTestNode(t *testing.T, n int) *testNode {
+	tn := &testNode{notifs: make(chan []*store.HeadChange, 1)}
+
+	var parent *types.TipSet
+	for i := 0; i < n; i++ {
+		parent = mock.TipSet(mock.MkBlock(parent, 1, uint64(i)))
+		tn.tipsets = append(tn.tipsets, parent)
+	}
+	return tn
+}
+
+func (tn *testNode) api(t *testing.T) api.FullNode {
+	var out apistruct.FullNodeStruct
+
+	out.Internal.ChainHead = func(ctx context.Context) (*types.TipSet, error) {
+		return tn.tipsets[len(tn.tipsets)-1], nil
+	}
+	out.Internal.ChainGetTipSet = func(ctx context.Context, tsk types.TipSetKey) (*types.TipSet, error) {
+		for _, ts := range tn.tipsets {
+			if ts.Key() == tsk {
+				return ts, nil
+			}
+		}
+		return nil, xerrors.New("tipset not found")
+	}
+	out.Internal.ChainGetTipSetByHeight = func(ctx context.Context, h uint64, ts *types.TipSet) (*types.TipSet, error) {
+		return tn.tipsets[h], nil
+	}
+	out.Internal.ChainGetBlock = func(ctx context.Context, c cid.Cid) (*types.BlockHeader, error) {
+		for _, ts := range tn.tipsets {
+			if ts.Blocks()[0].Cid() == c {
+				return ts.Blocks()[0], nil
+			}
+		}
+		return nil, xerrors.New("block not found")
+	}
+	out.Internal.ChainGetBlockMessages = func(ctx context.Context, c cid.Cid) (*api.BlockMessages, error) {
+		return &api.BlockMessages{}, nil
+	}
+	out.Internal.ChainNotify = func(ctx context.Context) (<-chan []*store.HeadChange, error) {
+		return tn.notifs, nil
+	}
+	out.Internal.StateSearchMsg = func(ctx context.Context, msg cid.Cid, lookback uint64, timeout time.Duration) (*api.MsgWait, error) {
+		tn.lookback = lookback
+		return tn.found, nil
+	}
+	out.Internal.StateWaitMsg = func(ctx context.Context, msg cid.Cid) (*api.MsgWait, error) {
+		t.Fatal("gateway shouldn't let the node wait for messages")
+		return nil, nil
+	}
+	out.Internal.StateGetActor = func(ctx context.Context, addr address.Address, ts *types.TipSet) (*types.Actor, error) {
+		return &types.Actor{}, nil
+	}
+
+	return &out
+}
+
+func TestGatewayCheckTipSet(t *testing.T) {
+	tn := newTestNode(t, 20)
+	a := &GatewayAPI{api: tn.api(t), lookbackCap: 5, maxTimeout: time.Second}
+	ctx := context.Background()
+
+	if _, err := a.StateGetActor(ctx, mock.Address(1000), tn.tipsets[17]); err != nil {
+		t.Fatalf("expected recent tipset to be accepted: %s", err)
+	}
+
+	if _, err := a.StateGetActor(ctx, mock.Address(1000), tn.tipsets[3]); err == nil {
+		t.Fatal("expected tipset older than the lookback cap to be rejected")
+	}
+
+	// a tipset the node doesn't know, e.g. with a forged height
+	forged := mock.MkBlock(tn.tipsets[2], 1, 1000)
+	forged.Height = 19
+	if _, err := a.StateGetActor(ctx, mock.Address(1000), mock.TipSet(forged)); err == nil {
+		t.Fatal("expected unknown tipset to be rejected")
+	}
+}
+
+func TestGatewayGetBlock(t *testing.T) {
+	tn := newTestNode(t, 20)
+	a := &GatewayAPI{api: tn.api(t), lookbackCap: 5, maxTimeout: time.Second}
+	ctx := context.Background()
+
+	recent := tn.tipsets[17].Blocks()[0].Cid()
+	if _, err := a.ChainGetBlock(ctx, recent); err != nil {
+		t.Fatalf("expected recent block to be returned: %s", err)
+	}
+	if _, err := a.ChainGetBlockMessages(ctx, recent); err != nil {
+		t.Fatalf("expected messages of recent block to be returned: %s", err)
+	}
+
+	old := tn.tipsets[3].Blocks()[0].Cid()
+	if _, err := a.ChainGetBlock(ctx, old); err == nil {
+		t.Fatal("expected block older than the lookback cap to be rejected")
+	}
+	if _, err := a.ChainGetBlockMessages(ctx, old); err == nil {
+		t.Fatal("expected messages of block older than the lookback cap to be rejected")
+	}
+}
+
+func TestGatewayGetReceipt(t *testing.T) {
+	tn := newTestNode(t, 20)
+	a := &GatewayAPI{api: tn.api(t), lookbackCap: 5, maxTimeout: time.Second}
+	ctx := context.Background()
+
+	mcid := tn.tipsets[0].Blocks()[0].Messages
+	tn.found = &api.MsgWait{
+		Receipt: types.MessageReceipt{ExitCode: 0, GasUsed: types.NewInt(7)},
+		TipSet:  tn.tipsets[18],
+	}
+
+	r, err := a.StateGetReceipt(ctx, mcid, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil || !r.GasUsed.Equals(types.NewInt(7)) {
+		t.Fatal("expected receipt to be returned")
+	}
+	if tn.lookback != 5 {
+		t.Fatalf("expected the search to be limited to the lookback cap, got %d", tn.lookback)
+	}
+
+	// executed after the requested tipset
+	r, err = a.StateGetReceipt(ctx, mcid, tn.tipsets[17])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != nil {
+		t.Fatal("expected no receipt for a message executed after the requested tipset")
+	}
+}
+
+func TestGatewayWaitMsg(t *testing.T) {
+	tn := newTestNode(t, 20)
+	a := &GatewayAPI{api: tn.api(t), lookbackCap: 5, maxTimeout: 100 * time.Millisecond}
+	ctx := context.Background()
+
+	mcid := tn.tipsets[0].Blocks()[0].Messages
+
+	if _, err := a.StateWaitMsg(ctx, mcid); err == nil {
+		t.Fatal("expected waiting for a message that doesn't show up to time out")
+	}
+
+	tn.found = &api.MsgWait{TipSet: tn.tipsets[19]}
+	mw, err := a.StateWaitMsg(ctx, mcid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mw == nil || tn.lookback != 5 {
+		t.Fatal("expected message to be found within the lookback cap")
+	}
+}
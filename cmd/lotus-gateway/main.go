@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"gopkg.in/urfave/cli.v2"
+
+	"github.com/filecoin-project/lotus/build"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/jsonrpc"
+)
+
+var log = logging.Logger("gateway")
+
+func main() {
+	logging.SetLogLevel("*", "INFO")
+
+	local := []*cli.Command{
+		runCmd,
+	}
+
+	app := &cli.App{
+		Name:    "lotus-gateway",
+		Usage:   "Public API server for lotus",
+		Version: build.UserVersion,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "repo",
+				EnvVars: []string{"LOTUS_PATH"},
+				Value:   "~/.lotus", // TODO: Consider XDG_DATA_HOME
+			},
+		},
+
+		Commands: local,
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		log.Warn(err)
+		os.Exit(1)
+	}
+}
+
+var runCmd = &cli.Command{
+	Name:  "run",
+	Usage: "Start the gateway",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "listen",
+			Usage: "host address and port the api server will listen on",
+			Value: "0.0.0.0:2346",
+		},
+		&cli.Uint64Flag{
+			Name:  "api-max-lookback",
+			Usage: "maximum number of epochs queries can look back",
+			Value: 2 * build.Finality,
+		},
+		&cli.DurationFlag{
+			Name:  "api-max-wait",
+			Usage: "maximum time calls can wait for messages",
+			Value: 10 * build.BlockDelay * time.Second,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		log.Info("Starting lotus gateway")
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		v, err := api.Version(ctx)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Remote version: %s", v)
+
+		rpcServer := jsonrpc.NewServer()
+		rpcServer.Register("Filecoin", &GatewayAPI{
+			api:         api,
+			lookbackCap: cctx.Uint64("api-max-lookback"),
+			maxTimeout:  cctx.Duration("api-max-wait"),
+		})
+
+		mux := http.NewServeMux()
+		mux.Handle("/rpc/v0", rpcServer)
+
+		srv := &http.Server{
+			Addr:    cctx.String("listen"),
+			Handler: mux,
+		}
+
+		sigChan := make(chan os.Signal, 2)
+		go func() {
+			select {
+			case <-sigChan:
+			case <-ctx.Done():
+			}
+			log.Warn("Shutting down...")
+			if err := srv.Shutdown(context.TODO()); err != nil {
+				log.Errorf("shutting down RPC server failed: %s", err)
+			}
+		}()
+		signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
+
+		log.Infof("Serving API on %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	},
+}
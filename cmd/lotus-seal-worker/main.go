@@ -4,7 +4,6 @@ import (
 	"os"
 	"sync"
 
-	paramfetch "github.com/filecoin-project/go-paramfetch"
 	"github.com/filecoin-project/go-sectorbuilder"
 	"github.com/mitchellh/go-homedir"
 
@@ -18,6 +17,8 @@ import (
 	"github.com/filecoin-project/lotus/build"
 	lcli "github.com/filecoin-project/lotus/cli"
 	"github.com/filecoin-project/lotus/lib/lotuslog"
+	"github.com/filecoin-project/lotus/lib/proofdevice"
+	"github.com/filecoin-project/lotus/lib/proofparams"
 	"github.com/filecoin-project/lotus/node/repo"
 )
 
@@ -57,6 +58,10 @@ func main() {
 				Usage: "enable use of GPU for mining operations",
 				Value: true,
 			},
+			&cli.StringFlag{
+				Name:  "proof-device",
+				Usage: "device to seal on: 'cpu', 'gpu' or 'gpu:<index>', defaults to the miner's seal device",
+			},
 			&cli.BoolFlag{
 				Name: "no-precommit",
 			},
@@ -85,10 +90,6 @@ var runCmd = &cli.Command{
 	Name:  "run",
 	Usage: "Start lotus worker",
 	Action: func(cctx *cli.Context) error {
-		if !cctx.Bool("enable-gpu-proving") {
-			os.Setenv("BELLMAN_NO_GPU", "true")
-		}
-
 		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
 		if err != nil {
 			return xerrors.Errorf("getting miner api: %w", err)
@@ -96,6 +97,23 @@ var runCmd = &cli.Command{
 		defer closer()
 		ctx := lcli.ReqContext(cctx)
 
+		device := cctx.String("proof-device")
+		if device == "" && !cctx.Bool("enable-gpu-proving") {
+			device = proofdevice.CPU
+		}
+		if device == "" {
+			device, err = nodeApi.WorkerProofDevice(ctx)
+			if err != nil {
+				return xerrors.Errorf("getting seal device: %w", err)
+			}
+		}
+		if err := proofdevice.Apply(device); err != nil {
+			return err
+		}
+		if device != "" {
+			log.Infof("sealing on device '%s'", device)
+		}
+
 		ainfo, err := lcli.GetAPIInfo(cctx, repo.StorageMiner)
 		if err != nil {
 			return xerrors.Errorf("could not get api info: %w", err)
@@ -134,7 +152,7 @@ var runCmd = &cli.Command{
 			return err
 		}
 
-		if err := paramfetch.GetParams(build.ParametersJson(), ssize); err != nil {
+		if err := proofparams.Fetch(ctx, build.ParametersJson(), ssize, proofparams.DefaultParallel); err != nil {
 			return xerrors.Errorf("get params: %w", err)
 		}
 
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/genesis"
+)
+
+var genesisCmd = &cli.Command{
+	Name:  "genesis",
+	Usage: "Create and edit genesis templates",
+	Description: `Templates are used to create the genesis block with
+   'lotus daemon --lotus-make-random-genesis=[out.car] --genesis-template=[template.json]'`,
+	Subcommands: []*cli.Command{
+		genesisNewCmd,
+		genesisAddMinerCmd,
+		genesisAddAccountCmd,
+	},
+}
+
+var genesisNewCmd = &cli.Command{
+	Name:      "new",
+	Usage:     "Create a new genesis template",
+	ArgsUsage: "[template file]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "network-name",
+			Usage: "name of the network",
+		},
+		&cli.StringFlag{
+			Name:  "timestamp",
+			Usage: "genesis timestamp in RFC3339 format, defaults to the time the genesis block is created",
+		},
+		&cli.Uint64Flag{
+			Name:  "block-delay",
+			Usage: "block time in seconds, must match the lotus build the network is run with",
+			Value: build.BlockDelay,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected 1 argument")
+		}
+
+		template := &genesis.Template{
+			NetworkName: cctx.String("network-name"),
+			BlockDelay:  cctx.Uint64("block-delay"),
+			Miners:      map[string]genesis.GenesisMiner{},
+		}
+
+		if ts := cctx.String("timestamp"); ts != "" {
+			t, err := time.Parse(time.RFC3339, ts)
+			if err != nil {
+				return xerrors.Errorf("parsing timestamp: %w", err)
+			}
+			template.Timestamp = uint64(t.Unix())
+		}
+
+		return writeTemplate(cctx.Args().First(), template)
+	},
+}
+
+var genesisAddMinerCmd = &cli.Command{
+	Name:      "add-miner",
+	Usage:     "Add the miners from a preseal manifest to a genesis template",
+	ArgsUsage: "[template file] [preseal manifest]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "worker-balance",
+			Usage: "initial balance of the miner worker addresses",
+			Value: "100000",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 2 {
+			return xerrors.Errorf("expected 2 arguments")
+		}
+
+		template, err := readTemplate(cctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
+
+		mpath, err := homedir.Expand(cctx.Args().Get(1))
+		if err != nil {
+			return err
+		}
+		mdata, err := ioutil.ReadFile(mpath)
+		if err != nil {
+			return xerrors.Errorf("reading preseal manifest: %w", err)
+		}
+
+		var miners map[string]genesis.GenesisMiner
+		if err := json.Unmarshal(mdata, &miners); err != nil {
+			return xerrors.Errorf("parsing preseal manifest: %w", err)
+		}
+
+		balance, err := types.ParseFIL(cctx.String("worker-balance"))
+		if err != nil {
+			return xerrors.Errorf("parsing worker balance: %w", err)
+		}
+
+		for maddr, miner := range miners {
+			if _, ok := template.Miners[maddr]; ok {
+				return xerrors.Errorf("miner %s is already in the template", maddr)
+			}
+			template.Miners[maddr] = miner
+
+			template.Accounts = append(template.Accounts, genesis.Account{
+				Address: miner.Worker,
+				Balance: types.BigInt(balance),
+			})
+
+			fmt.Printf("added miner %s with %d sectors\n", maddr, len(miner.Sectors))
+		}
+
+		return writeTemplate(cctx.Args().Get(0), template)
+	},
+}
+
+var genesisAddAccountCmd = &cli.Command{
+	Name:      "add-account",
+	Usage:     "Add an account with an initial balance to a genesis template",
+	ArgsUsage: "[template file] [address] [balance (FIL)]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 3 {
+			return xerrors.Errorf("expected 3 arguments")
+		}
+
+		template, err := readTemplate(cctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
+
+		addr, err := address.NewFromString(cctx.Args().Get(1))
+		if err != nil {
+			return xerrors.Errorf("parsing address: %w", err)
+		}
+		if addr.Protocol() != address.SECP256K1 && addr.Protocol() != address.BLS {
+			return xerrors.Errorf("expected a key address, got %s", addr)
+		}
+
+		balance, err := types.ParseFIL(cctx.Args().Get(2))
+		if err != nil {
+			return xerrors.Errorf("parsing balance: %w", err)
+		}
+
+		template.Accounts = append(template.Accounts, genesis.Account{
+			Address: addr,
+			Balance: types.BigInt(balance),
+		})
+
+		return writeTemplate(cctx.Args().Get(0), template)
+	},
+}
+
+func readTemplate(path string) (*genesis.Template, error) {
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, xerrors.Errorf("reading template: %w", err)
+	}
+
+	var template genesis.Template
+	if err := json.Unmarshal(b, &template); err != nil {
+		return nil, xerrors.Errorf("parsing template: %w", err)
+	}
+	if template.Miners == nil {
+		template.Miners = map[string]genesis.GenesisMiner{}
+	}
+
+	return &template, nil
+}
+
+func writeTemplate(path string, template *genesis.Template) error {
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(template, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return xerrors.Errorf("writing template: %w", err)
+	}
+	return nil
+}
@@ -36,6 +36,7 @@ func main() {
 		preSealCmd,
 		aggregateManifestsCmd,
 		aggregateSectorDirsCmd,
+		genesisCmd,
 	}
 
 	app := &cli.App{
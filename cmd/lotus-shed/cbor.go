@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var cborCmd = &cli.Command{
+	Name:      "cbor",
+	Usage:     "Fetch an object from the full node blockstore and decode it",
+	ArgsUsage: "[cid]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "enc",
+			Usage: "output encoding: raw, hex or cbor (decoded to json)",
+			Value: "cbor",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected 1 argument")
+		}
+
+		c, err := cid.Decode(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("parsing cid: %w", err)
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		obj, err := api.ChainReadObj(lcli.ReqContext(cctx), c)
+		if err != nil {
+			return xerrors.Errorf("reading object: %w", err)
+		}
+
+		return printValue(cctx.String("enc"), obj)
+	},
+}
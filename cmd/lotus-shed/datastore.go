@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	mh "github.com/multiformats/go-multihash"
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+var datastoreCmd = &cli.Command{
+	Name:        "datastore",
+	Usage:       "Inspect raw datastore keys",
+	Description: "The node owning the repo must be stopped",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "miner",
+			Usage: "open the storage miner repo instead of the full node repo",
+		},
+		&cli.StringFlag{
+			Name:  "namespace",
+			Usage: "datastore namespace",
+			Value: "/metadata",
+		},
+	},
+	Subcommands: []*cli.Command{
+		datastoreListCmd,
+		datastoreGetCmd,
+	},
+}
+
+var datastoreListCmd = &cli.Command{
+	Name:      "list",
+	Usage:     "List datastore keys",
+	ArgsUsage: "[key prefix]",
+	Action: func(cctx *cli.Context) error {
+		lr, err := openRepo(cctx, cctx.Bool("miner"))
+		if err != nil {
+			return err
+		}
+		defer lr.Close() // nolint:errcheck
+
+		ds, err := lr.Datastore(cctx.String("namespace"))
+		if err != nil {
+			return err
+		}
+
+		res, err := ds.Query(dsq.Query{Prefix: cctx.Args().First(), KeysOnly: true})
+		if err != nil {
+			return xerrors.Errorf("querying datastore: %w", err)
+		}
+		defer res.Close() // nolint:errcheck
+
+		for r := range res.Next() {
+			if r.Error != nil {
+				return r.Error
+			}
+			fmt.Println(r.Key)
+		}
+
+		return nil
+	},
+}
+
+var datastoreGetCmd = &cli.Command{
+	Name:      "get",
+	Usage:     "Print the value of a datastore key",
+	ArgsUsage: "[key]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "enc",
+			Usage: "output encoding: raw, hex or cbor (decoded to json)",
+			Value: "hex",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected 1 argument")
+		}
+
+		lr, err := openRepo(cctx, cctx.Bool("miner"))
+		if err != nil {
+			return err
+		}
+		defer lr.Close() // nolint:errcheck
+
+		ds, err := lr.Datastore(cctx.String("namespace"))
+		if err != nil {
+			return err
+		}
+
+		val, err := ds.Get(datastore.NewKey(cctx.Args().First()))
+		if err != nil {
+			return xerrors.Errorf("getting value: %w", err)
+		}
+
+		return printValue(cctx.String("enc"), val)
+	},
+}
+
+func printValue(enc string, val []byte) error {
+	switch enc {
+	case "raw":
+		_, err := os.Stdout.Write(val)
+		return err
+	case "hex":
+		fmt.Println(hex.EncodeToString(val))
+		return nil
+	case "cbor":
+		nd, err := cbor.Decode(val, mh.SHA2_256, -1)
+		if err != nil {
+			return xerrors.Errorf("decoding cbor: %w", err)
+		}
+		js, err := nd.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(js))
+		return nil
+	default:
+		return xerrors.Errorf("unknown encoding: %s", enc)
+	}
+}
+
+func openRepo(cctx *cli.Context, miner bool) (repo.LockedRepo, error) {
+	path, typ := cctx.String("repo"), repo.FullNode
+	if miner {
+		path, typ = cctx.String("storagerepo"), repo.StorageMiner
+	}
+
+	r, err := repo.NewFS(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := r.Exists()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, xerrors.Errorf("repo at '%s' is not initialized", path)
+	}
+
+	lr, err := r.Lock(typ)
+	if err != nil {
+		return nil, xerrors.Errorf("locking repo (is the node running?): %w", err)
+	}
+	return lr, nil
+}
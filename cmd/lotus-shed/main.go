@@ -21,12 +21,27 @@ func main() {
 		peerkeyCmd,
 		noncefix,
 		bigIntParseCmd,
+		datastoreCmd,
+		cborCmd,
+		sectorsCmd,
 	}
 
 	app := &cli.App{
-		Name:     "lotus-shed",
-		Usage:    "A place for all the lotus tools",
-		Version:  build.BuildVersion,
+		Name:    "lotus-shed",
+		Usage:   "A place for all the lotus tools",
+		Version: build.BuildVersion,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "repo",
+				EnvVars: []string{"LOTUS_PATH"},
+				Value:   "~/.lotus", // TODO: Consider XDG_DATA_HOME
+			},
+			&cli.StringFlag{
+				Name:    "storagerepo",
+				EnvVars: []string{"LOTUS_STORAGE_PATH"},
+				Value:   "~/.lotusstorage", // TODO: Consider XDG_DATA_HOME
+			},
+		},
 		Commands: local,
 	}
 
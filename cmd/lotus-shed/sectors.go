@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/filecoin-project/go-address"
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/go-sectorbuilder"
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/node/repo"
+	"github.com/filecoin-project/lotus/storage/sealing"
+)
+
+var sectorsCmd = &cli.Command{
+	Name:        "sectors",
+	Usage:       "Inspect the sector metadata of a storage miner",
+	Description: "The storage miner owning the repo must be stopped",
+	Subcommands: []*cli.Command{
+		sectorsDumpCmd,
+		sectorsVerifySealCmd,
+	},
+}
+
+var sectorsDumpCmd = &cli.Command{
+	Name:      "dump",
+	Usage:     "Print the metadata of all, or the given sectors as json",
+	ArgsUsage: "[sector ids...]",
+	Action: func(cctx *cli.Context) error {
+		lr, err := openRepo(cctx, true)
+		if err != nil {
+			return err
+		}
+		defer lr.Close() // nolint:errcheck
+
+		var sectors []sealing.SectorInfo
+		if cctx.Args().Present() {
+			for _, arg := range cctx.Args().Slice() {
+				id, err := strconv.ParseUint(arg, 10, 64)
+				if err != nil {
+					return xerrors.Errorf("parsing sector id %s: %w", arg, err)
+				}
+
+				info, err := loadSector(lr, id)
+				if err != nil {
+					return err
+				}
+				sectors = append(sectors, *info)
+			}
+		} else {
+			sectors, err = loadSectors(lr)
+			if err != nil {
+				return err
+			}
+		}
+
+		out, err := json.MarshalIndent(sectors, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var sectorsVerifySealCmd = &cli.Command{
+	Name:      "verify-seal",
+	Usage:     "Verify the stored seal proof of a sector against chain randomness",
+	ArgsUsage: "[sector id]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected 1 argument")
+		}
+
+		id, err := strconv.ParseUint(cctx.Args().First(), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("parsing sector id: %w", err)
+		}
+
+		lr, err := openRepo(cctx, true)
+		if err != nil {
+			return err
+		}
+		defer lr.Close() // nolint:errcheck
+
+		info, err := loadSector(lr, id)
+		if err != nil {
+			return err
+		}
+		if len(info.Proof) == 0 {
+			return xerrors.Errorf("sector %d has no seal proof yet (state: %s)", id, lapi.SectorStates[info.State])
+		}
+
+		maddr, err := minerAddress(lr)
+		if err != nil {
+			return err
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		head, err := api.ChainHead(ctx)
+		if err != nil {
+			return err
+		}
+
+		ticket, err := api.ChainGetRandomness(ctx, head.Key(), int64(info.Ticket.BlockHeight)-build.SealRandomnessLookback)
+		if err != nil {
+			return xerrors.Errorf("getting ticket randomness: %w", err)
+		}
+		if !bytes.Equal(ticket, info.Ticket.TicketBytes) {
+			fmt.Printf("ticket mismatch at height %d: stored %x, chain %x\n", info.Ticket.BlockHeight, info.Ticket.TicketBytes, ticket)
+		}
+
+		seed, err := api.ChainGetRandomness(ctx, head.Key(), int64(info.Seed.BlockHeight))
+		if err != nil {
+			return xerrors.Errorf("getting seed randomness: %w", err)
+		}
+		if !bytes.Equal(seed, info.Seed.TicketBytes) {
+			fmt.Printf("seed mismatch at height %d: stored %x, chain %x\n", info.Seed.BlockHeight, info.Seed.TicketBytes, seed)
+		}
+
+		ssize, err := api.StateMinerSectorSize(ctx, maddr, head)
+		if err != nil {
+			return xerrors.Errorf("getting sector size: %w", err)
+		}
+
+		ok, err := sectorbuilder.ProofVerifier.VerifySeal(ssize, info.CommR, info.CommD, maddr, ticket, seed, id, info.Proof)
+		if err != nil {
+			return xerrors.Errorf("verifying seal: %w", err)
+		}
+		if !ok {
+			return xerrors.Errorf("seal proof for sector %d is invalid", id)
+		}
+
+		fmt.Printf("seal proof for sector %d is valid\n", id)
+		return nil
+	},
+}
+
+func loadSector(lr repo.LockedRepo, id uint64) (*sealing.SectorInfo, error) {
+	mds, err := lr.Datastore("/metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := mds.Get(datastore.NewKey(sealing.SectorStorePrefix).ChildString(fmt.Sprint(id)))
+	if err != nil {
+		return nil, xerrors.Errorf("getting sector %d: %w", id, err)
+	}
+
+	var info sealing.SectorInfo
+	if err := cborutil.ReadCborRPC(bytes.NewReader(b), &info); err != nil {
+		return nil, xerrors.Errorf("decoding sector %d: %w", id, err)
+	}
+	return &info, nil
+}
+
+func loadSectors(lr repo.LockedRepo) ([]sealing.SectorInfo, error) {
+	mds, err := lr.Datastore("/metadata")
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := mds.Query(dsq.Query{Prefix: sealing.SectorStorePrefix})
+	if err != nil {
+		return nil, xerrors.Errorf("querying sectors: %w", err)
+	}
+
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, xerrors.Errorf("reading sectors: %w", err)
+	}
+
+	out := make([]sealing.SectorInfo, len(entries))
+	for i, e := range entries {
+		if err := cborutil.ReadCborRPC(bytes.NewReader(e.Value), &out[i]); err != nil {
+			return nil, xerrors.Errorf("decoding sector %s: %w", e.Key, err)
+		}
+	}
+	return out, nil
+}
+
+func minerAddress(lr repo.LockedRepo) (address.Address, error) {
+	mds, err := lr.Datastore("/metadata")
+	if err != nil {
+		return address.Undef, err
+	}
+
+	b, err := mds.Get(datastore.NewKey("miner-address"))
+	if err != nil {
+		return address.Undef, xerrors.Errorf("getting miner address: %w", err)
+	}
+	return address.NewFromBytes(b)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-sectorbuilder"
+	"github.com/ipfs/go-datastore"
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	lapi "github.com/filecoin-project/lotus/api"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/node/config"
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+var backupCmd = &cli.Command{
+	Name:      "backup",
+	Usage:     "Create a backup of the miner keys, metadata and config",
+	ArgsUsage: "[backup file path]",
+	Description: `The backup contains everything needed to restore the miner with
+   'lotus-storage-miner init --restore', except for the sealed sectors,
+   which need to be backed up or moved separately.
+
+   Unless --offline is set, the miner needs to be running and the backup
+   file is written on the miner machine.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "offline",
+			Usage: "create the backup directly from the repo of a stopped miner",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected 1 argument")
+		}
+
+		fpath, err := homedir.Expand(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("expanding file path: %w", err)
+		}
+		fpath, err = filepath.Abs(fpath)
+		if err != nil {
+			return xerrors.Errorf("getting absolute file path: %w", err)
+		}
+
+		if cctx.Bool("offline") {
+			return offlineBackup(cctx, fpath)
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		if err := nodeApi.CreateBackup(lcli.ReqContext(cctx), fpath); err != nil {
+			return err
+		}
+
+		fmt.Println("Success")
+		return nil
+	},
+}
+
+func offlineBackup(cctx *cli.Context, fpath string) error {
+	r, err := repo.NewFS(cctx.String(FlagStorageRepo))
+	if err != nil {
+		return err
+	}
+
+	ok, err := r.Exists()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return xerrors.Errorf("repo at '%s' is not initialized", cctx.String(FlagStorageRepo))
+	}
+
+	lr, err := r.Lock(repo.StorageMiner)
+	if err != nil {
+		return xerrors.Errorf("locking repo (is the miner running?): %w", err)
+	}
+	defer lr.Close() // nolint:errcheck
+
+	f, err := os.OpenFile(fpath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return xerrors.Errorf("creating backup file: %w", err)
+	}
+
+	if err := repo.Backup(lr, f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(fpath)
+		return xerrors.Errorf("creating backup: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return xerrors.Errorf("closing backup file: %w", err)
+	}
+
+	fmt.Println("Success")
+	return nil
+}
+
+// restoreMiner imports a backup into a freshly initialized repo and checks
+// that the restored miner matches the chain state
+func restoreMiner(ctx context.Context, cctx *cli.Context, api lapi.FullNode, r repo.Repo) error {
+	bpath, err := homedir.Expand(cctx.String("restore"))
+	if err != nil {
+		return xerrors.Errorf("expanding backup path: %w", err)
+	}
+
+	f, err := os.Open(bpath)
+	if err != nil {
+		return xerrors.Errorf("opening backup file: %w", err)
+	}
+	defer f.Close() // nolint:errcheck
+
+	lr, err := r.Lock(repo.StorageMiner)
+	if err != nil {
+		return err
+	}
+	defer lr.Close() // nolint:errcheck
+
+	log.Info("Restoring metadata backup")
+
+	if err := repo.Restore(lr, f); err != nil {
+		return xerrors.Errorf("restoring backup: %w", err)
+	}
+
+	if spath := cctx.String("restore-storage"); spath != "" {
+		spath, err := homedir.Expand(spath)
+		if err != nil {
+			return xerrors.Errorf("expanding storage path: %w", err)
+		}
+
+		log.Infof("Setting sector storage path to %s", spath)
+		if err := setStoragePath(lr, spath); err != nil {
+			return err
+		}
+	}
+
+	mds, err := lr.Datastore("/metadata")
+	if err != nil {
+		return err
+	}
+
+	maddrb, err := mds.Get(datastore.NewKey("miner-address"))
+	if err != nil {
+		return xerrors.Errorf("getting miner address from backup: %w", err)
+	}
+	maddr, err := address.NewFromBytes(maddrb)
+	if err != nil {
+		return xerrors.Errorf("parsing miner address: %w", err)
+	}
+
+	log.Infof("Checking miner %s on chain", maddr)
+
+	worker, err := api.StateMinerWorker(ctx, maddr, nil)
+	if err != nil {
+		return xerrors.Errorf("getting miner worker: %w", err)
+	}
+
+	has, err := api.WalletHas(ctx, worker)
+	if err != nil {
+		return xerrors.Errorf("checking worker key: %w", err)
+	}
+	if !has {
+		log.Warnf("worker key %s is not in the full node wallet, the miner won't be able to send messages until it is imported", worker)
+	}
+
+	return nil
+}
+
+func setStoragePath(lr repo.LockedRepo, spath string) error {
+	c, err := lr.Config()
+	if err != nil {
+		return xerrors.Errorf("loading config: %w", err)
+	}
+	cfg, ok := c.(*config.StorageMiner)
+	if !ok {
+		return xerrors.Errorf("invalid config from repo, got: %T", c)
+	}
+
+	cfg.SectorBuilder.Path = ""
+	cfg.SectorBuilder.Storage = sectorbuilder.SimplePath(spath)
+
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(cfg); err != nil {
+		return xerrors.Errorf("encoding config: %w", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(lr.Path(), "config.toml"), buf.Bytes(), 0644); err != nil {
+		return xerrors.Errorf("writing config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/go-sectorbuilder"
+	"github.com/filecoin-project/go-sectorbuilder/fs"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	lapi "github.com/filecoin-project/lotus/api"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/node/config"
+	"github.com/filecoin-project/lotus/node/repo"
+	"github.com/filecoin-project/lotus/storage/sealing"
+)
+
+var sectorsImportCmd = &cli.Command{
+	Name:      "import",
+	Usage:     "Import sealed sectors from another installation of the same miner",
+	ArgsUsage: "[other miner repo]",
+	Description: `Sealed sector files are imported using the sector storage configured in
+   the other repo. The sector metadata is rebuilt from the sectors committed
+   on chain, keeping deal information from the other repo where available.
+
+   Both miners need to be stopped.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "symlink",
+			Usage: "symlink the sealed sectors instead of copying them into place",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected 1 argument")
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		lr, err := lockMinerRepo(cctx.String(FlagStorageRepo))
+		if err != nil {
+			return err
+		}
+		defer lr.Close() // nolint:errcheck
+
+		srcPath, err := homedir.Expand(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+		srcLr, err := lockMinerRepo(srcPath)
+		if err != nil {
+			return xerrors.Errorf("opening source repo: %w", err)
+		}
+		defer srcLr.Close() // nolint:errcheck
+
+		mds, err := lr.Datastore("/metadata")
+		if err != nil {
+			return err
+		}
+		srcMds, err := srcLr.Datastore("/metadata")
+		if err != nil {
+			return err
+		}
+
+		maddr, err := repoMinerAddress(mds)
+		if err != nil {
+			return err
+		}
+		srcMaddr, err := repoMinerAddress(srcMds)
+		if err != nil {
+			return xerrors.Errorf("source repo: %w", err)
+		}
+		if maddr != srcMaddr {
+			return xerrors.Errorf("source repo is for miner %s, this repo is for %s", srcMaddr, maddr)
+		}
+
+		ssize, err := api.StateMinerSectorSize(ctx, maddr, nil)
+		if err != nil {
+			return xerrors.Errorf("getting sector size: %w", err)
+		}
+
+		srcPaths, err := repoStoragePaths(srcLr)
+		if err != nil {
+			return xerrors.Errorf("source repo: %w", err)
+		}
+		srcSb, err := sectorbuilder.New(&sectorbuilder.Config{
+			SectorSize:    ssize,
+			WorkerThreads: 2,
+			Paths:         srcPaths,
+		}, namespace.Wrap(srcMds, datastore.NewKey("/sectorbuilder")))
+		if err != nil {
+			return xerrors.Errorf("opening source sectorbuilder: %w", err)
+		}
+
+		paths, err := repoStoragePaths(lr)
+		if err != nil {
+			return err
+		}
+		sb, err := sectorbuilder.New(&sectorbuilder.Config{
+			SectorSize:    ssize,
+			WorkerThreads: 2,
+			Paths:         paths,
+		}, namespace.Wrap(mds, datastore.NewKey("/sectorbuilder")))
+		if err != nil {
+			return xerrors.Errorf("opening sectorbuilder: %w", err)
+		}
+
+		fmt.Println("Importing sealed sectors")
+		if err := sb.ImportFrom(srcSb, cctx.Bool("symlink")); err != nil {
+			return xerrors.Errorf("importing sectors: %w", err)
+		}
+
+		sectors, err := api.StateMinerSectors(ctx, maddr, nil)
+		if err != nil {
+			return xerrors.Errorf("getting sectors from chain: %w", err)
+		}
+
+		var imported, rebuilt int
+		for _, sector := range sectors {
+			sectorKey := datastore.NewKey(sealing.SectorStorePrefix).ChildString(fmt.Sprint(sector.SectorID))
+
+			has, err := mds.Has(sectorKey)
+			if err != nil {
+				return err
+			}
+			if has {
+				continue
+			}
+
+			info, err := importedSectorInfo(srcMds, sectorKey, sector, ssize)
+			if err != nil {
+				return xerrors.Errorf("sector %d: %w", sector.SectorID, err)
+			}
+			if len(info.Pieces) == 0 {
+				rebuilt++
+			}
+
+			b, err := cborutil.Dump(info)
+			if err != nil {
+				return err
+			}
+			if err := mds.Put(sectorKey, b); err != nil {
+				return xerrors.Errorf("storing metadata of sector %d: %w", sector.SectorID, err)
+			}
+			imported++
+		}
+
+		fmt.Printf("Imported %d sectors (%d without deal information)\n", imported, rebuilt)
+		return nil
+	},
+}
+
+// importedSectorInfo returns the metadata of a sector committed on chain,
+// taking it from the source repo when it matches the chain and its pieces fit
+// in a sector of the miner's sector size
+func importedSectorInfo(srcMds datastore.Batching, sectorKey datastore.Key, sector *lapi.ChainSectorInfo, ssize uint64) (*sealing.SectorInfo, error) {
+	b, err := srcMds.Get(sectorKey)
+	switch err {
+	case nil:
+		var info sealing.SectorInfo
+		if err := cborutil.ReadCborRPC(bytes.NewReader(b), &info); err != nil {
+			return nil, xerrors.Errorf("decoding source metadata: %w", err)
+		}
+
+		var pieceBytes uint64
+		for _, p := range info.Pieces {
+			pieceBytes += p.Size
+		}
+
+		switch {
+		case info.SectorID != sector.SectorID || !bytes.Equal(info.CommR, sector.CommR) || !bytes.Equal(info.CommD, sector.CommD):
+			log.Warnf("metadata of sector %d in the source repo doesn't match the chain, rebuilding", sector.SectorID)
+		case pieceBytes > sectorbuilder.UserBytesForSectorSize(ssize):
+			log.Warnf("pieces of sector %d in the source repo don't fit in a %d byte sector, rebuilding", sector.SectorID, ssize)
+		default:
+			info.State = lapi.Proving
+			return &info, nil
+		}
+	case datastore.ErrNotFound:
+	default:
+		return nil, xerrors.Errorf("getting source metadata: %w", err)
+	}
+
+	return &sealing.SectorInfo{
+		State:    lapi.Proving,
+		SectorID: sector.SectorID,
+		CommD:    sector.CommD,
+		CommR:    sector.CommR,
+	}, nil
+}
+
+func lockMinerRepo(path string) (repo.LockedRepo, error) {
+	r, err := repo.NewFS(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := r.Exists()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, xerrors.Errorf("repo at '%s' is not initialized", path)
+	}
+
+	lr, err := r.Lock(repo.StorageMiner)
+	if err != nil {
+		return nil, xerrors.Errorf("locking repo at '%s' (is the miner running?): %w", path, err)
+	}
+	return lr, nil
+}
+
+func repoMinerAddress(mds datastore.Batching) (address.Address, error) {
+	b, err := mds.Get(datastore.NewKey("miner-address"))
+	if err != nil {
+		return address.Undef, xerrors.Errorf("getting miner address: %w", err)
+	}
+	return address.NewFromBytes(b)
+}
+
+// repoStoragePaths returns the sector storage paths configured in a repo
+func repoStoragePaths(lr repo.LockedRepo) ([]fs.PathConfig, error) {
+	c, err := lr.Config()
+	if err != nil {
+		return nil, xerrors.Errorf("loading config: %w", err)
+	}
+	cfg, ok := c.(*config.StorageMiner)
+	if !ok {
+		return nil, xerrors.Errorf("invalid config from repo, got: %T", c)
+	}
+
+	if cfg.SectorBuilder.Path != "" {
+		return sectorbuilder.SimplePath(cfg.SectorBuilder.Path), nil
+	}
+	if len(cfg.SectorBuilder.Storage) > 0 {
+		return cfg.SectorBuilder.Storage, nil
+	}
+	return sectorbuilder.SimplePath(lr.Path()), nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/go-sectorbuilder"
+	"github.com/ipfs/go-datastore"
+
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/storage/sealing"
+)
+
+func TestImportedSectorInfo(t *testing.T) {
+	const ssize = 1024
+	onChain := &lapi.ChainSectorInfo{
+		SectorID: 5,
+		CommD:    []byte("commd"),
+		CommR:    []byte("commr"),
+	}
+	sectorKey := datastore.NewKey(sealing.SectorStorePrefix).ChildString(fmt.Sprint(onChain.SectorID))
+
+	withDeals := func(modify func(info *sealing.SectorInfo)) datastore.Batching {
+		info := &sealing.SectorInfo{
+			State:    lapi.CommitWait,
+			SectorID: onChain.SectorID,
+			CommD:    onChain.CommD,
+			CommR:    onChain.CommR,
+			Pieces: []sealing.Piece{{
+				DealID: 1,
+				Size:   sectorbuilder.UserBytesForSectorSize(ssize),
+				CommP:  []byte("commp"),
+			}},
+		}
+		if modify != nil {
+			modify(info)
+		}
+
+		b, err := cborutil.Dump(info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mds := datastore.NewMapDatastore()
+		if err := mds.Put(sectorKey, b); err != nil {
+			t.Fatal(err)
+		}
+		return mds
+	}
+
+	for _, tc := range []struct {
+		name     string
+		src      datastore.Batching
+		expDeals bool
+	}{
+		{name: "matching", src: withDeals(nil), expDeals: true},
+		{name: "missing", src: datastore.NewMapDatastore()},
+		{name: "other commr", src: withDeals(func(info *sealing.SectorInfo) {
+			info.CommR = []byte("other commr")
+		})},
+		{name: "other commd", src: withDeals(func(info *sealing.SectorInfo) {
+			info.CommD = []byte("other commd")
+		})},
+		{name: "other sector", src: withDeals(func(info *sealing.SectorInfo) {
+			info.SectorID = 6
+		})},
+		{name: "bigger sector size", src: withDeals(func(info *sealing.SectorInfo) {
+			info.Pieces[0].Size = sectorbuilder.UserBytesForSectorSize(2 * ssize)
+		})},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := importedSectorInfo(tc.src, sectorKey, onChain, ssize)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info.State != lapi.Proving || info.SectorID != onChain.SectorID {
+				t.Fatalf("expected sector %d to be proving, got sector %d in state %d", onChain.SectorID, info.SectorID, info.State)
+			}
+			if string(info.CommR) != string(onChain.CommR) || string(info.CommD) != string(onChain.CommD) {
+				t.Fatal("expected the commitments from chain")
+			}
+			if hasDeals := len(info.Pieces) > 0; hasDeals != tc.expDeals {
+				t.Fatalf("expected deal information to be kept: %t, got %t", tc.expDeals, hasDeals)
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-sectorbuilder"
 	"gopkg.in/urfave/cli.v2"
 
 	"github.com/filecoin-project/lotus/api"
@@ -12,6 +14,26 @@ import (
 	lcli "github.com/filecoin-project/lotus/cli"
 )
 
+type minerInfo struct {
+	Miner            address.Address
+	AdditionalActors []address.Address
+	SectorSize       uint64
+	Power            api.MinerPower
+	SectorCount      api.MinerSectors
+	Faults           []uint64
+	Workers          sectorbuilder.WorkerStats
+	Proving          provingInfo
+	Sectors          map[string]int
+}
+
+type provingInfo struct {
+	// ElectionPeriodStart is 0 when the miner isn't proving
+	ElectionPeriodStart uint64
+	FallbackEpoch       uint64
+	DeadlineEpoch       uint64
+	Height              uint64
+}
+
 var infoCmd = &cli.Command{
 	Name:  "info",
 	Usage: "Print storage miner info",
@@ -30,38 +52,78 @@ var infoCmd = &cli.Command{
 
 		ctx := lcli.ReqContext(cctx)
 
-		maddr, err := nodeApi.ActorAddress(ctx)
+		var mi minerInfo
+
+		mi.Miner, err = nodeApi.ActorAddress(ctx)
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("Miner: %s\n", maddr)
+		actors, err := nodeApi.ActorList(ctx)
+		if err != nil {
+			return err
+		}
+		if len(actors) > 1 {
+			mi.AdditionalActors = actors[1:]
+		}
 
-		// Sector size
-		sizeByte, err := api.StateMinerSectorSize(ctx, maddr, nil)
+		mi.SectorSize, err = api.StateMinerSectorSize(ctx, mi.Miner, nil)
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("Sector Size: %s\n", types.NewInt(sizeByte).SizeStr())
+		mi.Power, err = api.StateMinerPower(ctx, mi.Miner, nil)
+		if err != nil {
+			return err
+		}
 
-		pow, err := api.StateMinerPower(ctx, maddr, nil)
+		mi.SectorCount, err = api.StateMinerSectorCount(ctx, mi.Miner, nil)
+		if err != nil {
+			return err
+		}
+		mi.Faults, err = api.StateMinerFaults(ctx, mi.Miner, nil)
 		if err != nil {
 			return err
 		}
 
-		percI := types.BigDiv(types.BigMul(pow.MinerPower, types.NewInt(1000000)), pow.TotalPower)
-		fmt.Printf("Power: %s / %s (%0.4f%%)\n", pow.MinerPower.SizeStr(), pow.TotalPower.SizeStr(), float64(percI.Int64())/10000)
+		// TODO: indicate whether the post worker is in use
+		mi.Workers, err = nodeApi.WorkerStats(ctx)
+		if err != nil {
+			return err
+		}
 
-		secCounts, err := api.StateMinerSectorCount(ctx, maddr, nil)
+		mi.Proving, err = getProvingInfo(ctx, api, mi.Miner)
 		if err != nil {
 			return err
 		}
-		faults, err := api.StateMinerFaults(ctx, maddr, nil)
+
+		mi.Sectors, err = sectorsInfo(ctx, nodeApi)
 		if err != nil {
 			return err
 		}
 
+		if lcli.OutputJSON(cctx) {
+			return lcli.PrintJSON(mi)
+		}
+
+		fmt.Printf("Miner: %s\n", mi.Miner)
+
+		if len(mi.AdditionalActors) > 0 {
+			fmt.Printf("Additional actors (use --miner-actor):")
+			for _, a := range mi.AdditionalActors {
+				fmt.Printf(" %s", a)
+			}
+			fmt.Println()
+		}
+
+		sizeByte := mi.SectorSize
+		fmt.Printf("Sector Size: %s\n", types.NewInt(sizeByte).SizeStr())
+
+		pow := mi.Power
+		percI := types.BigDiv(types.BigMul(pow.MinerPower, types.NewInt(1000000)), pow.TotalPower)
+		fmt.Printf("Power: %s / %s (%0.4f%%)\n", pow.MinerPower.SizeStr(), pow.TotalPower.SizeStr(), float64(percI.Int64())/10000)
+
+		secCounts, faults := mi.SectorCount, mi.Faults
 		fmt.Printf("\tCommitted: %s\n", types.BigMul(types.NewInt(secCounts.Sset), types.NewInt(sizeByte)).SizeStr())
 		if len(faults) == 0 {
 			fmt.Printf("\tProving: %s\n", types.BigMul(types.NewInt(secCounts.Pset), types.NewInt(sizeByte)).SizeStr())
@@ -72,12 +134,7 @@ var infoCmd = &cli.Command{
 				float64(10000*uint64(len(faults))/secCounts.Pset)/100.)
 		}
 
-		// TODO: indicate whether the post worker is in use
-		wstat, err := nodeApi.WorkerStats(ctx)
-		if err != nil {
-			return err
-		}
-
+		wstat := mi.Workers
 		fmt.Printf("Worker use:\n")
 		fmt.Printf("\tLocal: %d / %d (+%d reserved)\n", wstat.LocalTotal-wstat.LocalReserved-wstat.LocalFree, wstat.LocalTotal-wstat.LocalReserved, wstat.LocalReserved)
 		fmt.Printf("\tRemote: %d / %d\n", wstat.RemotesTotal-wstat.RemotesFree, wstat.RemotesTotal)
@@ -88,47 +145,103 @@ var infoCmd = &cli.Command{
 		fmt.Printf("\tCommit: %d\n", wstat.CommitWait)
 		fmt.Printf("\tUnseal: %d\n", wstat.UnsealWait)
 
-		eps, err := api.StateMinerElectionPeriodStart(ctx, maddr, nil)
+		printProvingInfo(mi.Proving)
+
+		fmt.Println("Sectors: ", mi.Sectors)
+
+		// TODO: grab actr state / info
+		//  * Sealed sectors (count / bytes)
+		//  * Power
+		return nil
+	},
+}
+
+var provingCmd = &cli.Command{
+	Name:  "proving",
+	Usage: "View proving information",
+	Subcommands: []*cli.Command{
+		provingInfoCmd,
+	},
+}
+
+var provingInfoCmd = &cli.Command{
+	Name:  "info",
+	Usage: "Print the PoSt submission schedule of the miner",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
 		if err != nil {
 			return err
 		}
-		if eps != 0 {
-			head, err := api.ChainHead(ctx)
-			if err != nil {
-				return err
-			}
-			lastEps := int64(head.Height() - eps)
-			lastEpsS := lastEps * build.BlockDelay
-
-			fallback := lastEps + build.FallbackPoStDelay
-			fallbackS := fallback * build.BlockDelay
+		defer closer()
 
-			next := lastEps + build.SlashablePowerDelay
-			nextS := next * build.BlockDelay
+		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer acloser()
 
-			fmt.Printf("PoSt Submissions:\n")
-			fmt.Printf("\tPrevious: Epoch %d (%d block(s), ~%dm %ds ago)\n", eps, lastEps, lastEpsS/60, lastEpsS%60)
-			fmt.Printf("\tFallback: Epoch %d (in %d blocks, ~%dm %ds)\n", eps+build.FallbackPoStDelay, fallback, fallbackS/60, fallbackS%60)
-			fmt.Printf("\tDeadline: Epoch %d (in %d blocks, ~%dm %ds)\n", eps+build.SlashablePowerDelay, next, nextS/60, nextS%60)
+		ctx := lcli.ReqContext(cctx)
 
-		} else {
-			fmt.Printf("Proving Period: Not Proving\n")
+		maddr, err := nodeApi.ActorAddress(ctx)
+		if err != nil {
+			return err
 		}
 
-		sinfo, err := sectorsInfo(ctx, nodeApi)
+		pi, err := getProvingInfo(ctx, api, maddr)
 		if err != nil {
 			return err
 		}
 
-		fmt.Println("Sectors: ", sinfo)
+		if lcli.OutputJSON(cctx) {
+			return lcli.PrintJSON(pi)
+		}
 
-		// TODO: grab actr state / info
-		//  * Sealed sectors (count / bytes)
-		//  * Power
+		printProvingInfo(pi)
 		return nil
 	},
 }
 
+func getProvingInfo(ctx context.Context, api api.FullNode, maddr address.Address) (provingInfo, error) {
+	eps, err := api.StateMinerElectionPeriodStart(ctx, maddr, nil)
+	if err != nil {
+		return provingInfo{}, err
+	}
+
+	head, err := api.ChainHead(ctx)
+	if err != nil {
+		return provingInfo{}, err
+	}
+
+	pi := provingInfo{Height: head.Height()}
+	if eps != 0 {
+		pi.ElectionPeriodStart = eps
+		pi.FallbackEpoch = eps + build.FallbackPoStDelay
+		pi.DeadlineEpoch = eps + build.SlashablePowerDelay
+	}
+	return pi, nil
+}
+
+func printProvingInfo(pi provingInfo) {
+	if pi.ElectionPeriodStart == 0 {
+		fmt.Printf("Proving Period: Not Proving\n")
+		return
+	}
+
+	lastEps := int64(pi.Height - pi.ElectionPeriodStart)
+	lastEpsS := lastEps * build.BlockDelay
+
+	fallback := lastEps + build.FallbackPoStDelay
+	fallbackS := fallback * build.BlockDelay
+
+	next := lastEps + build.SlashablePowerDelay
+	nextS := next * build.BlockDelay
+
+	fmt.Printf("PoSt Submissions:\n")
+	fmt.Printf("\tPrevious: Epoch %d (%d block(s), ~%dm %ds ago)\n", pi.ElectionPeriodStart, lastEps, lastEpsS/60, lastEpsS%60)
+	fmt.Printf("\tFallback: Epoch %d (in %d blocks, ~%dm %ds)\n", pi.FallbackEpoch, fallback, fallbackS/60, fallbackS%60)
+	fmt.Printf("\tDeadline: Epoch %d (in %d blocks, ~%dm %ds)\n", pi.DeadlineEpoch, next, nextS/60, nextS%60)
+}
+
 func sectorsInfo(ctx context.Context, napi api.StorageMiner) (map[string]int, error) {
 	sectors, err := napi.SectorsList(ctx)
 	if err != nil {
@@ -15,7 +15,6 @@ import (
 	cborutil "github.com/filecoin-project/go-cbor-util"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	deals "github.com/filecoin-project/go-fil-markets/storagemarket/impl"
-	paramfetch "github.com/filecoin-project/go-paramfetch"
 	"github.com/filecoin-project/go-sectorbuilder"
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/namespace"
@@ -32,6 +31,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/types"
 	lcli "github.com/filecoin-project/lotus/cli"
 	"github.com/filecoin-project/lotus/genesis"
+	"github.com/filecoin-project/lotus/lib/proofparams"
 	"github.com/filecoin-project/lotus/markets/utils"
 	"github.com/filecoin-project/lotus/miner"
 	"github.com/filecoin-project/lotus/node/config"
@@ -90,6 +90,14 @@ var initCmd = &cli.Command{
 			Name:  "symlink-imported-sectors",
 			Usage: "attempt to symlink to presealed sectors instead of copying them into place",
 		},
+		&cli.StringFlag{
+			Name:  "restore",
+			Usage: "restore the miner from a backup created with 'lotus-storage-miner backup'",
+		},
+		&cli.StringFlag{
+			Name:  "restore-storage",
+			Usage: "with --restore, the path holding the existing sealed sectors",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		log.Info("Initializing lotus storage miner")
@@ -102,7 +110,7 @@ var initCmd = &cli.Command{
 		}
 
 		log.Info("Checking proof parameters")
-		if err := paramfetch.GetParams(build.ParametersJson(), ssize); err != nil {
+		if err := proofparams.Fetch(lcli.ReqContext(cctx), build.ParametersJson(), ssize, proofparams.DefaultParallel); err != nil {
 			return xerrors.Errorf("fetching proof parameters: %w", err)
 		}
 
@@ -156,6 +164,24 @@ var initCmd = &cli.Command{
 			return err
 		}
 
+		if cctx.String("restore") != "" {
+			if err := restoreMiner(ctx, cctx, api, r); err != nil {
+				log.Errorf("Failed to restore lotus-storage-miner: %+v", err)
+				path, err := homedir.Expand(repoPath)
+				if err != nil {
+					return err
+				}
+				log.Infof("Cleaning up %s after attempt...", path)
+				if err := os.RemoveAll(path); err != nil {
+					log.Errorf("Failed to clean up failed storage repo: %s", err)
+				}
+				return xerrors.Errorf("Storage-miner restore failed")
+			}
+
+			log.Info("Storage miner successfully restored, you can now start it with 'lotus-storage-miner run'")
+			return nil
+		}
+
 		if pssb := cctx.String("pre-sealed-sectors"); pssb != "" {
 			pssb, err := homedir.Expand(pssb)
 			if err != nil {
@@ -397,7 +423,7 @@ func storageMinerInit(ctx context.Context, cctx *cli.Context, api lapi.FullNode,
 			if err != nil {
 				return xerrors.Errorf("failed to set up sectorbuilder for genesis mining: %w", err)
 			}
-			epp := storage.NewElectionPoStProver(sb)
+			epp := storage.NewElectionPoStProver(sb, storage.DefaultProofTimeouts())
 
 			m := miner.NewMiner(api, epp)
 			{
@@ -515,7 +541,7 @@ func configureStorageMiner(ctx context.Context, api lapi.FullNode, addr address.
 		GasLimit: types.NewInt(100000000),
 	}
 
-	smsg, err := api.MpoolPushMessage(ctx, msg)
+	smsg, err := api.MpoolPushMessage(ctx, msg, nil)
 	if err != nil {
 		return err
 	}
@@ -586,7 +612,7 @@ func createStorageMiner(ctx context.Context, api lapi.FullNode, peerid peer.ID,
 		GasPrice: types.NewInt(0),
 	}
 
-	signed, err := api.MpoolPushMessage(ctx, createStorageMinerMsg)
+	signed, err := api.MpoolPushMessage(ctx, createStorageMinerMsg, nil)
 	if err != nil {
 		return address.Undef, err
 	}
@@ -25,8 +25,12 @@ func main() {
 		runCmd,
 		initCmd,
 		infoCmd,
+		provingCmd,
 		pledgeSectorCmd,
 		sectorsCmd,
+		messagesCmd,
+		miningCmd,
+		backupCmd,
 	}
 	jaeger := tracing.SetupJaegerTracing("lotus")
 	defer func() {
@@ -60,11 +64,17 @@ func main() {
 				Hidden:  true,
 				Value:   "~/.lotus", // TODO: Consider XDG_DATA_HOME
 			},
+			lcli.OutputFlag,
 			&cli.StringFlag{
 				Name:    FlagStorageRepo,
 				EnvVars: []string{"LOTUS_STORAGE_PATH"},
 				Value:   "~/.lotusstorage", // TODO: Consider XDG_DATA_HOME
 			},
+			&cli.StringFlag{
+				Name:    "miner-actor",
+				EnvVars: []string{"LOTUS_MINER_ACTOR"},
+				Usage:   "manage one of the additional miner actors run by the storage miner",
+			},
 		},
 
 		Commands: append(local, lcli.Commands...),
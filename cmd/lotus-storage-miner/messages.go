@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+	"gopkg.in/urfave/cli.v2"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var messagesCmd = &cli.Command{
+	Name:  "messages",
+	Usage: "Manage messages pushed by the miner",
+	Subcommands: []*cli.Command{
+		messagesListCmd,
+		messagesRebroadcastCmd,
+		messagesBumpFeeCmd,
+	},
+}
+
+var messagesListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List messages pushed by the miner",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "also list messages which landed on chain or were replaced",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		msgs, err := nodeApi.MessagesList(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 8, 4, 1, ' ', 0)
+		fmt.Fprintf(w, "CID\tKind\tNonce\tGasPrice\tPushed\tStatus\n")
+		for _, mm := range msgs {
+			if !cctx.Bool("all") && mm.Status != api.MessagePending && mm.Status != api.MessageFailed {
+				continue
+			}
+
+			status := string(mm.Status)
+			switch mm.Status {
+			case api.MessageLanded:
+				status = fmt.Sprintf("%s at %d", mm.Status, mm.Height)
+			case api.MessageFailed:
+				status = fmt.Sprintf("%s at %d (exit code %d)", mm.Status, mm.Height, mm.ExitCode)
+			case api.MessageReplaced:
+				status = fmt.Sprintf("%s by %s", mm.Status, mm.ReplacedBy)
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n",
+				mm.Cid, mm.Kind, mm.Message.Message.Nonce, mm.Message.Message.GasPrice,
+				mm.Pushed.Format(time.Stamp), status)
+		}
+		return w.Flush()
+	},
+}
+
+var messagesRebroadcastCmd = &cli.Command{
+	Name:      "rebroadcast",
+	Usage:     "Push a pending message to the network again",
+	ArgsUsage: "[message cid]",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected 1 argument")
+		}
+
+		c, err := cid.Parse(cctx.Args().First())
+		if err != nil {
+			return xerrors.Errorf("parsing message cid: %w", err)
+		}
+
+		return nodeApi.MessagesRebroadcast(ctx, c)
+	},
+}
+
+var messagesBumpFeeCmd = &cli.Command{
+	Name:      "bump-fee",
+	Usage:     "Replace a pending message with the same message paying a higher gas price",
+	ArgsUsage: "[message cid] [gas price]",
+	Description: `The gas price has to be at least 25% above the gas price of the pending
+   message. Sectors waiting for a replaced precommit or commit message need to
+   be restarted.`,
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if cctx.Args().Len() != 2 {
+			return xerrors.Errorf("expected 2 arguments")
+		}
+
+		c, err := cid.Parse(cctx.Args().Get(0))
+		if err != nil {
+			return xerrors.Errorf("parsing message cid: %w", err)
+		}
+
+		gasPrice, err := types.BigFromString(cctx.Args().Get(1))
+		if err != nil {
+			return xerrors.Errorf("parsing gas price: %w", err)
+		}
+
+		nc, err := nodeApi.MessagesBumpFee(ctx, c, gasPrice)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Replaced by %s\n", nc)
+		return nil
+	},
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/urfave/cli.v2"
+
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var miningCmd = &cli.Command{
+	Name:  "mining",
+	Usage: "Inspect block production",
+	Subcommands: []*cli.Command{
+		miningStatusCmd,
+	},
+}
+
+var miningStatusCmd = &cli.Command{
+	Name:  "status",
+	Usage: "Show timings of recent block production attempts",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "won",
+			Usage: "only show attempts which produced a block",
+		},
+		&cli.IntFlag{
+			Name:  "count",
+			Usage: "number of attempts to show",
+			Value: 20,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		st, err := nodeApi.MiningStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Mining with: %v\n", st.Addresses)
+
+		attempts := st.Attempts
+		if cctx.Bool("won") {
+			attempts = attempts[:0:0]
+			for _, a := range st.Attempts {
+				if a.Won {
+					attempts = append(attempts, a)
+				}
+			}
+		}
+		if n := cctx.Int("count"); n > 0 && len(attempts) > n {
+			attempts = attempts[len(attempts)-n:]
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Height\tMiner\tWon\tTotal\tLate\tStages\tError\n")
+		for _, a := range attempts {
+			stages := make([]string, len(a.Stages))
+			for i, s := range a.Stages {
+				stages[i] = fmt.Sprintf("%s:%s", s.Name, s.Duration.Round(time.Millisecond))
+			}
+
+			late := ""
+			if a.Late > 0 {
+				late = a.Late.Round(time.Millisecond).String()
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%t\t%s\t%s\t%s\t%s\n", a.Height, a.Miner, a.Won, a.Total.Round(time.Millisecond), late, strings.Join(stages, " "), a.Error)
+		}
+		return w.Flush()
+	},
+}
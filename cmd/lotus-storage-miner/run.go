@@ -6,8 +6,10 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"reflect"
 	"syscall"
 
+	"github.com/filecoin-project/go-address"
 	mux "github.com/gorilla/mux"
 	"github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr-net"
@@ -16,6 +18,7 @@ import (
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/api/apistruct"
+	"github.com/filecoin-project/lotus/api/docgen"
 	"github.com/filecoin-project/lotus/build"
 	lcli "github.com/filecoin-project/lotus/cli"
 	"github.com/filecoin-project/lotus/lib/auth"
@@ -87,8 +90,12 @@ var runCmd = &cli.Command{
 		}
 
 		var minerapi api.StorageMiner
+		var limiter *apistruct.Limiter
+		var actorAPIs map[address.Address]api.StorageMiner
 		stop, err := node.New(ctx,
 			node.StorageMiner(&minerapi),
+			node.APILimiter(&limiter),
+			node.StorageMinerActors(&actorAPIs),
 			node.Online(),
 			node.Repo(r),
 
@@ -132,7 +139,17 @@ var runCmd = &cli.Command{
 		mux := mux.NewRouter()
 
 		rpcServer := jsonrpc.NewServer()
-		rpcServer.Register("Filecoin", apistruct.PermissionedStorMinerAPI(minerapi))
+		rpcServer.Register("Filecoin", apistruct.PermissionedStorMinerAPI(apistruct.LimitedStorMinerAPI(minerapi, limiter)))
+		rpcServer.Register("Filecoin", &docgen.Discoverer{
+			Doc: docgen.NewDocument("Lotus Storage Miner API", build.UserVersion, reflect.TypeOf(new(api.StorageMiner)).Elem(), apistruct.StorageMinerStruct{}),
+		})
+		rpcServer.AliasMethod("rpc.discover", "Filecoin.Discover")
+
+		for maddr, aapi := range actorAPIs {
+			actorServer := jsonrpc.NewServer()
+			actorServer.Register("Filecoin", apistruct.PermissionedStorMinerAPI(apistruct.LimitedStorMinerAPI(aapi, limiter)))
+			mux.Handle("/rpc/v0/actor/"+maddr.String(), actorServer)
+		}
 
 		mux.Handle("/rpc/v0", rpcServer)
 		mux.PathPrefix("/remote").HandlerFunc(minerapi.(*impl.StorageMinerAPI).ServeRemote)
@@ -38,6 +38,10 @@ var sectorsCmd = &cli.Command{
 		sectorsListCmd,
 		sectorsRefsCmd,
 		sectorsUpdateCmd,
+		sectorsStartSealCmd,
+		sectorsRetryCmd,
+		sectorsAbortCmd,
+		sectorsImportCmd,
 	},
 }
 
@@ -72,6 +76,13 @@ var sectorsStatusCmd = &cli.Command{
 			return err
 		}
 
+		if lcli.OutputJSON(cctx) {
+			if !cctx.Bool("log") {
+				status.Log = nil
+			}
+			return lcli.PrintJSON(status)
+		}
+
 		fmt.Printf("SectorID:\t%d\n", status.SectorID)
 		fmt.Printf("Status:\t%s\n", api.SectorStates[status.State])
 		fmt.Printf("CommD:\t\t%x\n", status.CommD)
@@ -83,6 +94,15 @@ var sectorsStatusCmd = &cli.Command{
 		fmt.Printf("Proof:\t\t%x\n", status.Proof)
 		fmt.Printf("Deals:\t\t%v\n", status.Deals)
 		fmt.Printf("Retries:\t\t%d\n", status.Retries)
+		if status.PreCommitMsg != nil {
+			fmt.Printf("PreCommitMsg:\t%s\n", status.PreCommitMsg)
+		}
+		if status.CommitMsg != nil {
+			fmt.Printf("CommitMsg:\t%s\n", status.CommitMsg)
+		}
+		if status.FaultReportMsg != nil {
+			fmt.Printf("FaultReportMsg:\t%s\n", status.FaultReportMsg)
+		}
 		if status.LastErr != "" {
 			fmt.Printf("Last Error:\t\t%s\n", status.LastErr)
 		}
@@ -151,6 +171,36 @@ var sectorsListCmd = &cli.Command{
 			return list[i] < list[j]
 		})
 
+		if lcli.OutputJSON(cctx) {
+			type sectorListEntry struct {
+				api.SectorInfo
+				StateName string
+				InSectors bool
+				InProving bool
+				StatusErr string `json:",omitempty"`
+			}
+
+			out := make([]sectorListEntry, 0, len(list))
+			for _, s := range list {
+				st, err := nodeApi.SectorsStatus(ctx, s)
+				if err != nil {
+					out = append(out, sectorListEntry{SectorInfo: api.SectorInfo{SectorID: s}, StatusErr: err.Error()})
+					continue
+				}
+
+				_, inSSet := commitedIDs[s]
+				_, inPSet := provingIDs[s]
+				out = append(out, sectorListEntry{
+					SectorInfo: st,
+					StateName:  api.SectorStates[st.State],
+					InSectors:  inSSet,
+					InProving:  inPSet,
+				})
+			}
+
+			return lcli.PrintJSON(out)
+		}
+
 		w := tabwriter.NewWriter(os.Stdout, 8, 4, 1, ' ', 0)
 
 		for _, s := range list {
@@ -232,17 +282,107 @@ var sectorsUpdateCmd = &cli.Command{
 			return xerrors.Errorf("could not parse sector ID: %w", err)
 		}
 
-		var st api.SectorState
+		st, found := api.SectorState(0), false
 		for i, s := range api.SectorStates {
 			if cctx.Args().Get(1) == s {
-				st = api.SectorState(i)
+				st, found = api.SectorState(i), true
 			}
 		}
+		if !found {
+			return xerrors.Errorf("unknown sector state: %s", cctx.Args().Get(1))
+		}
 
 		return nodeApi.SectorsUpdate(ctx, id, st)
 	},
 }
 
+var sectorsStartSealCmd = &cli.Command{
+	Name:      "seal",
+	Usage:     "Start sealing a sector waiting for deals, filling the rest of it with pledge pieces",
+	ArgsUsage: "[sector id]",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("must pass sector ID")
+		}
+
+		id, err := strconv.ParseUint(cctx.Args().First(), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("could not parse sector ID: %w", err)
+		}
+
+		return nodeApi.SectorStartSealing(ctx, id)
+	},
+}
+
+var sectorsRetryCmd = &cli.Command{
+	Name:      "seal-retry",
+	Usage:     "Retry a failed sector from the step which failed, or a sector waiting for a message",
+	ArgsUsage: "[sector id]",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("must pass sector ID")
+		}
+
+		id, err := strconv.ParseUint(cctx.Args().First(), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("could not parse sector ID: %w", err)
+		}
+
+		return nodeApi.SectorsRetry(ctx, id)
+	},
+}
+
+var sectorsAbortCmd = &cli.Command{
+	Name:      "abort",
+	Usage:     "Stop sealing a sector which isn't committed on chain yet",
+	ArgsUsage: "[sector id]",
+	Description: `Deals in the sector won't be sealed, and if the sector was precommitted
+   the precommit deposit is lost.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "really-do-it",
+			Usage: "pass this flag if you know what you are doing",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Bool("really-do-it") {
+			return xerrors.Errorf("aborting a sector can't be undone, pass --really-do-it to confirm")
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("must pass sector ID")
+		}
+
+		id, err := strconv.ParseUint(cctx.Args().First(), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("could not parse sector ID: %w", err)
+		}
+
+		return nodeApi.SectorsAbort(ctx, id)
+	},
+}
+
 func yesno(b bool) string {
 	if b {
 		return "YES"
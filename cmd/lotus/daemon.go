@@ -8,7 +8,6 @@ import (
 	"os"
 	"runtime/pprof"
 
-	paramfetch "github.com/filecoin-project/go-paramfetch"
 	"github.com/filecoin-project/go-sectorbuilder"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	"github.com/mitchellh/go-homedir"
@@ -17,10 +16,13 @@ import (
 	"gopkg.in/urfave/cli.v2"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apistruct"
 	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/beacon/drand"
 	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/vm"
+	"github.com/filecoin-project/lotus/lib/proofparams"
 	"github.com/filecoin-project/lotus/node"
 	"github.com/filecoin-project/lotus/node/modules"
 	"github.com/filecoin-project/lotus/node/modules/testing"
@@ -31,6 +33,7 @@ import (
 const (
 	makeGenFlag          = "lotus-make-random-genesis"
 	preSealedSectorsFlag = "genesis-presealed-sectors"
+	genesisTemplateFlag  = "genesis-template"
 )
 
 // DaemonCmd is the `go-lotus daemon` command
@@ -51,6 +54,11 @@ var DaemonCmd = &cli.Command{
 			Name:   preSealedSectorsFlag,
 			Hidden: true,
 		},
+		&cli.StringFlag{
+			Name:   genesisTemplateFlag,
+			Hidden: true,
+			Usage:  "create the genesis block from a template created with `lotus-seed genesis`",
+		},
 		&cli.StringFlag{
 			Name:  "genesis",
 			Usage: "genesis file to use for first node run",
@@ -68,6 +76,10 @@ var DaemonCmd = &cli.Command{
 			Name:  "import-chain",
 			Usage: "on first run, load chain from given file",
 		},
+		&cli.StringFlag{
+			Name:  "import-snapshot",
+			Usage: "on first run, import a trusted chain snapshot (created with `lotus chain export --recent-stateroots`) without validating it",
+		},
 		&cli.BoolFlag{
 			Name:  "halt-after-import",
 			Usage: "halt the process after importing chain from file",
@@ -109,7 +121,7 @@ var DaemonCmd = &cli.Command{
 			return xerrors.Errorf("repo init error: %w", err)
 		}
 
-		if err := paramfetch.GetParams(build.ParametersJson(), 0); err != nil {
+		if err := proofparams.Fetch(ctx, build.ParametersJson(), 0, proofparams.DefaultParallel); err != nil {
 			return xerrors.Errorf("fetching proof parameters: %w", err)
 		}
 
@@ -123,8 +135,17 @@ var DaemonCmd = &cli.Command{
 		}
 
 		chainfile := cctx.String("import-chain")
-		if chainfile != "" {
-			if err := ImportChain(r, chainfile); err != nil {
+		snapshot := cctx.String("import-snapshot")
+		if chainfile != "" && snapshot != "" {
+			return xerrors.Errorf("cannot specify both 'import-chain' and 'import-snapshot'")
+		}
+		if chainfile != "" || snapshot != "" {
+			isSnapshot := snapshot != ""
+			if isSnapshot {
+				chainfile = snapshot
+			}
+
+			if err := ImportChain(r, chainfile, isSnapshot); err != nil {
 				return err
 			}
 			if cctx.Bool("halt-after-import") {
@@ -137,16 +158,22 @@ var DaemonCmd = &cli.Command{
 			genesis = node.Override(new(modules.Genesis), modules.LoadGenesis(genBytes))
 		}
 		if cctx.String(makeGenFlag) != "" {
-			if cctx.String(preSealedSectorsFlag) == "" {
-				return xerrors.Errorf("must also pass file with miner preseal info to `--%s`", preSealedSectorsFlag)
+			switch {
+			case cctx.String(genesisTemplateFlag) != "":
+				genesis = node.Override(new(modules.Genesis), testing.MakeGenesisTemplate(cctx.String(makeGenFlag), cctx.String(genesisTemplateFlag)))
+			case cctx.String(preSealedSectorsFlag) != "":
+				genesis = node.Override(new(modules.Genesis), testing.MakeGenesis(cctx.String(makeGenFlag), cctx.String(preSealedSectorsFlag), cctx.String("genesis-timestamp")))
+			default:
+				return xerrors.Errorf("must also pass a genesis template to `--%s`, or file with miner preseal info to `--%s`", genesisTemplateFlag, preSealedSectorsFlag)
 			}
-			genesis = node.Override(new(modules.Genesis), testing.MakeGenesis(cctx.String(makeGenFlag), cctx.String(preSealedSectorsFlag), cctx.String("genesis-timestamp")))
 		}
 
 		var api api.FullNode
+		var limiter *apistruct.Limiter
 
 		stop, err := node.New(ctx,
 			node.FullAPI(&api),
+			node.APILimiter(&limiter),
 
 			node.Online(),
 			node.Repo(r),
@@ -177,11 +204,14 @@ var DaemonCmd = &cli.Command{
 		}
 
 		// TODO: properly parse api endpoint (or make it a URL)
-		return serveRPC(api, stop, endpoint)
+		return serveRPC(api, limiter, stop, endpoint)
 	},
 }
 
-func ImportChain(r repo.Repo, fname string) error {
+// ImportChain loads a chain CAR file into the repo and sets its head. Full
+// chain exports are validated from genesis, snapshots are trusted as they
+// only carry recent state trees.
+func ImportChain(r repo.Repo, fname string, snapshot bool) error {
 	fi, err := os.Open(fname)
 	if err != nil {
 		return err
@@ -205,7 +235,12 @@ func ImportChain(r repo.Repo, fname string) error {
 
 	bs := blockstore.NewBlockstore(ds)
 
-	cst := store.NewChainStore(bs, mds, vm.Syscalls(sectorbuilder.ProofVerifier))
+	rb, err := drand.OfflineBeacon(mds)
+	if err != nil {
+		return xerrors.Errorf("creating randomness beacon: %w", err)
+	}
+
+	cst := store.NewChainStore(bs, mds, vm.Syscalls(sectorbuilder.ProofVerifier), rb)
 
 	log.Info("importing chain from file...")
 	ts, err := cst.Import(fi)
@@ -213,14 +248,18 @@ func ImportChain(r repo.Repo, fname string) error {
 		return xerrors.Errorf("importing chain failed: %w", err)
 	}
 
-	stm := stmgr.NewStateManager(cst)
+	if snapshot {
+		log.Warnf("skipping validation of imported snapshot, trusting head %s at height %d", ts.Cids(), ts.Height())
+	} else {
+		stm := stmgr.NewStateManager(cst)
 
-	log.Infof("validating imported chain...")
-	if err := stm.ValidateChain(context.TODO(), ts); err != nil {
-		return xerrors.Errorf("chain validation failed: %w", err)
+		log.Infof("validating imported chain...")
+		if err := stm.ValidateChain(context.TODO(), ts); err != nil {
+			return xerrors.Errorf("chain validation failed: %w", err)
+		}
 	}
 
-	log.Info("accepting %s as new head", ts.Cids())
+	log.Infof("accepting %s as new head", ts.Cids())
 	if err := cst.SetHead(ts); err != nil {
 		return err
 	}
@@ -60,6 +60,7 @@ func main() {
 				Hidden:  true,
 				Value:   "~/.lotus", // TODO: Consider XDG_DATA_HOME
 			},
+			lcli.OutputFlag,
 		},
 
 		Commands: append(local, lcli.Commands...),
@@ -8,9 +8,12 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"reflect"
 	"syscall"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/docgen"
+	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/lib/auth"
 	"github.com/filecoin-project/lotus/lib/jsonrpc"
 	"github.com/filecoin-project/lotus/node"
@@ -25,9 +28,13 @@ import (
 
 var log = logging.Logger("main")
 
-func serveRPC(a api.FullNode, stop node.StopFunc, addr multiaddr.Multiaddr) error {
+func serveRPC(a api.FullNode, limiter *apistruct.Limiter, stop node.StopFunc, addr multiaddr.Multiaddr) error {
 	rpcServer := jsonrpc.NewServer()
-	rpcServer.Register("Filecoin", apistruct.PermissionedFullAPI(a))
+	rpcServer.Register("Filecoin", apistruct.PermissionedFullAPI(apistruct.LimitedFullAPI(a, limiter)))
+	rpcServer.Register("Filecoin", &docgen.Discoverer{
+		Doc: docgen.NewDocument("Lotus Full Node API", build.UserVersion, reflect.TypeOf(new(api.FullNode)).Elem(), apistruct.FullNodeStruct{}),
+	})
+	rpcServer.AliasMethod("rpc.discover", "Filecoin.Discover")
 
 	ah := &auth.Handler{
 		Verify: a.AuthVerify,
@@ -23,3 +23,30 @@ type GenesisMiner struct {
 
 	Key types.KeyInfo // TODO: separate file
 }
+
+// Template describes the initial state of a network. Templates are created
+// and edited with the lotus-seed genesis commands.
+type Template struct {
+	// NetworkName is mixed into the genesis ticket, so networks created from
+	// otherwise identical templates get different genesis blocks
+	NetworkName string
+
+	// Timestamp of the genesis block, 0 uses the time the block is created
+	Timestamp uint64
+
+	// BlockDelay is the block time the network is run with. It's compiled into
+	// lotus, so it's only checked against the build when creating the genesis
+	// block. 0 skips the check.
+	BlockDelay uint64
+
+	Accounts []Account
+
+	// Miners maps miner addresses to their preseal info, in the same format
+	// as the lotus-seed pre-seal manifests
+	Miners map[string]GenesisMiner
+}
+
+type Account struct {
+	Address address.Address
+	Balance types.BigInt
+}
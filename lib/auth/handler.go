@@ -45,6 +45,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 		ctx = apistruct.WithPerm(ctx, allow)
+		ctx = apistruct.WithToken(ctx, token)
 	}
 
 	h.Next(w, r.WithContext(ctx))
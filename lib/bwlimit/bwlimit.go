@@ -0,0 +1,214 @@
+package bwlimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"golang.org/x/time/rate"
+)
+
+var log = logging.Logger("bwlimit")
+
+// chunkSize is the most data read or written at once on a limited stream,
+// and the burst allowed by the limiters
+const chunkSize = 256 << 10
+
+// slotWait is how long an incoming stream waits for a slot before it is reset.
+// The remote peer sees the reset and can open the stream again later.
+const slotWait = time.Minute
+
+// Limiter caps the rate of data sent and received on the streams of the hosts
+// it wraps. All hosts wrapped by one limiter share its limits.
+type Limiter struct {
+	in  *rate.Limiter
+	out *rate.Limiter
+}
+
+func newRate(bps uint64) *rate.Limiter {
+	if bps == 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(bps), chunkSize)
+}
+
+// NewLimiter creates a limiter for the given ingress and egress rates in
+// bytes per second, 0 disables the limit
+func NewLimiter(ingress, egress uint64) *Limiter {
+	return &Limiter{
+		in:  newRate(ingress),
+		out: newRate(egress),
+	}
+}
+
+// Host wraps h, limiting the bandwidth of the streams opened and handled
+// through it. At most maxStreams incoming streams are handled at once, others
+// wait until a stream is closed or its handler returns, and are reset if no
+// slot frees up in time. 0 allows any number of streams.
+//
+// Protocols which keep one long-lived stream per peer, like graphsync, hold a
+// slot for as long as the stream stays open, so for them maxStreams limits
+// the number of peers served at once rather than the number of transfers.
+//
+// Streams opened with NewStream are rate limited but don't take a slot. A
+// graphsync request sent on an outgoing stream is answered on a stream opened
+// by the remote peer, counting both could leave the response waiting for the
+// slot held by its own request.
+func (l *Limiter) Host(h host.Host, maxStreams int) host.Host {
+	lh := &limitedHost{
+		Host: h,
+		l:    l,
+		wait: slotWait,
+	}
+	if maxStreams > 0 {
+		lh.slots = make(chan struct{}, maxStreams)
+	}
+	return lh
+}
+
+type limitedHost struct {
+	host.Host
+
+	l     *Limiter
+	slots chan struct{}
+	wait  time.Duration
+}
+
+func (h *limitedHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (network.Stream, error) {
+	s, err := h.Host.NewStream(ctx, p, pids...)
+	if err != nil {
+		return nil, err
+	}
+	return newStream(s, h.l, func() {}), nil
+}
+
+func (h *limitedHost) SetStreamHandler(pid protocol.ID, handler network.StreamHandler) {
+	h.Host.SetStreamHandler(pid, h.handler(handler))
+}
+
+func (h *limitedHost) SetStreamHandlerMatch(pid protocol.ID, m func(string) bool, handler network.StreamHandler) {
+	h.Host.SetStreamHandlerMatch(pid, m, h.handler(handler))
+}
+
+func (h *limitedHost) handler(handler network.StreamHandler) network.StreamHandler {
+	return func(s network.Stream) {
+		release := func() {}
+		if h.slots != nil {
+			select {
+			case h.slots <- struct{}{}:
+			default:
+				log.Infow("waiting for a transfer slot", "protocol", s.Protocol(), "peer", s.Conn().RemotePeer())
+
+				timeout := time.NewTimer(h.wait)
+				select {
+				case h.slots <- struct{}{}:
+					timeout.Stop()
+				case <-timeout.C:
+					log.Warnw("no transfer slot freed up, resetting stream", "protocol", s.Protocol(), "peer", s.Conn().RemotePeer())
+					_ = s.Reset()
+					return
+				}
+			}
+
+			var once sync.Once
+			release = func() {
+				once.Do(func() {
+					<-h.slots
+				})
+			}
+		}
+		defer release()
+
+		handler(newStream(s, h.l, release))
+	}
+}
+
+type stream struct {
+	network.Stream
+
+	l       *Limiter
+	release func()
+
+	// ctx is cancelled when the stream is reset, wctx when it's closed for
+	// writing, so neither reads nor writes wait for the limiter after that
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wctx    context.Context
+	wcancel context.CancelFunc
+}
+
+func newStream(s network.Stream, l *Limiter, release func()) *stream {
+	ctx, cancel := context.WithCancel(context.Background())
+	wctx, wcancel := context.WithCancel(ctx)
+	return &stream{
+		Stream:  s,
+		l:       l,
+		release: release,
+		ctx:     ctx,
+		cancel:  cancel,
+		wctx:    wctx,
+		wcancel: wcancel,
+	}
+}
+
+func (s *stream) Read(p []byte) (int, error) {
+	if s.l.in == nil {
+		return s.Stream.Read(p)
+	}
+
+	if len(p) > chunkSize {
+		p = p[:chunkSize]
+	}
+
+	n, err := s.Stream.Read(p)
+	if n > 0 {
+		// n never exceeds the burst, this only fails once the stream is reset
+		if werr := s.l.in.WaitN(s.ctx, n); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return n, err
+}
+
+func (s *stream) Write(p []byte) (int, error) {
+	if s.l.out == nil {
+		return s.Stream.Write(p)
+	}
+
+	var written int
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+
+		if err := s.l.out.WaitN(s.wctx, len(chunk)); err != nil {
+			return written, err
+		}
+
+		n, err := s.Stream.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (s *stream) Close() error {
+	s.wcancel()
+	s.release()
+	return s.Stream.Close()
+}
+
+func (s *stream) Reset() error {
+	s.cancel()
+	s.release()
+	return s.Stream.Reset()
+}
@@ -0,0 +1,226 @@
+package bwlimit
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+const testProtocol = "/bwlimit/test"
+
+func limitedPair(t *testing.T, ctx context.Context, maxStreams int, wait time.Duration) (host.Host, *limitedHost) {
+	mn := mocknet.New(ctx)
+	a, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mn.ConnectAllButSelf(); err != nil {
+		t.Fatal(err)
+	}
+
+	lh := NewLimiter(0, 0).Host(b, maxStreams).(*limitedHost)
+	lh.wait = wait
+	return a, lh
+}
+
+// open opens a stream to the limited host, writing a byte so the handler runs
+func open(t *testing.T, ctx context.Context, from host.Host, to host.Host) network.Stream {
+	s, err := from.NewStream(ctx, to.ID(), testProtocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write([]byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStreamSlots(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, lh := limitedPair(t, ctx, 1, 5*time.Second)
+
+	handled := make(chan network.Stream, 2)
+	lh.SetStreamHandler(testProtocol, func(s network.Stream) {
+		handled <- s
+		_, _ = ioutil.ReadAll(s)
+	})
+
+	first := open(t, ctx, a, lh)
+	var held network.Stream
+	select {
+	case held = <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("first stream wasn't handled")
+	}
+
+	open(t, ctx, a, lh)
+	select {
+	case <-handled:
+		t.Fatal("second stream handled while the slot was taken")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// closing the first stream frees its slot
+	_ = held.Close()
+	_ = first.Close()
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting stream wasn't handled after the slot was freed")
+	}
+}
+
+func TestStreamSlotTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, lh := limitedPair(t, ctx, 1, 50*time.Millisecond)
+
+	handled := make(chan struct{}, 2)
+	lh.SetStreamHandler(testProtocol, func(s network.Stream) {
+		handled <- struct{}{}
+		<-ctx.Done()
+	})
+
+	open(t, ctx, a, lh)
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("first stream wasn't handled")
+	}
+
+	waiting := open(t, ctx, a, lh)
+
+	readErr := make(chan error, 1)
+	go func() {
+		_, err := waiting.Read(make([]byte, 1))
+		readErr <- err
+	}()
+
+	select {
+	case err := <-readErr:
+		if err == nil {
+			t.Fatal("expected the waiting stream to be reset")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting stream wasn't reset")
+	}
+
+	select {
+	case <-handled:
+		t.Fatal("reset stream was handled")
+	default:
+	}
+}
+
+type bufStream struct {
+	network.Stream
+	buf bytes.Buffer
+}
+
+func (s *bufStream) Write(p []byte) (int, error) {
+	return s.buf.Write(p)
+}
+
+func (s *bufStream) Read(p []byte) (int, error) {
+	return s.buf.Read(p)
+}
+
+func (s *bufStream) Reset() error {
+	return nil
+}
+
+func TestRateLimit(t *testing.T) {
+	l := NewLimiter(4*chunkSize, 4*chunkSize)
+	bs := &bufStream{}
+	s := newStream(bs, l, func() {})
+
+	// the first chunk is the burst, the other two wait a quarter second each
+	data := make([]byte, 3*chunkSize)
+
+	start := time.Now()
+	n, err := s.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(data) || bs.buf.Len() != len(data) {
+		t.Fatalf("expected %d bytes written, got %d", len(data), n)
+	}
+	if took := time.Since(start); took < 400*time.Millisecond {
+		t.Fatalf("egress wasn't limited, writing took %s", took)
+	}
+
+	start = time.Now()
+	read, err := ioutil.ReadAll(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != len(data) {
+		t.Fatalf("expected %d bytes read, got %d", len(data), len(read))
+	}
+	if took := time.Since(start); took < 400*time.Millisecond {
+		t.Fatalf("ingress wasn't limited, reading took %s", took)
+	}
+}
+
+func TestNoLimit(t *testing.T) {
+	bs := &bufStream{}
+	s := newStream(bs, NewLimiter(0, 0), func() {})
+
+	data := make([]byte, 64*chunkSize)
+	start := time.Now()
+	if _, err := s.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(s); err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Fatalf("unlimited transfer took %s", took)
+	}
+}
+
+func TestResetStopsWaiting(t *testing.T) {
+	bs := &bufStream{}
+	s := newStream(bs, NewLimiter(1, 1), func() {})
+
+	// the first chunk is the burst, the byte after it waits a second
+	written := make(chan error, 1)
+	go func() {
+		_, err := s.Write(make([]byte, chunkSize+1))
+		written <- err
+	}()
+
+	select {
+	case <-written:
+		t.Fatal("write wasn't limited")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := s.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-written:
+		if err == nil {
+			t.Fatal("expected the write to fail after the stream was reset")
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("write kept waiting after the stream was reset")
+	}
+}
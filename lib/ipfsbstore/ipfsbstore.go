@@ -0,0 +1,313 @@
+package ipfsbstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+	mh "github.com/multiformats/go-multihash"
+	"golang.org/x/xerrors"
+)
+
+var log = logging.Logger("ipfsbstore")
+
+// requestTimeout limits API calls for single blocks, so a stalled IPFS node
+// doesn't hang imports and retrievals
+const requestTimeout = time.Minute
+
+// IpfsBstore is a blockstore backed by the blockstore of an IPFS node, accessed
+// through its HTTP API. Reads are done in offline mode, so blocks missing
+// from the node aren't fetched from the IPFS network.
+type IpfsBstore struct {
+	ctx     context.Context
+	api     string
+	client  *http.Client
+	timeout time.Duration
+}
+
+// NewIpfsBstore creates a blockstore using the IPFS node with the API listening
+// on the given multiaddr, e.g. /ip4/127.0.0.1/tcp/5001. Requests are cancelled
+// with ctx.
+func NewIpfsBstore(ctx context.Context, apiAddr string) (*IpfsBstore, error) {
+	maddr, err := ma.NewMultiaddr(apiAddr)
+	if err != nil {
+		return nil, xerrors.Errorf("parsing IPFS API address: %w", err)
+	}
+	_, addr, err := manet.DialArgs(maddr)
+	if err != nil {
+		return nil, xerrors.Errorf("IPFS API address: %w", err)
+	}
+
+	return newIpfsBstore(ctx, "http://"+addr), nil
+}
+
+func newIpfsBstore(ctx context.Context, url string) *IpfsBstore {
+	return &IpfsBstore{
+		ctx: ctx,
+		api: url + "/api/v0/",
+		client: &http.Client{
+			// single block calls are limited by timeout, listing refs streams
+			// the response for as long as it takes
+			Transport: &http.Transport{
+				DialContext:           (&net.Dialer{Timeout: 10 * time.Second}).DialContext,
+				ResponseHeaderTimeout: requestTimeout,
+			},
+		},
+		timeout: requestTimeout,
+	}
+}
+
+type apiError struct {
+	Message string
+}
+
+// call makes an API request, the caller has to close the returned body
+func (i *IpfsBstore) call(ctx context.Context, cmd string, args url.Values, body io.Reader, contentType string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("POST", i.api+cmd+"?"+args.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := i.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, xerrors.Errorf("calling IPFS API: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close() // nolint:errcheck
+
+		var ae apiError
+		if err := json.NewDecoder(resp.Body).Decode(&ae); err != nil || ae.Message == "" {
+			return nil, xerrors.Errorf("IPFS API %s: %s", cmd, resp.Status)
+		}
+		if notFound(ae.Message) {
+			return nil, blockstore.ErrNotFound
+		}
+		return nil, xerrors.Errorf("IPFS API %s: %s", cmd, ae.Message)
+	}
+
+	return resp.Body, nil
+}
+
+// blockCall makes an API request about a single block and reads the response
+func (i *IpfsBstore) blockCall(cmd string, args url.Values, body io.Reader, contentType string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(i.ctx, i.timeout)
+	defer cancel()
+
+	resp, err := i.call(ctx, cmd, args, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close() // nolint:errcheck
+
+	data, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return nil, xerrors.Errorf("reading IPFS API %s response: %w", cmd, err)
+	}
+	return data, nil
+}
+
+// notFound returns whether an API error is the error of the IPFS node for
+// blocks it doesn't have
+func notFound(msg string) bool {
+	for _, err := range []error{blockstore.ErrNotFound, blockservice.ErrNotFound} {
+		if msg == err.Error() || strings.HasSuffix(msg, ": "+err.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+func blockArgs(c cid.Cid) url.Values {
+	return url.Values{
+		"arg":     []string{c.String()},
+		"offline": []string{"true"},
+	}
+}
+
+type blockStat struct {
+	Key  string
+	Size int
+}
+
+func (i *IpfsBstore) stat(c cid.Cid) (*blockStat, error) {
+	data, err := i.blockCall("block/stat", blockArgs(c), nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var st blockStat
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, xerrors.Errorf("decoding block stat: %w", err)
+	}
+	return &st, nil
+}
+
+func (i *IpfsBstore) DeleteBlock(c cid.Cid) error {
+	_, err := i.blockCall("block/rm", url.Values{"arg": []string{c.String()}}, nil, "")
+	if err == blockstore.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (i *IpfsBstore) Has(c cid.Cid) (bool, error) {
+	_, err := i.stat(c)
+	switch err {
+	case nil:
+		return true, nil
+	case blockstore.ErrNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (i *IpfsBstore) Get(c cid.Cid) (blocks.Block, error) {
+	data, err := i.blockCall("block/get", blockArgs(c), nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return blocks.NewBlockWithCid(data, c)
+}
+
+func (i *IpfsBstore) GetSize(c cid.Cid) (int, error) {
+	st, err := i.stat(c)
+	if err != nil {
+		return 0, err
+	}
+	return st.Size, nil
+}
+
+func (i *IpfsBstore) Put(block blocks.Block) error {
+	c := block.Cid()
+	pref := c.Prefix()
+
+	format, ok := cid.CodecToStr[pref.Codec]
+	if !ok {
+		return xerrors.Errorf("unsupported codec %d", pref.Codec)
+	}
+	if pref.Version == 0 {
+		format = "v0"
+	}
+	mhtype, ok := mh.Codes[pref.MhType]
+	if !ok {
+		return xerrors.Errorf("unsupported multihash %d", pref.MhType)
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fw, err := w.CreateFormFile("data", "data")
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(block.RawData()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	args := url.Values{
+		"format": []string{format},
+		"mhtype": []string{mhtype},
+		"mhlen":  []string{"-1"},
+	}
+	data, err := i.blockCall("block/put", args, &buf, w.FormDataContentType())
+	if err != nil {
+		return err
+	}
+
+	var st blockStat
+	if err := json.Unmarshal(data, &st); err != nil {
+		return xerrors.Errorf("decoding block put response: %w", err)
+	}
+	if st.Key != c.String() {
+		return xerrors.Errorf("IPFS stored block %s as %s", c, st.Key)
+	}
+	return nil
+}
+
+func (i *IpfsBstore) PutMany(blocks []blocks.Block) error {
+	for _, block := range blocks {
+		if err := i.Put(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type localRef struct {
+	Ref string
+	Err string
+}
+
+func (i *IpfsBstore) AllKeysChan(ctx context.Context) (<-chan cid.Cid, error) {
+	body, err := i.call(ctx, "refs/local", url.Values{}, nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan cid.Cid, 16)
+	go func() {
+		defer close(out)
+		defer body.Close() // nolint:errcheck
+
+		scanner := bufio.NewScanner(body)
+		for scanner.Scan() {
+			var ref localRef
+			if err := json.Unmarshal(scanner.Bytes(), &ref); err != nil {
+				log.Errorf("decoding local ref: %s", err)
+				return
+			}
+			if ref.Err != "" {
+				log.Errorf("listing local refs: %s", ref.Err)
+				continue
+			}
+
+			c, err := cid.Parse(ref.Ref)
+			if err != nil {
+				log.Errorf("parsing local ref %s: %s", ref.Ref, err)
+				continue
+			}
+
+			select {
+			case out <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			log.Errorf("reading local refs: %s", err)
+		}
+	}()
+
+	return out, nil
+}
+
+// HashOnRead is a no-op, the IPFS node verifies blocks itself when configured
+// to do so
+func (i *IpfsBstore) HashOnRead(enabled bool) {
+}
+
+var _ blockstore.Blockstore = &IpfsBstore{}
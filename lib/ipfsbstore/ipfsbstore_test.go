@@ -0,0 +1,165 @@
+package ipfsbstore
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-blockservice"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+// fakeIpfs serves the block API of an IPFS node from memory
+type fakeIpfs struct {
+	lk     sync.Mutex
+	blocks map[string][]byte
+
+	stall chan struct{}
+}
+
+func (f *fakeIpfs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.stall != nil {
+		select {
+		case <-f.stall:
+		case <-r.Context().Done():
+		}
+		return
+	}
+
+	f.lk.Lock()
+	defer f.lk.Unlock()
+
+	fail := func(msg string) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(apiError{Message: msg})
+	}
+
+	arg := r.URL.Query().Get("arg")
+	switch r.URL.Path {
+	case "/api/v0/block/put":
+		file, _, err := r.FormFile("data")
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+		data, err := ioutil.ReadAll(file)
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+		b := blocks.NewBlock(data)
+		f.blocks[b.Cid().String()] = data
+		_ = json.NewEncoder(w).Encode(blockStat{Key: b.Cid().String(), Size: len(data)})
+	case "/api/v0/block/get", "/api/v0/block/stat", "/api/v0/block/rm":
+		if arg == "plugin" {
+			fail("codec plugin not found")
+			return
+		}
+		data, ok := f.blocks[arg]
+		if !ok {
+			fail("failed to get block for " + arg + ": " + blockstore.ErrNotFound.Error())
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v0/block/get":
+			_, _ = w.Write(data)
+		case "/api/v0/block/stat":
+			_ = json.NewEncoder(w).Encode(blockStat{Key: arg, Size: len(data)})
+		case "/api/v0/block/rm":
+			delete(f.blocks, arg)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestIpfsBstore(t *testing.T) {
+	srv := httptest.NewServer(&fakeIpfs{blocks: map[string][]byte{}})
+	defer srv.Close()
+
+	ibs := newIpfsBstore(context.Background(), srv.URL)
+
+	b := blocks.NewBlock([]byte("some data"))
+	if err := ibs.Put(b); err != nil {
+		t.Fatal(err)
+	}
+
+	has, err := ibs.Has(b.Cid())
+	if err != nil || !has {
+		t.Fatalf("expected the block to be stored (%v)", err)
+	}
+	size, err := ibs.GetSize(b.Cid())
+	if err != nil || size != len(b.RawData()) {
+		t.Fatalf("expected size %d, got %d (%v)", len(b.RawData()), size, err)
+	}
+	got, err := ibs.Get(b.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.RawData()) != string(b.RawData()) {
+		t.Fatalf("got wrong block data %q", got.RawData())
+	}
+
+	if err := ibs.DeleteBlock(b.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ibs.Get(b.Cid()); err != blockstore.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if has, err := ibs.Has(b.Cid()); err != nil || has {
+		t.Fatalf("expected the block to be gone (%v)", err)
+	}
+	// deleting a missing block isn't an error
+	if err := ibs.DeleteBlock(b.Cid()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIpfsBstoreErrors(t *testing.T) {
+	srv := httptest.NewServer(&fakeIpfs{blocks: map[string][]byte{}})
+	defer srv.Close()
+
+	ibs := newIpfsBstore(context.Background(), srv.URL)
+
+	// errors that only mention something not being found aren't ErrNotFound
+	_, err := ibs.blockCall("block/stat", url.Values{"arg": []string{"plugin"}}, nil, "")
+	if err == nil || err == blockstore.ErrNotFound {
+		t.Fatalf("expected an error other than ErrNotFound, got %v", err)
+	}
+
+	if !notFound(blockservice.ErrNotFound.Error()) {
+		t.Error("expected the blockservice error to be recognized")
+	}
+}
+
+func TestIpfsBstoreTimeout(t *testing.T) {
+	stall := make(chan struct{})
+	srv := httptest.NewServer(&fakeIpfs{stall: stall})
+	defer srv.Close()
+	defer close(stall)
+
+	ibs := newIpfsBstore(context.Background(), srv.URL)
+	ibs.timeout = 50 * time.Millisecond
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ibs.Get(blocks.NewBlock([]byte("data")).Cid())
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request to a stalled node didn't time out")
+	}
+}
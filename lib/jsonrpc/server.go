@@ -110,4 +110,14 @@ func (s *RPCServer) Register(namespace string, handler interface{}) {
 	s.methods.register(namespace, handler)
 }
 
+// AliasMethod makes a registered method also callable under another name,
+// e.g. to serve methods with names outside of the registered namespaces
+func (s *RPCServer) AliasMethod(alias, original string) {
+	h, ok := s.methods[original]
+	if !ok {
+		panic("alias for unregistered method " + original) // ok
+	}
+	s.methods[alias] = h
+}
+
 var _ error = &respError{}
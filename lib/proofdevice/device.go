@@ -0,0 +1,77 @@
+package proofdevice
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Devices proofs can be generated on. A specific GPU is selected with
+// "gpu:<index>", e.g. "gpu:1".
+const (
+	CPU = "cpu"
+	GPU = "gpu"
+)
+
+const gpuPrefix = GPU + ":"
+
+// Validate checks the syntax of a device, an empty device is valid and keeps
+// the current setting
+func Validate(device string) error {
+	_, err := parse(device)
+	return err
+}
+
+// parse returns the GPU index selected by a device, -1 when any GPU can be
+// used and -2 for the CPU
+func parse(device string) (int, error) {
+	switch {
+	case device == "" || device == GPU:
+		return -1, nil
+	case device == CPU:
+		return -2, nil
+	case strings.HasPrefix(device, gpuPrefix):
+		idx, err := strconv.Atoi(strings.TrimPrefix(device, gpuPrefix))
+		if err != nil || idx < 0 {
+			return 0, xerrors.Errorf("invalid GPU index in device '%s'", device)
+		}
+		return idx, nil
+	default:
+		return 0, xerrors.Errorf("unknown proof device '%s', expected '%s', '%s' or '%s<index>'", device, CPU, GPU, gpuPrefix)
+	}
+}
+
+// Apply makes the proofs library generate proofs on the device. The library
+// reads its settings from the environment of the process, so this applies to
+// all proofs generated by this process and has to be called before the first
+// proof is generated. An empty device keeps the current environment.
+func Apply(device string) error {
+	idx, err := parse(device)
+	if err != nil {
+		return err
+	}
+	if device == "" {
+		return nil
+	}
+
+	if idx == -2 {
+		return os.Setenv("BELLMAN_NO_GPU", "1")
+	}
+
+	if err := os.Unsetenv("BELLMAN_NO_GPU"); err != nil {
+		return err
+	}
+	if idx >= 0 {
+		// the OpenCL drivers only expose the selected device to bellman
+		s := strconv.Itoa(idx)
+		if err := os.Setenv("CUDA_VISIBLE_DEVICES", s); err != nil {
+			return err
+		}
+		if err := os.Setenv("GPU_DEVICE_ORDINAL", s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
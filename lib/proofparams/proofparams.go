@@ -0,0 +1,300 @@
+package proofparams
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/minio/blake2b-simd"
+	"github.com/mitchellh/go-homedir"
+	"go.uber.org/multierr"
+	"golang.org/x/xerrors"
+)
+
+var log = logging.Logger("proofparams")
+
+const (
+	// DirEnv is the environment variable the proofs library reads the
+	// parameter cache directory from
+	DirEnv = "FIL_PROOFS_PARAMETER_CACHE"
+	// DefaultDir is used by the proofs library when DirEnv isn't set
+	DefaultDir = "/var/tmp/filecoin-proof-parameters/"
+
+	gatewayEnv     = "IPFS_GATEWAY"
+	defaultGateway = "https://ipfs.io/ipfs/"
+
+	partSuffix     = ".part"
+	verifiedSuffix = ".verified"
+)
+
+// DefaultParallel is the default number of files downloaded at once
+const DefaultParallel = 4
+
+type paramFile struct {
+	Cid        string `json:"cid"`
+	Digest     string `json:"digest"`
+	SectorSize uint64 `json:"sector_size"`
+}
+
+// Dir returns the parameter cache directory
+func Dir() string {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir
+	}
+	return DefaultDir
+}
+
+// SetDir makes this process, and the proofs library it calls, use dir as the
+// parameter cache. Processes sharing a cache, like the miner and its workers,
+// should set the same directory.
+func SetDir(dir string) error {
+	dir, err := homedir.Expand(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return xerrors.Errorf("creating parameter cache directory: %w", err)
+	}
+	return os.Setenv(DirEnv, dir)
+}
+
+// files returns the parameter files needed for sectors of the given size.
+// Verifying keys are small and needed for verifying proofs of any size, so
+// they're always included.
+func files(paramsJSON []byte, ssize uint64) (map[string]paramFile, error) {
+	var params map[string]paramFile
+	if err := json.Unmarshal(paramsJSON, &params); err != nil {
+		return nil, xerrors.Errorf("parsing parameters.json: %w", err)
+	}
+
+	for name, info := range params {
+		if info.SectorSize != ssize && strings.HasSuffix(name, ".params") {
+			delete(params, name)
+		}
+	}
+	return params, nil
+}
+
+func sortedNames(params map[string]paramFile) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Fetch downloads the parameter files needed for sectors of the given size
+// which are missing from the cache or don't match their digest. Up to
+// `parallel` files are downloaded at once, interrupted downloads are resumed.
+func Fetch(ctx context.Context, paramsJSON []byte, ssize uint64, parallel int) error {
+	params, err := files(paramsJSON, ssize)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return xerrors.Errorf("creating parameter cache directory: %w", err)
+	}
+
+	if parallel < 1 {
+		parallel = 1
+	}
+	throttle := make(chan struct{}, parallel)
+
+	var wg sync.WaitGroup
+	var lk sync.Mutex
+	var errs error
+
+	for _, name := range sortedNames(params) {
+		name, info := name, params[name]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case throttle <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-throttle }()
+
+			if err := fetchFile(ctx, name, info); err != nil {
+				lk.Lock()
+				errs = multierr.Append(errs, xerrors.Errorf("%s: %w", name, err))
+				lk.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return errs
+}
+
+func fetchFile(ctx context.Context, name string, info paramFile) error {
+	path := filepath.Join(Dir(), name)
+
+	err := verify(path, info)
+	if err == nil {
+		log.Infof("parameter file %s is ok", path)
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		log.Warnf("parameter file %s is invalid, downloading again: %s", path, err)
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	if err := download(ctx, path+partSuffix, info.Cid); err != nil {
+		return xerrors.Errorf("downloading: %w", err)
+	}
+
+	if err := verify(path+partSuffix, info); err != nil {
+		// don't resume from a corrupted file next time
+		if rerr := os.Remove(path + partSuffix); rerr != nil {
+			log.Errorf("removing corrupted download %s: %s", path+partSuffix, rerr)
+		}
+		return xerrors.Errorf("checking download: %w", err)
+	}
+
+	if err := os.Rename(path+partSuffix, path); err != nil {
+		return err
+	}
+	return markVerified(path, info.Digest)
+}
+
+// download fetches a file from the IPFS gateway, continuing from the end of
+// an earlier partial download
+func download(ctx context.Context, out string, c string) error {
+	gw := os.Getenv(gatewayEnv)
+	if gw == "" {
+		gw = defaultGateway
+	}
+
+	f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint:errcheck
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("GET", gw+c, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		log.Infof("resuming download of %s at %d bytes", out, offset)
+	case http.StatusOK:
+		// the gateway doesn't support ranges, start over
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		log.Infof("downloading %s from %s", out, gw+c)
+	case http.StatusRequestedRangeNotSatisfiable:
+		// already complete
+		return nil
+	default:
+		return xerrors.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// verify checks the digest of a file. Files verified before, and not changed
+// since, aren't read again.
+func verify(path string, info paramFile) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if vfi, err := os.Stat(path + verifiedSuffix); err == nil && !vfi.ModTime().Before(fi.ModTime()) {
+		d, err := ioutil.ReadFile(path + verifiedSuffix)
+		if err == nil && string(d) == info.Digest {
+			return nil
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint:errcheck
+
+	h := blake2b.New512()
+	if _, err := io.Copy(h, f); err != nil {
+		return xerrors.Errorf("reading file: %w", err)
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil)[:16])
+	if sum != info.Digest {
+		return xerrors.Errorf("digest mismatch: expected %s, got %s", info.Digest, sum)
+	}
+
+	if !strings.HasSuffix(path, partSuffix) {
+		if err := markVerified(path, sum); err != nil {
+			log.Warnf("recording verification of %s: %s", path, err)
+		}
+	}
+	return nil
+}
+
+// markVerified records the digest of a verified file next to it
+func markVerified(path string, digest string) error {
+	return ioutil.WriteFile(path+verifiedSuffix, []byte(digest), 0644)
+}
+
+// Check verifies that all parameter files needed for sectors of the given
+// size are in the cache and match their digests, without downloading anything
+func Check(paramsJSON []byte, ssize uint64) error {
+	params, err := files(paramsJSON, ssize)
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for _, name := range sortedNames(params) {
+		path := filepath.Join(Dir(), name)
+		if err := verify(path, params[name]); err != nil {
+			errs = multierr.Append(errs, xerrors.Errorf("%s: %w", path, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,69 @@
+package sigs
+
+import (
+	"bytes"
+
+	"github.com/filecoin-project/go-address"
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// Cache remembers successfully verified (signer, message) pairs, so that
+// checking the same signature again costs a cache lookup. A nil cache
+// verifies every signature.
+type Cache struct {
+	verified *lru.ARCCache
+}
+
+func NewCache(size int) (*Cache, error) {
+	verified, err := lru.NewARC(size)
+	if err != nil {
+		return nil, xerrors.Errorf("creating signature cache: %w", err)
+	}
+	return &Cache{verified: verified}, nil
+}
+
+func cacheKey(addr address.Address, msg []byte) string {
+	return string(addr.Bytes()) + string(msg)
+}
+
+// Verify verifies signatures like the package level Verify, using the cache
+func (c *Cache) Verify(sig *types.Signature, addr address.Address, msg []byte) error {
+	if c == nil {
+		return Verify(sig, addr, msg)
+	}
+
+	k := cacheKey(addr, msg)
+
+	if sig != nil {
+		if v, ok := c.verified.Get(k); ok {
+			cached := v.(*types.Signature)
+			if cached.Type == sig.Type && bytes.Equal(cached.Data, sig.Data) {
+				return nil
+			}
+		}
+	}
+
+	if err := Verify(sig, addr, msg); err != nil {
+		return err
+	}
+
+	c.verified.Add(k, sig)
+	return nil
+}
+
+// Verified returns the signature of msg by addr if it was verified through
+// the cache earlier
+func (c *Cache) Verified(addr address.Address, msg []byte) (*types.Signature, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	v, ok := c.verified.Get(cacheKey(addr, msg))
+	if !ok {
+		return nil, false
+	}
+	return v.(*types.Signature), true
+}
@@ -0,0 +1,630 @@
+package splitstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+
+	block "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	dstore "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	logging "github.com/ipfs/go-log/v2"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var log = logging.Logger("splitstore")
+
+const (
+	// ColdStoreMove keeps compacted blocks in the cold store
+	ColdStoreMove = "move"
+	// ColdStoreDiscard deletes compacted state, and everything in the cold
+	// store that isn't referenced by the chain anymore. Only chain headers and
+	// messages are kept around for the full chain, they are moved to the cold
+	// store.
+	ColdStoreDiscard = "discard"
+)
+
+var baseEpochKey = dstore.NewKey("/meta/baseEpoch")
+
+// coldCleanedKey is set once data in the cold store that isn't chain headers
+// or messages was discarded. Blocks are only ever written to the hot store, so
+// this only has to be done once.
+var coldCleanedKey = dstore.NewKey("/meta/coldCleaned")
+
+type Config struct {
+	// ColdStoreType is either ColdStoreMove or ColdStoreDiscard
+	ColdStoreType string
+
+	// HotRetention is the number of epochs of state kept in the hot store
+	HotRetention uint64
+
+	// CompactionInterval is the number of epochs between compactions
+	CompactionInterval uint64
+}
+
+// ChainAccessor is the part of the ChainStore the splitstore needs to find
+// out which blocks are still in use, and to pause chain writes while blocks
+// are deleted
+type ChainAccessor interface {
+	GetHeaviestTipSet() *types.TipSet
+	LoadTipSet(types.TipSetKey) (*types.TipSet, error)
+	GetGenesis() (*types.BlockHeader, error)
+	SubscribeHeadChanges(func(rev, app []*types.TipSet) error)
+	GCLocker() bstore.GCLocker
+}
+
+// SplitStore is a blockstore keeping recently written and recently referenced
+// chain data in a hot store, while older data gets moved to (or discarded
+// from) a cold store during periodic compactions.
+type SplitStore struct {
+	cfg Config
+
+	compacting int32
+
+	hot  bstore.Blockstore
+	cold bstore.Blockstore
+
+	// tracker records the epoch at which each hot block was written
+	tracker dstore.Datastore
+	ds      dstore.Datastore
+
+	lk        sync.Mutex
+	curEpoch  uint64
+	baseEpoch uint64
+
+	// protected is set while compacting, it collects blocks read or written
+	// since marking started. They may be referenced by new data the mark
+	// phase didn't see, so they are not removed.
+	protectLk sync.Mutex
+	protected *cid.Set
+
+	chain ChainAccessor
+}
+
+var _ bstore.Blockstore = (*SplitStore)(nil)
+
+func New(hot, cold bstore.Blockstore, ds dstore.Batching, cfg Config) (*SplitStore, error) {
+	switch cfg.ColdStoreType {
+	case ColdStoreMove, ColdStoreDiscard:
+	default:
+		return nil, xerrors.Errorf("unknown cold store type %q", cfg.ColdStoreType)
+	}
+
+	ss := &SplitStore{
+		cfg:     cfg,
+		hot:     hot,
+		cold:    cold,
+		tracker: namespace.Wrap(ds, dstore.NewKey("/tracker")),
+		ds:      ds,
+	}
+
+	v, err := ds.Get(baseEpochKey)
+	switch err {
+	case nil:
+		ss.baseEpoch = bytesToEpoch(v)
+	case dstore.ErrNotFound:
+	default:
+		return nil, xerrors.Errorf("loading base epoch: %w", err)
+	}
+
+	return ss, nil
+}
+
+// Start begins tracking the chain head and compacting the hot store
+func (s *SplitStore) Start(chain ChainAccessor) {
+	s.chain = chain
+
+	s.lk.Lock()
+	if head := chain.GetHeaviestTipSet(); head != nil {
+		s.curEpoch = head.Height()
+	}
+	if s.baseEpoch == 0 {
+		s.baseEpoch = s.curEpoch
+	}
+	s.lk.Unlock()
+
+	chain.SubscribeHeadChanges(func(_, apply []*types.TipSet) error {
+		if len(apply) == 0 {
+			return nil
+		}
+		return s.headChange(apply[len(apply)-1])
+	})
+}
+
+func (s *SplitStore) headChange(head *types.TipSet) error {
+	s.lk.Lock()
+	s.curEpoch = head.Height()
+	due := head.Height() > s.baseEpoch+s.cfg.CompactionInterval && head.Height() > s.cfg.HotRetention
+	s.lk.Unlock()
+
+	if !due || !atomic.CompareAndSwapInt32(&s.compacting, 0, 1) {
+		return nil
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&s.compacting, 0)
+
+		if err := s.compact(head); err != nil {
+			log.Errorf("splitstore compaction failed: %+v", err)
+			return
+		}
+
+		s.lk.Lock()
+		s.baseEpoch = head.Height()
+		s.lk.Unlock()
+
+		if err := s.ds.Put(baseEpochKey, epochToBytes(head.Height())); err != nil {
+			log.Errorf("persisting splitstore base epoch: %+v", err)
+		}
+	}()
+
+	return nil
+}
+
+func (s *SplitStore) compact(head *types.TipSet) error {
+	boundary := head.Height() - s.cfg.HotRetention
+	discard := s.cfg.ColdStoreType == ColdStoreDiscard
+
+	log.Infow("compacting splitstore", "head", head.Height(), "boundary", boundary)
+
+	s.protectLk.Lock()
+	s.protected = cid.NewSet()
+	s.protectLk.Unlock()
+
+	defer func() {
+		s.protectLk.Lock()
+		s.protected = nil
+		s.protectLk.Unlock()
+	}()
+
+	// mark everything still reachable from the recent part of the chain. Only
+	// the retained epochs are walked, everything older was dealt with by
+	// previous compactions. When discarding, the genesis state is kept too.
+	marked := cid.NewSet()
+	for ts := head; ts.Height() >= boundary; {
+		if err := s.markTipSet(ts, true, marked); err != nil {
+			return err
+		}
+
+		if ts.Height() == 0 {
+			break
+		}
+
+		var err error
+		ts, err = s.chain.LoadTipSet(ts.Parents())
+		if err != nil {
+			return xerrors.Errorf("loading parent tipset: %w", err)
+		}
+	}
+
+	var coldCleaned bool
+	if discard {
+		gen, err := s.chain.GetGenesis()
+		if err != nil {
+			return xerrors.Errorf("loading genesis: %w", err)
+		}
+		if err := s.markBlock(gen, true, marked); err != nil {
+			return err
+		}
+
+		coldCleaned, err = s.ds.Has(coldCleanedKey)
+		if err != nil {
+			return xerrors.Errorf("checking for cold store cleanup: %w", err)
+		}
+	}
+
+	// headers and messages of the whole chain, only needed to clean up the
+	// cold store the first time data is discarded
+	var chainData *cid.Set
+	if discard && !coldCleaned {
+		chainData = cid.NewSet()
+		for ts := head; ; {
+			if err := s.markTipSet(ts, false, chainData); err != nil {
+				return err
+			}
+
+			if ts.Height() == 0 {
+				break
+			}
+
+			var err error
+			ts, err = s.chain.LoadTipSet(ts.Parents())
+			if err != nil {
+				return xerrors.Errorf("loading parent tipset: %w", err)
+			}
+		}
+	}
+
+	ch, err := s.hot.AllKeysChan(context.TODO())
+	if err != nil {
+		return xerrors.Errorf("listing hot store keys: %w", err)
+	}
+
+	var candidates []cid.Cid
+	for c := range ch {
+		if marked.Has(c) {
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+
+	// when discarding, old headers and their messages are still moved to the
+	// cold store
+	keepCold := chainData
+	if discard && keepCold == nil {
+		keepCold = cid.NewSet()
+		if err := s.markHeaders(candidates, keepCold); err != nil {
+			return err
+		}
+	}
+
+	// writes to the chain blockstore are paused while deleting, nothing can
+	// be protected after this point
+	defer s.chain.GCLocker().GCLock().Unlock()
+
+	var moved, removed int
+	for _, c := range candidates {
+		if s.isProtected(c) {
+			continue
+		}
+
+		// check the write epoch as late as possible, the block may have been
+		// written again since we started compacting
+		epoch, err := s.writeEpoch(c)
+		if err != nil {
+			return err
+		}
+		if epoch >= boundary {
+			continue
+		}
+
+		if !discard || keepCold.Has(c) {
+			blk, err := s.hot.Get(c)
+			if err != nil {
+				return xerrors.Errorf("getting block to move (cid=%s): %w", c, err)
+			}
+			if err := s.cold.Put(blk); err != nil {
+				return xerrors.Errorf("moving block to cold store (cid=%s): %w", c, err)
+			}
+			moved++
+		} else {
+			removed++
+		}
+
+		if err := s.hot.DeleteBlock(c); err != nil {
+			return xerrors.Errorf("deleting block from hot store (cid=%s): %w", c, err)
+		}
+		if err := s.tracker.Delete(dstore.NewKey(c.KeyString())); err != nil && err != dstore.ErrNotFound {
+			return xerrors.Errorf("untracking block (cid=%s): %w", c, err)
+		}
+	}
+
+	if chainData != nil {
+		n, err := s.discardCold(chainData, marked)
+		if err != nil {
+			return xerrors.Errorf("discarding cold blocks: %w", err)
+		}
+		removed += n
+
+		if err := s.ds.Put(coldCleanedKey, []byte{1}); err != nil {
+			return xerrors.Errorf("recording cold store cleanup: %w", err)
+		}
+	}
+
+	log.Infow("splitstore compaction done", "kept", marked.Len(), "moved", moved, "discarded", removed)
+	return nil
+}
+
+// markTipSet marks the headers and messages of ts, and its parent state and
+// receipts when withState is set
+func (s *SplitStore) markTipSet(ts *types.TipSet, withState bool, marked *cid.Set) error {
+	for _, b := range ts.Blocks() {
+		if err := s.markBlock(b, withState, marked); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SplitStore) markBlock(b *types.BlockHeader, withState bool, marked *cid.Set) error {
+	marked.Add(b.Cid())
+
+	if err := s.mark(b.Messages, marked); err != nil {
+		return xerrors.Errorf("marking messages (cid=%s): %w", b.Messages, err)
+	}
+
+	if !withState {
+		return nil
+	}
+
+	if err := s.mark(b.ParentStateRoot, marked); err != nil {
+		return xerrors.Errorf("marking state root (cid=%s): %w", b.ParentStateRoot, err)
+	}
+	if err := s.mark(b.ParentMessageReceipts, marked); err != nil {
+		return xerrors.Errorf("marking receipts (cid=%s): %w", b.ParentMessageReceipts, err)
+	}
+
+	return nil
+}
+
+// markHeaders marks the block headers among the given hot blocks, along with
+// their messages
+func (s *SplitStore) markHeaders(cids []cid.Cid, marked *cid.Set) error {
+	for _, c := range cids {
+		if c.Prefix().Codec != cid.DagCBOR {
+			continue
+		}
+
+		blk, err := s.hot.Get(c)
+		if err == bstore.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return xerrors.Errorf("getting block (cid=%s): %w", c, err)
+		}
+
+		h, err := types.DecodeBlock(blk.RawData())
+		if err != nil || h.Cid() != c {
+			continue
+		}
+
+		marked.Add(c)
+		if err := s.mark(h.Messages, marked); err != nil {
+			return xerrors.Errorf("marking messages (cid=%s): %w", h.Messages, err)
+		}
+	}
+
+	return nil
+}
+
+// discardCold deletes everything but chain headers and messages from the cold
+// store. Blocks that are still in use are moved to the hot store, where they
+// are compacted from then on. Blocks are only ever written to the hot store,
+// so this has to be done only once. Must be called with the GC lock held.
+func (s *SplitStore) discardCold(chainData, marked *cid.Set) (int, error) {
+	ch, err := s.cold.AllKeysChan(context.TODO())
+	if err != nil {
+		return 0, xerrors.Errorf("listing cold store keys: %w", err)
+	}
+
+	var garbage, live []cid.Cid
+	for c := range ch {
+		switch {
+		case chainData.Has(c):
+		case marked.Has(c) || s.isProtected(c):
+			live = append(live, c)
+		default:
+			garbage = append(garbage, c)
+		}
+	}
+
+	for _, c := range live {
+		blk, err := s.cold.Get(c)
+		if err != nil {
+			return 0, xerrors.Errorf("getting block to move (cid=%s): %w", c, err)
+		}
+		if err := s.hot.Put(blk); err != nil {
+			return 0, xerrors.Errorf("moving block to hot store (cid=%s): %w", c, err)
+		}
+		if err := s.track(c); err != nil {
+			return 0, err
+		}
+		garbage = append(garbage, c)
+	}
+
+	for _, c := range garbage {
+		if err := s.cold.DeleteBlock(c); err != nil && err != bstore.ErrNotFound {
+			return 0, xerrors.Errorf("deleting block from cold store (cid=%s): %w", c, err)
+		}
+	}
+
+	return len(garbage) - len(live), nil
+}
+
+func (s *SplitStore) mark(root cid.Cid, marked *cid.Set) error {
+	if !marked.Visit(root) {
+		return nil
+	}
+
+	if root.Prefix().Codec != cid.DagCBOR {
+		return nil
+	}
+
+	get := s.hot.Get
+	if s.cfg.ColdStoreType == ColdStoreDiscard {
+		// cold data is deleted when not marked, so it has to be walked too
+		get = s.get
+	}
+
+	blk, err := get(root)
+	if err == bstore.ErrNotFound {
+		// already compacted, everything it links to is older
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	links, err := cbg.ScanForLinks(bytes.NewReader(blk.RawData()))
+	if err != nil {
+		return xerrors.Errorf("scanning for links: %w", err)
+	}
+
+	for _, c := range links {
+		if err := s.mark(c, marked); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// protect keeps c from being removed by a running compaction
+func (s *SplitStore) protect(c cid.Cid) {
+	s.protectLk.Lock()
+	defer s.protectLk.Unlock()
+
+	if s.protected != nil {
+		s.protected.Add(c)
+	}
+}
+
+func (s *SplitStore) isProtected(c cid.Cid) bool {
+	s.protectLk.Lock()
+	defer s.protectLk.Unlock()
+
+	return s.protected != nil && s.protected.Has(c)
+}
+
+func (s *SplitStore) track(c cid.Cid) error {
+	s.lk.Lock()
+	epoch := s.curEpoch
+	s.lk.Unlock()
+
+	return s.tracker.Put(dstore.NewKey(c.KeyString()), epochToBytes(epoch))
+}
+
+func (s *SplitStore) writeEpoch(c cid.Cid) (uint64, error) {
+	v, err := s.tracker.Get(dstore.NewKey(c.KeyString()))
+	switch err {
+	case nil:
+		return bytesToEpoch(v), nil
+	case dstore.ErrNotFound:
+		// written before we started tracking
+		return 0, nil
+	default:
+		return 0, xerrors.Errorf("getting write epoch (cid=%s): %w", c, err)
+	}
+}
+
+func (s *SplitStore) DeleteBlock(c cid.Cid) error {
+	if err := s.hot.DeleteBlock(c); err != nil && err != bstore.ErrNotFound {
+		return err
+	}
+	if err := s.tracker.Delete(dstore.NewKey(c.KeyString())); err != nil && err != dstore.ErrNotFound {
+		return err
+	}
+
+	return s.cold.DeleteBlock(c)
+}
+
+func (s *SplitStore) Has(c cid.Cid) (bool, error) {
+	s.protect(c)
+
+	has, err := s.hot.Has(c)
+	if err != nil {
+		return false, err
+	}
+	if has {
+		return true, nil
+	}
+
+	return s.cold.Has(c)
+}
+
+func (s *SplitStore) Get(c cid.Cid) (block.Block, error) {
+	s.protect(c)
+	return s.get(c)
+}
+
+func (s *SplitStore) get(c cid.Cid) (block.Block, error) {
+	blk, err := s.hot.Get(c)
+	if err != bstore.ErrNotFound {
+		return blk, err
+	}
+
+	return s.cold.Get(c)
+}
+
+func (s *SplitStore) GetSize(c cid.Cid) (int, error) {
+	s.protect(c)
+
+	size, err := s.hot.GetSize(c)
+	if err != bstore.ErrNotFound {
+		return size, err
+	}
+
+	return s.cold.GetSize(c)
+}
+
+func (s *SplitStore) Put(blk block.Block) error {
+	s.protect(blk.Cid())
+
+	if err := s.hot.Put(blk); err != nil {
+		return err
+	}
+
+	return s.track(blk.Cid())
+}
+
+func (s *SplitStore) PutMany(blks []block.Block) error {
+	for _, blk := range blks {
+		s.protect(blk.Cid())
+	}
+
+	if err := s.hot.PutMany(blks); err != nil {
+		return err
+	}
+
+	for _, blk := range blks {
+		if err := s.track(blk.Cid()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SplitStore) AllKeysChan(ctx context.Context) (<-chan cid.Cid, error) {
+	hot, err := s.hot.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cold, err := s.cold.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan cid.Cid)
+	go func() {
+		defer close(out)
+		for _, in := range []<-chan cid.Cid{hot, cold} {
+			for c := range in {
+				select {
+				case out <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (s *SplitStore) HashOnRead(enabled bool) {
+	s.hot.HashOnRead(enabled)
+	s.cold.HashOnRead(enabled)
+}
+
+func epochToBytes(epoch uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, epoch)
+	return buf
+}
+
+func bytesToEpoch(buf []byte) uint64 {
+	if len(buf) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(buf)
+}
@@ -0,0 +1,312 @@
+package splitstore
+
+import (
+	"fmt"
+	"testing"
+
+	block "github.com/ipfs/go-block-format"
+	dstore "github.com/ipfs/go-datastore"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+)
+
+type testChain struct {
+	tipsets []*types.TipSet
+	gcl     bstore.GCLocker
+
+	// loads counts LoadTipSet calls, onLoad is called on each of them
+	loads  int
+	onLoad func()
+}
+
+func (tc *testChain) GetHeaviestTipSet() *types.TipSet {
+	return tc.tipsets[len(tc.tipsets)-1]
+}
+
+func (tc *testChain) LoadTipSet(tsk types.TipSetKey) (*types.TipSet, error) {
+	tc.loads++
+	if tc.onLoad != nil {
+		tc.onLoad()
+	}
+
+	for _, ts := range tc.tipsets {
+		if ts.Key() == tsk {
+			return ts, nil
+		}
+	}
+	return nil, fmt.Errorf("tipset not found")
+}
+
+func (tc *testChain) GetGenesis() (*types.BlockHeader, error) {
+	return tc.tipsets[0].Blocks()[0], nil
+}
+
+func (tc *testChain) SubscribeHeadChanges(func(rev, app []*types.TipSet) error) {}
+
+func (tc *testChain) GCLocker() bstore.GCLocker {
+	return tc.gcl
+}
+
+func testCompaction(t *testing.T, coldType string) (*SplitStore, bstore.Blockstore, bstore.Blockstore, *testChain, block.Block) {
+	hot := bstore.NewBlockstore(dstore.NewMapDatastore())
+	cold := bstore.NewBlockstore(dstore.NewMapDatastore())
+
+	ss, err := New(hot, cold, dstore.NewMapDatastore(), Config{
+		ColdStoreType:      coldType,
+		HotRetention:       1,
+		CompactionInterval: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	garbage := block.NewBlock([]byte("not referenced by the chain"))
+	if err := ss.Put(garbage); err != nil {
+		t.Fatal(err)
+	}
+
+	tc := &testChain{gcl: bstore.NewGCLocker()}
+	var parent *types.TipSet
+	for i := 0; i < 4; i++ {
+		b := mock.MkBlock(parent, 1, 1)
+		sb, err := b.ToStorageBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ss.curEpoch = b.Height
+		if err := ss.Put(sb); err != nil {
+			t.Fatal(err)
+		}
+
+		parent = mock.TipSet(b)
+		tc.tipsets = append(tc.tipsets, parent)
+	}
+
+	ss.chain = tc
+	if err := ss.compact(tc.GetHeaviestTipSet()); err != nil {
+		t.Fatal(err)
+	}
+
+	return ss, hot, cold, tc, garbage
+}
+
+func mustHave(t *testing.T, bs bstore.Blockstore, blk block.Block, expect bool, what string) {
+	t.Helper()
+	has, err := bs.Has(blk.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has != expect {
+		t.Fatalf("expected %s to have %s: %t, got %t", what, blk.Cid(), expect, has)
+	}
+}
+
+func TestCompactionMove(t *testing.T) {
+	ss, hot, cold, tc, garbage := testCompaction(t, ColdStoreMove)
+
+	// the last two epochs are within retention
+	for i, ts := range tc.tipsets {
+		sb, err := ts.Blocks()[0].ToStorageBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recent := i >= 2
+		mustHave(t, hot, sb, recent, "hot store")
+		mustHave(t, cold, sb, !recent, "cold store")
+		mustHave(t, ss, sb, true, "splitstore")
+	}
+
+	mustHave(t, hot, garbage, false, "hot store")
+	mustHave(t, cold, garbage, true, "cold store")
+
+	if _, err := ss.Get(garbage.Cid()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompactionDiscard(t *testing.T) {
+	ss, hot, cold, tc, garbage := testCompaction(t, ColdStoreDiscard)
+
+	// headers are kept for the whole chain, old ones in the cold store. The
+	// genesis is kept in the hot store along with its state.
+	for i, ts := range tc.tipsets {
+		sb, err := ts.Blocks()[0].ToStorageBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recent := i == 0 || i >= 2
+		mustHave(t, hot, sb, recent, "hot store")
+		mustHave(t, cold, sb, !recent, "cold store")
+		mustHave(t, ss, sb, true, "splitstore")
+	}
+
+	mustHave(t, hot, garbage, false, "hot store")
+	mustHave(t, cold, garbage, false, "cold store")
+	mustHave(t, ss, garbage, false, "splitstore")
+}
+
+func TestCompactionDiscardCold(t *testing.T) {
+	hot := bstore.NewBlockstore(dstore.NewMapDatastore())
+	cold := bstore.NewBlockstore(dstore.NewMapDatastore())
+
+	ss, err := New(hot, cold, dstore.NewMapDatastore(), Config{
+		ColdStoreType:      ColdStoreDiscard,
+		HotRetention:       1,
+		CompactionInterval: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// data written before the splitstore was enabled lives in the cold store
+	oldGarbage := block.NewBlock([]byte("old data not referenced by the chain"))
+	if err := cold.Put(oldGarbage); err != nil {
+		t.Fatal(err)
+	}
+
+	tc := &testChain{gcl: bstore.NewGCLocker()}
+	var parent *types.TipSet
+	var headers []block.Block
+	for i := 0; i < 4; i++ {
+		b := mock.MkBlock(parent, 1, 1)
+		sb, err := b.ToStorageBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers = append(headers, sb)
+
+		// the genesis is in the cold store
+		bs := bstore.Blockstore(ss)
+		if i == 0 {
+			bs = cold
+		}
+		ss.curEpoch = b.Height
+		if err := bs.Put(sb); err != nil {
+			t.Fatal(err)
+		}
+
+		parent = mock.TipSet(b)
+		tc.tipsets = append(tc.tipsets, parent)
+	}
+
+	ss.chain = tc
+	if err := ss.compact(tc.GetHeaviestTipSet()); err != nil {
+		t.Fatal(err)
+	}
+
+	mustHave(t, cold, oldGarbage, false, "cold store")
+	mustHave(t, cold, headers[0], true, "cold store")
+	for _, h := range headers {
+		mustHave(t, ss, h, true, "splitstore")
+	}
+}
+
+func TestCompactionProtectsTouchedBlocks(t *testing.T) {
+	hot := bstore.NewBlockstore(dstore.NewMapDatastore())
+	cold := bstore.NewBlockstore(dstore.NewMapDatastore())
+
+	ss, err := New(hot, cold, dstore.NewMapDatastore(), Config{
+		ColdStoreType:      ColdStoreDiscard,
+		HotRetention:       1,
+		CompactionInterval: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// old blocks not referenced by the chain when compaction starts
+	oldCold := block.NewBlock([]byte("old cold data re-referenced during compaction"))
+	if err := cold.Put(oldCold); err != nil {
+		t.Fatal(err)
+	}
+	oldHot := block.NewBlock([]byte("old hot data re-referenced during compaction"))
+	if err := ss.Put(oldHot); err != nil {
+		t.Fatal(err)
+	}
+
+	tc := &testChain{gcl: bstore.NewGCLocker()}
+	var parent *types.TipSet
+	for i := 0; i < 4; i++ {
+		b := mock.MkBlock(parent, 1, 1)
+		sb, err := b.ToStorageBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ss.curEpoch = b.Height
+		if err := ss.Put(sb); err != nil {
+			t.Fatal(err)
+		}
+
+		parent = mock.TipSet(b)
+		tc.tipsets = append(tc.tipsets, parent)
+	}
+
+	// new state referencing the old blocks is computed while marking, the
+	// writer only checks that they exist
+	tc.onLoad = func() {
+		for _, blk := range []block.Block{oldCold, oldHot} {
+			if _, err := ss.Has(blk.Cid()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	ss.chain = tc
+	if err := ss.compact(tc.GetHeaviestTipSet()); err != nil {
+		t.Fatal(err)
+	}
+
+	mustHave(t, ss, oldCold, true, "splitstore")
+	mustHave(t, ss, oldHot, true, "splitstore")
+
+	// the cold store only keeps chain data, the protected block was moved
+	mustHave(t, cold, oldCold, false, "cold store")
+	mustHave(t, hot, oldCold, true, "hot store")
+}
+
+func TestCompactionDiscardIncremental(t *testing.T) {
+	ss, _, _, tc, _ := testCompaction(t, ColdStoreDiscard)
+
+	// extend the chain and compact again, only the retained epochs have to
+	// be walked now that the cold store was cleaned up
+	parent := tc.GetHeaviestTipSet()
+	for i := 0; i < 2; i++ {
+		b := mock.MkBlock(parent, 1, 1)
+		sb, err := b.ToStorageBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ss.curEpoch = b.Height
+		if err := ss.Put(sb); err != nil {
+			t.Fatal(err)
+		}
+
+		parent = mock.TipSet(b)
+		tc.tipsets = append(tc.tipsets, parent)
+	}
+
+	tc.loads = 0
+	if err := ss.compact(tc.GetHeaviestTipSet()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the parents of the retained tipsets
+	if expect := int(ss.cfg.HotRetention) + 1; tc.loads != expect {
+		t.Fatalf("expected %d tipset loads, got %d", expect, tc.loads)
+	}
+
+	for _, ts := range tc.tipsets {
+		sb, err := ts.Blocks()[0].ToStorageBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustHave(t, ss, sb, true, "splitstore")
+	}
+}
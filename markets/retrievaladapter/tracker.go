@@ -0,0 +1,188 @@
+package retrievaladapter
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/markets/utils"
+)
+
+// maxFinished is how many finished retrievals the tracker keeps to report
+const maxFinished = 256
+
+type trackedRetrieval struct {
+	info api.RetrievalInfo
+
+	// done is closed when the transfer completes or fails
+	done     chan struct{}
+	err      error
+	finished time.Time
+}
+
+// ClientTracker follows the progress of all retrievals made by the client. It
+// subscribes to retrieval client events once, so any number of retrievals can
+// run and be waited on concurrently. Only the most recent finished
+// retrievals are kept.
+type ClientTracker struct {
+	lk    sync.Mutex
+	deals map[retrievalmarket.DealID]*trackedRetrieval
+}
+
+func NewClientTracker() *ClientTracker {
+	return &ClientTracker{
+		deals: map[retrievalmarket.DealID]*trackedRetrieval{},
+	}
+}
+
+// get returns the record for a deal, creating it when events for the deal
+// arrive before the retrieval is registered with Started
+func (t *ClientTracker) get(id retrievalmarket.DealID) *trackedRetrieval {
+	r, ok := t.deals[id]
+	if !ok {
+		r = &trackedRetrieval{
+			info: api.RetrievalInfo{
+				ID:         uint64(id),
+				Status:     api.RetrievalTransferring,
+				FundsSpent: types.NewInt(0),
+			},
+			done: make(chan struct{}),
+		}
+		t.deals[id] = r
+	}
+	return r
+}
+
+// OnEvent records retrieval client events, it never blocks the retrieval
+// client
+func (t *ClientTracker) OnEvent(event retrievalmarket.ClientEvent, state retrievalmarket.ClientDealState) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	r := t.get(state.ID)
+	if c, err := cid.Cast(state.PieceCID); err == nil {
+		r.info.Root = c
+	}
+	r.info.BytesReceived = state.TotalReceived
+	r.info.FundsSpent = utils.FromSharedTokenAmount(state.FundsSpent)
+
+	if r.info.Status != api.RetrievalTransferring {
+		return
+	}
+
+	switch event {
+	case retrievalmarket.ClientEventError:
+		r.finish(xerrors.New("Retrieval Error"))
+		t.prune()
+	case retrievalmarket.ClientEventComplete:
+		r.info.Status = api.RetrievalExporting
+		close(r.done)
+	}
+}
+
+func (r *trackedRetrieval) finish(err error) {
+	r.err = err
+	r.info.Status = api.RetrievalFailed
+	r.info.Error = err.Error()
+	r.finished = time.Now()
+	close(r.done)
+}
+
+// prune drops the oldest finished retrievals above maxFinished
+func (t *ClientTracker) prune() {
+	var finished []retrievalmarket.DealID
+	for id, r := range t.deals {
+		if !r.finished.IsZero() {
+			finished = append(finished, id)
+		}
+	}
+	if len(finished) <= maxFinished {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		fi, fj := t.deals[finished[i]].finished, t.deals[finished[j]].finished
+		if fi.Equal(fj) {
+			return finished[i] < finished[j]
+		}
+		return fi.Before(fj)
+	})
+	for _, id := range finished[:len(finished)-maxFinished] {
+		delete(t.deals, id)
+	}
+}
+
+// Started registers the order of a retrieval started with the given deal ID
+func (t *ClientTracker) Started(id retrievalmarket.DealID, order api.RetrievalOrder, path string) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	r := t.get(id)
+	r.info.Root = order.Root
+	r.info.Size = order.Size
+	r.info.Total = order.Total
+	r.info.Client = order.Client
+	r.info.Miner = order.Miner
+	r.info.MinerPeerID = order.MinerPeerID
+	r.info.Path = path
+	r.info.Started = time.Now()
+}
+
+// Wait waits for the transfer of a retrieval to complete
+func (t *ClientTracker) Wait(ctx context.Context, id retrievalmarket.DealID) error {
+	t.lk.Lock()
+	r := t.get(id)
+	t.lk.Unlock()
+
+	select {
+	case <-r.done:
+		return r.err
+	case <-ctx.Done():
+		t.lk.Lock()
+		if r.info.Status == api.RetrievalTransferring {
+			r.finish(xerrors.Errorf("stopped waiting for retrieval: %w", ctx.Err()))
+			t.prune()
+		}
+		t.lk.Unlock()
+		return xerrors.New("Retrieval Timed Out")
+	}
+}
+
+// Exported records the result of writing out retrieved data
+func (t *ClientTracker) Exported(id retrievalmarket.DealID, err error) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	r := t.get(id)
+	r.finished = time.Now()
+	if err != nil {
+		r.info.Status = api.RetrievalFailed
+		r.info.Error = err.Error()
+	} else {
+		r.info.Status = api.RetrievalComplete
+	}
+	t.prune()
+}
+
+// List returns all retrievals, the most recently started first
+func (t *ClientTracker) List() []api.RetrievalInfo {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	out := make([]api.RetrievalInfo, 0, len(t.deals))
+	for _, r := range t.deals {
+		out = append(out, r.info)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Started.After(out[j].Started)
+	})
+	return out
+}
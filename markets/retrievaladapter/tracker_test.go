@@ -0,0 +1,63 @@
+package retrievaladapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func TestClientTracker(t *testing.T) {
+	ct := NewClientTracker()
+
+	ct.Started(1, api.RetrievalOrder{Size: 10}, "/tmp/out")
+	ct.OnEvent(retrievalmarket.ClientEventComplete, retrievalmarket.ClientDealState{DealProposal: retrievalmarket.DealProposal{ID: 1}, TotalReceived: 10})
+
+	if err := ct.Wait(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	ct.Exported(1, nil)
+
+	ct.Started(2, api.RetrievalOrder{Size: 10}, "/tmp/out2")
+	ct.OnEvent(retrievalmarket.ClientEventError, retrievalmarket.ClientDealState{DealProposal: retrievalmarket.DealProposal{ID: 2}})
+	if err := ct.Wait(context.Background(), 2); err == nil {
+		t.Fatal("expected the failed retrieval to return an error")
+	}
+
+	infos := map[uint64]api.RetrievalInfo{}
+	for _, info := range ct.List() {
+		infos[info.ID] = info
+	}
+	if infos[1].Status != api.RetrievalComplete || infos[1].BytesReceived != 10 {
+		t.Errorf("unexpected first retrieval %+v", infos[1])
+	}
+	if infos[2].Status != api.RetrievalFailed || infos[2].Error == "" {
+		t.Errorf("unexpected second retrieval %+v", infos[2])
+	}
+}
+
+func TestClientTrackerPrunes(t *testing.T) {
+	ct := NewClientTracker()
+
+	for i := 0; i < maxFinished+10; i++ {
+		id := retrievalmarket.DealID(i)
+		ct.Started(id, api.RetrievalOrder{}, "")
+		ct.Exported(id, xerrors.New("failed"))
+	}
+
+	// transfers in progress are kept
+	ct.Started(retrievalmarket.DealID(maxFinished+10), api.RetrievalOrder{}, "")
+
+	if n := len(ct.List()); n != maxFinished+1 {
+		t.Fatalf("expected %d tracked retrievals, got %d", maxFinished+1, n)
+	}
+	if _, ok := ct.deals[0]; ok {
+		t.Error("expected the oldest finished retrieval to be dropped")
+	}
+	if _, ok := ct.deals[retrievalmarket.DealID(maxFinished+10)]; !ok {
+		t.Error("expected the retrieval in progress to be kept")
+	}
+}
@@ -0,0 +1,228 @@
+package staging
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+)
+
+var log = logging.Logger("staging")
+
+var stagedAtPrefix = datastore.NewKey("/staged")
+
+// InUseFunc returns the staged blocks still needed by deals, GC keeps them
+// whatever their age
+type InUseFunc func(context.Context) (*cid.Set, error)
+
+// ErrQuotaExceeded is returned when storing a block would take the staging
+// area over its quota
+var ErrQuotaExceeded = xerrors.New("staging area quota exceeded")
+
+// Blockstore holds incoming deal data until it's added to a sector. It
+// enforces a size quota and remembers when each block was staged, so data of
+// deals which never completed can be removed.
+type Blockstore struct {
+	blockstore.Blockstore
+
+	stagedAt datastore.Batching
+
+	maxSize uint64
+
+	lk   sync.Mutex
+	used uint64
+}
+
+// NewBlockstore creates a staging blockstore in ds. A maxSize of 0 disables
+// the quota.
+func NewBlockstore(ds datastore.Batching, maxSize uint64) (*Blockstore, error) {
+	bs := &Blockstore{
+		Blockstore: blockstore.NewBlockstore(ds),
+		stagedAt:   namespace.Wrap(ds, stagedAtPrefix),
+		maxSize:    maxSize,
+	}
+
+	keys, err := bs.Blockstore.AllKeysChan(context.TODO())
+	if err != nil {
+		return nil, xerrors.Errorf("listing staged blocks: %w", err)
+	}
+	for c := range keys {
+		size, err := bs.Blockstore.GetSize(c)
+		if err != nil {
+			return nil, xerrors.Errorf("getting size of staged block %s: %w", c, err)
+		}
+		bs.used += uint64(size)
+	}
+
+	return bs, nil
+}
+
+// Used returns the number of bytes staged
+func (bs *Blockstore) Used() uint64 {
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+	return bs.used
+}
+
+func (bs *Blockstore) Put(blk blocks.Block) error {
+	return bs.PutMany([]blocks.Block{blk})
+}
+
+func (bs *Blockstore) PutMany(blks []blocks.Block) error {
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+
+	var toPut []blocks.Block
+	var size uint64
+	for _, blk := range blks {
+		has, err := bs.Blockstore.Has(blk.Cid())
+		if err != nil {
+			return err
+		}
+		if has {
+			continue
+		}
+
+		toPut = append(toPut, blk)
+		size += uint64(len(blk.RawData()))
+	}
+
+	if len(toPut) > 0 {
+		if bs.maxSize > 0 && bs.used+size > bs.maxSize {
+			return xerrors.Errorf("storing %d bytes (%d/%d used): %w", size, bs.used, bs.maxSize, ErrQuotaExceeded)
+		}
+
+		if err := bs.Blockstore.PutMany(toPut); err != nil {
+			return err
+		}
+		bs.used += size
+	}
+
+	// blocks already staged, e.g. left by a failed deal, are staged again
+	// for the deal transferring them now, so GC keeps them as long
+	now := make([]byte, 8)
+	binary.BigEndian.PutUint64(now, uint64(time.Now().Unix()))
+	for _, blk := range blks {
+		if err := bs.stagedAt.Put(dshelp.CidToDsKey(blk.Cid()), now); err != nil {
+			return xerrors.Errorf("recording staging time: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (bs *Blockstore) DeleteBlock(c cid.Cid) error {
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+
+	return bs.deleteBlock(c)
+}
+
+func (bs *Blockstore) deleteBlock(c cid.Cid) error {
+	size, err := bs.Blockstore.GetSize(c)
+	switch err {
+	case nil:
+	case blockstore.ErrNotFound:
+		size = 0
+	default:
+		return err
+	}
+
+	if err := bs.Blockstore.DeleteBlock(c); err != nil {
+		return err
+	}
+	if err := bs.stagedAt.Delete(dshelp.CidToDsKey(c)); err != nil && err != datastore.ErrNotFound {
+		return xerrors.Errorf("deleting staging time: %w", err)
+	}
+
+	if uint64(size) > bs.used {
+		bs.used = 0
+	} else {
+		bs.used -= uint64(size)
+	}
+	return nil
+}
+
+// GC removes blocks staged earlier than maxAge ago, except for blocks in
+// inUse, which may be nil. Data of deals which got added to a sector is
+// removed right away, so these are blocks of deals which failed or never
+// completed.
+func (bs *Blockstore) GC(maxAge time.Duration, inUse *cid.Set) error {
+	bs.lk.Lock()
+	defer bs.lk.Unlock()
+
+	res, err := bs.stagedAt.Query(query.Query{})
+	if err != nil {
+		return xerrors.Errorf("querying staging times: %w", err)
+	}
+	ents, err := res.Rest()
+	if err != nil {
+		return xerrors.Errorf("reading staging times: %w", err)
+	}
+
+	cutoff := uint64(time.Now().Add(-maxAge).Unix())
+
+	var removed int
+	for _, ent := range ents {
+		if len(ent.Value) != 8 || binary.BigEndian.Uint64(ent.Value) > cutoff {
+			continue
+		}
+
+		c, err := dshelp.DsKeyToCid(datastore.RawKey(ent.Key))
+		if err != nil {
+			return xerrors.Errorf("parsing staged block key %s: %w", ent.Key, err)
+		}
+		if inUse != nil && inUse.Has(c) {
+			continue
+		}
+		if err := bs.deleteBlock(c); err != nil {
+			return xerrors.Errorf("deleting staged block %s: %w", c, err)
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		log.Infof("removed %d blocks staged before %s, %d bytes staged", removed, time.Unix(int64(cutoff), 0), bs.used)
+	}
+	return nil
+}
+
+// Run periodically removes blocks staged for longer than maxAge and not in
+// use until ctx is cancelled. inUse may be nil.
+func (bs *Blockstore) Run(ctx context.Context, interval time.Duration, maxAge time.Duration, inUse InUseFunc) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			var keep *cid.Set
+			if inUse != nil {
+				var err error
+				keep, err = inUse(ctx)
+				if err != nil {
+					log.Errorf("staging area gc: listing blocks in use: %+v", err)
+					continue
+				}
+			}
+
+			if err := bs.GC(maxAge, keep); err != nil {
+				log.Errorf("staging area gc: %+v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+var _ blockstore.Blockstore = &Blockstore{}
@@ -0,0 +1,88 @@
+package staging
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+)
+
+// backdate makes blk look like it was staged an hour ago
+func backdate(t *testing.T, bs *Blockstore, blk blocks.Block) {
+	t.Helper()
+	old := make([]byte, 8)
+	binary.BigEndian.PutUint64(old, uint64(time.Now().Add(-time.Hour).Unix()))
+	if err := bs.stagedAt.Put(dshelp.CidToDsKey(blk.Cid()), old); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustHave(t *testing.T, bs *Blockstore, blk blocks.Block, expect bool) {
+	t.Helper()
+	has, err := bs.Has(blk.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has != expect {
+		t.Fatalf("expected staged block %s: %t, got %t", blk.Cid(), expect, has)
+	}
+}
+
+func TestGCRestagedBlocks(t *testing.T) {
+	bs, err := NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restaged := blocks.NewBlock([]byte("transferred again by a new deal"))
+	stale := blocks.NewBlock([]byte("left by a failed deal"))
+	for _, blk := range []blocks.Block{restaged, stale} {
+		if err := bs.Put(blk); err != nil {
+			t.Fatal(err)
+		}
+		backdate(t, bs, blk)
+	}
+
+	if err := bs.Put(restaged); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.GC(time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	mustHave(t, bs, restaged, true)
+	mustHave(t, bs, stale, false)
+	if bs.Used() != uint64(len(restaged.RawData())) {
+		t.Fatalf("expected %d bytes used, got %d", len(restaged.RawData()), bs.Used())
+	}
+}
+
+func TestGCKeepsBlocksInUse(t *testing.T) {
+	bs, err := NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blk := blocks.NewBlock([]byte("waiting to be added to a sector"))
+	if err := bs.Put(blk); err != nil {
+		t.Fatal(err)
+	}
+	backdate(t, bs, blk)
+
+	inUse := cid.NewSet()
+	inUse.Add(blk.Cid())
+	if err := bs.GC(time.Minute, inUse); err != nil {
+		t.Fatal(err)
+	}
+	mustHave(t, bs, blk, true)
+
+	if err := bs.GC(time.Minute, nil); err != nil {
+		t.Fatal(err)
+	}
+	mustHave(t, bs, blk, false)
+}
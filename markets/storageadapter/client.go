@@ -121,7 +121,7 @@ func (n *ClientNodeAdapter) AddFunds(ctx context.Context, addr address.Address,
 		GasPrice: types.NewInt(0),
 		GasLimit: types.NewInt(1000000),
 		Method:   actors.SMAMethods.AddBalance,
-	})
+	}, nil)
 	if err != nil {
 		return err
 	}
@@ -220,7 +220,7 @@ func (c *ClientNodeAdapter) ValidatePublishedDeal(ctx context.Context, deal stor
 
 func (c *ClientNodeAdapter) OnDealSectorCommitted(ctx context.Context, provider address.Address, dealId uint64, cb storagemarket.DealSectorCommittedCallback) error {
 	checkFunc := func(ts *types.TipSet) (done bool, more bool, err error) {
-		sd, err := stmgr.GetStorageDeal(ctx, c.StateManager, dealId, ts)
+		sd, err := stmgr.GetStorageDeal(ctx, c.sm, dealId, ts)
 		if err != nil {
 			// TODO: This may be fine for some errors
 			return false, false, xerrors.Errorf("failed to look up deal on chain: %w", err)
@@ -246,7 +246,7 @@ func (c *ClientNodeAdapter) OnDealSectorCommitted(ctx context.Context, provider
 			return false, nil
 		}
 
-		sd, err := stmgr.GetStorageDeal(ctx, c.StateManager, dealId, ts)
+		sd, err := stmgr.GetStorageDeal(ctx, c.sm, dealId, ts)
 		if err != nil {
 			return false, xerrors.Errorf("failed to look up deal on chain: %w", err)
 		}
@@ -325,7 +325,7 @@ func (n *ClientNodeAdapter) GetDefaultWalletAddress(ctx context.Context) (addres
 func (n *ClientNodeAdapter) ValidateAskSignature(ask *sharedtypes.SignedStorageAsk) error {
 	tss := n.cs.GetHeaviestTipSet().ParentState()
 
-	w, err := stmgr.GetMinerWorkerRaw(context.TODO(), n.StateManager, tss, ask.Ask.Miner)
+	w, err := stmgr.GetMinerWorkerRaw(context.TODO(), n.sm, tss, ask.Ask.Miner)
 	if err != nil {
 		return xerrors.Errorf("failed to get worker for miner in ask", err)
 	}
@@ -5,9 +5,15 @@ package storageadapter
 import (
 	"bytes"
 	"context"
+	"sync"
 
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	ipld "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log"
+	"github.com/ipfs/go-merkledag"
 	unixfile "github.com/ipfs/go-unixfs/file"
 	"golang.org/x/xerrors"
 
@@ -15,10 +21,13 @@ import (
 	"github.com/filecoin-project/go-fil-markets/shared/tokenamount"
 	sharedtypes "github.com/filecoin-project/go-fil-markets/shared/types"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	storageimpl "github.com/filecoin-project/go-fil-markets/storagemarket/impl"
+	"github.com/filecoin-project/go-statestore"
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/lib/padreader"
+	"github.com/filecoin-project/lotus/markets/staging"
 	"github.com/filecoin-project/lotus/markets/utils"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/storage/sectorblocks"
@@ -32,15 +41,36 @@ type ProviderNodeAdapter struct {
 	// this goes away with the data transfer module
 	dag dtypes.StagingDAG
 
+	// staging and dealStore are used to keep staged blocks which are still
+	// needed by other deals
+	staging   dtypes.StagingBlockstore
+	dealStore *statestore.StateStore
+	stagedLk  sync.Mutex
+
 	secb *sectorblocks.SectorBlocks
+
+	// actors holds the sector blocks of the additional miner actors, deals
+	// made with one of them are sealed into its sectors
+	actors sectorblocks.ActorSectorBlocks
 }
 
-func NewProviderNodeAdapter(dag dtypes.StagingDAG, secb *sectorblocks.SectorBlocks, full api.FullNode) storagemarket.StorageProviderNode {
+func NewProviderNodeAdapter(dag dtypes.StagingDAG, staging dtypes.StagingBlockstore, ds dtypes.MetadataDS, secb *sectorblocks.SectorBlocks, actors sectorblocks.ActorSectorBlocks, full api.FullNode) storagemarket.StorageProviderNode {
 	return &ProviderNodeAdapter{
-		FullNode: full,
-		dag:      dag,
-		secb:     secb,
+		FullNode:  full,
+		dag:       dag,
+		staging:   staging,
+		dealStore: statestore.New(namespace.Wrap(ds, datastore.NewKey(storageimpl.ProviderDsPrefix))),
+		secb:      secb,
+		actors:    actors,
+	}
+}
+
+// sectorBlocks returns the sector blocks of the actor a deal was made with
+func (n *ProviderNodeAdapter) sectorBlocks(provider address.Address) *sectorblocks.SectorBlocks {
+	if secb, ok := n.actors[provider]; ok {
+		return secb
 	}
+	return n.secb
 }
 
 func (n *ProviderNodeAdapter) PublishDeals(ctx context.Context, deal storagemarket.MinerDeal) (storagemarket.DealID, cid.Cid, error) {
@@ -72,7 +102,7 @@ func (n *ProviderNodeAdapter) PublishDeals(ctx context.Context, deal storagemark
 		GasLimit: types.NewInt(1000000),
 		Method:   actors.SMAMethods.PublishStorageDeals,
 		Params:   params,
-	})
+	}, nil)
 	if err != nil {
 		return 0, cid.Undef, err
 	}
@@ -122,15 +152,126 @@ func (n *ProviderNodeAdapter) OnDealComplete(ctx context.Context, deal storagema
 		return 0, xerrors.Errorf("deal.Proposal.PieceSize didn't match padded unixfs file size")
 	}
 
-	sectorID, err := n.secb.AddUnixfsPiece(ctx, uf, deal.DealID)
+	sectorID, err := n.sectorBlocks(deal.Proposal.Provider).AddUnixfsPiece(ctx, uf, deal.DealID, deal.Proposal.PieceRef)
 	if err != nil {
 		return 0, xerrors.Errorf("AddPiece failed: %s", err)
 	}
 	log.Warnf("New Sector: %d (deal %d)", sectorID, deal.DealID)
 
+	// The data is in the sector now, drop the staged copy
+	if err := n.removeStaged(ctx, deal.ProposalCid, root.Cid()); err != nil {
+		log.Errorf("removing staged data of deal %d: %+v", deal.DealID, err)
+	}
+
 	return sectorID, nil
 }
 
+// needsStaged returns whether a deal in the given state may still read its
+// data from the staging blockstore
+func needsStaged(state api.DealState) bool {
+	switch state {
+	case api.DealUnknown, api.DealAccepted, api.DealStaged:
+		return true
+	default:
+		return false
+	}
+}
+
+// StagedInUse returns the staged blocks of deals which may still read them
+// from the staging blockstore
+func StagedInUse(dealStore *statestore.StateStore, bs blockstore.Blockstore) staging.InUseFunc {
+	return func(ctx context.Context) (*cid.Set, error) {
+		var deals []storageimpl.MinerDeal
+		if err := dealStore.List(&deals); err != nil {
+			return nil, xerrors.Errorf("listing deals: %w", err)
+		}
+
+		inUse := cid.NewSet()
+		for _, d := range deals {
+			if !needsStaged(d.State) {
+				continue
+			}
+			if err := merkledag.Walk(ctx, stagedLinks(bs), d.Ref, inUse.Visit); err != nil {
+				return nil, xerrors.Errorf("walking staged dag %s: %w", d.Ref, err)
+			}
+		}
+		return inUse, nil
+	}
+}
+
+// removeStaged removes the staged blocks of a deal, except for blocks which
+// other deals still waiting to be added to a sector reference
+func (n *ProviderNodeAdapter) removeStaged(ctx context.Context, proposal cid.Cid, root cid.Cid) error {
+	n.stagedLk.Lock()
+	defer n.stagedLk.Unlock()
+
+	var deals []storageimpl.MinerDeal
+	if err := n.dealStore.List(&deals); err != nil {
+		return xerrors.Errorf("listing deals: %w", err)
+	}
+
+	var active []cid.Cid
+	for _, d := range deals {
+		if d.ProposalCid == proposal || !needsStaged(d.State) {
+			continue
+		}
+		active = append(active, d.Ref)
+	}
+
+	staged, err := unreferencedStaged(ctx, n.staging, root, active)
+	if err != nil {
+		return err
+	}
+
+	return n.dag.RemoveMany(ctx, staged)
+}
+
+// unreferencedStaged returns the staged blocks of the dag under root which
+// aren't part of any of the other dags
+func unreferencedStaged(ctx context.Context, bs blockstore.Blockstore, root cid.Cid, others []cid.Cid) ([]cid.Cid, error) {
+	inUse := cid.NewSet()
+	for _, c := range others {
+		if err := merkledag.Walk(ctx, stagedLinks(bs), c, inUse.Visit); err != nil {
+			return nil, xerrors.Errorf("walking staged dag %s: %w", c, err)
+		}
+	}
+
+	var staged []cid.Cid
+	err := merkledag.Walk(ctx, stagedLinks(bs), root, func(c cid.Cid) bool {
+		if inUse.Has(c) {
+			// the whole sub-dag is shared
+			return false
+		}
+		staged = append(staged, c)
+		return true
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("walking staged dag: %w", err)
+	}
+
+	return staged, nil
+}
+
+// stagedLinks only reads blocks from the staging blockstore, blocks which
+// aren't staged have no links instead of being fetched from the network
+func stagedLinks(bs blockstore.Blockstore) merkledag.GetLinks {
+	return func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+		blk, err := bs.Get(c)
+		if err == blockstore.ErrNotFound {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		nd, err := ipld.Decode(blk)
+		if err != nil {
+			return nil, err
+		}
+		return nd.Links(), nil
+	}
+}
+
 func (n *ProviderNodeAdapter) ListProviderDeals(ctx context.Context, addr address.Address) ([]storagemarket.StorageDeal, error) {
 	allDeals, err := n.StateMarketDeals(ctx, nil)
 	if err != nil {
@@ -180,7 +321,7 @@ func (n *ProviderNodeAdapter) AddFunds(ctx context.Context, addr address.Address
 		GasPrice: types.NewInt(0),
 		GasLimit: types.NewInt(1000000),
 		Method:   actors.SMAMethods.AddBalance,
-	})
+	}, nil)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,97 @@
+package storageadapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipfs/go-merkledag"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/storage/sectorblocks"
+)
+
+func TestDealSectorBlocks(t *testing.T) {
+	mainAddr, err := address.NewIDAddress(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := address.NewIDAddress(1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mainBlocks := &sectorblocks.SectorBlocks{}
+	otherBlocks := &sectorblocks.SectorBlocks{}
+
+	n := &ProviderNodeAdapter{
+		secb:   mainBlocks,
+		actors: sectorblocks.ActorSectorBlocks{other: otherBlocks},
+	}
+
+	if n.sectorBlocks(mainAddr) != mainBlocks {
+		t.Error("deals with the main actor should use its sector blocks")
+	}
+	if n.sectorBlocks(other) != otherBlocks {
+		t.Error("deals with an additional actor should use its sector blocks")
+	}
+}
+
+func TestUnreferencedStaged(t *testing.T) {
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	put := func(data string, links ...*merkledag.ProtoNode) *merkledag.ProtoNode {
+		nd := merkledag.NodeWithData([]byte(data))
+		for _, l := range links {
+			if err := nd.AddNodeLink(data, l); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := bs.Put(nd); err != nil {
+			t.Fatal(err)
+		}
+		return nd
+	}
+
+	shared := put("shared", put("shared-leaf"))
+	own := put("own")
+	root := put("root", shared, own)
+	other := put("other", shared)
+
+	// only the blocks of root which aren't part of the other deal are removed
+	staged, err := unreferencedStaged(ctx, bs, root.Cid(), []cid.Cid{other.Cid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed := cid.NewSet()
+	for _, c := range staged {
+		removed.Add(c)
+	}
+	if removed.Len() != 2 || !removed.Has(root.Cid()) || !removed.Has(own.Cid()) {
+		t.Fatalf("unexpected blocks removed: %v", staged)
+	}
+
+	// another deal for the same data keeps all of it
+	staged, err = unreferencedStaged(ctx, bs, root.Cid(), []cid.Cid{root.Cid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(staged) != 0 {
+		t.Fatalf("expected no blocks to be removed, got %v", staged)
+	}
+
+	// without other deals everything goes
+	staged, err = unreferencedStaged(ctx, bs, root.Cid(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(staged) != 4 {
+		t.Fatalf("expected 4 blocks to be removed, got %d", len(staged))
+	}
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"net/http"
+
+	"contrib.go.opencensus.io/exporter/prometheus"
+	"go.opencensus.io/stats/view"
+	"golang.org/x/xerrors"
+)
+
+// Exporter registers the views and returns a handler serving all registered
+// views in the prometheus text format
+func Exporter(namespace string, views ...*view.View) (http.Handler, error) {
+	if err := view.Register(views...); err != nil {
+		return nil, xerrors.Errorf("registering views: %w", err)
+	}
+
+	exporter, err := prometheus.NewExporter(prometheus.Options{
+		Namespace: namespace,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("creating prometheus exporter: %w", err)
+	}
+	view.RegisterExporter(exporter)
+
+	return exporter, nil
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// Tags
+var (
+	MinerID, _     = tag.NewKey("miner")
+	MiningStage, _ = tag.NewKey("stage")
+	SectorState, _ = tag.NewKey("sector_state")
+)
+
+// Measures
+var (
+	MiningStageDuration = stats.Float64("miner/stage_duration_ms", "Duration of a block production stage", stats.UnitMilliseconds)
+	MiningLateBlocks    = stats.Int64("miner/late_blocks", "Blocks ready to publish after their timestamp", stats.UnitDimensionless)
+	MiningLateness      = stats.Float64("miner/lateness_ms", "How long after their timestamp blocks were ready to publish", stats.UnitMilliseconds)
+
+	FPoStDuration = stats.Float64("miner/fpost_duration_ms", "Duration of fallback PoSt generation", stats.UnitMilliseconds)
+	FPoStFailures = stats.Int64("miner/fpost_failures", "Failed fallback PoSt attempts", stats.UnitDimensionless)
+
+	StoragePathFailures = stats.Int64("miner/storage_path_failures", "Number of unreadable sector storage paths", stats.UnitDimensionless)
+
+	SealingStageDuration = stats.Float64("sealing/stage_duration_s", "Time sectors spent in a sealing state", stats.UnitSeconds)
+
+	ChainHeight = stats.Int64("chain/height", "Height of the current head", stats.UnitDimensionless)
+	SyncLag     = stats.Int64("chain/sync_lag_epochs", "Epochs between the current head and the expected chain height", stats.UnitDimensionless)
+	MpoolSize   = stats.Int64("mpool/pending", "Number of pending messages in the message pool", stats.UnitDimensionless)
+)
+
+// Views
+var (
+	MiningStageDurationView = &view.View{
+		Measure:     MiningStageDuration,
+		Aggregation: view.Distribution(1, 10, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 20000, 45000),
+		TagKeys:     []tag.Key{MinerID, MiningStage},
+	}
+	MiningLateBlocksView = &view.View{
+		Measure:     MiningLateBlocks,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{MinerID},
+	}
+	MiningLatenessView = &view.View{
+		Measure:     MiningLateness,
+		Aggregation: view.Distribution(100, 500, 1000, 2500, 5000, 10000, 20000, 45000),
+		TagKeys:     []tag.Key{MinerID},
+	}
+
+	FPoStDurationView = &view.View{
+		Measure:     FPoStDuration,
+		Aggregation: view.Distribution(1000, 5000, 10000, 30000, 60000, 120000, 300000, 600000, 1200000),
+		TagKeys:     []tag.Key{MinerID},
+	}
+	FPoStFailuresView = &view.View{
+		Measure:     FPoStFailures,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{MinerID},
+	}
+	StoragePathFailuresView = &view.View{
+		Measure:     StoragePathFailures,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{MinerID},
+	}
+	SealingStageDurationView = &view.View{
+		Measure:     SealingStageDuration,
+		Aggregation: view.Distribution(1, 10, 60, 300, 900, 1800, 3600, 7200, 14400, 28800, 86400),
+		TagKeys:     []tag.Key{SectorState},
+	}
+
+	ChainHeightView = &view.View{
+		Measure:     ChainHeight,
+		Aggregation: view.LastValue(),
+	}
+	SyncLagView = &view.View{
+		Measure:     SyncLag,
+		Aggregation: view.LastValue(),
+	}
+	MpoolSizeView = &view.View{
+		Measure:     MpoolSize,
+		Aggregation: view.LastValue(),
+	}
+)
+
+// ChainViews are the views of full node metrics
+var ChainViews = []*view.View{
+	ChainHeightView,
+	SyncLagView,
+	MpoolSizeView,
+}
+
+// MinerViews are the views of block production metrics
+var MinerViews = []*view.View{
+	MiningStageDurationView,
+	MiningLateBlocksView,
+	MiningLatenessView,
+	FPoStDurationView,
+	FPoStFailuresView,
+	StoragePathFailuresView,
+	SealingStageDurationView,
+}
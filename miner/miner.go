@@ -58,6 +58,12 @@ type Miner struct {
 	lastWork *MiningBase
 
 	minedBlockHeights *lru.ARCCache
+
+	// recent block production attempts, guarded by lk
+	attempts []api.MiningAttempt
+
+	// miners with candidates being precomputed, guarded by lk
+	precomputing map[address.Address]bool
 }
 
 func (m *Miner) Addresses() ([]address.Address, error) {
@@ -160,6 +166,13 @@ eventLoop:
 			continue
 		}
 
+		// the election inputs only depend on the base, start computing them
+		// while waiting for other blocks to arrive
+		pre := make(map[address.Address]*winCheck, len(addrs))
+		for _, addr := range addrs {
+			pre[addr] = m.startWinCheck(ctx, addr, prebase)
+		}
+
 		// Wait until propagation delay period after block we plan to mine on
 		if err := m.waitFunc(ctx, prebase.ts.MinTimestamp()); err != nil {
 			log.Error(err)
@@ -179,25 +192,51 @@ eventLoop:
 		lastBase = *base
 
 		blks := make([]*types.BlockMsg, 0)
+		won := make([]*attempt, 0)
 
 		for _, addr := range addrs {
-			b, err := m.mineOne(ctx, addr, base)
+			at := newAttempt(ctx, addr, base)
+			b, err := m.mineOne(ctx, addr, base, pre[addr], at)
 			if err != nil {
 				log.Errorf("mining block failed: %+v", err)
+				m.recordAttempt(at, err)
 				continue
 			}
 			if b != nil {
 				blks = append(blks, b)
+				won = append(won, at)
+			} else {
+				m.recordAttempt(at, nil)
 			}
 		}
 
+		// whether the round is won or not, the next one is mined one round
+		// later, whichever tipset it's mined on
+		next := int64(lastBase.ts.Height()+lastBase.nullRounds) + 2
+		for _, addr := range addrs {
+			m.precomputeCandidates(ctx, addr, lastBase.ts, next)
+		}
+
 		if len(blks) != 0 {
+			// blocks are late when they are ready after their timestamp,
+			// waiting for the timestamp below doesn't count
+			ready := time.Now()
+			for i, at := range won {
+				if bt := time.Unix(int64(blks[i].Header.Timestamp), 0); ready.After(bt) {
+					at.late(ready.Sub(bt))
+				}
+			}
+
 			btime := time.Unix(int64(blks[0].Header.Timestamp), 0)
-			if time.Now().Before(btime) {
+			if ready.Before(btime) {
 				time.Sleep(time.Until(btime))
 			} else {
 				log.Warnw("mined block in the past", "block-time", btime,
-					"time", time.Now(), "duration", time.Since(btime))
+					"time", ready, "duration", ready.Sub(btime))
+			}
+			for _, at := range won {
+				// don't count waiting for the block time as publishing
+				at.skip()
 			}
 
 			mWon := make(map[address.Address]struct{})
@@ -205,11 +244,16 @@ eventLoop:
 				_, notOk := mWon[b.Header.Miner]
 				if notOk {
 					log.Errorw("2 blocks for the same miner. Throwing hands in the air. Report this. It is important.", "bloks", blks)
+					for _, at := range won {
+						m.recordAttempt(at, xerrors.New("multiple blocks for the same miner"))
+					}
 					continue eventLoop
 				}
 				mWon[b.Header.Miner] = struct{}{}
 			}
-			for _, b := range blks {
+			for i, b := range blks {
+				at := won[i]
+
 				// TODO: this code was written to handle creating blocks for multiple miners.
 				// However, we don't use that, and we probably never will. So even though this code will
 				// never see different miners, i'm going to handle the caching as if it was going to.
@@ -217,13 +261,17 @@ eventLoop:
 				blkKey := fmt.Sprintf("%s-%d", b.Header.Miner, b.Header.Height)
 				if _, ok := m.minedBlockHeights.Get(blkKey); ok {
 					log.Warnw("Created a block at the same height as another block we've created", "height", b.Header.Height, "miner", b.Header.Miner, "parents", b.Header.Parents)
+					m.recordAttempt(at, xerrors.New("already created a block at this height"))
 					continue
 				}
 
 				m.minedBlockHeights.Add(blkKey, true)
-				if err := m.api.SyncSubmitBlock(ctx, b); err != nil {
+				err := m.api.SyncSubmitBlock(ctx, b)
+				if err != nil {
 					log.Errorf("failed to submit newly mined block: %s", err)
 				}
+				at.stage("publish")
+				m.recordAttempt(at, err)
 			}
 		} else {
 			nextRound := time.Unix(int64(base.ts.MinTimestamp()+uint64(build.BlockDelay*base.nullRounds)), 0)
@@ -284,7 +332,94 @@ func (m *Miner) hasPower(ctx context.Context, addr address.Address, ts *types.Ti
 	return !power.MinerPower.Equals(types.NewInt(0)), nil
 }
 
-func (m *Miner) mineOne(ctx context.Context, addr address.Address, base *MiningBase) (*types.BlockMsg, error) {
+// randomnessConfidence is how many rounds deep the ticket the election
+// randomness of a round is taken from has to be, before the candidates of the
+// round are precomputed
+const randomnessConfidence = 5
+
+// precomputeCandidates starts generating the election PoSt candidates for a
+// later round in the background, once its randomness is final. The prover
+// keeps them, so the election check of the round doesn't wait for candidate
+// generation, even when the round is mined on another tipset.
+func (m *Miner) precomputeCandidates(ctx context.Context, addr address.Address, ts *types.TipSet, round int64) {
+	if round-build.EcRandomnessLookback > int64(ts.Height())-randomnessConfidence {
+		return
+	}
+
+	m.lk.Lock()
+	if m.precomputing[addr] {
+		// still busy with an earlier round
+		m.lk.Unlock()
+		return
+	}
+	if m.precomputing == nil {
+		m.precomputing = map[address.Address]bool{}
+	}
+	m.precomputing[addr] = true
+	m.lk.Unlock()
+
+	go func() {
+		defer func() {
+			m.lk.Lock()
+			delete(m.precomputing, addr)
+			m.lk.Unlock()
+		}()
+
+		start := time.Now()
+		if err := gen.PrecomputeCandidates(ctx, ts, round, addr, m.epp, m.api); err != nil {
+			if ctx.Err() == nil {
+				log.Warnf("precomputing election candidates for round %d: %s", round, err)
+			}
+			return
+		}
+		log.Debugw("precomputed election candidates", "miner", addr, "round", round, "took", time.Since(start))
+	}()
+}
+
+// winCheck is a background computation of the election inputs for a round
+type winCheck struct {
+	ts    *types.TipSet
+	round int64
+
+	done    chan struct{}
+	proofin *gen.ProofInput
+	err     error
+}
+
+func (m *Miner) startWinCheck(ctx context.Context, addr address.Address, base *MiningBase) *winCheck {
+	wc := &winCheck{
+		ts:    base.ts,
+		round: int64(base.ts.Height() + base.nullRounds + 1),
+		done:  make(chan struct{}),
+	}
+
+	go func() {
+		defer close(wc.done)
+		start := time.Now()
+
+		wc.proofin, wc.err = gen.IsRoundWinner(ctx, wc.ts, wc.round, addr, m.epp, m.api)
+		log.Debugw("precomputed election inputs", "miner", addr, "round", wc.round, "took", time.Since(start))
+	}()
+
+	return wc
+}
+
+// result returns the precomputed inputs if they were computed for the given
+// round, waiting for the computation to finish
+func (wc *winCheck) result(ctx context.Context, ts *types.TipSet, round int64) (*gen.ProofInput, bool, error) {
+	if wc == nil || wc.round != round || !wc.ts.Equals(ts) {
+		return nil, false, nil
+	}
+
+	select {
+	case <-wc.done:
+		return wc.proofin, true, wc.err
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+func (m *Miner) mineOne(ctx context.Context, addr address.Address, base *MiningBase, pre *winCheck, at *attempt) (*types.BlockMsg, error) {
 	log.Debugw("attempting to mine a block", "tipset", types.LogCids(base.ts.Cids()))
 	start := time.Now()
 
@@ -297,6 +432,7 @@ func (m *Miner) mineOne(ctx context.Context, addr address.Address, base *MiningB
 		base.nullRounds++
 		return nil, nil
 	}
+	at.stage("power-check")
 
 	log.Infof("Time delta between now and our mining base: %ds (nulls: %d)", uint64(time.Now().Unix())-base.ts.MinTimestamp(), base.nullRounds)
 
@@ -304,11 +440,17 @@ func (m *Miner) mineOne(ctx context.Context, addr address.Address, base *MiningB
 	if err != nil {
 		return nil, xerrors.Errorf("scratching ticket failed: %w", err)
 	}
+	at.stage("ticket")
 
-	proofin, err := gen.IsRoundWinner(ctx, base.ts, int64(base.ts.Height()+base.nullRounds+1), addr, m.epp, m.api)
+	round := int64(base.ts.Height() + base.nullRounds + 1)
+	proofin, ok, err := pre.result(ctx, base.ts, round)
+	if !ok && err == nil {
+		proofin, err = gen.IsRoundWinner(ctx, base.ts, round, addr, m.epp, m.api)
+	}
 	if err != nil {
 		return nil, xerrors.Errorf("failed to check if we win next round: %w", err)
 	}
+	at.stage("election-check")
 
 	if proofin == nil {
 		base.nullRounds++
@@ -320,16 +462,19 @@ func (m *Miner) mineOne(ctx context.Context, addr address.Address, base *MiningB
 	if err != nil {
 		return nil, xerrors.Errorf("failed to get pending messages: %w", err)
 	}
+	at.stage("message-fetch")
 
 	proof, err := gen.ComputeProof(ctx, m.epp, proofin)
 	if err != nil {
 		return nil, xerrors.Errorf("computing election proof: %w", err)
 	}
+	at.stage("post-proof")
 
-	b, err := m.createBlock(base, addr, ticket, proof, pending)
+	b, err := m.createBlock(base, addr, ticket, proof, pending, at)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to create block: %w", err)
 	}
+	at.Won = true
 
 	dur := time.Since(start)
 	log.Infow("mined new block", "cid", b.Cid(), "height", b.Header.Height, "took", dur)
@@ -385,11 +530,12 @@ func (m *Miner) computeTicket(ctx context.Context, addr address.Address, base *M
 	}, nil
 }
 
-func (m *Miner) createBlock(base *MiningBase, addr address.Address, ticket *types.Ticket, proof *types.EPostProof, pending []*types.SignedMessage) (*types.BlockMsg, error) {
+func (m *Miner) createBlock(base *MiningBase, addr address.Address, ticket *types.Ticket, proof *types.EPostProof, pending []*types.SignedMessage, at *attempt) (*types.BlockMsg, error) {
 	msgs, err := SelectMessages(context.TODO(), m.api.StateGetActor, base.ts, pending)
 	if err != nil {
 		return nil, xerrors.Errorf("message filtering failed: %w", err)
 	}
+	at.stage("message-selection")
 
 	if len(msgs) > build.BlockMessageLimit {
 		log.Error("SelectMessages returned too many messages: ", len(msgs))
@@ -401,7 +547,13 @@ func (m *Miner) createBlock(base *MiningBase, addr address.Address, ticket *type
 	nheight := base.ts.Height() + base.nullRounds + 1
 
 	// why even return this? that api call could just submit it for us
-	return m.api.MinerCreateBlock(context.TODO(), addr, base.ts, ticket, proof, msgs, nheight, uint64(uts))
+	b, err := m.api.MinerCreateBlock(context.TODO(), addr, base.ts, ticket, proof, msgs, nheight, uint64(uts))
+	if err != nil {
+		return nil, err
+	}
+	at.stage("block-create")
+
+	return b, nil
 }
 
 type ActorLookup func(context.Context, address.Address, *types.TipSet) (*types.Actor, error)
@@ -0,0 +1,95 @@
+package miner
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/metrics"
+)
+
+// number of block production attempts kept for MiningStatus
+const recentAttempts = 100
+
+// attempt times the stages of a single block production attempt
+type attempt struct {
+	api.MiningAttempt
+
+	ctx  context.Context
+	last time.Time
+}
+
+func newAttempt(ctx context.Context, addr address.Address, base *MiningBase) *attempt {
+	now := time.Now()
+	ctx, _ = tag.New(ctx, tag.Insert(metrics.MinerID, addr.String()))
+
+	return &attempt{
+		MiningAttempt: api.MiningAttempt{
+			Miner:  addr,
+			Base:   base.ts.Key(),
+			Height: base.ts.Height() + base.nullRounds + 1,
+			Start:  now,
+		},
+		ctx:  ctx,
+		last: now,
+	}
+}
+
+// stage records the time since the previous stage ended
+func (a *attempt) stage(name string) {
+	now := time.Now()
+	d := now.Sub(a.last)
+	a.last = now
+
+	a.Stages = append(a.Stages, api.MiningStage{Name: name, Duration: d})
+
+	ctx, _ := tag.New(a.ctx, tag.Insert(metrics.MiningStage, name))
+	stats.Record(ctx, metrics.MiningStageDuration.M(float64(d)/float64(time.Millisecond)))
+}
+
+// skip starts timing the next stage from now
+func (a *attempt) skip() {
+	a.last = time.Now()
+}
+
+func (a *attempt) late(d time.Duration) {
+	a.Late = d
+	stats.Record(a.ctx, metrics.MiningLateBlocks.M(1), metrics.MiningLateness.M(float64(d)/float64(time.Millisecond)))
+}
+
+func (m *Miner) recordAttempt(a *attempt, err error) {
+	a.Total = time.Since(a.Start)
+	if err != nil {
+		a.Error = err.Error()
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	m.attempts = append(m.attempts, a.MiningAttempt)
+	if len(m.attempts) > recentAttempts {
+		m.attempts = m.attempts[len(m.attempts)-recentAttempts:]
+	}
+}
+
+func (m *Miner) Status() (*api.MiningStatus, error) {
+	addrs, err := m.Addresses()
+	if err != nil {
+		return nil, err
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	out := &api.MiningStatus{
+		Addresses: addrs,
+		Attempts:  make([]api.MiningAttempt, len(m.attempts)),
+	}
+	copy(out.Attempts, m.attempts)
+
+	return out, nil
+}
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"time"
 
+	"github.com/filecoin-project/go-address"
 	sectorbuilder "github.com/filecoin-project/go-sectorbuilder"
-	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	logging "github.com/ipfs/go-log"
+	autonat "github.com/libp2p/go-libp2p-autonat"
 	ci "github.com/libp2p/go-libp2p-core/crypto"
+	coredisc "github.com/libp2p/go-libp2p-core/discovery"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/peerstore"
@@ -26,7 +28,9 @@ import (
 	deals "github.com/filecoin-project/go-fil-markets/storagemarket/impl"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apistruct"
 	"github.com/filecoin-project/lotus/chain"
+	"github.com/filecoin-project/lotus/chain/beacon"
 	"github.com/filecoin-project/lotus/chain/blocksync"
 	"github.com/filecoin-project/lotus/chain/gen"
 	"github.com/filecoin-project/lotus/chain/market"
@@ -34,12 +38,18 @@ import (
 	"github.com/filecoin-project/lotus/chain/metrics"
 	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/sub"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/vm"
 	"github.com/filecoin-project/lotus/chain/wallet"
+	"github.com/filecoin-project/lotus/lib/bwlimit"
+	"github.com/filecoin-project/lotus/lib/sigs"
 	_ "github.com/filecoin-project/lotus/lib/sigs/bls"
 	_ "github.com/filecoin-project/lotus/lib/sigs/secp"
+	"github.com/filecoin-project/lotus/lib/splitstore"
+	"github.com/filecoin-project/lotus/markets/retrievaladapter"
 	"github.com/filecoin-project/lotus/markets/storageadapter"
+	lmetrics "github.com/filecoin-project/lotus/metrics"
 	"github.com/filecoin-project/lotus/miner"
 	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/hello"
@@ -55,6 +65,7 @@ import (
 	"github.com/filecoin-project/lotus/storage"
 	"github.com/filecoin-project/lotus/storage/sealing"
 	"github.com/filecoin-project/lotus/storage/sectorblocks"
+	"github.com/filecoin-project/lotus/tracing"
 )
 
 var log = logging.Logger("builder")
@@ -65,15 +76,16 @@ type special struct{ id int }
 
 //nolint:golint
 var (
-	DefaultTransportsKey = special{0} // Libp2p option
-	DiscoveryHandlerKey  = special{2} // Private type
-	AddrsFactoryKey      = special{3} // Libp2p option
-	SmuxTransportKey     = special{4} // Libp2p option
-	RelayKey             = special{5} // Libp2p option
-	SecurityKey          = special{6} // Libp2p option
-	BaseRoutingKey       = special{7} // fx groups + multiret
-	NatPortMapKey        = special{8} // Libp2p option
-	ConnectionManagerKey = special{9} // Libp2p option
+	DefaultTransportsKey = special{0}  // Libp2p option
+	DiscoveryHandlerKey  = special{2}  // Private type
+	AddrsFactoryKey      = special{3}  // Libp2p option
+	SmuxTransportKey     = special{4}  // Libp2p option
+	RelayKey             = special{5}  // Libp2p option
+	SecurityKey          = special{6}  // Libp2p option
+	BaseRoutingKey       = special{7}  // fx groups + multiret
+	NatPortMapKey        = special{8}  // Libp2p option
+	ConnectionManagerKey = special{9}  // Libp2p option
+	BandwidthReporterKey = special{10} // Libp2p option + multiret
 )
 
 type invoke int
@@ -85,6 +97,9 @@ const (
 	PstoreAddSelfKeysKey = invoke(iota)
 	StartListeningKey
 	BootstrapKey
+	RunPinnedPeersKey
+	AutoRelayKey
+	AdvertiseRelayKey
 
 	// filecoin
 	SetGenesisKey
@@ -100,6 +115,7 @@ const (
 	RegisterClientValidatorKey
 
 	// storage miner
+	SetProofDeviceKey
 	GetParamsKey
 	HandleDealsKey
 	HandleRetrievalKey
@@ -108,10 +124,19 @@ const (
 
 	// daemon
 	ExtractApiKey
+	ExtractAPILimiterKey
+	ExtractActorAPIsKey
 	HeadMetricsKey
+	SyncCheckpointKey
+	StartSplitstoreKey
+	StatePrunerKey
+	RunSlasherKey
+	RecordChainMetricsKey
 	RunPeerTaggerKey
 
 	SetApiEndpointKey
+	ServeMetricsKey
+	TracingKey
 
 	_nInvokes // keep this last
 )
@@ -166,9 +191,13 @@ func libp2p() Option {
 		Override(NatPortMapKey, lp2p.NatPortMap),
 
 		Override(ConnectionManagerKey, lp2p.ConnectionManager(50, 200, 20*time.Second, nil)),
+		Override(BandwidthReporterKey, lp2p.BandwidthCounter),
 
 		Override(new(*pubsub.PubSub), lp2p.GossipSub()),
 
+		Override(new(*peermgr.PinnedPeers), peermgr.NewPinnedPeers),
+		Override(RunPinnedPeersKey, modules.RunPinnedPeers),
+
 		Override(PstoreAddSelfKeysKey, lp2p.PstoreAddSelfKeys),
 		Override(StartListeningKey, lp2p.StartListening(config.DefaultFullNode().Libp2p.ListenAddresses)),
 	)
@@ -199,15 +228,19 @@ func Online() Option {
 
 			Override(new(dtypes.BootstrapPeers), modules.BuiltinBootstrap),
 
+			Override(new(sub.BlockValidators), sub.DefaultBlockValidators),
+			Override(new(sub.MessageValidators), sub.DefaultMessageValidators),
+			Override(new(*sub.PeerScorer), modules.PubsubPeerScorer(sub.ScoreParams{})),
 			Override(HandleIncomingMessagesKey, modules.HandleIncomingMessages),
 
 			Override(new(sectorbuilder.Verifier), sectorbuilder.ProofVerifier),
 			Override(new(*types.VMSyscalls), vm.Syscalls),
+			Override(new(beacon.RandomnessBeacon), modules.RandomnessBeacon),
 			Override(new(*store.ChainStore), modules.ChainStore),
 			Override(new(*stmgr.StateManager), stmgr.NewStateManager),
 			Override(new(*wallet.Wallet), wallet.NewWallet),
 
-			Override(new(dtypes.ChainGCLocker), blockstore.NewGCLocker),
+			Override(new(dtypes.ChainGCLocker), modules.ChainGCLocker),
 			Override(new(dtypes.ChainGCBlockstore), modules.ChainGCBlockstore),
 			Override(new(dtypes.ChainExchange), modules.ChainExchange),
 			Override(new(dtypes.ChainBlockService), modules.ChainBlockservice),
@@ -216,6 +249,8 @@ func Online() Option {
 			// Filecoin services
 			Override(new(*chain.Syncer), modules.NewSyncer),
 			Override(new(*blocksync.BlockSync), blocksync.NewBlockSyncClient),
+			Override(new(*sigs.Cache), modules.SigCache),
+			Override(new(*messagepool.Config), modules.MpoolConfig(config.DefaultFullNode().Mpool)),
 			Override(new(*messagepool.MessagePool), modules.MessagePool),
 
 			Override(new(modules.Genesis), modules.ErrorGenesis),
@@ -234,6 +269,7 @@ func Online() Option {
 			Override(new(retrievalmarket.PeerResolver), modules.RetrievalResolver),
 
 			Override(new(retrievalmarket.RetrievalClient), modules.RetrievalClient),
+			Override(new(*retrievaladapter.ClientTracker), modules.RetrievalClientTracker),
 			Override(new(dtypes.ClientDealStore), modules.NewClientDealStore),
 			Override(new(dtypes.ClientDataTransfer), modules.NewClientDAGServiceDataTransfer),
 			Override(new(*deals.ClientRequestValidator), modules.NewClientRequestValidator),
@@ -252,11 +288,15 @@ func Online() Option {
 			Override(new(sectorbuilder.Interface), modules.SectorBuilder),
 			Override(new(*sectorblocks.SectorBlocks), sectorblocks.NewSectorBlocks),
 			Override(new(sealing.TicketFn), modules.SealTicketGen),
+			Override(new(*storage.MessageTracker), modules.MessageTracker),
 			Override(new(*storage.Miner), modules.StorageMiner),
+			Override(new(storage.ActorMiners), storage.ActorMiners{}),
+			Override(new(sectorblocks.ActorSectorBlocks), modules.ActorSectorBlocks),
 
-			Override(new(dtypes.StagingBlockstore), modules.StagingBlockstore),
+			Override(new(dtypes.StagingBlockstore), modules.StagingBlockstore(config.Staging{})),
 			Override(new(dtypes.StagingDAG), modules.StagingDAG),
-			Override(new(dtypes.StagingGraphsync), modules.StagingGraphsync),
+			Override(new(*bwlimit.Limiter), modules.TransferLimiter(config.Transfers{})),
+			Override(new(dtypes.StagingGraphsync), modules.StagingGraphsync(config.Transfers{})),
 			Override(new(retrievalmarket.RetrievalProvider), modules.RetrievalProvider),
 			Override(new(dtypes.ProviderDealStore), modules.NewProviderDealStore),
 			Override(new(dtypes.ProviderDataTransfer), modules.NewProviderDAGServiceDataTransfer),
@@ -264,9 +304,11 @@ func Online() Option {
 			Override(new(storagemarket.StorageProvider), modules.StorageProvider),
 			Override(new(storagemarket.StorageProviderNode), storageadapter.NewProviderNodeAdapter),
 			Override(RegisterProviderValidatorKey, modules.RegisterProviderValidator),
-			Override(HandleRetrievalKey, modules.HandleRetrieval),
-			Override(GetParamsKey, modules.GetParams),
+			Override(HandleRetrievalKey, modules.HandleRetrieval(config.Transfers{})),
+			Override(GetParamsKey, modules.CheckParams("")),
+			Override(new(dtypes.SealProofDevice), dtypes.SealProofDevice("")),
 			Override(HandleDealsKey, modules.HandleDeals),
+			Override(new(storage.ProofTimeouts), storage.DefaultProofTimeouts),
 			Override(new(gen.ElectionPoStProver), storage.NewElectionPoStProver),
 			Override(new(*miner.Miner), modules.SetupBlockProducer),
 		),
@@ -309,6 +351,8 @@ func ConfigCommon(cfg *config.Common) Option {
 			return lr.SetAPIEndpoint(apima)
 		}),
 
+		Override(new(*apistruct.Limiter), modules.APILimiter(cfg.API.Limits)),
+
 		ApplyIf(func(s *Settings) bool { return s.Online },
 			Override(StartListeningKey, lp2p.StartListening(cfg.Libp2p.ListenAddresses)),
 			Override(ConnectionManagerKey, lp2p.ConnectionManager(
@@ -317,6 +361,28 @@ func ConfigCommon(cfg *config.Common) Option {
 				time.Duration(cfg.Libp2p.ConnMgrGrace),
 				cfg.Libp2p.ProtectedPeers)),
 
+			Override(AddrsFactoryKey, lp2p.AddrsFactory(cfg.Libp2p.AnnounceAddresses, cfg.Libp2p.NoAnnounceAddresses)),
+
+			If(!cfg.Libp2p.EnableNATPortMap,
+				Unset(NatPortMapKey),
+			),
+			If(cfg.Libp2p.EnableAutoNAT,
+				Override(new(autonat.AutoNAT), lp2p.AutoNAT),
+			),
+
+			Override(RelayKey, lp2p.Relay(!cfg.Libp2p.EnableRelay, cfg.Libp2p.EnableRelayHop)),
+			If(cfg.Libp2p.EnableRelay && cfg.Libp2p.EnableRelayHop,
+				Override(new(coredisc.Discovery), lp2p.Discovery),
+				Override(AdvertiseRelayKey, lp2p.AdvertiseRelay),
+			),
+			If(cfg.Libp2p.EnableRelay && cfg.Libp2p.EnableAutoRelay && !cfg.Libp2p.EnableRelayHop,
+				Override(new(coredisc.Discovery), lp2p.Discovery),
+				Override(AutoRelayKey, lp2p.AutoRelay),
+			),
+
+			ApplyIf(func(s *Settings) bool { return cfg.Libp2p.BootstrapNetwork != "" },
+				Override(new(dtypes.BootstrapPeers), modules.NetworkBootstrap(cfg.Libp2p.BootstrapNetwork)),
+			),
 			ApplyIf(func(s *Settings) bool { return len(cfg.Libp2p.BootstrapPeers) > 0 },
 				Override(new(dtypes.BootstrapPeers), modules.ConfigBootstrap(cfg.Libp2p.BootstrapPeers)),
 			),
@@ -338,9 +404,70 @@ func ConfigFullNode(c interface{}) Option {
 		If(cfg.Metrics.PubsubTracing,
 			Override(new(*pubsub.PubSub), lp2p.GossipSub(lp2p.PubsubTracer())),
 		),
+
+		Override(new(*sub.PeerScorer), modules.PubsubPeerScorer(sub.ScoreParams{
+			Penalties: map[string]float64{
+				"/fil/blocks":   cfg.Pubsub.InvalidBlockPenalty,
+				"/fil/messages": cfg.Pubsub.InvalidMessagePenalty,
+			},
+			ValidReward:        cfg.Pubsub.ValidReward,
+			MaxScore:           cfg.Pubsub.MaxScore,
+			Decay:              cfg.Pubsub.ScoreDecay,
+			DecayInterval:      time.Duration(cfg.Pubsub.DecayInterval),
+			GraylistThreshold:  cfg.Pubsub.GraylistThreshold,
+			BlacklistThreshold: cfg.Pubsub.BlacklistThreshold,
+		})),
+
+		If(cfg.Splitstore.Enable,
+			Override(new(*splitstore.SplitStore), modules.SplitBlockstore(splitstore.Config{
+				ColdStoreType:      cfg.Splitstore.ColdStoreType,
+				HotRetention:       cfg.Splitstore.HotStoreRetention,
+				CompactionInterval: cfg.Splitstore.CompactionInterval,
+			})),
+			Override(new(dtypes.ChainBlockstore), modules.SplitChainBlockstore),
+			Override(StartSplitstoreKey, modules.StartSplitstore),
+		),
+
+		If(cfg.Pruning.Enable,
+			Override(StatePrunerKey, modules.StatePruner(cfg.Pruning.RetainEpochs, time.Duration(cfg.Pruning.Interval))),
+		),
+
+		If(cfg.Slasher.Enable,
+			Override(RunSlasherKey, modules.RunSlasher(cfg.Slasher.ReporterAddress)),
+		),
+
+		If(cfg.Client.UseIpfs,
+			Unset(new(dtypes.ClientFilestore)),
+			Override(new(dtypes.ClientBlockstore), modules.IpfsClientBlockstore(cfg.Client.IpfsAPI)),
+		),
+
+		If(cfg.Tracing.Enabled(),
+			Override(TracingKey, modules.Tracing(tracingOptions("lotus", cfg.Tracing))),
+		),
+
+		If(cfg.Prometheus.ListenAddress != "",
+			Override(ServeMetricsKey, modules.ServeMetrics(cfg.Prometheus.ListenAddress, "lotus", lmetrics.ChainViews)),
+			Override(RecordChainMetricsKey, modules.RecordChainMetrics),
+		),
+
+		If(len(cfg.Sync.Checkpoint) > 0,
+			Override(SyncCheckpointKey, modules.SyncCheckpoint(cfg.Sync.Checkpoint)),
+		),
+
+		Override(new(*messagepool.Config), modules.MpoolConfig(cfg.Mpool)),
 	)
 }
 
+func tracingOptions(service string, cfg config.Tracing) tracing.Options {
+	return tracing.Options{
+		ServiceName:             service,
+		JaegerAgentEndpoint:     cfg.JaegerAgentEndpoint,
+		JaegerCollectorEndpoint: cfg.JaegerCollectorEndpoint,
+		OCAgentAddress:          cfg.OCAgentAddress,
+		SampleRate:              cfg.SampleRate,
+	}
+}
+
 func ConfigStorageMiner(c interface{}, lr repo.LockedRepo) Option {
 	cfg, ok := c.(*config.StorageMiner)
 	if !ok {
@@ -357,13 +484,39 @@ func ConfigStorageMiner(c interface{}, lr repo.LockedRepo) Option {
 		log.Warn("LEGACY SectorBuilder.Path FOUND IN CONFIG. Please use the new storage config")
 	}
 
+	mainWorkers, actorWorkers, err := modules.SplitWorkers(cfg.SectorBuilder.WorkerCount, len(cfg.Actors))
+	if err != nil {
+		return Error(err)
+	}
+
 	return Options(
 		ConfigCommon(&cfg.Common),
 
 		Override(new(*sectorbuilder.Config), modules.SectorBuilderConfig(scfg,
-			cfg.SectorBuilder.WorkerCount,
+			mainWorkers,
 			cfg.SectorBuilder.DisableLocalPreCommit,
 			cfg.SectorBuilder.DisableLocalCommit)),
+
+		Override(new(dtypes.StagingBlockstore), modules.StagingBlockstore(cfg.Staging)),
+		Override(new(*bwlimit.Limiter), modules.TransferLimiter(cfg.Transfers)),
+		Override(new(dtypes.StagingGraphsync), modules.StagingGraphsync(cfg.Transfers)),
+		Override(HandleRetrievalKey, modules.HandleRetrieval(cfg.Transfers)),
+		Override(GetParamsKey, modules.CheckParams(cfg.SectorBuilder.ParameterCache)),
+		Override(SetProofDeviceKey, modules.SetProofDevice(cfg.Proofs, !cfg.SectorBuilder.DisableLocalCommit)),
+		Override(new(storage.ProofTimeouts), modules.ProofTimeouts(cfg.Proofs)),
+		Override(new(dtypes.SealProofDevice), dtypes.SealProofDevice(cfg.Proofs.Seal())),
+
+		If(len(cfg.Actors) > 0,
+			Override(new(storage.ActorMiners), modules.ActorMiners(cfg.Actors, actorWorkers, lr.Path())),
+		),
+
+		If(cfg.Tracing.Enabled(),
+			Override(TracingKey, modules.Tracing(tracingOptions("lotus-storage-miner", cfg.Tracing))),
+		),
+
+		If(cfg.Prometheus.ListenAddress != "",
+			Override(ServeMetricsKey, modules.ServeMetrics(cfg.Prometheus.ListenAddress, "lotus_miner", lmetrics.MinerViews)),
+		),
 	)
 }
 
@@ -381,14 +534,18 @@ func Repo(r repo.Repo) Option {
 		return Options(
 			Override(new(repo.LockedRepo), modules.LockedRepo(lr)), // module handles closing
 
+			// before the node config, which may replace it with the splitstore
+			Override(new(dtypes.ChainBlockstore), modules.ChainBlockstore),
+
+			// before the node config, which may replace them with an IPFS blockstore
+			Override(new(dtypes.ClientFilestore), modules.ClientFstore),
+			Override(new(dtypes.ClientBlockstore), modules.ClientBlockstore),
+
 			ApplyIf(isType(repo.FullNode), ConfigFullNode(c)),
 			ApplyIf(isType(repo.StorageMiner), ConfigStorageMiner(c, lr)),
 
 			Override(new(dtypes.MetadataDS), modules.Datastore),
-			Override(new(dtypes.ChainBlockstore), modules.ChainBlockstore),
 
-			Override(new(dtypes.ClientFilestore), modules.ClientFstore),
-			Override(new(dtypes.ClientBlockstore), modules.ClientBlockstore),
 			Override(new(dtypes.ClientDAG), modules.ClientDAG),
 			Override(new(dtypes.ClientGraphsync), modules.ClientGraphsync),
 
@@ -412,6 +569,41 @@ func FullAPI(out *api.FullNode) Option {
 	}
 }
 
+// APILimiter sets out to the limiter of calls made to the node API, which is
+// nil when the config doesn't set any limits
+func APILimiter(out **apistruct.Limiter) Option {
+	return func(s *Settings) error {
+		s.invokes[ExtractAPILimiterKey] = fx.Invoke(func(l *apistruct.Limiter) {
+			*out = l
+		})
+		return nil
+	}
+}
+
+// StorageMinerActors sets out to the APIs of the additional miner actors run
+// by the storage miner
+func StorageMinerActors(out *map[address.Address]api.StorageMiner) Option {
+	return func(s *Settings) error {
+		resAPI := &impl.StorageMinerAPI{}
+		s.invokes[ExtractActorAPIsKey] = fx.Options(
+			fx.Extract(resAPI),
+			fx.Invoke(func() error {
+				apis := map[address.Address]api.StorageMiner{}
+				for _, maddr := range resAPI.Actors.Addresses() {
+					aapi, err := resAPI.ForActor(maddr)
+					if err != nil {
+						return err
+					}
+					apis[maddr] = aapi
+				}
+				*out = apis
+				return nil
+			}),
+		)
+		return nil
+	}
+}
+
 type StopFunc func(context.Context) error
 
 // New builds and starts new Filecoin node
@@ -9,14 +9,24 @@ import (
 
 // Common is common config between full node and miner
 type Common struct {
-	API    API
-	Libp2p Libp2p
+	API        API
+	Libp2p     Libp2p
+	Prometheus Prometheus
+	Tracing    Tracing
+	Datastore  Datastore
 }
 
 // FullNode is a full node config
 type FullNode struct {
 	Common
-	Metrics Metrics
+	Metrics    Metrics
+	Mpool      Mpool
+	Pubsub     Pubsub
+	Sync       Sync
+	Splitstore Splitstore
+	Pruning    Pruning
+	Slasher    Slasher
+	Client     Client
 }
 
 // // Common
@@ -26,23 +36,123 @@ type StorageMiner struct {
 	Common
 
 	SectorBuilder SectorBuilder
+	Staging       Staging
+	Transfers     Transfers
+	Proofs        Proofs
+
+	// Actors lists additional miner actors run by this process. Each actor
+	// gets its own sealing pipeline and fallback PoSt scheduler, and deals
+	// made with it are sealed into its sectors. Block production is handled
+	// for the main actor only.
+	Actors []MinerActor
+}
+
+// MinerActor configures an additional miner actor
+type MinerActor struct {
+	Address string
+	// Storage lists the sector storage paths of the actor. When empty, the
+	// 'actors/<address>' directory of the repo is used.
+	Storage []fs.PathConfig
 }
 
 // API contains configs for API endpoint
 type API struct {
 	ListenAddress string
 	Timeout       Duration
+
+	// Limits are applied to calls made with each token separately
+	Limits []APILimit
+}
+
+// APILimit restricts calls to a group of API methods
+type APILimit struct {
+	// Methods is the method name prefix selecting the group, e.g. "Chain",
+	// an empty prefix matches all methods
+	Methods string
+	// Rate is the number of calls allowed per second, 0 means unlimited
+	Rate  float64
+	Burst int
+	// MaxConcurrent is the max number of calls in progress, 0 means unlimited
+	MaxConcurrent int
+}
+
+// Prometheus configures the metrics endpoint
+type Prometheus struct {
+	// ListenAddress is the multiaddress /metrics is served on, the endpoint
+	// is disabled when empty
+	ListenAddress string
+}
+
+// Tracing configures where traces are exported to, tracing is disabled when
+// no endpoint is set
+type Tracing struct {
+	// JaegerAgentEndpoint is the host:port of a jaeger agent
+	JaegerAgentEndpoint string
+	// JaegerCollectorEndpoint is the HTTP URL of a jaeger collector
+	JaegerCollectorEndpoint string
+	// OCAgentAddress is the host:port of an OpenCensus agent, or of an
+	// OpenTelemetry collector with the opencensus receiver enabled
+	OCAgentAddress string
+
+	// SampleRate is the fraction of traces recorded, between 0 and 1
+	SampleRate float64
+}
+
+func (t Tracing) Enabled() bool {
+	return t.JaegerAgentEndpoint != "" || t.JaegerCollectorEndpoint != "" || t.OCAgentAddress != ""
+}
+
+// Datastore configures the badger datastore of the repo
+type Datastore struct {
+	// GCInterval is the time between value log garbage collections, 0
+	// disables periodic collection
+	GCInterval Duration
+	// GCDiscardRatio is the fraction of stale data a value log file needs to
+	// contain to be rewritten
+	GCDiscardRatio float64
+
+	// NumCompactors is the number of concurrent LSM tree compactions, 0 uses
+	// the badger default
+	NumCompactors int
+	// CompactL0OnClose compacts level 0 tables when the node shuts down
+	CompactL0OnClose bool
 }
 
 // Libp2p contains configs for libp2p
 type Libp2p struct {
 	ListenAddresses []string
-	BootstrapPeers  []string
-	ProtectedPeers  []string
+	// BootstrapPeers replace the builtin bootstrap list when set
+	BootstrapPeers []string
+	// BootstrapNetwork selects the builtin bootstrap list of a network, e.g.
+	// "testnet", instead of the list of the network the node is built for
+	BootstrapNetwork string
+	ProtectedPeers   []string
 
+	// The connection manager trims connections down to ConnMgrLow once
+	// there are more than ConnMgrHigh. Connections younger than ConnMgrGrace
+	// and protected peers are kept.
 	ConnMgrLow   uint
 	ConnMgrHigh  uint
 	ConnMgrGrace Duration
+
+	// AnnounceAddresses replace the advertised listen addresses when set,
+	// e.g. with the public address of a NAT forwarding to this node
+	AnnounceAddresses   []string
+	NoAnnounceAddresses []string
+
+	// EnableNATPortMap sets up port mappings with UPnP / NAT-PMP routers
+	EnableNATPortMap bool
+	// EnableAutoNAT detects whether the node is publicly reachable by asking
+	// peers to dial it back
+	EnableAutoNAT bool
+
+	// EnableRelay allows connecting through circuit relays
+	EnableRelay bool
+	// EnableRelayHop makes the node relay connections for other peers
+	EnableRelayHop bool
+	// EnableAutoRelay advertises addresses through discovered relays when
+	// the node isn't publicly reachable. Requires EnableRelay.
+	EnableAutoRelay bool
 }
 
 // // Full Node
@@ -53,19 +163,181 @@ type Metrics struct {
 	PubsubTracing bool
 }
 
+// Splitstore configures the hot/cold chain blockstore
+type Splitstore struct {
+	Enable bool
+	// ColdStoreType is "move" to keep compacted data in the cold store, or
+	// "discard" to drop old state entirely. Block headers and messages are
+	// kept in the cold store in both modes.
+	ColdStoreType string
+	// HotStoreRetention is the number of epochs of state kept in the hot store
+	HotStoreRetention uint64
+	// CompactionInterval is the number of epochs between compactions
+	CompactionInterval uint64
+}
+
+// Pruning configures periodic removal of old state trees
+type Pruning struct {
+	Enable bool
+	// RetainEpochs is the number of recent epochs to keep state trees for
+	RetainEpochs uint64
+	// Interval is the time between pruning runs, invalid values fall back
+	// to the default
+	Interval Duration
+}
+
+// Pubsub configures scoring of the peers forwarding gossiped blocks and
+// messages. Peers are penalized for each message failing validation.
+type Pubsub struct {
+	InvalidBlockPenalty   float64
+	InvalidMessagePenalty float64
+	// ValidReward is added to the score for each valid message, up to MaxScore
+	ValidReward float64
+	MaxScore    float64
+
+	// ScoreDecay is the fraction of the score kept after each DecayInterval
+	ScoreDecay    float64
+	DecayInterval Duration
+
+	// Messages from peers scoring below GraylistThreshold are dropped without
+	// validation, peers below BlacklistThreshold are disconnected from pubsub
+	GraylistThreshold  float64
+	BlacklistThreshold float64
+}
+
+// Slasher configures reporting of consensus faults seen in gossiped blocks
+type Slasher struct {
+	Enable bool
+	// ReporterAddress sends the fault reports and receives the slashing
+	// rewards, the default wallet address is used when empty
+	ReporterAddress string
+}
+
+// Client configures where the deal client keeps payload data
+type Client struct {
+	// UseIpfs stores client data in the blockstore of a local IPFS node
+	// instead of the lotus repo
+	UseIpfs bool
+	// IpfsAPI is the multiaddr of the IPFS node API
+	IpfsAPI string
+}
+
+// Sync contains configs for the chain syncer
+type Sync struct {
+	// Checkpoint lists the block CIDs of a trusted tipset. Chains not
+	// containing it are rejected, and tipsets before it aren't validated or
+	// executed. Its parent state has to be imported with a snapshot.
+	Checkpoint []string
+}
+
+// Mpool contains limits applied to messages received from the network
+type Mpool struct {
+	MaxPendingPerActor int
+	MinGasPrice        uint64
+
+	SizeLimitHigh int
+	SizeLimitLow  int
+}
+
 // // Storage Miner
 
 type SectorBuilder struct {
-	Path        string // TODO: remove // FORK (-ish)
-	Storage     []fs.PathConfig
+	Path    string // TODO: remove // FORK (-ish)
+	Storage []fs.PathConfig
+	// WorkerCount is the number of local sectorbuilder workers, shared evenly
+	// by the main miner actor and the additional Actors
 	WorkerCount uint
 
 	DisableLocalPreCommit bool
 	DisableLocalCommit    bool
+
+	// ParameterCache is the proof parameter directory. Workers should use the
+	// same directory, set with FIL_PROOFS_PARAMETER_CACHE. When empty, the
+	// directory from the environment is used.
+	ParameterCache string
+}
+
+// Staging configures where incoming deal data is kept until it's added to a
+// sector
+type Staging struct {
+	// Path is the directory of a separate datastore for staged data. When
+	// empty, staged data is kept in the repo datastore.
+	Path string
+	// MaxSize is the number of staged bytes above which new deal data is
+	// rejected, 0 disables the quota
+	MaxSize uint64
+	// MaxAge is how long data of deals which didn't make it into a sector is
+	// kept after it was last transferred, 0 keeps it forever. Data of deals
+	// still waiting to be added to a sector is always kept.
+	MaxAge Duration
+}
+
+// Transfers limits deal data transfers, so they can't use up the bandwidth
+// needed to propagate blocks and PoSt messages
+type Transfers struct {
+	// IngressLimit and EgressLimit cap the rate deal and retrieval data is
+	// received and sent at, in bytes per second. 0 disables the limit.
+	IngressLimit uint64
+	EgressLimit  uint64
+
+	// MaxStorageTransfers is the number of peers storage deal data is
+	// received from at once, 0 for no limit. Graphsync keeps one stream per
+	// peer open for all its transfers, so this limits peers, not deals.
+	// Streams of other peers are reset when no slot frees up in a minute.
+	// Only streams opened by remote peers are counted, the ones the node
+	// opens itself, e.g. to send graphsync requests, are rate limited but
+	// don't take a slot.
+	MaxStorageTransfers int
+	// MaxRetrievals is the number of retrievals served at once, 0 for no
+	// limit
+	MaxRetrievals int
+}
+
+// Proofs selects the devices proofs are generated on: "cpu", "gpu" to let the
+// proofs library pick a GPU, or "gpu:<index>" for a specific GPU. Empty values
+// keep the setting from the environment.
+type Proofs struct {
+	Device string
+
+	// PoStDevice overrides Device for PoSt, which runs in the miner process
+	PoStDevice string
+	// SealDevice overrides Device for sealing on workers which don't select
+	// a device themselves. The miner process can only use one device, so
+	// when it differs from the PoSt device, local commit has to be disabled.
+	SealDevice string
+
+	// FallbackPoStTimeout and ElectionPoStTimeout limit how long the miner
+	// waits for the proofs library, so a hung device doesn't block PoSt
+	// scheduling. When 0, fallback PoSts time out when they can no longer
+	// be submitted in time and election PoSts don't time out.
+	FallbackPoStTimeout Duration
+	ElectionPoStTimeout Duration
+}
+
+func (p Proofs) PoSt() string {
+	if p.PoStDevice != "" {
+		return p.PoStDevice
+	}
+	return p.Device
+}
+
+func (p Proofs) Seal() string {
+	if p.SealDevice != "" {
+		return p.SealDevice
+	}
+	return p.Device
 }
 
 func defCommon() Common {
 	return Common{
+		Tracing: Tracing{
+			SampleRate: 1,
+		},
+		Datastore: Datastore{
+			GCInterval:       Duration(15 * time.Minute),
+			GCDiscardRatio:   0.2,
+			CompactL0OnClose: true,
+		},
 		API: API{
 			ListenAddress: "/ip4/127.0.0.1/tcp/1234/http",
 			Timeout:       Duration(30 * time.Second),
@@ -79,6 +351,9 @@ func defCommon() Common {
 			ConnMgrLow:   150,
 			ConnMgrHigh:  180,
 			ConnMgrGrace: Duration(20 * time.Second),
+
+			EnableNATPortMap: true,
+			EnableAutoNAT:    true,
 		},
 	}
 
@@ -88,6 +363,37 @@ func defCommon() Common {
 func DefaultFullNode() *FullNode {
 	return &FullNode{
 		Common: defCommon(),
+		Mpool: Mpool{
+			MaxPendingPerActor: 1000,
+			MinGasPrice:        0,
+
+			SizeLimitHigh: 30000,
+			SizeLimitLow:  20000,
+		},
+		Pubsub: Pubsub{
+			InvalidBlockPenalty:   100,
+			InvalidMessagePenalty: 10,
+			ValidReward:           1,
+			MaxScore:              100,
+
+			ScoreDecay:    0.9,
+			DecayInterval: Duration(time.Minute),
+
+			GraylistThreshold:  -500,
+			BlacklistThreshold: -2000,
+		},
+		Splitstore: Splitstore{
+			ColdStoreType:      "move",
+			HotStoreRetention:  2000,
+			CompactionInterval: 500,
+		},
+		Pruning: Pruning{
+			RetainEpochs: 2000,
+			Interval:     Duration(time.Hour),
+		},
+		Client: Client{
+			IpfsAPI: "/ip4/127.0.0.1/tcp/5001",
+		},
 	}
 }
 
@@ -98,6 +404,9 @@ func DefaultStorageMiner() *StorageMiner {
 		SectorBuilder: SectorBuilder{
 			WorkerCount: 5,
 		},
+		Staging: Staging{
+			MaxAge: Duration(24 * time.Hour),
+		},
 	}
 	cfg.Common.API.ListenAddress = "/ip4/127.0.0.1/tcp/2345/http"
 	return cfg
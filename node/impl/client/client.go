@@ -1,7 +1,6 @@
 package client
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"io"
@@ -32,6 +31,7 @@ import (
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/markets/retrievaladapter"
 	"github.com/filecoin-project/lotus/markets/utils"
 	"github.com/filecoin-project/lotus/node/impl/full"
 	"github.com/filecoin-project/lotus/node/impl/paych"
@@ -49,6 +49,7 @@ type API struct {
 	SMDealClient storagemarket.StorageClient
 	RetDiscovery retrievalmarket.PeerResolver
 	Retrieval    retrievalmarket.RetrievalClient
+	Retrievals   *retrievaladapter.ClientTracker
 	Chain        *store.ChainStore
 
 	LocalDAG   dtypes.ClientDAG
@@ -134,10 +135,8 @@ func (a *API) ClientGetDealInfo(ctx context.Context, d cid.Cid) (*api.DealInfo,
 }
 
 func (a *API) ClientHasLocal(ctx context.Context, root cid.Cid) (bool, error) {
-	// TODO: check if we have the ENTIRE dag
-
 	offExch := merkledag.NewDAGService(blockservice.New(a.Blockstore, offline.Exchange(a.Blockstore)))
-	_, err := offExch.Get(ctx, root)
+	err := merkledag.Walk(ctx, merkledag.GetLinksWithDAG(offExch), root, cid.NewSet().Visit)
 	if err == ipld.ErrNotFound {
 		return false, nil
 	}
@@ -239,7 +238,7 @@ func (a *API) ClientImportLocal(ctx context.Context, f io.Reader) (cid.Cid, erro
 
 func (a *API) ClientListImports(ctx context.Context) ([]api.Import, error) {
 	if a.Filestore == nil {
-		return nil, errors.New("listing imports is not supported with in-memory dag yet")
+		return nil, errors.New("listing imports is only supported with the lotus client filestore")
 	}
 	next, err := filestore.ListAll(a.Filestore, false)
 	if err != nil {
@@ -267,6 +266,19 @@ func (a *API) ClientListImports(ctx context.Context) ([]api.Import, error) {
 }
 
 func (a *API) ClientRetrieve(ctx context.Context, order api.RetrievalOrder, path string) error {
+	// Retrieved blocks are kept in the client blockstore, so data from a
+	// retrieval which completed can be written out again without paying.
+	// Interrupted transfers start over: the retrieval protocol has no way to
+	// ask the provider to continue after the last block received, it always
+	// sends the whole DAG.
+	has, err := a.ClientHasLocal(ctx, order.Root)
+	if err != nil {
+		return xerrors.Errorf("checking local data: %w", err)
+	}
+	if has {
+		return a.exportUnixfs(ctx, order.Root, path)
+	}
+
 	if order.MinerPeerID == "" {
 		pid, err := a.StateMinerPeerID(ctx, order.Miner, nil)
 		if err != nil {
@@ -276,20 +288,7 @@ func (a *API) ClientRetrieve(ctx context.Context, order api.RetrievalOrder, path
 		order.MinerPeerID = pid
 	}
 
-	retrievalResult := make(chan error, 1)
-
-	unsubscribe := a.Retrieval.SubscribeToEvents(func(event retrievalmarket.ClientEvent, state retrievalmarket.ClientDealState) {
-		if bytes.Equal(state.PieceCID, order.Root.Bytes()) {
-			switch event {
-			case retrievalmarket.ClientEventError:
-				retrievalResult <- xerrors.New("Retrieval Error")
-			case retrievalmarket.ClientEventComplete:
-				retrievalResult <- nil
-			}
-		}
-	})
-
-	a.Retrieval.Retrieve(
+	id := a.Retrieval.Retrieve(
 		ctx,
 		order.Root.Bytes(),
 		retrievalmarket.NewParamsV0(types.BigDiv(order.Total, types.NewInt(order.Size)).Int, 0, 0),
@@ -297,18 +296,23 @@ func (a *API) ClientRetrieve(ctx context.Context, order api.RetrievalOrder, path
 		order.MinerPeerID,
 		order.Client,
 		order.Miner)
-	select {
-	case <-ctx.Done():
-		return xerrors.New("Retrieval Timed Out")
-	case err := <-retrievalResult:
-		if err != nil {
-			return xerrors.Errorf("RetrieveUnixfs: %w", err)
-		}
+	a.Retrievals.Started(id, order, path)
+
+	if err := a.Retrievals.Wait(ctx, id); err != nil {
+		return xerrors.Errorf("RetrieveUnixfs: %w", err)
 	}
 
-	unsubscribe()
+	err = a.exportUnixfs(ctx, order.Root, path)
+	a.Retrievals.Exported(id, err)
+	return err
+}
+
+func (a *API) ClientListRetrievals(ctx context.Context) ([]api.RetrievalInfo, error) {
+	return a.Retrievals.List(), nil
+}
 
-	nd, err := a.LocalDAG.Get(ctx, order.Root)
+func (a *API) exportUnixfs(ctx context.Context, root cid.Cid, path string) error {
+	nd, err := a.LocalDAG.Get(ctx, root)
 	if err != nil {
 		return xerrors.Errorf("ClientRetrieve: %w", err)
 	}
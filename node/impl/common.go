@@ -2,20 +2,32 @@ package impl
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
 
 	logging "github.com/ipfs/go-log/v2"
 
 	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	autonat "github.com/libp2p/go-libp2p-autonat"
 	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/metrics"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
 	ma "github.com/multiformats/go-multiaddr"
 	"go.uber.org/fx"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apistruct"
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
+	"github.com/filecoin-project/lotus/node/repo"
+	"github.com/filecoin-project/lotus/peermgr"
 )
 
 type CommonAPI struct {
@@ -23,29 +35,113 @@ type CommonAPI struct {
 
 	APISecret *dtypes.APIAlg
 	Host      host.Host
+	DS        dtypes.MetadataDS
+	Repo      repo.LockedRepo
+
+	Pinned *peermgr.PinnedPeers `optional:"true"`
+	// AutoNAT is set when reachability detection is enabled
+	AutoNAT autonat.AutoNAT `optional:"true"`
+
+	Reporter metrics.Reporter `optional:"true"`
 }
 
 type jwtPayload struct {
 	Allow []string
+	// ID is set on tokens minted with AuthNew, which can be revoked. Tokens
+	// without it (like the one created with the repo) are always valid.
+	ID string `json:",omitempty"`
 }
 
+var authTokenPrefix = datastore.NewKey("/auth/tokens")
+
 func (a *CommonAPI) AuthVerify(ctx context.Context, token string) ([]api.Permission, error) {
 	var payload jwtPayload
 	if _, err := jwt.Verify([]byte(token), (*jwt.HMACSHA)(a.APISecret), &payload); err != nil {
 		return nil, xerrors.Errorf("JWT Verification failed: %w", err)
 	}
 
+	if payload.ID != "" {
+		has, err := a.DS.Has(authTokenPrefix.ChildString(payload.ID))
+		if err != nil {
+			return nil, xerrors.Errorf("checking token %s: %w", payload.ID, err)
+		}
+		if !has {
+			return nil, xerrors.Errorf("token %s was revoked", payload.ID)
+		}
+	}
+
 	return payload.Allow, nil
 }
 
 func (a *CommonAPI) AuthNew(ctx context.Context, perms []api.Permission) ([]byte, error) {
+	for _, perm := range perms {
+		if !apistruct.ValidPermission(perm) {
+			return nil, xerrors.Errorf("unknown permission '%s'", perm)
+		}
+	}
+
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, xerrors.Errorf("generating token id: %w", err)
+	}
+
 	p := jwtPayload{
-		Allow: perms, // TODO: consider checking validity
+		Allow: perms,
+		ID:    hex.EncodeToString(id[:]),
+	}
+
+	tok, err := json.Marshal(&api.AuthToken{
+		ID:      p.ID,
+		Allow:   perms,
+		Created: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.DS.Put(authTokenPrefix.ChildString(p.ID), tok); err != nil {
+		return nil, xerrors.Errorf("storing token info: %w", err)
 	}
 
 	return jwt.Sign(&p, (*jwt.HMACSHA)(a.APISecret))
 }
 
+func (a *CommonAPI) AuthList(ctx context.Context) ([]api.AuthToken, error) {
+	res, err := a.DS.Query(dsq.Query{Prefix: authTokenPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	out := []api.AuthToken{}
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+
+		var tok api.AuthToken
+		if err := json.Unmarshal(r.Value, &tok); err != nil {
+			return nil, xerrors.Errorf("unmarshaling token info: %w", err)
+		}
+		out = append(out, tok)
+	}
+
+	return out, nil
+}
+
+func (a *CommonAPI) AuthRevoke(ctx context.Context, id string) error {
+	k := authTokenPrefix.ChildString(id)
+	has, err := a.DS.Has(k)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return xerrors.Errorf("token %s not found", id)
+	}
+
+	return a.DS.Delete(k)
+}
+
 func (a *CommonAPI) NetConnectedness(ctx context.Context, pid peer.ID) (network.Connectedness, error) {
 	return a.Host.Network().Connectedness(pid), nil
 }
@@ -81,6 +177,108 @@ func (a *CommonAPI) NetDisconnect(ctx context.Context, p peer.ID) error {
 	return a.Host.Network().ClosePeer(p)
 }
 
+func (a *CommonAPI) pinned() (*peermgr.PinnedPeers, error) {
+	if a.Pinned == nil {
+		return nil, xerrors.New("peer pinning is only available on online nodes")
+	}
+	return a.Pinned, nil
+}
+
+func (a *CommonAPI) NetPeerAdd(ctx context.Context, p peer.AddrInfo) error {
+	pp, err := a.pinned()
+	if err != nil {
+		return err
+	}
+	return pp.Add(ctx, p)
+}
+
+func (a *CommonAPI) NetPeerRemove(ctx context.Context, p peer.ID) error {
+	pp, err := a.pinned()
+	if err != nil {
+		return err
+	}
+	return pp.Remove(p)
+}
+
+func (a *CommonAPI) NetPeerProtect(ctx context.Context, p peer.ID, protect bool) error {
+	pp, err := a.pinned()
+	if err != nil {
+		return err
+	}
+	return pp.Protect(p, protect)
+}
+
+func (a *CommonAPI) NetPeerList(context.Context) ([]api.PinnedPeer, error) {
+	pp, err := a.pinned()
+	if err != nil {
+		return nil, err
+	}
+	return pp.List(), nil
+}
+
+func (a *CommonAPI) NetReachability(context.Context) (api.NetReachability, error) {
+	out := api.NetReachability{
+		Status: api.ReachabilityUnknown,
+		Advertised: peer.AddrInfo{
+			ID:    a.Host.ID(),
+			Addrs: a.Host.Addrs(),
+		},
+	}
+
+	if a.AutoNAT == nil {
+		return out, nil
+	}
+
+	switch a.AutoNAT.Status() {
+	case autonat.NATStatusPublic:
+		out.Status = api.ReachabilityPublic
+		pa, err := a.AutoNAT.PublicAddr()
+		if err == nil {
+			out.PublicAddr = pa.String()
+		}
+	case autonat.NATStatusPrivate:
+		out.Status = api.ReachabilityPrivate
+	}
+
+	return out, nil
+}
+
+func (a *CommonAPI) reporter() (metrics.Reporter, error) {
+	if a.Reporter == nil {
+		return nil, xerrors.New("bandwidth stats are only available on online nodes")
+	}
+	return a.Reporter, nil
+}
+
+func (a *CommonAPI) NetBandwidthStats(ctx context.Context) (metrics.Stats, error) {
+	r, err := a.reporter()
+	if err != nil {
+		return metrics.Stats{}, err
+	}
+	return r.GetBandwidthTotals(), nil
+}
+
+func (a *CommonAPI) NetBandwidthStatsByPeer(ctx context.Context) (map[string]metrics.Stats, error) {
+	r, err := a.reporter()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]metrics.Stats)
+	for p, s := range r.GetBandwidthByPeer() {
+		out[p.String()] = s
+	}
+	return out, nil
+}
+
+func (a *CommonAPI) NetBandwidthStatsByProtocol(ctx context.Context) (map[protocol.ID]metrics.Stats, error) {
+	r, err := a.reporter()
+	if err != nil {
+		return nil, err
+	}
+	return r.GetBandwidthByProtocol(), nil
+}
+
 func (a *CommonAPI) ID(context.Context) (peer.ID, error) {
 	return a.Host.ID(), nil
 }
@@ -102,4 +300,8 @@ func (a *CommonAPI) LogSetLevel(ctx context.Context, subsystem, level string) er
 	return logging.SetLogLevel(subsystem, level)
 }
 
+func (a *CommonAPI) DatastoreGC(context.Context) error {
+	return a.Repo.DatastoreGC()
+}
+
 var _ api.Common = &CommonAPI{}
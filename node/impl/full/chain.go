@@ -19,13 +19,13 @@ import (
 	"github.com/ipfs/go-path"
 	"github.com/ipfs/go-path/resolver"
 	mh "github.com/multiformats/go-multihash"
-	"github.com/prometheus/common/log"
 	"go.uber.org/fx"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-address"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
 )
@@ -35,13 +35,22 @@ type ChainAPI struct {
 
 	WalletAPI
 
-	Chain *store.ChainStore
+	Chain        *store.ChainStore
+	StateManager *stmgr.StateManager
 }
 
 func (a *ChainAPI) ChainNotify(ctx context.Context) (<-chan []*store.HeadChange, error) {
 	return a.Chain.SubHeadChanges(ctx), nil
 }
 
+func (a *ChainAPI) ChainNotifyReorgs(ctx context.Context) (<-chan *store.ReorgChange, error) {
+	return a.Chain.SubReorgs(ctx), nil
+}
+
+func (a *ChainAPI) ChainNotifyConfidence(ctx context.Context, confidence uint64) (<-chan []*store.HeadChange, error) {
+	return a.Chain.SubHeadChangesConfidence(ctx, confidence), nil
+}
+
 func (a *ChainAPI) ChainHead(context.Context) (*types.TipSet, error) {
 	return a.Chain.GetHeaviestTipSet(), nil
 }
@@ -320,12 +329,12 @@ func (a *ChainAPI) ChainGetMessage(ctx context.Context, mc cid.Cid) (*types.Mess
 	return cm.VMMessage(), nil
 }
 
-func (a *ChainAPI) ChainExport(ctx context.Context, ts *types.TipSet) (<-chan []byte, error) {
+func (a *ChainAPI) ChainExport(ctx context.Context, nroots uint64, ts *types.TipSet) (<-chan []byte, error) {
 	r, w := io.Pipe()
 	out := make(chan []byte)
 	go func() {
 		defer w.Close()
-		if err := a.Chain.Export(ctx, ts, w); err != nil {
+		if err := a.Chain.Export(ctx, ts, nroots, w); err != nil {
 			log.Errorf("chain export call failed: %s", err)
 			return
 		}
@@ -350,3 +359,48 @@ func (a *ChainAPI) ChainExport(ctx context.Context, ts *types.TipSet) (<-chan []
 
 	return out, nil
 }
+
+func (a *ChainAPI) ChainPrune(ctx context.Context, retain uint64) (*api.PruneResult, error) {
+	res, err := a.StateManager.PruneState(ctx, retain)
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.PruneResult{
+		Roots:  res.Roots,
+		Blocks: res.Blocks,
+		Bytes:  res.Bytes,
+	}, nil
+}
+
+func (a *ChainAPI) ChainGasTrace(ctx context.Context, msg cid.Cid) (*api.GasProfile, error) {
+	ts, _, err := a.StateManager.SearchForMessage(ctx, msg, stmgr.LookbackNoLimit)
+	if err != nil {
+		return nil, xerrors.Errorf("searching for message: %w", err)
+	}
+	if ts == nil {
+		return nil, xerrors.Errorf("message %s not found on chain", msg)
+	}
+
+	// the message was executed on top of the tipset it was included in
+	pts, err := a.Chain.LoadTipSet(ts.Parents())
+	if err != nil {
+		return nil, xerrors.Errorf("loading inclusion tipset: %w", err)
+	}
+
+	m, r, err := a.StateManager.Replay(ctx, pts, msg)
+	if err != nil {
+		return nil, xerrors.Errorf("replaying message: %w", err)
+	}
+	if m == nil || r.ExecutionTrace == nil {
+		return nil, xerrors.Errorf("message %s wasn't executed in tipset %s", msg, pts.Key())
+	}
+
+	return &api.GasProfile{
+		MsgCid:  msg,
+		TipSet:  pts.Key(),
+		Receipt: &r.MessageReceipt,
+		ByClass: r.ExecutionTrace.GasBreakdown(),
+		Trace:   r.ExecutionTrace,
+	}, nil
+}
@@ -2,6 +2,7 @@ package full
 
 import (
 	"context"
+	"sort"
 
 	"github.com/ipfs/go-cid"
 	"go.uber.org/fx"
@@ -78,15 +79,130 @@ func (a *MpoolAPI) MpoolPending(ctx context.Context, ts *types.TipSet) ([]*types
 	}
 }
 
+func (a *MpoolAPI) MpoolPendingFilter(ctx context.Context, f *api.MpoolFilter, ts *types.TipSet) ([]*types.SignedMessage, error) {
+	pending, err := a.MpoolPending(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*types.SignedMessage, 0, len(pending))
+	for _, m := range pending {
+		if f.Matches(&m.Message) {
+			out = append(out, m)
+		}
+	}
+
+	return out, nil
+}
+
+func (a *MpoolAPI) MpoolStats(ctx context.Context, ts *types.TipSet) (*api.MpoolStats, error) {
+	pending, err := a.MpoolPending(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	return mpoolStats(pending, func(addr address.Address) (*types.Actor, error) {
+		return a.StateManager.GetActor(addr, ts)
+	}), nil
+}
+
+// mpoolStats computes per-sender statistics of pending messages. Senders
+// whose actor can't be loaded are still reported, with the error set and
+// their messages left unclassified.
+func mpoolStats(pending []*types.SignedMessage, getActor func(address.Address) (*types.Actor, error)) *api.MpoolStats {
+	bySender := map[address.Address]map[uint64]*types.SignedMessage{}
+	for _, m := range pending {
+		msgs, ok := bySender[m.Message.From]
+		if !ok {
+			msgs = map[uint64]*types.SignedMessage{}
+			bySender[m.Message.From] = msgs
+		}
+		msgs[m.Message.Nonce] = m
+	}
+
+	out := &api.MpoolStats{
+		Senders: make([]api.MpoolSenderStats, 0, len(bySender)),
+	}
+
+	for from, msgs := range bySender {
+		ss := api.MpoolSenderStats{
+			Address:  from,
+			GasLimit: types.NewInt(0),
+		}
+
+		act, err := getActor(from)
+		if err != nil {
+			ss.Error = xerrors.Errorf("getting actor: %w", err).Error()
+		} else {
+			ss.StateNonce = act.Nonce
+		}
+
+		next := ss.StateNonce
+		for {
+			if _, ok := msgs[next]; !ok {
+				break
+			}
+			next++
+		}
+
+		sum := types.NewInt(0)
+		for _, m := range msgs {
+			ss.Count++
+			ss.Size += m.Size()
+
+			switch {
+			case act == nil:
+			case m.Message.Nonce < act.Nonce:
+				ss.Past++
+			case m.Message.Nonce < next:
+				ss.Ready++
+			default:
+				ss.Future++
+			}
+
+			gp := m.Message.GasPrice
+			if ss.MinGasPrice.Nil() || gp.LessThan(ss.MinGasPrice) {
+				ss.MinGasPrice = gp
+			}
+			if ss.MaxGasPrice.Nil() || gp.GreaterThan(ss.MaxGasPrice) {
+				ss.MaxGasPrice = gp
+			}
+			sum = types.BigAdd(sum, gp)
+			ss.GasLimit = types.BigAdd(ss.GasLimit, m.Message.GasLimit)
+		}
+		ss.AvgGasPrice = types.BigDiv(sum, types.NewInt(uint64(ss.Count)))
+
+		out.Count += ss.Count
+		out.Size += ss.Size
+		out.Senders = append(out.Senders, ss)
+	}
+
+	sort.Slice(out.Senders, func(i, j int) bool {
+		return out.Senders[i].Count > out.Senders[j].Count
+	})
+
+	return out
+}
+
 func (a *MpoolAPI) MpoolPush(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, error) {
 	return a.Mpool.Push(smsg)
 }
 
-func (a *MpoolAPI) MpoolPushMessage(ctx context.Context, msg *types.Message) (*types.SignedMessage, error) {
+func (a *MpoolAPI) MpoolPushMessage(ctx context.Context, msg *types.Message, spec *api.MessageSendSpec) (*types.SignedMessage, error) {
 	if msg.Nonce != 0 {
 		return nil, xerrors.Errorf("MpoolPushMessage expects message nonce to be 0, was %d", msg.Nonce)
 	}
 
+	// work on a copy, so a message can be pushed again after a failed push
+	cp := *msg
+	msg = &cp
+
+	if err := applySendSpec(msg, spec, a.estimateGasPrice); err != nil {
+		return nil, xerrors.Errorf("mpool push: %w", err)
+	}
+
+	// PushWithNonce holds the mpool lock from getting the nonce until the
+	// message is added
 	return a.Mpool.PushWithNonce(msg.From, func(nonce uint64) (*types.SignedMessage, error) {
 		msg.Nonce = nonce
 
@@ -103,6 +219,61 @@ func (a *MpoolAPI) MpoolPushMessage(ctx context.Context, msg *types.Message) (*t
 	})
 }
 
+// applySendSpec prices msg according to spec and checks it against the
+// spec's fee limit
+func applySendSpec(msg *types.Message, spec *api.MessageSendSpec, estimate func(premium uint64) (types.BigInt, error)) error {
+	if spec == nil {
+		return nil
+	}
+
+	if msg.GasPrice.Nil() || msg.GasPrice.IsZero() {
+		gp, err := estimate(spec.GasPremium)
+		if err != nil {
+			return xerrors.Errorf("estimating gas price: %w", err)
+		}
+		msg.GasPrice = gp
+	}
+
+	if !spec.MaxFee.Nil() && !spec.MaxFee.IsZero() {
+		fee := types.BigMul(msg.GasPrice, msg.GasLimit)
+		if fee.GreaterThan(spec.MaxFee) {
+			return xerrors.Errorf("message fee %s (gas price %s * limit %s) exceeds max fee %s", fee, msg.GasPrice, msg.GasLimit, spec.MaxFee)
+		}
+	}
+
+	return nil
+}
+
+func (a *MpoolAPI) estimateGasPrice(premium uint64) (types.BigInt, error) {
+	pending, ts := a.Mpool.Pending()
+	if ts == nil {
+		return types.EmptyInt, xerrors.New("message pool has no head tipset yet")
+	}
+
+	return gasPriceEstimate(pending, premium), nil
+}
+
+// gasPriceEstimate returns the average gas price of pending messages raised
+// by premium percent. Messages without a gas price are left out, they would
+// pull the estimate towards 0.
+func gasPriceEstimate(pending []*types.SignedMessage, premium uint64) types.BigInt {
+	sum := types.NewInt(0)
+	var count uint64
+	for _, m := range pending {
+		if m.Message.GasPrice.Nil() || m.Message.GasPrice.IsZero() {
+			continue
+		}
+		sum = types.BigAdd(sum, m.Message.GasPrice)
+		count++
+	}
+	if count == 0 {
+		return types.NewInt(0)
+	}
+	avg := types.BigDiv(sum, types.NewInt(count))
+
+	return types.BigDiv(types.BigMul(avg, types.NewInt(100+premium)), types.NewInt(100))
+}
+
 func (a *MpoolAPI) MpoolGetNonce(ctx context.Context, addr address.Address) (uint64, error) {
 	return a.Mpool.GetNonce(addr)
 }
@@ -110,3 +281,37 @@ func (a *MpoolAPI) MpoolGetNonce(ctx context.Context, addr address.Address) (uin
 func (a *MpoolAPI) MpoolSub(ctx context.Context) (<-chan api.MpoolUpdate, error) {
 	return a.Mpool.Updates(ctx)
 }
+
+func (a *MpoolAPI) MpoolSubFilter(ctx context.Context, f *api.MpoolFilter) (<-chan api.MpoolUpdate, error) {
+	updates, err := a.Mpool.Updates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan api.MpoolUpdate, 20)
+	go func() {
+		defer close(out)
+
+		for {
+			select {
+			case u, ok := <-updates:
+				if !ok {
+					return
+				}
+				if !f.Matches(&u.Message.Message) {
+					continue
+				}
+
+				select {
+				case out <- u:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
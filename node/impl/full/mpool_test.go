@@ -0,0 +1,120 @@
+package full
+
+import (
+	"testing"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestMpoolStats(t *testing.T) {
+	known, err := address.NewIDAddress(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing, err := address.NewIDAddress(1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := func(from address.Address, nonce, gasPrice uint64) *types.SignedMessage {
+		return &types.SignedMessage{
+			Message: types.Message{
+				To:       known,
+				From:     from,
+				Nonce:    nonce,
+				Value:    types.NewInt(0),
+				GasPrice: types.NewInt(gasPrice),
+				GasLimit: types.NewInt(10),
+			},
+		}
+	}
+
+	pending := []*types.SignedMessage{
+		msg(known, 1, 2),
+		msg(known, 2, 4),
+		msg(known, 3, 6),
+		msg(known, 5, 8),
+		msg(missing, 0, 1),
+	}
+
+	stats := mpoolStats(pending, func(addr address.Address) (*types.Actor, error) {
+		if addr == missing {
+			return nil, xerrors.New("actor not found")
+		}
+		return &types.Actor{Nonce: 2}, nil
+	})
+
+	if stats.Count != 5 || len(stats.Senders) != 2 {
+		t.Fatalf("expected 5 messages from 2 senders, got %d from %d", stats.Count, len(stats.Senders))
+	}
+
+	ss := stats.Senders[0]
+	if ss.Address != known || ss.Error != "" {
+		t.Fatalf("unexpected first sender %s (error %q)", ss.Address, ss.Error)
+	}
+	if ss.Past != 1 || ss.Ready != 2 || ss.Future != 1 {
+		t.Errorf("expected 1 past, 2 ready and 1 future message, got %d, %d and %d", ss.Past, ss.Ready, ss.Future)
+	}
+	if !ss.AvgGasPrice.Equals(types.NewInt(5)) || !ss.MinGasPrice.Equals(types.NewInt(2)) || !ss.MaxGasPrice.Equals(types.NewInt(8)) {
+		t.Errorf("bad gas prices: min %s, avg %s, max %s", ss.MinGasPrice, ss.AvgGasPrice, ss.MaxGasPrice)
+	}
+
+	ss = stats.Senders[1]
+	if ss.Address != missing || ss.Error == "" {
+		t.Fatalf("expected the sender without an actor to be reported with an error")
+	}
+	if ss.Count != 1 || ss.Past+ss.Ready+ss.Future != 0 {
+		t.Errorf("expected 1 unclassified message, got count %d", ss.Count)
+	}
+}
+
+func TestGasPriceEstimate(t *testing.T) {
+	msg := func(gasPrice uint64) *types.SignedMessage {
+		return &types.SignedMessage{Message: types.Message{GasPrice: types.NewInt(gasPrice)}}
+	}
+
+	if gp := gasPriceEstimate(nil, 10); !gp.IsZero() {
+		t.Errorf("expected 0 without pending messages, got %s", gp)
+	}
+
+	pending := []*types.SignedMessage{msg(0), msg(100), msg(0), msg(300)}
+	if gp := gasPriceEstimate(pending, 10); !gp.Equals(types.NewInt(220)) {
+		t.Errorf("expected 220, got %s", gp)
+	}
+}
+
+func TestApplySendSpec(t *testing.T) {
+	estimate := func(premium uint64) (types.BigInt, error) {
+		return types.NewInt(100 + premium), nil
+	}
+
+	msg := &types.Message{GasPrice: types.NewInt(0), GasLimit: types.NewInt(10)}
+	if err := applySendSpec(msg, &api.MessageSendSpec{GasPremium: 5, MaxFee: types.NewInt(1050)}, estimate); err != nil {
+		t.Fatal(err)
+	}
+	if !msg.GasPrice.Equals(types.NewInt(105)) {
+		t.Errorf("expected estimated gas price 105, got %s", msg.GasPrice)
+	}
+
+	msg = &types.Message{GasPrice: types.NewInt(0), GasLimit: types.NewInt(10)}
+	if err := applySendSpec(msg, &api.MessageSendSpec{GasPremium: 5, MaxFee: types.NewInt(1049)}, estimate); err == nil {
+		t.Error("expected the estimated fee to exceed the max fee")
+	}
+
+	msg = &types.Message{GasPrice: types.NewInt(200), GasLimit: types.NewInt(10)}
+	if err := applySendSpec(msg, &api.MessageSendSpec{MaxFee: types.NewInt(1000)}, estimate); err == nil {
+		t.Error("expected the set fee to exceed the max fee")
+	}
+
+	msg = &types.Message{GasPrice: types.NewInt(0), GasLimit: types.NewInt(10)}
+	failing := func(uint64) (types.BigInt, error) {
+		return types.EmptyInt, xerrors.New("no head")
+	}
+	if err := applySendSpec(msg, &api.MessageSendSpec{}, failing); err == nil {
+		t.Error("expected the estimate error to be returned")
+	}
+}
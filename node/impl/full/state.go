@@ -3,13 +3,17 @@ package full
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/filecoin-project/go-amt-ipld"
 
 	cid "github.com/ipfs/go-cid"
 	"github.com/ipfs/go-hamt-ipld"
+	logging "github.com/ipfs/go-log/v2"
 	"github.com/libp2p/go-libp2p-core/peer"
 	cbg "github.com/whyrusleeping/cbor-gen"
 	"go.uber.org/fx"
@@ -28,6 +32,8 @@ import (
 	"github.com/filecoin-project/lotus/lib/bufbstore"
 )
 
+var log = logging.Logger("fullnode")
+
 type StateAPI struct {
 	fx.In
 
@@ -114,7 +120,7 @@ func (a *StateAPI) StatePledgeCollateral(ctx context.Context, ts *types.TipSet)
 }
 
 func (a *StateAPI) StateCall(ctx context.Context, msg *types.Message, ts *types.TipSet) (*api.MethodCall, error) {
-	return a.StateManager.Call(ctx, msg, ts)
+	return a.StateManager.CallWithTrace(ctx, msg, ts)
 }
 
 func (a *StateAPI) StateReplay(ctx context.Context, ts *types.TipSet, mc cid.Cid) (*api.ReplayResults, error) {
@@ -129,12 +135,22 @@ func (a *StateAPI) StateReplay(ctx context.Context, ts *types.TipSet, mc cid.Cid
 	}
 
 	return &api.ReplayResults{
-		Msg:     m,
-		Receipt: &r.MessageReceipt,
-		Error:   errstr,
+		MsgCid:         mc,
+		Msg:            m,
+		Receipt:        &r.MessageReceipt,
+		Error:          errstr,
+		ExecutionTrace: r.ExecutionTrace,
 	}, nil
 }
 
+func (a *StateAPI) StateReplayTipSet(ctx context.Context, ts *types.TipSet) ([]*api.ReplayResults, error) {
+	if ts == nil {
+		ts = a.Chain.GetHeaviestTipSet()
+	}
+
+	return a.StateManager.ReplayTipSet(ctx, ts)
+}
+
 func (a *StateAPI) stateForTs(ctx context.Context, ts *types.TipSet) (*state.StateTree, error) {
 	if ts == nil {
 		ts = a.Chain.GetHeaviestTipSet()
@@ -185,11 +201,37 @@ func (a *StateAPI) StateReadState(ctx context.Context, act *types.Actor, ts *typ
 	}
 
 	return &api.ActorState{
+		Code:    act.Code,
+		Head:    act.Head,
+		Nonce:   act.Nonce,
 		Balance: act.Balance,
 		State:   oif,
 	}, nil
 }
 
+func (a *StateAPI) StateReadActor(ctx context.Context, addr address.Address, ts *types.TipSet) (*api.ActorState, error) {
+	state, err := a.stateForTs(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	act, err := state.GetActor(addr)
+	if err != nil {
+		return nil, xerrors.Errorf("loading actor %s: %w", addr, err)
+	}
+
+	return a.StateReadState(ctx, act, ts)
+}
+
+func (a *StateAPI) StateDecodeState(ctx context.Context, code cid.Cid, head cid.Cid) (interface{}, error) {
+	blk, err := a.Chain.Blockstore().Get(head)
+	if err != nil {
+		return nil, xerrors.Errorf("loading state object: %w", err)
+	}
+
+	return vm.DumpActorState(code, blk.RawData())
+}
+
 // This is on StateAPI because miner.Miner requires this, and MinerAPI requires miner.Miner
 func (a *StateAPI) MinerCreateBlock(ctx context.Context, addr address.Address, parents *types.TipSet, ticket *types.Ticket, proof *types.EPostProof, msgs []*types.SignedMessage, height, ts uint64) (*types.BlockMsg, error) {
 	fblk, err := gen.MinerCreateBlock(ctx, a.StateManager, a.Wallet, addr, parents, ticket, proof, msgs, height, ts)
@@ -223,6 +265,120 @@ func (a *StateAPI) StateWaitMsg(ctx context.Context, msg cid.Cid) (*api.MsgWait,
 	}, nil
 }
 
+func (a *StateAPI) StateSearchMsg(ctx context.Context, msg cid.Cid, lookback uint64, timeout time.Duration) (*api.MsgWait, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ts, recpt, err := a.StateManager.SearchForMessage(ctx, msg, lookback)
+	if err != nil {
+		return nil, err
+	}
+	if ts == nil {
+		return nil, nil
+	}
+
+	return &api.MsgWait{
+		Receipt: *recpt,
+		TipSet:  ts,
+	}, nil
+}
+
+func (a *StateAPI) StateSubMessages(ctx context.Context, f *api.MessageFilter) (<-chan []api.ExecutedMessage, error) {
+	notifs := a.Chain.SubHeadChanges(ctx)
+
+	out := make(chan []api.ExecutedMessage, 20)
+	go func() {
+		defer close(out)
+
+		for {
+			select {
+			case changes, ok := <-notifs:
+				if !ok {
+					return
+				}
+
+				var batch []api.ExecutedMessage
+				for _, hc := range changes {
+					if hc.Type == store.HCCurrent {
+						continue
+					}
+
+					msgs, err := a.executedMessages(hc.Val, f)
+					if err != nil {
+						log.Errorf("loading messages executed in %s: %s", hc.Val.Key(), err)
+						continue
+					}
+					for i := range msgs {
+						msgs[i].Reverted = hc.Type == store.HCRevert
+					}
+					batch = append(batch, msgs...)
+				}
+
+				if len(batch) == 0 {
+					continue
+				}
+
+				select {
+				case out <- batch:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// executedMessages returns the messages matching the filter which were
+// executed in the state transition to ts
+func (a *StateAPI) executedMessages(ts *types.TipSet, f *api.MessageFilter) ([]api.ExecutedMessage, error) {
+	if ts.Height() == 0 {
+		return nil, nil
+	}
+
+	pts, err := a.Chain.LoadTipSet(ts.Parents())
+	if err != nil {
+		return nil, xerrors.Errorf("loading parent tipset: %w", err)
+	}
+
+	cm, err := a.Chain.MessagesForTipset(pts)
+	if err != nil {
+		return nil, xerrors.Errorf("loading parent messages: %w", err)
+	}
+
+	var out []api.ExecutedMessage
+	for i, m := range cm {
+		msg := m.VMMessage()
+		if !f.MatchesMessage(msg) {
+			continue
+		}
+
+		r, err := a.Chain.GetParentReceipt(ts.Blocks()[0], i)
+		if err != nil {
+			return nil, xerrors.Errorf("loading receipt %d: %w", i, err)
+		}
+		if !f.MatchesReceipt(r) {
+			continue
+		}
+
+		out = append(out, api.ExecutedMessage{
+			Cid:     m.Cid(),
+			Message: msg,
+			Receipt: *r,
+			TipSet:  pts.Key(),
+			Height:  pts.Height(),
+		})
+	}
+
+	return out, nil
+}
+
 func (a *StateAPI) StateGetReceipt(ctx context.Context, msg cid.Cid, ts *types.TipSet) (*types.MessageReceipt, error) {
 	return a.StateManager.GetReceipt(ctx, msg, ts)
 }
@@ -353,6 +509,209 @@ func (a *StateAPI) StateChangedActors(ctx context.Context, old cid.Cid, new cid.
 	return out, nil
 }
 
+func (a *StateAPI) StateDiff(ctx context.Context, from, to types.TipSetKey, withState bool) ([]*api.ActorDiff, error) {
+	loadRoot := func(tsk types.TipSetKey) (cid.Cid, error) {
+		ts, err := a.Chain.LoadTipSet(tsk)
+		if err != nil {
+			return cid.Undef, xerrors.Errorf("loading tipset %s: %w", tsk, err)
+		}
+
+		st, _, err := a.StateManager.TipSetState(ctx, ts)
+		if err != nil {
+			return cid.Undef, xerrors.Errorf("computing tipset state: %w", err)
+		}
+		return st, nil
+	}
+
+	oldRoot, err := loadRoot(from)
+	if err != nil {
+		return nil, err
+	}
+
+	newRoot, err := loadRoot(to)
+	if err != nil {
+		return nil, err
+	}
+
+	cst := hamt.CSTFromBstore(a.Chain.Blockstore())
+
+	oh, err := hamt.LoadNode(ctx, cst, oldRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	nh, err := hamt.LoadNode(ctx, cst, newRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*api.ActorDiff
+
+	err = nh.ForEach(ctx, func(k string, nval interface{}) error {
+		ncval := nval.(*cbg.Deferred)
+
+		var nact, oact types.Actor
+		if err := nact.UnmarshalCBOR(bytes.NewReader(ncval.Raw)); err != nil {
+			return err
+		}
+
+		var ocval cbg.Deferred
+		var oactp *types.Actor
+		switch err := oh.Find(ctx, k, &ocval); err {
+		case nil:
+			if bytes.Equal(ocval.Raw, ncval.Raw) {
+				return nil // not changed
+			}
+
+			if err := oact.UnmarshalCBOR(bytes.NewReader(ocval.Raw)); err != nil {
+				return err
+			}
+			oactp = &oact
+		case hamt.ErrNotFound:
+		default:
+			return err
+		}
+
+		d, err := a.diffActor(ctx, k, oactp, &nact, withState)
+		if err != nil {
+			return err
+		}
+
+		out = append(out, d)
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("diffing new state: %w", err)
+	}
+
+	err = oh.ForEach(ctx, func(k string, oval interface{}) error {
+		var ncval cbg.Deferred
+		switch err := nh.Find(ctx, k, &ncval); err {
+		case nil:
+			return nil // handled above
+		case hamt.ErrNotFound:
+		default:
+			return err
+		}
+
+		var oact types.Actor
+		if err := oact.UnmarshalCBOR(bytes.NewReader(oval.(*cbg.Deferred).Raw)); err != nil {
+			return err
+		}
+
+		d, err := a.diffActor(ctx, k, &oact, nil, withState)
+		if err != nil {
+			return err
+		}
+
+		out = append(out, d)
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("diffing old state: %w", err)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Address.String() < out[j].Address.String()
+	})
+
+	return out, nil
+}
+
+// diffActor describes the change between two versions of an actor. A nil
+// oact means the actor was created, a nil nact that it was deleted.
+func (a *StateAPI) diffActor(ctx context.Context, k string, oact, nact *types.Actor, withState bool) (*api.ActorDiff, error) {
+	addr, err := address.NewFromBytes([]byte(k))
+	if err != nil {
+		return nil, xerrors.Errorf("address in state tree was not valid: %w", err)
+	}
+
+	d := &api.ActorDiff{
+		Address:    addr,
+		Change:     api.ActorModified,
+		OldBalance: types.NewInt(0),
+		NewBalance: types.NewInt(0),
+	}
+
+	switch {
+	case oact == nil:
+		d.Change = api.ActorCreated
+	case nact == nil:
+		d.Change = api.ActorDeleted
+	}
+
+	if oact != nil {
+		d.Code = oact.Code
+		d.OldBalance = oact.Balance
+		d.OldNonce = oact.Nonce
+	}
+	if nact != nil {
+		d.Code = nact.Code
+		d.NewBalance = nact.Balance
+		d.NewNonce = nact.Nonce
+	}
+	d.BalanceDelta = types.BigSub(d.NewBalance, d.OldBalance)
+
+	if !withState {
+		return d, nil
+	}
+
+	oldFields, err := a.actorStateFields(ctx, oact)
+	if err != nil {
+		return nil, xerrors.Errorf("decoding old state of %s: %w", addr, err)
+	}
+
+	newFields, err := a.actorStateFields(ctx, nact)
+	if err != nil {
+		return nil, xerrors.Errorf("decoding new state of %s: %w", addr, err)
+	}
+
+	d.StateDiff = map[string]api.FieldDiff{}
+	for f, ov := range oldFields {
+		if nv, ok := newFields[f]; !ok || !bytes.Equal(ov, nv) {
+			d.StateDiff[f] = api.FieldDiff{Old: ov, New: nv}
+		}
+	}
+	for f, nv := range newFields {
+		if _, ok := oldFields[f]; !ok {
+			d.StateDiff[f] = api.FieldDiff{New: nv}
+		}
+	}
+
+	return d, nil
+}
+
+// actorStateFields returns the JSON encoded top-level fields of the actor's
+// decoded state, nil for actors without a known state type
+func (a *StateAPI) actorStateFields(ctx context.Context, act *types.Actor) (map[string]json.RawMessage, error) {
+	if act == nil || act.Code == actors.AccountCodeCid {
+		return nil, nil
+	}
+
+	blk, err := a.Chain.Blockstore().Get(act.Head)
+	if err != nil {
+		return nil, err
+	}
+
+	oif, err := vm.DumpActorState(act.Code, blk.RawData())
+	if err != nil {
+		// not all actors have state we know how to decode
+		return nil, nil
+	}
+
+	data, err := json.Marshal(oif)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, nil // not a struct
+	}
+
+	return fields, nil
+}
+
 func (a *StateAPI) StateMinerSectorCount(ctx context.Context, addr address.Address, ts *types.TipSet) (api.MinerSectors, error) {
 	return stmgr.SectorSetSizes(ctx, a.StateManager, addr, ts)
 }
@@ -410,6 +769,10 @@ func (a *StateAPI) StateCompute(ctx context.Context, height uint64, msgs []*type
 	return stmgr.ComputeState(ctx, a.StateManager, height, msgs, ts)
 }
 
+func (a *StateAPI) StateCirculatingSupply(ctx context.Context, ts *types.TipSet) (api.CirculatingSupply, error) {
+	return stmgr.GetCirculatingSupply(ctx, a.StateManager, ts)
+}
+
 func (a *StateAPI) MsigGetAvailableBalance(ctx context.Context, addr address.Address, ts *types.TipSet) (types.BigInt, error) {
 	if ts == nil {
 		ts = a.Chain.GetHeaviestTipSet()
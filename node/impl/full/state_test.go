@@ -0,0 +1,144 @@
+package full
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/gen"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/types"
+	_ "github.com/filecoin-project/lotus/lib/sigs/bls"
+	_ "github.com/filecoin-project/lotus/lib/sigs/secp"
+)
+
+func init() {
+	build.SectorSizes = []uint64{1024}
+	build.MinimumMinerPower = 1024
+}
+
+func testStateAPI(t *testing.T) (*StateAPI, *gen.ChainGen) {
+	cg, err := gen.NewGenerator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &StateAPI{
+		Wallet:       cg.Wallet(),
+		StateManager: stmgr.NewStateManager(cg.ChainStore()),
+		Chain:        cg.ChainStore(),
+	}, cg
+}
+
+func TestStateDiff(t *testing.T) {
+	a, cg := testStateAPI(t)
+
+	mts, err := cg.NextTipSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gts, err := types.NewTipSet([]*types.BlockHeader{cg.Genesis()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the state of a tipset includes the messages in it, here the banker
+	// funding a new account per message
+	diff, err := a.StateDiff(context.TODO(), gts.Key(), mts.TipSet.TipSet().Key(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var created, sent int
+	var initDiff *api.ActorDiff
+	for _, d := range diff {
+		switch {
+		case d.Change == api.ActorCreated:
+			created++
+			if !d.OldBalance.IsZero() || !d.BalanceDelta.Equals(d.NewBalance) || d.NewBalance.IsZero() {
+				t.Errorf("bad balances for created actor %s: old %s, new %s, delta %s", d.Address, d.OldBalance, d.NewBalance, d.BalanceDelta)
+			}
+		case d.Code == actors.InitCodeCid:
+			initDiff = d
+		case d.NewNonce > d.OldNonce:
+			sent += int(d.NewNonce - d.OldNonce)
+			if d.BalanceDelta.Sign() >= 0 {
+				t.Errorf("expected the balance of sender %s to decrease, delta %s", d.Address, d.BalanceDelta)
+			}
+		}
+	}
+
+	if created != len(mts.Messages) || sent != len(mts.Messages) {
+		t.Fatalf("expected %d created actors and sent messages, got %d and %d", len(mts.Messages), created, sent)
+	}
+
+	if initDiff == nil {
+		t.Fatal("expected the init actor to be modified")
+	}
+	if _, ok := initDiff.StateDiff["NextID"]; !ok {
+		t.Fatalf("expected NextID in the init actor state diff, got %v", initDiff.StateDiff)
+	}
+
+	// no changes between a state and itself
+	diff, err = a.StateDiff(context.TODO(), gts.Key(), gts.Key(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 0 {
+		t.Fatalf("expected no changes, got %d", len(diff))
+	}
+}
+
+func TestStateCallTrace(t *testing.T) {
+	a, cg := testStateAPI(t)
+
+	mts, err := cg.NextTipSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	maddr := cg.Miners[0]
+	enc, err := actors.SerializeParams(&actors.IsValidMinerParam{Addr: maddr})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret, err := a.StateCall(context.TODO(), &types.Message{
+		To:     actors.StoragePowerAddress,
+		From:   maddr,
+		Method: actors.SPAMethods.IsValidMiner,
+		Params: enc,
+	}, mts.TipSet.TipSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ret.ExitCode != 0 || ret.Error != "" {
+		t.Fatalf("call failed with exit code %d: %s", ret.ExitCode, ret.Error)
+	}
+	if valid, ok := ret.Decoded.(bool); !ok || !valid {
+		t.Fatalf("expected the miner to be valid, got %#v", ret.Decoded)
+	}
+
+	// the power actor asks the miner whether it was slashed
+	et := ret.ExecutionTrace
+	if et == nil {
+		t.Fatal("expected an execution trace")
+	}
+	if et.Msg.To != actors.StoragePowerAddress || et.Msg.Method != actors.SPAMethods.IsValidMiner {
+		t.Fatalf("unexpected top level call to %s, method %d", et.Msg.To, et.Msg.Method)
+	}
+	if len(et.Subcalls) != 1 {
+		t.Fatalf("expected 1 subcall, got %d", len(et.Subcalls))
+	}
+	sub := et.Subcalls[0]
+	if sub.Msg.To != maddr || sub.Msg.Method != actors.MAMethods.IsSlashed {
+		t.Fatalf("unexpected subcall to %s, method %d", sub.Msg.To, sub.Msg.Method)
+	}
+	if sub.MsgRct.ExitCode != 0 {
+		t.Fatalf("subcall failed with exit code %d", sub.MsgRct.ExitCode)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/filecoin-project/lotus/chain"
 	"github.com/filecoin-project/lotus/chain/types"
 	cid "github.com/ipfs/go-cid"
-	"github.com/prometheus/common/log"
 
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"go.uber.org/fx"
@@ -97,3 +96,17 @@ func (a *SyncAPI) SyncCheckBad(ctx context.Context, bcid cid.Cid) (string, error
 
 	return reason, nil
 }
+
+func (a *SyncAPI) SyncCheckpoint(ctx context.Context, tsk types.TipSetKey) error {
+	log.Warnf("Setting checkpoint %s", tsk)
+	return a.Syncer.SetCheckpoint(ctx, tsk)
+}
+
+func (a *SyncAPI) SyncGetCheckpoint(ctx context.Context) (*types.TipSet, error) {
+	return a.Syncer.Checkpoint(ctx)
+}
+
+func (a *SyncAPI) SyncClearCheckpoint(ctx context.Context) error {
+	log.Warn("Clearing checkpoint")
+	return a.Syncer.RemoveCheckpoint()
+}
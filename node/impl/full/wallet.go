@@ -2,8 +2,10 @@ package full
 
 import (
 	"context"
+	"strings"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/wallet"
@@ -61,6 +63,30 @@ func (a *WalletAPI) WalletSetDefault(ctx context.Context, addr address.Address)
 	return a.Wallet.SetDefault(addr)
 }
 
+func (a *WalletAPI) WalletDefaultAddressFor(ctx context.Context, purpose string) (address.Address, error) {
+	return a.Wallet.GetDefaultFor(purpose)
+}
+
+func (a *WalletAPI) WalletDefaultAddresses(ctx context.Context) (map[string]address.Address, error) {
+	return a.Wallet.ListDefaults()
+}
+
+func (a *WalletAPI) WalletSetDefaultFor(ctx context.Context, purpose string, addr address.Address) error {
+	if err := checkAddrPurpose(purpose); err != nil {
+		return err
+	}
+	return a.Wallet.SetDefaultFor(purpose, addr)
+}
+
+func checkAddrPurpose(purpose string) error {
+	for _, p := range api.AddrPurposes {
+		if p == purpose {
+			return nil
+		}
+	}
+	return xerrors.Errorf("unknown address purpose %q, expected one of %s", purpose, strings.Join(api.AddrPurposes, ", "))
+}
+
 func (a *WalletAPI) WalletExport(ctx context.Context, addr address.Address) (*types.KeyInfo, error) {
 	return a.Wallet.Export(addr)
 }
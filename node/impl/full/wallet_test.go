@@ -0,0 +1,22 @@
+package full
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func TestCheckAddrPurpose(t *testing.T) {
+	for _, p := range api.AddrPurposes {
+		if err := checkAddrPurpose(p); err != nil {
+			t.Errorf("expected purpose %q to be accepted: %s", p, err)
+		}
+	}
+
+	// miner messages are always sent from the worker
+	for _, p := range []string{"", "seal", "publish-deals", "sealing", "post"} {
+		if err := checkAddrPurpose(p); err == nil {
+			t.Errorf("expected purpose %q to be rejected", p)
+		}
+	}
+}
@@ -8,17 +8,24 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/ipfs/go-cid"
 	files "github.com/ipfs/go-ipfs-files"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-sectorbuilder"
 	"github.com/filecoin-project/go-sectorbuilder/fs"
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/api/apistruct"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/lib/tarutil"
 	"github.com/filecoin-project/lotus/miner"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+	"github.com/filecoin-project/lotus/node/repo"
 	"github.com/filecoin-project/lotus/storage"
 	"github.com/filecoin-project/lotus/storage/sectorblocks"
 )
@@ -33,6 +40,31 @@ type StorageMinerAPI struct {
 	Miner      *storage.Miner
 	BlockMiner *miner.Miner
 	Full       api.FullNode
+
+	Messages          *storage.MessageTracker
+	Actors            storage.ActorMiners
+	ActorSectorBlocks sectorblocks.ActorSectorBlocks
+
+	SealProofDevice dtypes.SealProofDevice
+}
+
+// ForActor returns a view of the API which operates on one of the additional
+// miner actors
+func (sm *StorageMinerAPI) ForActor(maddr address.Address) (*StorageMinerAPI, error) {
+	am, ok := sm.Actors[maddr]
+	if !ok {
+		return nil, xerrors.Errorf("actor %s isn't run by this miner", maddr)
+	}
+
+	out := *sm
+	out.SectorBuilderConfig = am.SectorBuilderConfig
+	out.SectorBuilder = am.SectorBuilder
+	out.Miner = am.Miner
+	out.Messages = am.Messages
+	out.SectorBlocks = sm.ActorSectorBlocks[maddr]
+	out.Actors = nil
+	out.ActorSectorBlocks = nil
+	return &out, nil
 }
 
 func (sm *StorageMinerAPI) ServeRemote(w http.ResponseWriter, r *http.Request) {
@@ -167,10 +199,105 @@ func (sm *StorageMinerAPI) ActorAddress(context.Context) (address.Address, error
 	return sm.SectorBuilderConfig.Miner, nil
 }
 
+func (sm *StorageMinerAPI) ActorList(context.Context) ([]address.Address, error) {
+	return append([]address.Address{sm.SectorBuilderConfig.Miner}, sm.Actors.Addresses()...), nil
+}
+
 func (sm *StorageMinerAPI) ActorSectorSize(ctx context.Context, addr address.Address) (uint64, error) {
 	return sm.Full.StateMinerSectorSize(ctx, addr, nil)
 }
 
+func (sm *StorageMinerAPI) MiningStatus(context.Context) (*api.MiningStatus, error) {
+	return sm.BlockMiner.Status()
+}
+
+func (sm *StorageMinerAPI) MinerStats(ctx context.Context) (*api.MinerStats, error) {
+	maddr := sm.SectorBuilderConfig.Miner
+
+	head, err := sm.Full.ChainHead(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	out := &api.MinerStats{
+		Miner:        maddr,
+		Height:       head.Height(),
+		SectorStates: map[string]int{},
+	}
+
+	pow, err := sm.Full.StateMinerPower(ctx, maddr, head)
+	if err != nil {
+		return nil, xerrors.Errorf("getting miner power: %w", err)
+	}
+	out.Power, out.NetworkPower = pow.MinerPower, pow.TotalPower
+	if !pow.TotalPower.IsZero() {
+		share := types.BigDiv(types.BigMul(pow.MinerPower, types.NewInt(1000000)), pow.TotalPower)
+		out.PowerShare = float64(share.Int64()) / 1000000
+	}
+
+	out.SectorSize, err = sm.Full.StateMinerSectorSize(ctx, maddr, head)
+	if err != nil {
+		return nil, xerrors.Errorf("getting sector size: %w", err)
+	}
+
+	counts, err := sm.Full.StateMinerSectorCount(ctx, maddr, head)
+	if err != nil {
+		return nil, xerrors.Errorf("getting sector counts: %w", err)
+	}
+	out.CommittedSectors, out.ProvingSectors = counts.Sset, counts.Pset
+
+	faults, err := sm.Full.StateMinerFaults(ctx, maddr, head)
+	if err != nil {
+		return nil, xerrors.Errorf("getting faults: %w", err)
+	}
+	out.FaultySectors = uint64(len(faults))
+
+	sectors, err := sm.Miner.ListSectors()
+	if err != nil {
+		return nil, xerrors.Errorf("listing sectors: %w", err)
+	}
+	for _, sector := range sectors {
+		out.SectorStates[api.SectorStates[sector.State]]++
+	}
+
+	deals, err := sm.Full.StateMarketDeals(ctx, head)
+	if err != nil {
+		return nil, xerrors.Errorf("getting market deals: %w", err)
+	}
+	for _, deal := range deals {
+		if deal.Provider != maddr {
+			continue
+		}
+		if deal.ActivationEpoch != 0 {
+			out.ActiveDeals++
+		} else {
+			out.PendingDeals++
+		}
+	}
+
+	out.Worker, err = sm.Full.StateMinerWorker(ctx, maddr, head)
+	if err != nil {
+		return nil, xerrors.Errorf("getting worker address: %w", err)
+	}
+	out.WorkerBalance, err = sm.Full.WalletBalance(ctx, out.Worker)
+	if err != nil {
+		return nil, xerrors.Errorf("getting worker balance: %w", err)
+	}
+
+	out.ElectionPeriodStart, err = sm.Full.StateMinerElectionPeriodStart(ctx, maddr, head)
+	if err != nil {
+		return nil, xerrors.Errorf("getting election period start: %w", err)
+	}
+	if out.ElectionPeriodStart != 0 {
+		out.ProvingDeadline = out.ElectionPeriodStart + build.SlashablePowerDelay
+		if out.ProvingDeadline > head.Height() {
+			out.DeadlineIn = time.Duration(out.ProvingDeadline-head.Height()) * build.BlockDelay * time.Second
+		}
+	}
+
+	return out, nil
+}
+
 func (sm *StorageMinerAPI) PledgeSector(ctx context.Context) error {
 	return sm.Miner.PledgeSector()
 }
@@ -207,6 +334,10 @@ func (sm *StorageMinerAPI) SectorsStatus(ctx context.Context, sid uint64) (api.S
 		Seed:     info.Seed.SB(),
 		Retries:  info.Nonce,
 
+		PreCommitMsg:   info.PreCommitMessage,
+		CommitMsg:      info.CommitMessage,
+		FaultReportMsg: info.FaultReportMsg,
+
 		LastErr: info.LastErr,
 		Log:     log,
 	}, nil
@@ -246,6 +377,18 @@ func (sm *StorageMinerAPI) SectorsUpdate(ctx context.Context, id uint64, state a
 	return sm.Miner.ForceSectorState(ctx, id, state)
 }
 
+func (sm *StorageMinerAPI) SectorStartSealing(ctx context.Context, id uint64) error {
+	return sm.Miner.StartPackingSector(id)
+}
+
+func (sm *StorageMinerAPI) SectorsRetry(ctx context.Context, id uint64) error {
+	return sm.Miner.RetrySector(ctx, id)
+}
+
+func (sm *StorageMinerAPI) SectorsAbort(ctx context.Context, id uint64) error {
+	return sm.Miner.AbortSector(ctx, id)
+}
+
 func (sm *StorageMinerAPI) WorkerQueue(ctx context.Context, cfg sectorbuilder.WorkerCfg) (<-chan sectorbuilder.WorkerTask, error) {
 	return sm.SectorBuilder.AddWorker(ctx, cfg)
 }
@@ -254,4 +397,41 @@ func (sm *StorageMinerAPI) WorkerDone(ctx context.Context, task uint64, res sect
 	return sm.SectorBuilder.TaskDone(ctx, task, res)
 }
 
+func (sm *StorageMinerAPI) WorkerProofDevice(context.Context) (string, error) {
+	return string(sm.SealProofDevice), nil
+}
+
+func (sm *StorageMinerAPI) MessagesList(context.Context) ([]api.MinerMessage, error) {
+	return sm.Messages.List()
+}
+
+func (sm *StorageMinerAPI) MessagesRebroadcast(ctx context.Context, msg cid.Cid) error {
+	return sm.Messages.Rebroadcast(ctx, msg)
+}
+
+func (sm *StorageMinerAPI) MessagesBumpFee(ctx context.Context, msg cid.Cid, gasPrice types.BigInt) (cid.Cid, error) {
+	return sm.Messages.BumpFee(ctx, msg, gasPrice)
+}
+
+func (sm *StorageMinerAPI) CreateBackup(ctx context.Context, fpath string) error {
+	// write to a temp file first so a failed backup doesn't leave a
+	// truncated archive behind
+	f, err := os.OpenFile(fpath+".tmp", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return xerrors.Errorf("creating backup file: %w", err)
+	}
+
+	if err := repo.Backup(sm.Repo, f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(fpath + ".tmp")
+		return xerrors.Errorf("creating backup: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return xerrors.Errorf("closing backup file: %w", err)
+	}
+
+	return os.Rename(fpath+".tmp", fpath)
+}
+
 var _ api.StorageMiner = &StorageMinerAPI{}
@@ -3,12 +3,16 @@ package modules
 import (
 	"bytes"
 	"context"
+	"path/filepath"
+	"time"
 
 	"github.com/ipfs/go-bitswap"
 	"github.com/ipfs/go-bitswap/network"
 	"github.com/ipfs/go-blockservice"
 	"github.com/ipfs/go-car"
+	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	badger "github.com/ipfs/go-ds-badger2"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/routing"
@@ -16,12 +20,18 @@ import (
 	"go.uber.org/fx"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain"
+	"github.com/filecoin-project/lotus/chain/beacon"
+	"github.com/filecoin-project/lotus/chain/beacon/drand"
 	"github.com/filecoin-project/lotus/chain/blocksync"
 	"github.com/filecoin-project/lotus/chain/messagepool"
 	"github.com/filecoin-project/lotus/chain/stmgr"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/sigs"
+	"github.com/filecoin-project/lotus/lib/splitstore"
+	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/helpers"
 	"github.com/filecoin-project/lotus/node/repo"
@@ -41,9 +51,26 @@ func ChainExchange(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, rt
 	return exch
 }
 
-func MessagePool(lc fx.Lifecycle, sm *stmgr.StateManager, ps *pubsub.PubSub, ds dtypes.MetadataDS) (*messagepool.MessagePool, error) {
+func MpoolConfig(cfg config.Mpool) func() *messagepool.Config {
+	return func() *messagepool.Config {
+		return &messagepool.Config{
+			MaxPendingPerActor: cfg.MaxPendingPerActor,
+			MinGasPrice:        types.NewInt(cfg.MinGasPrice),
+			SizeLimitHigh:      cfg.SizeLimitHigh,
+			SizeLimitLow:       cfg.SizeLimitLow,
+		}
+	}
+}
+
+// SigCache creates the cache of verified message signatures shared by the
+// syncer, the mpool and the message pubsub validator
+func SigCache() (*sigs.Cache, error) {
+	return sigs.NewCache(build.VerifSigCacheSize)
+}
+
+func MessagePool(lc fx.Lifecycle, sm *stmgr.StateManager, ps *pubsub.PubSub, ds dtypes.MetadataDS, cfg *messagepool.Config, sc *sigs.Cache) (*messagepool.MessagePool, error) {
 	mpp := messagepool.NewProvider(sm, ps)
-	mp, err := messagepool.New(mpp, ds)
+	mp, err := messagepool.New(mpp, ds, sc, cfg)
 	if err != nil {
 		return nil, xerrors.Errorf("constructing mpool: %w", err)
 	}
@@ -65,6 +92,52 @@ func ChainBlockstore(r repo.LockedRepo) (dtypes.ChainBlockstore, error) {
 	return blockstore.NewIdStore(bs), nil
 }
 
+func SplitBlockstore(cfg splitstore.Config) func(lc fx.Lifecycle, r repo.LockedRepo) (*splitstore.SplitStore, error) {
+	return func(lc fx.Lifecycle, r repo.LockedRepo) (*splitstore.SplitStore, error) {
+		// the hot store gets a badger instance of its own, so that blocks
+		// deleted from it during compaction can actually be reclaimed
+		opts := badger.DefaultOptions
+		opts.Truncate = true
+		hot, err := badger.NewDatastore(filepath.Join(r.Path(), "splitstore", "hot"), &opts)
+		if err != nil {
+			return nil, xerrors.Errorf("opening hot store: %w", err)
+		}
+		lc.Append(fx.Hook{
+			OnStop: func(_ context.Context) error {
+				return hot.Close()
+			},
+		})
+
+		// the cold store is the regular chain blockstore, so enabling the
+		// splitstore on an existing repo doesn't require a migration
+		cold, err := r.Datastore("/blocks")
+		if err != nil {
+			return nil, err
+		}
+
+		ds, err := r.Datastore("/splitstore")
+		if err != nil {
+			return nil, err
+		}
+
+		return splitstore.New(blockstore.NewBlockstore(hot), blockstore.NewBlockstore(cold), ds, cfg)
+	}
+}
+
+func SplitChainBlockstore(ss *splitstore.SplitStore) dtypes.ChainBlockstore {
+	return blockstore.NewIdStore(ss)
+}
+
+func StartSplitstore(ss *splitstore.SplitStore, cs *store.ChainStore) {
+	ss.Start(cs)
+}
+
+// ChainGCLocker returns the chain store's GC locker, which pauses chain
+// blockstore writes while state is pruned or the splitstore is compacted
+func ChainGCLocker(cs *store.ChainStore) dtypes.ChainGCLocker {
+	return cs.GCLocker()
+}
+
 func ChainGCBlockstore(bs dtypes.ChainBlockstore, gcl dtypes.ChainGCLocker) dtypes.ChainGCBlockstore {
 	return blockstore.NewGCBlockstore(bs, gcl)
 }
@@ -73,13 +146,57 @@ func ChainBlockservice(bs dtypes.ChainBlockstore, rem dtypes.ChainExchange) dtyp
 	return blockservice.New(bs, rem)
 }
 
-func ChainStore(lc fx.Lifecycle, bs dtypes.ChainBlockstore, ds dtypes.MetadataDS, syscalls *types.VMSyscalls) *store.ChainStore {
-	chain := store.NewChainStore(bs, ds, syscalls)
+// RandomnessBeacon returns the randomness beacon of the network, or nil when
+// the network build doesn't use one. Entries are fetched in the background
+// while the node runs.
+func RandomnessBeacon(mctx helpers.MetricsCtx, lc fx.Lifecycle, ds dtypes.MetadataDS) (beacon.RandomnessBeacon, error) {
+	b, err := drand.NetworkBeacon(ds)
+	if err != nil {
+		return nil, xerrors.Errorf("creating drand beacon: %w", err)
+	}
+	if b == nil {
+		return nil, nil
+	}
+
+	ctx := helpers.LifecycleCtx(mctx, lc)
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go b.Run(ctx)
+			return nil
+		},
+	})
+
+	return b, nil
+}
+
+func ChainStore(lc fx.Lifecycle, bs dtypes.ChainBlockstore, ds dtypes.MetadataDS, syscalls *types.VMSyscalls, rb beacon.RandomnessBeacon) *store.ChainStore {
+	chain := store.NewChainStore(bs, ds, syscalls, rb)
 
 	if err := chain.Load(); err != nil {
 		log.Warnf("loading chain state from disk: %s", err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			head := chain.GetHeaviestTipSet()
+			if head == nil {
+				return nil
+			}
+
+			go func() {
+				if err := chain.BackfillMsgIndex(ctx, head); err != nil && ctx.Err() == nil {
+					log.Errorf("backfilling message index: %+v", err)
+				}
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+
 	return chain
 }
 
@@ -126,8 +243,58 @@ func SetGenesis(cs *store.ChainStore, g Genesis) error {
 	return cs.SetGenesis(genesis)
 }
 
-func NewSyncer(lc fx.Lifecycle, sm *stmgr.StateManager, bsync *blocksync.BlockSync, h host.Host) (*chain.Syncer, error) {
-	syncer, err := chain.NewSyncer(sm, bsync, h.ConnManager(), h.ID())
+func SyncCheckpoint(blocks []string) func(*chain.Syncer) error {
+	return func(syncer *chain.Syncer) error {
+		var cids []cid.Cid
+		for _, s := range blocks {
+			c, err := cid.Decode(s)
+			if err != nil {
+				return xerrors.Errorf("parsing checkpoint block cid: %w", err)
+			}
+			cids = append(cids, c)
+		}
+
+		// headers for the checkpoint will be fetched when syncing
+		syncer.SetConfigCheckpoint(types.NewTipSetKey(cids...))
+		return nil
+	}
+}
+
+func StatePruner(retain uint64, interval time.Duration) func(helpers.MetricsCtx, fx.Lifecycle, *stmgr.StateManager) {
+	if interval <= 0 {
+		def := time.Duration(config.DefaultFullNode().Pruning.Interval)
+		log.Warnf("invalid pruning interval %s, using %s", interval, def)
+		interval = def
+	}
+
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, sm *stmgr.StateManager) {
+		ctx := helpers.LifecycleCtx(mctx, lc)
+
+		lc.Append(fx.Hook{
+			OnStart: func(_ context.Context) error {
+				go func() {
+					tick := time.NewTicker(interval)
+					defer tick.Stop()
+
+					for {
+						select {
+						case <-tick.C:
+							if _, err := sm.PruneState(ctx, retain); err != nil {
+								log.Errorf("pruning chain state: %+v", err)
+							}
+						case <-ctx.Done():
+							return
+						}
+					}
+				}()
+				return nil
+			},
+		})
+	}
+}
+
+func NewSyncer(lc fx.Lifecycle, sm *stmgr.StateManager, bsync *blocksync.BlockSync, h host.Host, sc *sigs.Cache) (*chain.Syncer, error) {
+	syncer, err := chain.NewSyncer(sm, bsync, h.ConnManager(), h.ID(), sc)
 	if err != nil {
 		return nil, err
 	}
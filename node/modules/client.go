@@ -28,7 +28,9 @@ import (
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/routing"
 	"go.uber.org/fx"
+	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/lotus/lib/ipfsbstore"
 	"github.com/filecoin-project/lotus/markets/retrievaladapter"
 	payapi "github.com/filecoin-project/lotus/node/impl/paych"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
@@ -56,6 +58,18 @@ func ClientBlockstore(fstore dtypes.ClientFilestore) dtypes.ClientBlockstore {
 	return blockstore.NewIdStore((*filestore.Filestore)(fstore))
 }
 
+// IpfsClientBlockstore uses the blockstore of the IPFS node with the API at
+// apiAddr for client data
+func IpfsClientBlockstore(apiAddr string) func(helpers.MetricsCtx, fx.Lifecycle) (dtypes.ClientBlockstore, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle) (dtypes.ClientBlockstore, error) {
+		ibs, err := ipfsbstore.NewIpfsBstore(helpers.LifecycleCtx(mctx, lc), apiAddr)
+		if err != nil {
+			return nil, xerrors.Errorf("constructing IPFS blockstore: %w", err)
+		}
+		return blockstore.NewIdStore(ibs), nil
+	}
+}
+
 // RegisterClientValidator is an initialization hook that registers the client
 // request validator with the data transfer module as the validator for
 // StorageDataTransferVoucher types
@@ -118,3 +132,18 @@ func RetrievalClient(h host.Host, bs dtypes.ClientBlockstore, pmgr *paych.Manage
 	adapter := retrievaladapter.NewRetrievalClientNode(pmgr, payapi)
 	return retrievalimpl.NewClient(h, bs, adapter)
 }
+
+// RetrievalClientTracker follows the progress of retrievals made by the client
+func RetrievalClientTracker(lc fx.Lifecycle, rc retrievalmarket.RetrievalClient) *retrievaladapter.ClientTracker {
+	t := retrievaladapter.NewClientTracker()
+	unsubscribe := rc.SubscribeToEvents(t.OnEvent)
+
+	lc.Append(fx.Hook{
+		OnStop: func(_ context.Context) error {
+			unsubscribe()
+			return nil
+		},
+	})
+
+	return t
+}
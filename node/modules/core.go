@@ -12,6 +12,7 @@ import (
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/lib/addrutil"
+	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/repo"
 	"github.com/gbrlsnchs/jwt/v3"
@@ -78,6 +79,25 @@ func APISecret(keystore types.KeyStore, lr repo.LockedRepo) (*dtypes.APIAlg, err
 	return (*dtypes.APIAlg)(jwt.NewHS256(key.PrivateKey)), nil
 }
 
+func APILimiter(limits []config.APILimit) func() *apistruct.Limiter {
+	return func() *apistruct.Limiter {
+		if len(limits) == 0 {
+			return nil
+		}
+
+		out := make([]apistruct.Limit, len(limits))
+		for i, l := range limits {
+			out[i] = apistruct.Limit{
+				Methods:       l.Methods,
+				Rate:          l.Rate,
+				Burst:         l.Burst,
+				MaxConcurrent: l.MaxConcurrent,
+			}
+		}
+		return apistruct.NewLimiter(out)
+	}
+}
+
 func ConfigBootstrap(peers []string) func() (dtypes.BootstrapPeers, error) {
 	return func() (dtypes.BootstrapPeers, error) {
 		return addrutil.ParseAddresses(context.TODO(), peers)
@@ -87,3 +107,9 @@ func ConfigBootstrap(peers []string) func() (dtypes.BootstrapPeers, error) {
 func BuiltinBootstrap() (dtypes.BootstrapPeers, error) {
 	return build.BuiltinBootstrap()
 }
+
+func NetworkBootstrap(network string) func() (dtypes.BootstrapPeers, error) {
+	return func() (dtypes.BootstrapPeers, error) {
+		return build.NetworkBootstrap(network)
+	}
+}
@@ -41,3 +41,7 @@ type ProviderDataTransfer datatransfer.Manager
 type StagingDAG ipld.DAGService
 type StagingBlockstore blockstore.Blockstore
 type StagingGraphsync graphsync.GraphExchange
+
+// SealProofDevice is the device remote workers seal on unless they select one
+// themselves
+type SealProofDevice string
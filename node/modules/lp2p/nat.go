@@ -2,6 +2,10 @@ package lp2p
 
 import (
 	"github.com/libp2p/go-libp2p"
+	autonat "github.com/libp2p/go-libp2p-autonat"
+	"go.uber.org/fx"
+
+	"github.com/filecoin-project/lotus/node/modules/helpers"
 )
 
 /*import (
@@ -36,3 +40,9 @@ func AutoNATService(quic bool) func(repo repo.Repo, mctx helpers.MetricsCtx, lc
 */
 
 var NatPortMap = simpleOpt(libp2p.NATPortMap())
+
+// AutoNAT detects whether the node is publicly reachable by asking peers to
+// dial it back on its advertised addresses
+func AutoNAT(mctx helpers.MetricsCtx, lc fx.Lifecycle, h RawHost) autonat.AutoNAT {
+	return autonat.NewAutoNAT(helpers.LifecycleCtx(mctx, lc), h, nil)
+}
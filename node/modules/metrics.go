@@ -0,0 +1,119 @@
+package modules
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/messagepool"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/metrics"
+	"github.com/filecoin-project/lotus/node/modules/helpers"
+	"github.com/filecoin-project/lotus/tracing"
+)
+
+// ServeMetrics registers the views and serves them, along with views
+// registered elsewhere, on /metrics in the prometheus text format
+func ServeMetrics(listen string, namespace string, views []*view.View) func(lc fx.Lifecycle) error {
+	return func(lc fx.Lifecycle) error {
+		addr, err := multiaddr.NewMultiaddr(listen)
+		if err != nil {
+			return xerrors.Errorf("parsing metrics listen address: %w", err)
+		}
+
+		exporter, err := metrics.Exporter(namespace, views...)
+		if err != nil {
+			return err
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", exporter)
+		srv := &http.Server{Handler: mux}
+
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				lst, err := manet.Listen(addr)
+				if err != nil {
+					return xerrors.Errorf("listening on metrics address: %w", err)
+				}
+
+				go func() {
+					if err := srv.Serve(manet.NetListener(lst)); err != http.ErrServerClosed {
+						log.Errorf("metrics server failed: %s", err)
+					}
+				}()
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				return srv.Shutdown(ctx)
+			},
+		})
+
+		return nil
+	}
+}
+
+// RecordChainMetrics records the chain height, sync lag and message pool
+// size on each head change
+func RecordChainMetrics(mctx helpers.MetricsCtx, lc fx.Lifecycle, cs *store.ChainStore, mp *messagepool.MessagePool) error {
+	ctx := helpers.LifecycleCtx(mctx, lc)
+
+	gen, err := cs.GetGenesis()
+	if err != nil {
+		return xerrors.Errorf("getting genesis: %w", err)
+	}
+
+	go func() {
+		for changes := range cs.SubHeadChanges(ctx) {
+			var height uint64
+			for _, c := range changes {
+				if c.Type == store.HCApply || c.Type == store.HCCurrent {
+					height = c.Val.Height()
+				}
+			}
+			if height == 0 {
+				continue
+			}
+
+			expected := (uint64(time.Now().Unix()) - gen.Timestamp) / build.BlockDelay
+			lag := int64(0)
+			if expected > height {
+				lag = int64(expected - height)
+			}
+
+			stats.Record(ctx,
+				metrics.ChainHeight.M(int64(height)),
+				metrics.SyncLag.M(lag),
+				metrics.MpoolSize.M(int64(mp.PendingCount())))
+		}
+	}()
+
+	return nil
+}
+
+// Tracing exports traces to the configured collectors
+func Tracing(o tracing.Options) func(lc fx.Lifecycle) error {
+	return func(lc fx.Lifecycle) error {
+		stop, err := tracing.Setup(o)
+		if err != nil {
+			return err
+		}
+
+		lc.Append(fx.Hook{
+			OnStop: func(context.Context) error {
+				stop()
+				return nil
+			},
+		})
+
+		return nil
+	}
+}
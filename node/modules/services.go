@@ -7,15 +7,22 @@ import (
 	inet "github.com/libp2p/go-libp2p-core/network"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"go.uber.org/fx"
+	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket/discovery"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/lotus/chain"
 	"github.com/filecoin-project/lotus/chain/blocksync"
 	"github.com/filecoin-project/lotus/chain/messagepool"
+	"github.com/filecoin-project/lotus/chain/slasher"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/sub"
+	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/node/hello"
+	"github.com/filecoin-project/lotus/node/impl/full"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/helpers"
 	"github.com/filecoin-project/lotus/peermgr"
@@ -41,13 +48,29 @@ func RunPeerMgr(mctx helpers.MetricsCtx, lc fx.Lifecycle, pmgr *peermgr.PeerMgr)
 	go pmgr.Run(helpers.LifecycleCtx(mctx, lc))
 }
 
+func RunPinnedPeers(mctx helpers.MetricsCtx, lc fx.Lifecycle, pp *peermgr.PinnedPeers) {
+	go pp.Run(helpers.LifecycleCtx(mctx, lc))
+}
+
 func RunBlockSync(h host.Host, svc *blocksync.BlockSyncService) {
 	h.SetStreamHandler(blocksync.BlockSyncProtocolID, svc.HandleStream)
 }
 
-func HandleIncomingBlocks(mctx helpers.MetricsCtx, lc fx.Lifecycle, pubsub *pubsub.PubSub, s *chain.Syncer, h host.Host) {
+func PubsubPeerScorer(params sub.ScoreParams) func(helpers.MetricsCtx, fx.Lifecycle, *pubsub.PubSub) *sub.PeerScorer {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, ps *pubsub.PubSub) *sub.PeerScorer {
+		scorer := sub.NewPeerScorer(params, ps)
+		go scorer.Run(helpers.LifecycleCtx(mctx, lc))
+		return scorer
+	}
+}
+
+func HandleIncomingBlocks(mctx helpers.MetricsCtx, lc fx.Lifecycle, pubsub *pubsub.PubSub, s *chain.Syncer, h host.Host, vals sub.BlockValidators, scorer *sub.PeerScorer) {
 	ctx := helpers.LifecycleCtx(mctx, lc)
 
+	if err := pubsub.RegisterTopicValidator("/fil/blocks", sub.TopicValidator("/fil/blocks", h.ID(), scorer, vals)); err != nil {
+		panic(err)
+	}
+
 	blocksub, err := pubsub.Subscribe("/fil/blocks")
 	if err != nil {
 		panic(err)
@@ -56,9 +79,13 @@ func HandleIncomingBlocks(mctx helpers.MetricsCtx, lc fx.Lifecycle, pubsub *pubs
 	go sub.HandleIncomingBlocks(ctx, blocksub, s, h.ConnManager())
 }
 
-func HandleIncomingMessages(mctx helpers.MetricsCtx, lc fx.Lifecycle, pubsub *pubsub.PubSub, mpool *messagepool.MessagePool) {
+func HandleIncomingMessages(mctx helpers.MetricsCtx, lc fx.Lifecycle, pubsub *pubsub.PubSub, mpool *messagepool.MessagePool, h host.Host, vals sub.MessageValidators, scorer *sub.PeerScorer) {
 	ctx := helpers.LifecycleCtx(mctx, lc)
 
+	if err := pubsub.RegisterTopicValidator("/fil/messages", sub.TopicValidator("/fil/messages", h.ID(), scorer, vals)); err != nil {
+		panic(err)
+	}
+
 	msgsub, err := pubsub.Subscribe("/fil/messages")
 	if err != nil {
 		panic(err)
@@ -89,3 +116,55 @@ func NewLocalDiscovery(ds dtypes.MetadataDS) *discovery.Local {
 func RetrievalResolver(l *discovery.Local) retrievalmarket.PeerResolver {
 	return discovery.Multi(l)
 }
+
+// RunSlasher reports consensus faults in blocks received from the network.
+// Reports are sent from the given address, or the default wallet address.
+func RunSlasher(reporter string) func(helpers.MetricsCtx, fx.Lifecycle, *chain.Syncer, *store.ChainStore, *stmgr.StateManager, full.MpoolAPI) error {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, s *chain.Syncer, cs *store.ChainStore, sm *stmgr.StateManager, mp full.MpoolAPI) error {
+		ctx := helpers.LifecycleCtx(mctx, lc)
+
+		lc.Append(fx.Hook{
+			OnStart: func(_ context.Context) error {
+				var addr address.Address
+				var err error
+				if reporter != "" {
+					addr, err = address.NewFromString(reporter)
+				} else {
+					addr, err = mp.WalletDefaultAddress(ctx)
+				}
+				if err != nil {
+					return xerrors.Errorf("getting slasher reporter address: %w", err)
+				}
+
+				blocks, err := s.IncomingBlocks(ctx)
+				if err != nil {
+					return xerrors.Errorf("subscribing to incoming blocks: %w", err)
+				}
+
+				// same lookup as block validation, the worker in the state
+				// computed from the block parents
+				worker := func(ctx context.Context, miner address.Address, parents types.TipSetKey) (address.Address, error) {
+					pts, err := cs.LoadTipSet(parents)
+					if err != nil {
+						return address.Undef, xerrors.Errorf("loading parent tipset: %w", err)
+					}
+					st, _, err := sm.TipSetState(ctx, pts)
+					if err != nil {
+						return address.Undef, xerrors.Errorf("computing parent state: %w", err)
+					}
+					return stmgr.GetMinerWorkerRaw(ctx, sm, st, miner)
+				}
+				push := func(ctx context.Context, msg *types.Message) (*types.SignedMessage, error) {
+					return mp.MpoolPushMessage(ctx, msg, nil)
+				}
+				head := func() uint64 {
+					return cs.GetHeaviestTipSet().Height()
+				}
+				go slasher.New(addr, push, worker, head).Run(ctx, blocks)
+				return nil
+			},
+		})
+
+		return nil
+	}
+}
@@ -3,7 +3,10 @@ package modules
 import (
 	"context"
 	"math"
+	"path/filepath"
 	"reflect"
+	"sync"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	dtgraphsync "github.com/filecoin-project/go-data-transfer/impl/graphsync"
@@ -12,7 +15,6 @@ import (
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	deals "github.com/filecoin-project/go-fil-markets/storagemarket/impl"
 	storageimpl "github.com/filecoin-project/go-fil-markets/storagemarket/impl"
-	paramfetch "github.com/filecoin-project/go-paramfetch"
 	"github.com/filecoin-project/go-sectorbuilder"
 	"github.com/filecoin-project/go-sectorbuilder/fs"
 	"github.com/filecoin-project/go-statestore"
@@ -21,6 +23,7 @@ import (
 	"github.com/ipfs/go-blockservice"
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/namespace"
+	badger "github.com/ipfs/go-ds-badger2"
 	graphsync "github.com/ipfs/go-graphsync/impl"
 	"github.com/ipfs/go-graphsync/ipldbridge"
 	gsnet "github.com/ipfs/go-graphsync/network"
@@ -36,8 +39,14 @@ import (
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/gen"
+	"github.com/filecoin-project/lotus/lib/bwlimit"
+	"github.com/filecoin-project/lotus/lib/proofdevice"
+	"github.com/filecoin-project/lotus/lib/proofparams"
 	"github.com/filecoin-project/lotus/markets/retrievaladapter"
+	"github.com/filecoin-project/lotus/markets/staging"
+	"github.com/filecoin-project/lotus/markets/storageadapter"
 	"github.com/filecoin-project/lotus/miner"
+	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/helpers"
 	"github.com/filecoin-project/lotus/node/repo"
@@ -55,12 +64,59 @@ func minerAddrFromDS(ds dtypes.MetadataDS) (address.Address, error) {
 	return address.NewFromBytes(maddrb)
 }
 
-func GetParams(sbc *sectorbuilder.Config) error {
-	if err := paramfetch.GetParams(build.ParametersJson(), sbc.SectorSize); err != nil {
-		return xerrors.Errorf("fetching proof parameters: %w", err)
+// CheckParams refuses to start the miner until all proof parameters for its
+// sector size are in the cache. An empty dir keeps the cache directory set in
+// the environment.
+func CheckParams(dir string) func(sbc *sectorbuilder.Config) error {
+	return func(sbc *sectorbuilder.Config) error {
+		if dir != "" {
+			if err := proofparams.SetDir(dir); err != nil {
+				return err
+			}
+		}
+
+		log.Infof("checking proof parameters in %s", proofparams.Dir())
+		if err := proofparams.Check(build.ParametersJson(), sbc.SectorSize); err != nil {
+			return xerrors.Errorf("missing or invalid proof parameters, run 'lotus-storage-miner fetch-params --param-dir=%s': %w", proofparams.Dir(), err)
+		}
+
+		return nil
+	}
+}
+
+// SetProofDevice selects the device proofs generated by the miner process run
+// on. Sealing devices are handed to remote workers.
+func SetProofDevice(cfg config.Proofs, localCommit bool) func() error {
+	return func() error {
+		if err := proofdevice.Validate(cfg.Seal()); err != nil {
+			return xerrors.Errorf("invalid seal device: %w", err)
+		}
+		if localCommit && cfg.Seal() != cfg.PoSt() {
+			return xerrors.Errorf("seal device '%s' differs from PoSt device '%s', which requires disabling local commit (SectorBuilder.DisableLocalCommit)", cfg.Seal(), cfg.PoSt())
+		}
+
+		if err := proofdevice.Apply(cfg.PoSt()); err != nil {
+			return xerrors.Errorf("invalid PoSt device: %w", err)
+		}
+		if cfg.PoSt() != "" {
+			log.Infof("generating proofs on device '%s'", cfg.PoSt())
+		}
+		return nil
 	}
+}
 
-	return nil
+// ProofTimeouts applies the configured PoSt timeouts over the defaults
+func ProofTimeouts(cfg config.Proofs) func() storage.ProofTimeouts {
+	return func() storage.ProofTimeouts {
+		out := storage.DefaultProofTimeouts()
+		if cfg.FallbackPoStTimeout > 0 {
+			out.FallbackPoSt = time.Duration(cfg.FallbackPoStTimeout)
+		}
+		if cfg.ElectionPoStTimeout > 0 {
+			out.ElectionPoSt = time.Duration(cfg.ElectionPoStTimeout)
+		}
+		return out
+	}
 }
 
 func SectorBuilderConfig(storage []fs.PathConfig, threads uint, noprecommit, nocommit bool) func(dtypes.MetadataDS, api.FullNode) (*sectorbuilder.Config, error) {
@@ -101,7 +157,20 @@ func SectorBuilderConfig(storage []fs.PathConfig, threads uint, noprecommit, noc
 	}
 }
 
-func StorageMiner(mctx helpers.MetricsCtx, lc fx.Lifecycle, api api.FullNode, h host.Host, ds dtypes.MetadataDS, sb sectorbuilder.Interface, tktFn sealing.TicketFn) (*storage.Miner, error) {
+// MessageTracker records the messages pushed by the main miner actor
+func MessageTracker(mctx helpers.MetricsCtx, lc fx.Lifecycle, api api.FullNode, ds dtypes.MetadataDS) *storage.MessageTracker {
+	mt := storage.NewMessageTracker(helpers.LifecycleCtx(mctx, lc), api, namespace.Wrap(ds, datastore.NewKey("/messages")))
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			return mt.Start()
+		},
+	})
+
+	return mt
+}
+
+func StorageMiner(mctx helpers.MetricsCtx, lc fx.Lifecycle, api api.FullNode, mt *storage.MessageTracker, h host.Host, ds dtypes.MetadataDS, sb sectorbuilder.Interface, sbcfg *sectorbuilder.Config, tktFn sealing.TicketFn, timeouts storage.ProofTimeouts) (*storage.Miner, error) {
 	maddr, err := minerAddrFromDS(ds)
 	if err != nil {
 		return nil, err
@@ -114,15 +183,17 @@ func StorageMiner(mctx helpers.MetricsCtx, lc fx.Lifecycle, api api.FullNode, h
 		return nil, err
 	}
 
-	fps := storage.NewFPoStScheduler(api, sb, maddr, worker)
+	pft := storage.NewPathFaultTracker(api, sb, maddr, sbcfg.Paths)
+	fps := storage.NewFPoStScheduler(mt, sb, maddr, worker, pft, timeouts)
 
-	sm, err := storage.NewMiner(api, maddr, worker, h, ds, sb, tktFn)
+	sm, err := storage.NewMiner(mt, maddr, worker, h, ds, sb, tktFn)
 	if err != nil {
 		return nil, err
 	}
 
 	lc.Append(fx.Hook{
 		OnStart: func(context.Context) error {
+			go pft.Run(ctx)
 			go fps.Run(ctx)
 			return sm.Run(ctx)
 		},
@@ -132,13 +203,170 @@ func StorageMiner(mctx helpers.MetricsCtx, lc fx.Lifecycle, api api.FullNode, h
 	return sm, nil
 }
 
-func HandleRetrieval(host host.Host, lc fx.Lifecycle, m retrievalmarket.RetrievalProvider) {
-	lc.Append(fx.Hook{
-		OnStart: func(context.Context) error {
-			m.Start(host)
-			return nil
-		},
-	})
+// SplitWorkers divides the local sectorbuilder workers between the main miner
+// actor and the additional actors, so that together they don't run more
+// workers than configured. The remainder goes to the main actor.
+func SplitWorkers(total uint, actors int) (uint, uint, error) {
+	if actors == 0 {
+		return total, 0, nil
+	}
+
+	n := uint(actors) + 1
+	if total < n {
+		return 0, 0, xerrors.Errorf("%d sectorbuilder workers can't be shared by %d miner actors, each needs at least one", total, n)
+	}
+	return total/n + total%n, total / n, nil
+}
+
+// ActorMiners sets up sealing and fallback PoSt for additional miner actors.
+// Actor state is kept in a separate namespace of the metadata datastore.
+// Deals are accepted for all actors, see ActorSectorBlocks. Each actor's
+// sectorbuilder runs the given number of local workers, see SplitWorkers.
+func ActorMiners(actors []config.MinerActor, workers uint, repoPath string) func(helpers.MetricsCtx, fx.Lifecycle, api.FullNode, host.Host, dtypes.MetadataDS, *sectorbuilder.Config, sealing.TicketFn, storage.ProofTimeouts) (storage.ActorMiners, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, api api.FullNode, h host.Host, ds dtypes.MetadataDS, mainCfg *sectorbuilder.Config, tktFn sealing.TicketFn, timeouts storage.ProofTimeouts) (storage.ActorMiners, error) {
+		ctx := helpers.LifecycleCtx(mctx, lc)
+		out := storage.ActorMiners{}
+
+		for _, a := range actors {
+			maddr, err := address.NewFromString(a.Address)
+			if err != nil {
+				return nil, xerrors.Errorf("parsing actor address '%s': %w", a.Address, err)
+			}
+			if maddr == mainCfg.Miner {
+				return nil, xerrors.Errorf("actor %s is the main miner actor", maddr)
+			}
+			if _, ok := out[maddr]; ok {
+				return nil, xerrors.Errorf("actor %s configured twice", maddr)
+			}
+
+			ssize, err := api.StateMinerSectorSize(ctx, maddr, nil)
+			if err != nil {
+				return nil, xerrors.Errorf("getting sector size of %s: %w", maddr, err)
+			}
+
+			worker, err := api.StateMinerWorker(ctx, maddr, nil)
+			if err != nil {
+				return nil, xerrors.Errorf("getting worker of %s: %w", maddr, err)
+			}
+
+			// copy the paths, the config is shared with other modules
+			paths := append([]fs.PathConfig(nil), a.Storage...)
+			if len(paths) == 0 {
+				paths = sectorbuilder.SimplePath(filepath.Join(repoPath, "actors", maddr.String()))
+			}
+			for i := range paths {
+				paths[i].Path, err = homedir.Expand(paths[i].Path)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			sbcfg := *mainCfg
+			sbcfg.Miner = maddr
+			sbcfg.SectorSize = ssize
+			sbcfg.Paths = paths
+			sbcfg.WorkerThreads = uint8(workers)
+
+			ads := namespace.Wrap(ds, datastore.NewKey("/actors/"+maddr.String()))
+
+			sb, err := sectorbuilder.New(&sbcfg, namespace.Wrap(ads, datastore.NewKey("/sectorbuilder")))
+			if err != nil {
+				return nil, xerrors.Errorf("creating sectorbuilder for %s: %w", maddr, err)
+			}
+
+			// cancelled when the actor is stopped, the lifecycle context is
+			// only cancelled after the stop hooks ran
+			actx, cancel := context.WithCancel(ctx)
+
+			mt := storage.NewMessageTracker(actx, api, namespace.Wrap(ads, datastore.NewKey("/messages")))
+
+			sm, err := storage.NewMiner(mt, maddr, worker, h, ads, sb, tktFn)
+			if err != nil {
+				return nil, xerrors.Errorf("creating miner for %s: %w", maddr, err)
+			}
+
+			pft := storage.NewPathFaultTracker(api, sb, maddr, paths)
+
+			am := &storage.ActorMiner{
+				SectorBuilderConfig: &sbcfg,
+				SectorBuilder:       sb,
+				Datastore:           ads,
+				Miner:               sm,
+				FPoSt:               storage.NewFPoStScheduler(mt, sb, maddr, worker, pft, timeouts),
+				Messages:            mt,
+			}
+			out[maddr] = am
+
+			var wg sync.WaitGroup
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					if err := mt.Start(); err != nil {
+						return err
+					}
+					wg.Add(2)
+					go func() {
+						defer wg.Done()
+						pft.Run(actx)
+					}()
+					go func() {
+						defer wg.Done()
+						am.FPoSt.Run(actx)
+					}()
+					return am.Miner.Run(actx)
+				},
+				OnStop: func(stopCtx context.Context) error {
+					err := am.Miner.Stop(stopCtx)
+
+					// stops the fault tracker, the PoSt scheduler and the
+					// messages followed by the message tracker
+					cancel()
+
+					done := make(chan struct{})
+					go func() {
+						wg.Wait()
+						close(done)
+					}()
+					select {
+					case <-done:
+					case <-stopCtx.Done():
+						return stopCtx.Err()
+					}
+					return err
+				},
+			})
+		}
+
+		return out, nil
+	}
+}
+
+// ActorSectorBlocks creates the sector blocks of the additional miner actors,
+// which are used to seal deals made with them
+func ActorSectorBlocks(actors storage.ActorMiners) sectorblocks.ActorSectorBlocks {
+	out := sectorblocks.ActorSectorBlocks{}
+	for maddr, am := range actors {
+		out[maddr] = sectorblocks.NewSectorBlocks(am.Miner, am.Datastore, am.SectorBuilder)
+	}
+	return out
+}
+
+// TransferLimiter creates the bandwidth limiter shared by storage and
+// retrieval data transfers
+func TransferLimiter(cfg config.Transfers) func() *bwlimit.Limiter {
+	return func() *bwlimit.Limiter {
+		return bwlimit.NewLimiter(cfg.IngressLimit, cfg.EgressLimit)
+	}
+}
+
+func HandleRetrieval(cfg config.Transfers) func(host.Host, fx.Lifecycle, retrievalmarket.RetrievalProvider, *bwlimit.Limiter) {
+	return func(host host.Host, lc fx.Lifecycle, m retrievalmarket.RetrievalProvider, lim *bwlimit.Limiter) {
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				m.Start(lim.Host(host, cfg.MaxRetrievals))
+				return nil
+			},
+		})
+	}
 }
 
 func HandleDeals(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, h storagemarket.StorageProvider) {
@@ -176,18 +404,62 @@ func NewProviderDealStore(ds dtypes.MetadataDS) dtypes.ProviderDealStore {
 	return statestore.New(namespace.Wrap(ds, datastore.NewKey("/deals/client")))
 }
 
+// stagingGCInterval is the time between removals of stale staged deal data
+const stagingGCInterval = 10 * time.Minute
+
 // StagingBlockstore creates a blockstore for staging blocks for a miner
-// in a storage deal, prior to sealing
-func StagingBlockstore(r repo.LockedRepo) (dtypes.StagingBlockstore, error) {
-	stagingds, err := r.Datastore("/staging")
-	if err != nil {
-		return nil, err
-	}
+// in a datastore separate from the chainstore
+func StagingBlockstore(cfg config.Staging) func(helpers.MetricsCtx, fx.Lifecycle, repo.LockedRepo, dtypes.MetadataDS) (dtypes.StagingBlockstore, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, r repo.LockedRepo, mds dtypes.MetadataDS) (dtypes.StagingBlockstore, error) {
+		var stagingds datastore.Batching
+		if cfg.Path == "" {
+			ds, err := r.Datastore("/staging")
+			if err != nil {
+				return nil, err
+			}
+			stagingds = ds
+		} else {
+			path, err := homedir.Expand(cfg.Path)
+			if err != nil {
+				return nil, err
+			}
 
-	bs := blockstore.NewBlockstore(stagingds)
-	ibs := blockstore.NewIdStore(bs)
+			opts := badger.DefaultOptions
+			ds, err := badger.NewDatastore(path, &opts)
+			if err != nil {
+				return nil, xerrors.Errorf("opening staging datastore at %s: %w", path, err)
+			}
+			lc.Append(fx.Hook{
+				OnStop: func(_ context.Context) error {
+					return ds.Close()
+				},
+			})
+			stagingds = ds
+		}
 
-	return ibs, nil
+		bs, err := staging.NewBlockstore(stagingds, cfg.MaxSize)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("deal staging area: %d bytes used, quota %d bytes", bs.Used(), cfg.MaxSize)
+
+		if cfg.MaxAge > 0 {
+			// data of deals not added to a sector yet is kept, however long
+			// they take
+			deals := statestore.New(namespace.Wrap(mds, datastore.NewKey(storageimpl.ProviderDsPrefix)))
+			inUse := storageadapter.StagedInUse(deals, bs)
+
+			ctx := helpers.LifecycleCtx(mctx, lc)
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					go bs.Run(ctx, stagingGCInterval, time.Duration(cfg.MaxAge), inUse)
+					return nil
+				},
+			})
+		}
+
+		return blockstore.NewIdStore(bs), nil
+	}
 }
 
 // StagingDAG is a DAGService for the StagingBlockstore
@@ -208,16 +480,18 @@ func StagingDAG(mctx helpers.MetricsCtx, lc fx.Lifecycle, ibs dtypes.StagingBloc
 	return dag, nil
 }
 
-// StagingGraphsync creates a graphsync instance which reads and writes blocks
-// to the StagingBlockstore
-func StagingGraphsync(mctx helpers.MetricsCtx, lc fx.Lifecycle, ibs dtypes.StagingBlockstore, h host.Host) dtypes.StagingGraphsync {
-	graphsyncNetwork := gsnet.NewFromLibp2pHost(h)
-	ipldBridge := ipldbridge.NewIPLDBridge()
-	loader := storeutil.LoaderForBlockstore(ibs)
-	storer := storeutil.StorerForBlockstore(ibs)
-	gs := graphsync.New(helpers.LifecycleCtx(mctx, lc), graphsyncNetwork, ipldBridge, loader, storer)
-
-	return gs
+// StagingGraphsync creates a graphsync instance which reads from and writes
+// to the staging blockstore. Its transfers are limited by the transfer config.
+func StagingGraphsync(cfg config.Transfers) func(helpers.MetricsCtx, fx.Lifecycle, dtypes.StagingBlockstore, host.Host, *bwlimit.Limiter) dtypes.StagingGraphsync {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, ibs dtypes.StagingBlockstore, h host.Host, lim *bwlimit.Limiter) dtypes.StagingGraphsync {
+		graphsyncNetwork := gsnet.NewFromLibp2pHost(lim.Host(h, cfg.MaxStorageTransfers))
+		ipldBridge := ipldbridge.NewIPLDBridge()
+		loader := storeutil.LoaderForBlockstore(ibs)
+		storer := storeutil.StorerForBlockstore(ibs)
+		gs := graphsync.New(helpers.LifecycleCtx(mctx, lc), graphsyncNetwork, ipldBridge, loader, storer)
+
+		return gs
+	}
 }
 
 func SetupBlockProducer(lc fx.Lifecycle, ds dtypes.MetadataDS, api api.FullNode, epp gen.ElectionPoStProver) (*miner.Miner, error) {
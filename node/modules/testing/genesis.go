@@ -7,6 +7,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/ipfs/go-blockservice"
@@ -20,6 +21,7 @@ import (
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/gen"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/wallet"
@@ -87,39 +89,16 @@ func MakeGenesis(outFile, presealInfo, timestamp string) func(bs dtypes.ChainBlo
 				return nil, err
 			}
 
-			var fakePeerIDs []peer.ID
-			minerAddresses := make([]address.Address, 0, len(preseals))
-			for s := range preseals {
-				a, err := address.NewFromString(s)
-				if err != nil {
-					return nil, err
-				}
-				if a.Protocol() != address.ID {
-					return nil, xerrors.New("expected ID address")
-				}
-				minerAddresses = append(minerAddresses, a)
-				fakePeerIDs = append(fakePeerIDs, peer.ID("peer"+a.String()))
+			template := &genesis.Template{
+				Miners: preseals,
 			}
-
-			gmc := &gen.GenMinerCfg{
-				PeerIDs:    fakePeerIDs,
-				PreSeals:   preseals,
-				MinerAddrs: minerAddresses,
-			}
-
-			addrs := map[address.Address]types.BigInt{}
-
 			for _, miner := range preseals {
-				if _, err := w.Import(&miner.Key); err != nil {
-					return nil, xerrors.Errorf("importing miner key: %w", err)
-				}
-
-				_ = w.SetDefault(miner.Worker)
-
-				addrs[miner.Worker] = types.FromFil(100000)
+				template.Accounts = append(template.Accounts, genesis.Account{
+					Address: miner.Worker,
+					Balance: types.FromFil(100000),
+				})
 			}
 
-			ts := uint64(time.Now().Unix())
 			if timestamp != "" {
 				t, err := time.Parse(time.RFC3339, timestamp)
 				if err != nil {
@@ -127,36 +106,125 @@ func MakeGenesis(outFile, presealInfo, timestamp string) func(bs dtypes.ChainBlo
 				}
 
 				glog.Infof("will use %s as the genesis timestamp", t)
-				ts = uint64(t.Unix())
+				template.Timestamp = uint64(t.Unix())
 			}
 
-			b, err := gen.MakeGenesisBlock(bs, syscalls, addrs, gmc, ts)
+			return makeGenesis(bs, w, syscalls, outFile, template)
+		}
+	}
+}
+
+// MakeGenesisTemplate creates the genesis block described by a template
+// created with the lotus-seed genesis commands
+func MakeGenesisTemplate(outFile, templateFile string) func(bs dtypes.ChainBlockstore, w *wallet.Wallet, syscalls *types.VMSyscalls) modules.Genesis {
+	return func(bs dtypes.ChainBlockstore, w *wallet.Wallet, syscalls *types.VMSyscalls) modules.Genesis {
+		return func() (*types.BlockHeader, error) {
+			glog.Warn("Generating new genesis block from template, note that this SHOULD NOT happen unless you are setting up new network")
+			templateFile, err := homedir.Expand(templateFile)
 			if err != nil {
 				return nil, err
 			}
 
-			fmt.Println("GENESIS MINER ADDRESS: ", gmc.MinerAddrs[0].String())
-
-			f, err := os.OpenFile(outFile, os.O_CREATE|os.O_WRONLY, 0644)
+			fdata, err := ioutil.ReadFile(templateFile)
 			if err != nil {
-				return nil, err
+				return nil, xerrors.Errorf("reading genesis template: %w", err)
 			}
 
-			offl := offline.Exchange(bs)
-			blkserv := blockservice.New(bs, offl)
-			dserv := merkledag.NewDAGService(blkserv)
+			var template genesis.Template
+			if err := json.Unmarshal(fdata, &template); err != nil {
+				return nil, xerrors.Errorf("parsing genesis template: %w", err)
+			}
 
-			if err := car.WriteCar(context.TODO(), dserv, []cid.Cid{b.Genesis.Cid()}, f); err != nil {
-				return nil, err
+			if template.BlockDelay != 0 && template.BlockDelay != build.BlockDelay {
+				return nil, xerrors.Errorf("template block delay %ds doesn't match the block delay of this build (%ds)", template.BlockDelay, build.BlockDelay)
 			}
 
-			glog.Warnf("WRITING GENESIS FILE AT %s", f.Name())
+			return makeGenesis(bs, w, syscalls, outFile, &template)
+		}
+	}
+}
 
-			if err := f.Close(); err != nil {
-				return nil, err
-			}
+func makeGenesis(bs dtypes.ChainBlockstore, w *wallet.Wallet, syscalls *types.VMSyscalls, outFile string, template *genesis.Template) (*types.BlockHeader, error) {
+	if len(template.Miners) == 0 {
+		return nil, xerrors.New("genesis needs at least one miner")
+	}
 
-			return b.Genesis, nil
+	var fakePeerIDs []peer.ID
+	minerAddresses := make([]address.Address, 0, len(template.Miners))
+	for s := range template.Miners {
+		a, err := address.NewFromString(s)
+		if err != nil {
+			return nil, err
+		}
+		if a.Protocol() != address.ID {
+			return nil, xerrors.New("expected ID address")
+		}
+		minerAddresses = append(minerAddresses, a)
+	}
+
+	// sort miners so the same template always creates the same genesis
+	sort.Slice(minerAddresses, func(i, j int) bool {
+		return minerAddresses[i].String() < minerAddresses[j].String()
+	})
+	for _, a := range minerAddresses {
+		fakePeerIDs = append(fakePeerIDs, peer.ID("peer"+a.String()))
+	}
+
+	gmc := &gen.GenMinerCfg{
+		PeerIDs:     fakePeerIDs,
+		PreSeals:    template.Miners,
+		MinerAddrs:  minerAddresses,
+		NetworkName: template.NetworkName,
+	}
+
+	for _, a := range minerAddresses {
+		miner := template.Miners[a.String()]
+		if _, err := w.Import(&miner.Key); err != nil {
+			return nil, xerrors.Errorf("importing miner key: %w", err)
+		}
+
+		_ = w.SetDefault(miner.Worker)
+	}
+
+	addrs := map[address.Address]types.BigInt{}
+	for _, acct := range template.Accounts {
+		if bal, ok := addrs[acct.Address]; ok {
+			addrs[acct.Address] = types.BigAdd(bal, acct.Balance)
+			continue
 		}
+		addrs[acct.Address] = acct.Balance
 	}
+
+	ts := template.Timestamp
+	if ts == 0 {
+		ts = uint64(time.Now().Unix())
+	}
+
+	b, err := gen.MakeGenesisBlock(bs, syscalls, addrs, gmc, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("GENESIS MINER ADDRESS: ", gmc.MinerAddrs[0].String())
+
+	f, err := os.OpenFile(outFile, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	offl := offline.Exchange(bs)
+	blkserv := blockservice.New(bs, offl)
+	dserv := merkledag.NewDAGService(blkserv)
+
+	if err := car.WriteCar(context.TODO(), dserv, []cid.Cid{b.Genesis.Cid()}, f); err != nil {
+		return nil, err
+	}
+
+	glog.Warnf("WRITING GENESIS FILE AT %s", f.Name())
+
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Genesis, nil
 }
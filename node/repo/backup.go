@@ -0,0 +1,199 @@
+package repo
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// Backup archives are gzipped tar files holding the repo config, the
+// keystore (one JSON encoded KeyInfo per key) and a dump of the metadata
+// datastore with one JSON encoded entry per line. Sector data isn't included.
+const (
+	backupConfig   = "config.toml"
+	backupKeystore = "keystore/"
+	backupMetadata = "metadata"
+
+	metadataNamespace = "/metadata"
+)
+
+type backupEntry struct {
+	Key   string
+	Value []byte
+}
+
+// Backup writes an archive of the repo metadata to w. The metadata
+// datastore is read in a single query, which badger serves from a
+// consistent snapshot, so the repo can be in use.
+func Backup(lr LockedRepo, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	cfg, err := ioutil.ReadFile(filepath.Join(lr.Path(), fsConfig))
+	if err != nil && !os.IsNotExist(err) {
+		return xerrors.Errorf("reading config: %w", err)
+	}
+	if err := writeTarFile(tw, backupConfig, cfg); err != nil {
+		return err
+	}
+
+	ks, err := lr.KeyStore()
+	if err != nil {
+		return xerrors.Errorf("opening keystore: %w", err)
+	}
+	names, err := ks.List()
+	if err != nil {
+		return xerrors.Errorf("listing keys: %w", err)
+	}
+	for _, name := range names {
+		ki, err := ks.Get(name)
+		if err != nil {
+			return xerrors.Errorf("getting key %s: %w", name, err)
+		}
+		b, err := json.Marshal(ki)
+		if err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, backupKeystore+name, b); err != nil {
+			return err
+		}
+	}
+
+	mds, err := lr.Datastore(metadataNamespace)
+	if err != nil {
+		return xerrors.Errorf("opening metadata datastore: %w", err)
+	}
+	res, err := mds.Query(dsq.Query{})
+	if err != nil {
+		return xerrors.Errorf("querying metadata: %w", err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return xerrors.Errorf("reading metadata: %w", err)
+	}
+
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(backupEntry{Key: e.Key, Value: e.Value}); err != nil {
+			return err
+		}
+	}
+	if err := writeTarFile(tw, backupMetadata, []byte(buf.String())); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Restore imports a backup archive into a freshly initialized repo
+func Restore(lr LockedRepo, r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return xerrors.Errorf("opening backup: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	ks, err := lr.KeyStore()
+	if err != nil {
+		return xerrors.Errorf("opening keystore: %w", err)
+	}
+	mds, err := lr.Datastore(metadataNamespace)
+	if err != nil {
+		return xerrors.Errorf("opening metadata datastore: %w", err)
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return xerrors.Errorf("reading backup: %w", err)
+		}
+
+		switch {
+		case hdr.Name == backupConfig:
+			b, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			if len(b) == 0 {
+				continue
+			}
+			if err := ioutil.WriteFile(filepath.Join(lr.Path(), fsConfig), b, 0644); err != nil {
+				return xerrors.Errorf("writing config: %w", err)
+			}
+		case strings.HasPrefix(hdr.Name, backupKeystore):
+			var ki types.KeyInfo
+			if err := json.NewDecoder(tr).Decode(&ki); err != nil {
+				return xerrors.Errorf("decoding key %s: %w", hdr.Name, err)
+			}
+			if err := ks.Put(strings.TrimPrefix(hdr.Name, backupKeystore), ki); err != nil {
+				return xerrors.Errorf("restoring key %s: %w", hdr.Name, err)
+			}
+		case hdr.Name == backupMetadata:
+			if err := restoreMetadata(mds, tr); err != nil {
+				return err
+			}
+		default:
+			log.Warnf("skipping unknown backup entry %s", hdr.Name)
+		}
+	}
+
+	return nil
+}
+
+func restoreMetadata(mds datastore.Batching, r io.Reader) error {
+	b, err := mds.Batch()
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(r)
+	for {
+		var e backupEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return xerrors.Errorf("decoding metadata entry: %w", err)
+		}
+
+		if err := b.Put(datastore.NewKey(e.Key), e.Value); err != nil {
+			return xerrors.Errorf("restoring %s: %w", e.Key, err)
+		}
+	}
+
+	return b.Commit()
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return xerrors.Errorf("writing %s header: %w", name, err)
+	}
+
+	if _, err := tw.Write(data); err != nil {
+		return xerrors.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
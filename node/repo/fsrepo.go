@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/namespace"
@@ -253,12 +254,44 @@ func (fsr *fsLockedRepo) stillValid() error {
 	return nil
 }
 
+func (fsr *fsLockedRepo) dsOptions() (*badger.Options, error) {
+	opts := badger.DefaultOptions
+	opts.Truncate = true
+
+	c, err := fsr.Config()
+	if err != nil {
+		return nil, xerrors.Errorf("loading config: %w", err)
+	}
+
+	var dcfg config.Datastore
+	switch c := c.(type) {
+	case *config.FullNode:
+		dcfg = c.Datastore
+	case *config.StorageMiner:
+		dcfg = c.Datastore
+	}
+
+	opts.GcInterval = time.Duration(dcfg.GCInterval)
+	if dcfg.GCDiscardRatio > 0 {
+		opts.GcDiscardRatio = dcfg.GCDiscardRatio
+	}
+	if dcfg.NumCompactors > 0 {
+		opts.NumCompactors = dcfg.NumCompactors
+	}
+	opts.CompactL0OnClose = dcfg.CompactL0OnClose
+
+	return &opts, nil
+}
+
 func (fsr *fsLockedRepo) Datastore(ns string) (datastore.Batching, error) {
 	fsr.dsOnce.Do(func() {
-		opts := badger.DefaultOptions
-		opts.Truncate = true
+		var opts *badger.Options
+		opts, fsr.dsErr = fsr.dsOptions()
+		if fsr.dsErr != nil {
+			return
+		}
 
-		fsr.ds, fsr.dsErr = badger.NewDatastore(fsr.join(fsDatastore), &opts)
+		fsr.ds, fsr.dsErr = badger.NewDatastore(fsr.join(fsDatastore), opts)
 		/*if fsr.dsErr == nil {
 			fsr.ds = datastore.NewLogDatastore(fsr.ds, "fsrepo")
 		}*/
@@ -269,6 +302,22 @@ func (fsr *fsLockedRepo) Datastore(ns string) (datastore.Batching, error) {
 	return namespace.Wrap(fsr.ds, datastore.NewKey(ns)), nil
 }
 
+func (fsr *fsLockedRepo) DatastoreGC() error {
+	if err := fsr.stillValid(); err != nil {
+		return err
+	}
+
+	if _, err := fsr.Datastore("/"); err != nil {
+		return err
+	}
+
+	gcds, ok := fsr.ds.(datastore.GCDatastore)
+	if !ok {
+		return xerrors.Errorf("datastore %T doesn't support garbage collection", fsr.ds)
+	}
+	return gcds.CollectGarbage()
+}
+
 func (fsr *fsLockedRepo) Config() (interface{}, error) {
 	if err := fsr.stillValid(); err != nil {
 		return nil, err
@@ -34,6 +34,10 @@ type LockedRepo interface {
 	// Returns datastore defined in this repo.
 	Datastore(namespace string) (datastore.Batching, error)
 
+	// DatastoreGC reclaims space used by deleted and overwritten datastore
+	// entries
+	DatastoreGC() error
+
 	// Returns config in this repo
 	Config() (interface{}, error)
 
@@ -162,6 +162,11 @@ func (lmem *lockedMemRepo) Datastore(ns string) (datastore.Batching, error) {
 	return namespace.Wrap(lmem.mem.datastore, datastore.NewKey(ns)), nil
 }
 
+// DatastoreGC is a no-op, the in-memory datastore doesn't need collection
+func (lmem *lockedMemRepo) DatastoreGC() error {
+	return lmem.checkToken()
+}
+
 func (lmem *lockedMemRepo) Config() (interface{}, error) {
 	if err := lmem.checkToken(); err != nil {
 		return nil, err
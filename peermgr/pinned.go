@@ -0,0 +1,199 @@
+package peermgr
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	host "github.com/libp2p/go-libp2p-core/host"
+	net "github.com/libp2p/go-libp2p-core/network"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+)
+
+const (
+	pinnedTag     = "pinned"
+	pinnedWeight  = 100
+	reconnectTime = 10 * time.Second
+	dialTimeout   = 30 * time.Second
+)
+
+var pinnedPrefix = datastore.NewKey("/peermgr/pinned")
+
+// PinnedPeers are peers added through the API which the node stays
+// connected to. The list is persisted in the metadata datastore.
+type PinnedPeers struct {
+	h  host.Host
+	ds datastore.Batching
+
+	lk    sync.Mutex
+	peers map[peer.ID]api.PinnedPeer
+}
+
+func NewPinnedPeers(h host.Host, ds dtypes.MetadataDS) (*PinnedPeers, error) {
+	pp := &PinnedPeers{
+		h:     h,
+		ds:    ds,
+		peers: map[peer.ID]api.PinnedPeer{},
+	}
+
+	res, err := ds.Query(dsq.Query{Prefix: pinnedPrefix.String()})
+	if err != nil {
+		return nil, xerrors.Errorf("querying pinned peers: %w", err)
+	}
+
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, xerrors.Errorf("loading pinned peers: %w", err)
+	}
+
+	for _, e := range entries {
+		var p api.PinnedPeer
+		if err := json.Unmarshal(e.Value, &p); err != nil {
+			return nil, xerrors.Errorf("decoding pinned peer %s: %w", e.Key, err)
+		}
+
+		pp.peers[p.AddrInfo.ID] = p
+		pp.tag(p)
+	}
+
+	return pp, nil
+}
+
+// Run connects to pinned peers, and reconnects after they disconnect
+func (pp *PinnedPeers) Run(ctx context.Context) {
+	tick := time.NewTicker(reconnectTime)
+	defer tick.Stop()
+
+	for {
+		var wg sync.WaitGroup
+		for _, p := range pp.List() {
+			if pp.h.Network().Connectedness(p.AddrInfo.ID) == net.Connected {
+				continue
+			}
+
+			wg.Add(1)
+			go func(pi peer.AddrInfo) {
+				defer wg.Done()
+
+				dctx, cancel := context.WithTimeout(ctx, dialTimeout)
+				defer cancel()
+
+				if err := pp.h.Connect(dctx, pi); err != nil {
+					log.Warnf("connecting to pinned peer %s: %s", pi.ID, err)
+				}
+			}(p.AddrInfo)
+		}
+		wg.Wait()
+
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Add pins the peer, replacing its addresses if it was already pinned
+func (pp *PinnedPeers) Add(ctx context.Context, pi peer.AddrInfo) error {
+	pp.lk.Lock()
+	p := pp.peers[pi.ID]
+	p.AddrInfo = pi
+	err := pp.put(p)
+	pp.lk.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return pp.h.Connect(ctx, pi)
+}
+
+func (pp *PinnedPeers) Remove(id peer.ID) error {
+	pp.lk.Lock()
+	defer pp.lk.Unlock()
+
+	p, ok := pp.peers[id]
+	if !ok {
+		return xerrors.Errorf("peer %s is not pinned", id)
+	}
+
+	if err := pp.ds.Delete(pinnedPrefix.ChildString(id.Pretty())); err != nil {
+		return xerrors.Errorf("removing pinned peer: %w", err)
+	}
+	delete(pp.peers, id)
+
+	pp.h.ConnManager().UntagPeer(id, pinnedTag)
+	if p.Protected {
+		pp.h.ConnManager().Unprotect(id, pinnedTag)
+	}
+
+	return nil
+}
+
+// Protect excludes the peer from connection manager trimming. Peers which
+// aren't pinned yet are pinned with their currently known addresses when
+// protected, unprotecting them doesn't pin them.
+func (pp *PinnedPeers) Protect(id peer.ID, protect bool) error {
+	pp.lk.Lock()
+	defer pp.lk.Unlock()
+
+	p, pinned := pp.peers[id]
+	if !pinned {
+		if !protect {
+			pp.h.ConnManager().Unprotect(id, pinnedTag)
+			return nil
+		}
+		p.AddrInfo = pp.h.Peerstore().PeerInfo(id)
+	}
+	p.Protected = protect
+
+	if err := pp.put(p); err != nil {
+		return err
+	}
+
+	if !protect {
+		pp.h.ConnManager().Unprotect(id, pinnedTag)
+	}
+
+	return nil
+}
+
+func (pp *PinnedPeers) List() []api.PinnedPeer {
+	pp.lk.Lock()
+	defer pp.lk.Unlock()
+
+	out := make([]api.PinnedPeer, 0, len(pp.peers))
+	for _, p := range pp.peers {
+		out = append(out, p)
+	}
+	return out
+}
+
+// put must be called with lk held
+func (pp *PinnedPeers) put(p api.PinnedPeer) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	if err := pp.ds.Put(pinnedPrefix.ChildString(p.AddrInfo.ID.Pretty()), b); err != nil {
+		return xerrors.Errorf("storing pinned peer: %w", err)
+	}
+
+	pp.peers[p.AddrInfo.ID] = p
+	pp.tag(p)
+	return nil
+}
+
+func (pp *PinnedPeers) tag(p api.PinnedPeer) {
+	pp.h.ConnManager().TagPeer(p.AddrInfo.ID, pinnedTag, pinnedWeight)
+	if p.Protected {
+		pp.h.ConnManager().Protect(p.AddrInfo.ID, pinnedTag)
+	}
+}
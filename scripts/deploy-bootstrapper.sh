@@ -31,4 +31,4 @@ ssh "$host" 'systemctl restart lotus-daemon'
 
 log 'Extracting addr info'
 
-ssh "$host" 'lotus net listen' | grep -v '/10' | grep -v '/127' >> build/bootstrap/bootstrappers.pi
+ssh "$host" 'lotus net listen' | grep -v '/10' | grep -v '/127' >> build/bootstrap/testnet.pi
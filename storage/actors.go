@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-sectorbuilder"
+	"github.com/ipfs/go-datastore"
+)
+
+// ActorMiner holds the components run for an additional miner actor served
+// by the same process as the main actor
+type ActorMiner struct {
+	SectorBuilderConfig *sectorbuilder.Config
+	SectorBuilder       sectorbuilder.Interface
+
+	// Datastore is the namespace of the metadata datastore holding the
+	// state of the actor
+	Datastore datastore.Batching
+
+	Miner    *Miner
+	FPoSt    *FPoStScheduler
+	Messages *MessageTracker
+}
+
+// ActorMiners maps additional miner actor addresses to their components
+type ActorMiners map[address.Address]*ActorMiner
+
+// Addresses returns the addresses of the actors in a stable order
+func (am ActorMiners) Addresses() []address.Address {
+	out := make([]address.Address, 0, len(am))
+	for maddr := range am {
+		out = append(out, maddr)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].String() < out[j].String()
+	})
+	return out
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+)
+
+func TestActorMinersAddresses(t *testing.T) {
+	am := ActorMiners{}
+	for _, id := range []uint64{1002, 1000, 1001} {
+		maddr, err := address.NewIDAddress(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		am[maddr] = &ActorMiner{}
+	}
+
+	for i := 0; i < 5; i++ {
+		addrs := am.Addresses()
+		if len(addrs) != 3 {
+			t.Fatalf("expected 3 addresses, got %d", len(addrs))
+		}
+		for j, exp := range []string{"t01000", "t01001", "t01002"} {
+			if addrs[j].String() != exp {
+				t.Fatalf("expected %s at %d, got %s", exp, j, addrs[j])
+			}
+		}
+	}
+}
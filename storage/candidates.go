@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+
+	"github.com/filecoin-project/go-sectorbuilder"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// candidateCacheSize is how many sets of election PoSt candidates are kept,
+// enough for the rounds precomputed ahead of the one being mined
+const candidateCacheSize = 8
+
+// candidateCache keeps the election PoSt candidates generated for a challenge
+// seed and proving set, so candidates precomputed for a round aren't
+// generated again when it's mined. Concurrent generations of the same
+// candidates wait for the first one.
+type candidateCache struct {
+	lk    sync.Mutex
+	cache *lru.Cache
+}
+
+type candidateResult struct {
+	done chan struct{}
+	cds  []sectorbuilder.EPostCandidate
+	err  error
+}
+
+func newCandidateCache() *candidateCache {
+	cache, err := lru.New(candidateCacheSize)
+	if err != nil {
+		panic(err) // only errors on a non-positive size
+	}
+	return &candidateCache{cache: cache}
+}
+
+// get returns the candidates for the seed and sectors, generating them with
+// gen if they weren't generated before
+func (cc *candidateCache) get(ctx context.Context, ssi sectorbuilder.SortedPublicSectorInfo, seed [32]byte, gen func() ([]sectorbuilder.EPostCandidate, error)) ([]sectorbuilder.EPostCandidate, error) {
+	key := candidateKey(ssi, seed)
+
+	cc.lk.Lock()
+	if v, ok := cc.cache.Get(key); ok {
+		cc.lk.Unlock()
+
+		res := v.(*candidateResult)
+		select {
+		case <-res.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if res.err == nil {
+			return res.cds, nil
+		}
+
+		// the generation the result was waiting for failed, possibly because
+		// its context was cancelled
+		return gen()
+	}
+
+	res := &candidateResult{done: make(chan struct{})}
+	cc.cache.Add(key, res)
+	cc.lk.Unlock()
+
+	res.cds, res.err = gen()
+	if res.err != nil {
+		cc.lk.Lock()
+		cc.cache.Remove(key)
+		cc.lk.Unlock()
+	}
+	close(res.done)
+
+	return res.cds, res.err
+}
+
+func candidateKey(ssi sectorbuilder.SortedPublicSectorInfo, seed [32]byte) [32]byte {
+	h := sha256.New()
+	h.Write(seed[:])
+
+	var idb [8]byte
+	for _, s := range ssi.Values() {
+		binary.BigEndian.PutUint64(idb[:], s.SectorID)
+		h.Write(idb[:])
+		h.Write(s.CommR[:])
+	}
+
+	var key [32]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
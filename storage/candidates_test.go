@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ffi "github.com/filecoin-project/filecoin-ffi"
+	"github.com/filecoin-project/go-sectorbuilder"
+)
+
+func TestCandidateCache(t *testing.T) {
+	cc := newCandidateCache()
+	ctx := context.Background()
+
+	ssi := sectorbuilder.NewSortedPublicSectorInfo([]ffi.PublicSectorInfo{{SectorID: 1}, {SectorID: 2}})
+	other := sectorbuilder.NewSortedPublicSectorInfo([]ffi.PublicSectorInfo{{SectorID: 1}})
+
+	var calls int
+	gen := func() ([]sectorbuilder.EPostCandidate, error) {
+		calls++
+		return []sectorbuilder.EPostCandidate{{SectorID: 1}}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		cds, err := cc.get(ctx, ssi, [32]byte{1}, gen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cds) != 1 {
+			t.Fatalf("expected 1 candidate, got %d", len(cds))
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected candidates to be generated once, got %d", calls)
+	}
+
+	// another seed or proving set needs other candidates
+	if _, err := cc.get(ctx, ssi, [32]byte{2}, gen); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cc.get(ctx, other, [32]byte{1}, gen); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 generations, got %d", calls)
+	}
+
+	// failures aren't kept
+	fail := func() ([]sectorbuilder.EPostCandidate, error) {
+		calls++
+		return nil, errors.New("boom")
+	}
+	if _, err := cc.get(ctx, ssi, [32]byte{3}, fail); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := cc.get(ctx, ssi, [32]byte{3}, gen); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 5 {
+		t.Fatalf("expected failed candidates to be generated again, got %d generations", calls)
+	}
+}
+
+func TestCandidateCacheWaits(t *testing.T) {
+	cc := newCandidateCache()
+	ctx := context.Background()
+
+	ssi := sectorbuilder.NewSortedPublicSectorInfo([]ffi.PublicSectorInfo{{SectorID: 1}})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := cc.get(ctx, ssi, [32]byte{1}, func() ([]sectorbuilder.EPostCandidate, error) {
+			close(started)
+			<-release
+			return []sectorbuilder.EPostCandidate{{SectorID: 1}}, nil
+		})
+		done <- err
+	}()
+	<-started
+
+	// a lookup while the candidates are generated waits for them
+	res := make(chan []sectorbuilder.EPostCandidate)
+	go func() {
+		cds, err := cc.get(ctx, ssi, [32]byte{1}, func() ([]sectorbuilder.EPostCandidate, error) {
+			return nil, errors.New("generated twice")
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		res <- cds
+	}()
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if cds := <-res; len(cds) != 1 {
+		t.Fatalf("expected the waiting lookup to get the candidates, got %d", len(cds))
+	}
+
+	// waiting stops with the context
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	block := make(chan struct{})
+	defer close(block)
+	go cc.get(ctx, ssi, [32]byte{2}, func() ([]sectorbuilder.EPostCandidate, error) { // nolint:errcheck
+		<-block
+		return nil, nil
+	})
+	for {
+		cc.lk.Lock()
+		n := cc.cache.Len()
+		cc.lk.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := cc.get(cctx, ssi, [32]byte{2}, nil); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -6,12 +6,15 @@ import (
 
 	ffi "github.com/filecoin-project/filecoin-ffi"
 	sectorbuilder "github.com/filecoin-project/go-sectorbuilder"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
 	"go.opencensus.io/trace"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/metrics"
 )
 
 func (s *FPoStScheduler) failPost(eps uint64) {
@@ -34,15 +37,22 @@ func (s *FPoStScheduler) doPost(ctx context.Context, eps uint64, ts *types.TipSe
 		ctx, span := trace.StartSpan(ctx, "FPoStScheduler.doPost")
 		defer span.End()
 
+		mctx, _ := tag.New(ctx, tag.Insert(metrics.MinerID, s.actor.String()))
+		start := time.Now()
+
 		proof, err := s.runPost(ctx, eps, ts)
 		if err != nil {
 			log.Errorf("runPost failed: %+v", err)
+			stats.Record(mctx, metrics.FPoStFailures.M(1))
 			s.failPost(eps)
 			return
 		}
 
+		stats.Record(mctx, metrics.FPoStDuration.M(float64(time.Since(start))/float64(time.Millisecond)))
+
 		if err := s.submitPost(ctx, proof); err != nil {
 			log.Errorf("submitPost failed: %+v", err)
+			stats.Record(mctx, metrics.FPoStFailures.M(1))
 			s.failPost(eps)
 			return
 		}
@@ -68,7 +78,7 @@ func (s *FPoStScheduler) declareFaults(ctx context.Context, fc uint64, params *a
 		GasPrice: types.NewInt(1),
 	}
 
-	sm, err := s.api.MpoolPushMessage(ctx, msg)
+	sm, err := s.api.MpoolPushMessage(ctx, msg, nil)
 	if err != nil {
 		return xerrors.Errorf("pushing faults message to mpool: %w", err)
 	}
@@ -89,6 +99,23 @@ func (s *FPoStScheduler) declareFaults(ctx context.Context, fc uint64, params *a
 func (s *FPoStScheduler) checkFaults(ctx context.Context, ssi sectorbuilder.SortedPublicSectorInfo) ([]uint64, error) {
 	faults := s.sb.Scrub(ssi)
 
+	if s.pathFaults != nil {
+		inSet := map[uint64]struct{}{}
+		for _, si := range ssi.Values() {
+			inSet[si.SectorID] = struct{}{}
+		}
+
+		for _, id := range s.pathFaults.Faults() {
+			if _, ok := inSet[id]; !ok {
+				continue
+			}
+			faults = append(faults, &sectorbuilder.Fault{
+				SectorID: id,
+				Err:      xerrors.New("sector storage path failed"),
+			})
+		}
+	}
+
 	declaredFaults := map[uint64]struct{}{}
 
 	{
@@ -169,7 +196,12 @@ func (s *FPoStScheduler) runPost(ctx context.Context, eps uint64, ts *types.TipS
 		"sectors", len(ssi.Values()),
 		"faults", len(faults))
 
-	scandidates, proof, err := s.sb.GenerateFallbackPoSt(ssi, seed, faults)
+	var scandidates []sectorbuilder.EPostCandidate
+	var proof []byte
+	err = s.prover.call(ctx, s.timeouts.FallbackPoSt, func() (err error) {
+		scandidates, proof, err = s.sb.GenerateFallbackPoSt(ssi, seed, faults)
+		return err
+	})
 	if err != nil {
 		return nil, xerrors.Errorf("running post failed: %w", err)
 	}
@@ -237,7 +269,7 @@ func (s *FPoStScheduler) submitPost(ctx context.Context, proof *actors.SubmitFal
 	}
 
 	// TODO: consider maybe caring about the output
-	sm, err := s.api.MpoolPushMessage(ctx, msg)
+	sm, err := s.api.MpoolPushMessage(ctx, msg, nil)
 	if err != nil {
 		return xerrors.Errorf("pushing message to mpool: %w", err)
 	}
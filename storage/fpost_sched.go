@@ -26,6 +26,11 @@ type FPoStScheduler struct {
 	actor  address.Address
 	worker address.Address
 
+	pathFaults *PathFaultTracker
+
+	timeouts ProofTimeouts
+	prover   *proofWorker
+
 	cur *types.TipSet
 
 	// if a post is in progress, this indicates for which ElectionPeriodStart
@@ -36,8 +41,16 @@ type FPoStScheduler struct {
 	failLk sync.Mutex
 }
 
-func NewFPoStScheduler(api storageMinerApi, sb sectorbuilder.Interface, actor address.Address, worker address.Address) *FPoStScheduler {
-	return &FPoStScheduler{api: api, sb: sb, actor: actor, worker: worker}
+func NewFPoStScheduler(api storageMinerApi, sb sectorbuilder.Interface, actor address.Address, worker address.Address, pathFaults *PathFaultTracker, timeouts ProofTimeouts) *FPoStScheduler {
+	return &FPoStScheduler{
+		api:        api,
+		sb:         sb,
+		actor:      actor,
+		worker:     worker,
+		pathFaults: pathFaults,
+		timeouts:   timeouts,
+		prover:     newProofWorker("fallback PoSt"),
+	}
 }
 
 func (s *FPoStScheduler) Run(ctx context.Context) {
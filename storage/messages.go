@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/messagepool"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// MessageTracker records all messages pushed through it and follows them until
+// they land on chain. It wraps the node API, so passing it to the sealing
+// pipeline and the PoSt scheduler instead of the node API tracks everything the
+// miner sends.
+type MessageTracker struct {
+	storageMinerApi
+
+	ctx context.Context
+	ds  datastore.Batching
+
+	lk sync.Mutex
+}
+
+// NewMessageTracker creates a tracker storing messages in ds. Messages are
+// followed until ctx is cancelled.
+func NewMessageTracker(ctx context.Context, api storageMinerApi, ds datastore.Batching) *MessageTracker {
+	return &MessageTracker{
+		storageMinerApi: api,
+		ctx:             ctx,
+		ds:              ds,
+	}
+}
+
+func (mt *MessageTracker) MpoolPushMessage(ctx context.Context, msg *types.Message, spec *api.MessageSendSpec) (*types.SignedMessage, error) {
+	smsg, err := mt.storageMinerApi.MpoolPushMessage(ctx, msg, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	mm := api.MinerMessage{
+		Cid:     smsg.Cid(),
+		Kind:    messageKind(&smsg.Message),
+		Message: smsg,
+		Pushed:  time.Now(),
+		Status:  api.MessagePending,
+	}
+	if err := mt.put(mm); err != nil {
+		log.Errorf("recording pushed message %s: %+v", mm.Cid, err)
+	}
+
+	go mt.wait(mm.Cid)
+
+	return smsg, nil
+}
+
+// Start follows messages left pending by a previous run
+func (mt *MessageTracker) Start() error {
+	msgs, err := mt.List()
+	if err != nil {
+		return xerrors.Errorf("listing tracked messages: %w", err)
+	}
+
+	for _, mm := range msgs {
+		if mm.Status == api.MessagePending {
+			go mt.wait(mm.Cid)
+		}
+	}
+	return nil
+}
+
+func (mt *MessageTracker) wait(c cid.Cid) {
+	mw, err := mt.StateWaitMsg(mt.ctx, c)
+	if err != nil {
+		if mt.ctx.Err() == nil {
+			log.Errorf("waiting for message %s: %+v", c, err)
+		}
+		return
+	}
+
+	err = mt.update(c, func(mm *api.MinerMessage) {
+		mm.Height = mw.TipSet.Height()
+		mm.ExitCode = mw.Receipt.ExitCode
+
+		mm.Status = api.MessageLanded
+		if mw.Receipt.ExitCode != 0 {
+			mm.Status = api.MessageFailed
+			log.Errorf("ALERT: %s message %s failed with exit code %d", mm.Kind, c, mw.Receipt.ExitCode)
+		}
+	})
+	if err != nil {
+		log.Errorf("updating message %s: %+v", c, err)
+	}
+}
+
+// List returns all tracked messages, the most recent first
+func (mt *MessageTracker) List() ([]api.MinerMessage, error) {
+	res, err := mt.ds.Query(query.Query{})
+	if err != nil {
+		return nil, err
+	}
+
+	ents, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]api.MinerMessage, len(ents))
+	for i, ent := range ents {
+		if err := json.Unmarshal(ent.Value, &out[i]); err != nil {
+			return nil, xerrors.Errorf("decoding message %s: %w", ent.Key, err)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Pushed.After(out[j].Pushed)
+	})
+	return out, nil
+}
+
+// Rebroadcast pushes a pending message to the network again
+func (mt *MessageTracker) Rebroadcast(ctx context.Context, c cid.Cid) error {
+	mm, err := mt.get(c)
+	if err != nil {
+		return err
+	}
+	if mm.Status != api.MessagePending {
+		return xerrors.Errorf("message %s is %s, not %s", c, mm.Status, api.MessagePending)
+	}
+
+	if _, err := mt.MpoolPush(ctx, mm.Message); err != nil {
+		return xerrors.Errorf("pushing message: %w", err)
+	}
+	return nil
+}
+
+// BumpFee replaces a pending message by the same message with a higher gas
+// price, returning the CID of the new message
+func (mt *MessageTracker) BumpFee(ctx context.Context, c cid.Cid, gasPrice types.BigInt) (cid.Cid, error) {
+	mm, err := mt.get(c)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if mm.Status != api.MessagePending {
+		return cid.Undef, xerrors.Errorf("message %s is %s, not %s", c, mm.Status, api.MessagePending)
+	}
+
+	if _, ok := sealingKinds[mm.Kind]; ok {
+		return cid.Undef, xerrors.Errorf("can't replace %s message %s, sealing waits for it to land", mm.Kind, c)
+	}
+
+	if minPrice := messagepool.ReplaceByFeeMinimum(mm.Message.Message.GasPrice); gasPrice.LessThan(minPrice) {
+		return cid.Undef, xerrors.Errorf("gas price has to be at least %s to replace the message", minPrice)
+	}
+
+	msg := mm.Message.Message
+	msg.GasPrice = gasPrice
+
+	smsg, err := mt.WalletSignMessage(ctx, msg.From, &msg)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("signing message: %w", err)
+	}
+	if _, err := mt.MpoolPush(ctx, smsg); err != nil {
+		return cid.Undef, xerrors.Errorf("pushing message: %w", err)
+	}
+
+	nc := smsg.Cid()
+	err = mt.put(api.MinerMessage{
+		Cid:     nc,
+		Kind:    mm.Kind,
+		Message: smsg,
+		Pushed:  time.Now(),
+		Status:  api.MessagePending,
+	})
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("recording replacement message: %w", err)
+	}
+
+	err = mt.update(c, func(mm *api.MinerMessage) {
+		mm.Status = api.MessageReplaced
+		mm.ReplacedBy = &nc
+	})
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	go mt.wait(nc)
+
+	return nc, nil
+}
+
+func (mt *MessageTracker) get(c cid.Cid) (*api.MinerMessage, error) {
+	b, err := mt.ds.Get(datastore.NewKey(c.String()))
+	if err == datastore.ErrNotFound {
+		return nil, xerrors.Errorf("message %s isn't tracked", c)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var mm api.MinerMessage
+	if err := json.Unmarshal(b, &mm); err != nil {
+		return nil, xerrors.Errorf("decoding message: %w", err)
+	}
+	return &mm, nil
+}
+
+func (mt *MessageTracker) put(mm api.MinerMessage) error {
+	b, err := json.Marshal(mm)
+	if err != nil {
+		return err
+	}
+	return mt.ds.Put(datastore.NewKey(mm.Cid.String()), b)
+}
+
+func (mt *MessageTracker) update(c cid.Cid, cb func(*api.MinerMessage)) error {
+	mt.lk.Lock()
+	defer mt.lk.Unlock()
+
+	mm, err := mt.get(c)
+	if err != nil {
+		return err
+	}
+	cb(mm)
+	return mt.put(*mm)
+}
+
+// messageKind names the messages sent by the miner
+// sealingKinds are the kinds of messages the sealing state machine stores in
+// sector state and waits for by CID, replacing them would stall the sector
+var sealingKinds = map[string]struct{}{
+	"PreCommit":     {},
+	"Commit":        {},
+	"DeclareFaults": {},
+}
+
+func messageKind(msg *types.Message) string {
+	if msg.To == actors.StorageMarketAddress {
+		switch msg.Method {
+		case actors.SMAMethods.PublishStorageDeals:
+			return "PublishDeals"
+		case actors.SMAMethods.AddBalance:
+			return "AddBalance"
+		}
+		return fmt.Sprintf("Market(%d)", msg.Method)
+	}
+
+	switch msg.Method {
+	case actors.MAMethods.PreCommitSector:
+		return "PreCommit"
+	case actors.MAMethods.ProveCommitSector:
+		return "Commit"
+	case actors.MAMethods.SubmitFallbackPoSt:
+		return "PoSt"
+	case actors.MAMethods.DeclareFaults:
+		return "DeclareFaults"
+	}
+	return fmt.Sprintf("Method(%d)", msg.Method)
+}
@@ -52,7 +52,8 @@ type storageMinerApi interface {
 	StateMarketStorageDeal(context.Context, uint64, *types.TipSet) (*actors.OnChainDeal, error)
 	StateMinerFaults(context.Context, address.Address, *types.TipSet) ([]uint64, error)
 
-	MpoolPushMessage(context.Context, *types.Message) (*types.SignedMessage, error)
+	MpoolPushMessage(context.Context, *types.Message, *api.MessageSendSpec) (*types.SignedMessage, error)
+	MpoolPush(context.Context, *types.SignedMessage) (cid.Cid, error)
 
 	ChainHead(context.Context) (*types.TipSet, error)
 	ChainNotify(context.Context) (<-chan []*store.HeadChange, error)
@@ -62,6 +63,7 @@ type storageMinerApi interface {
 	ChainReadObj(context.Context, cid.Cid) ([]byte, error)
 
 	WalletSign(context.Context, address.Address, []byte) (*types.Signature, error)
+	WalletSignMessage(context.Context, address.Address, *types.Message) (*types.SignedMessage, error)
 	WalletBalance(context.Context, address.Address) (types.BigInt, error)
 	WalletHas(context.Context, address.Address) (bool, error)
 }
@@ -115,26 +117,45 @@ func (m *Miner) runPreflightChecks(ctx context.Context) error {
 
 type SectorBuilderEpp struct {
 	sb sectorbuilder.Interface
+
+	timeouts   ProofTimeouts
+	prover     *proofWorker
+	candidates *candidateCache
 }
 
-func NewElectionPoStProver(sb sectorbuilder.Interface) *SectorBuilderEpp {
-	return &SectorBuilderEpp{sb}
+func NewElectionPoStProver(sb sectorbuilder.Interface, timeouts ProofTimeouts) *SectorBuilderEpp {
+	return &SectorBuilderEpp{
+		sb:         sb,
+		timeouts:   timeouts,
+		prover:     newProofWorker("election PoSt"),
+		candidates: newCandidateCache(),
+	}
 }
 
 var _ gen.ElectionPoStProver = (*SectorBuilderEpp)(nil)
 
+// GenerateCandidates generates the election PoSt candidates of the proving
+// set for the randomness. Candidates generated before, e.g. precomputed with
+// gen.PrecomputeCandidates, are reused.
 func (epp *SectorBuilderEpp) GenerateCandidates(ctx context.Context, ssi sectorbuilder.SortedPublicSectorInfo, rand []byte) ([]sectorbuilder.EPostCandidate, error) {
-	start := time.Now()
-	var faults []uint64 // TODO
-
 	var randbuf [32]byte
 	copy(randbuf[:], rand)
-	cds, err := epp.sb.GenerateEPostCandidates(ssi, randbuf, faults)
-	if err != nil {
-		return nil, err
-	}
-	log.Infof("Generate candidates took %s", time.Since(start))
-	return cds, nil
+
+	return epp.candidates.get(ctx, ssi, randbuf, func() ([]sectorbuilder.EPostCandidate, error) {
+		start := time.Now()
+		var faults []uint64 // TODO
+
+		var cds []sectorbuilder.EPostCandidate
+		err := epp.prover.call(ctx, epp.timeouts.ElectionPoSt, func() (err error) {
+			cds, err = epp.sb.GenerateEPostCandidates(ssi, randbuf, faults)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("Generate candidates took %s", time.Since(start))
+		return cds, nil
+	})
 }
 
 func (epp *SectorBuilderEpp) ComputeProof(ctx context.Context, ssi sectorbuilder.SortedPublicSectorInfo, rand []byte, winners []sectorbuilder.EPostCandidate) ([]byte, error) {
@@ -143,7 +164,11 @@ func (epp *SectorBuilderEpp) ComputeProof(ctx context.Context, ssi sectorbuilder
 		return []byte("valid proof"), nil
 	}
 	start := time.Now()
-	proof, err := epp.sb.ComputeElectionPoSt(ssi, rand, winners)
+	var proof []byte
+	err := epp.prover.call(ctx, epp.timeouts.ElectionPoSt, func() (err error) {
+		proof, err = epp.sb.ComputeElectionPoSt(ssi, rand, winners)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
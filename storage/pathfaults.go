@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-sectorbuilder"
+	"github.com/filecoin-project/go-sectorbuilder/fs"
+
+	"github.com/filecoin-project/lotus/metrics"
+)
+
+const (
+	// PathCheckInterval is the time between storage path checks
+	PathCheckInterval = 30 * time.Second
+	// PathCheckTimeout is how long a path can take to respond before it's
+	// considered failed, e.g. when a network mount hangs
+	PathCheckTimeout = 10 * time.Second
+)
+
+// PathFaultTracker watches the sector storage paths. When a path becomes
+// unreadable, sectors sealed on it are reported as faults right away, so the
+// next fallback PoSt declares them instead of failing on them.
+type PathFaultTracker struct {
+	api   storageMinerApi
+	sb    sectorbuilder.Interface
+	actor address.Address
+	paths []fs.PathConfig
+
+	lk     sync.Mutex
+	failed map[string]error
+	faulty map[uint64]string // sector -> path
+}
+
+func NewPathFaultTracker(api storageMinerApi, sb sectorbuilder.Interface, actor address.Address, paths []fs.PathConfig) *PathFaultTracker {
+	return &PathFaultTracker{
+		api:   api,
+		sb:    sb,
+		actor: actor,
+		paths: paths,
+
+		failed: map[string]error{},
+		faulty: map[uint64]string{},
+	}
+}
+
+func (t *PathFaultTracker) Run(ctx context.Context) {
+	tick := time.NewTicker(PathCheckInterval)
+	defer tick.Stop()
+
+	for {
+		t.check(ctx)
+
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Faults returns the sectors on failed storage paths
+func (t *PathFaultTracker) Faults() []uint64 {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	out := make([]uint64, 0, len(t.faulty))
+	for id := range t.faulty {
+		out = append(out, id)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+func (t *PathFaultTracker) check(ctx context.Context) {
+	for _, p := range t.paths {
+		err := checkPath(ctx, p.Path)
+		if ctx.Err() != nil {
+			return
+		}
+
+		t.lk.Lock()
+		_, wasFailed := t.failed[p.Path]
+		t.lk.Unlock()
+
+		switch {
+		case err != nil && !wasFailed:
+			sectors, serr := t.sectorsOnPath(ctx, p.Path)
+			if serr != nil {
+				log.Errorf("finding sectors on failed storage path %s: %+v", p.Path, serr)
+			}
+
+			log.Errorf("ALERT: storage path %s failed, marking %d sectors as faulty: %+v", p.Path, len(sectors), err)
+
+			t.lk.Lock()
+			t.failed[p.Path] = err
+			for _, id := range sectors {
+				t.faulty[id] = p.Path
+			}
+			t.lk.Unlock()
+		case err == nil && wasFailed:
+			log.Warnf("storage path %s is readable again", p.Path)
+
+			t.lk.Lock()
+			delete(t.failed, p.Path)
+			for id, path := range t.faulty {
+				if path == p.Path {
+					delete(t.faulty, id)
+				}
+			}
+			t.lk.Unlock()
+		}
+	}
+
+	t.lk.Lock()
+	failed := len(t.failed)
+	t.lk.Unlock()
+
+	mctx, _ := tag.New(ctx, tag.Insert(metrics.MinerID, t.actor.String()))
+	stats.Record(mctx, metrics.StoragePathFailures.M(int64(failed)))
+}
+
+func (t *PathFaultTracker) sectorsOnPath(ctx context.Context, path string) ([]uint64, error) {
+	sectors, err := t.api.StateMinerSectors(ctx, t.actor, nil)
+	if err != nil {
+		return nil, xerrors.Errorf("getting miner sectors: %w", err)
+	}
+
+	prefix := filepath.Clean(path) + string(filepath.Separator)
+
+	var out []uint64
+	for _, sector := range sectors {
+		sp, err := t.sb.SectorPath(fs.DataSealed, sector.SectorID)
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(string(sp), prefix) {
+			out = append(out, sector.SectorID)
+		}
+	}
+	return out, nil
+}
+
+// checkPath checks that the sealed sector directory of a storage path can be
+// listed in time
+func checkPath(ctx context.Context, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, PathCheckTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		if _, err := os.Stat(path); err != nil {
+			done <- err
+			return
+		}
+		_, err := ioutil.ReadDir(filepath.Join(path, string(fs.DataSealed)))
+		if os.IsNotExist(err) {
+			err = nil // nothing sealed on this path yet
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return xerrors.Errorf("storage path not responding: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/build"
+)
+
+// ProofTimeouts limits how long the miner waits for the proofs library, so a
+// hung device doesn't block PoSt scheduling. A timeout of 0 waits until the
+// computation returns or the context is cancelled.
+type ProofTimeouts struct {
+	FallbackPoSt time.Duration
+	ElectionPoSt time.Duration
+}
+
+// DefaultProofTimeouts gives up on fallback PoSts once there is no time left
+// to submit them before power gets slashed. Election PoSts aren't limited.
+func DefaultProofTimeouts() ProofTimeouts {
+	return ProofTimeouts{
+		FallbackPoSt: time.Duration(build.SlashablePowerDelay-build.FallbackPoStDelay) * time.Duration(build.BlockDelay) * time.Second,
+	}
+}
+
+// proofWorker runs computations of the proofs library one at a time
+type proofWorker struct {
+	name string
+	busy chan struct{}
+}
+
+func newProofWorker(name string) *proofWorker {
+	return &proofWorker{
+		name: name,
+		busy: make(chan struct{}, 1),
+	}
+}
+
+// call runs f on the worker until it returns, ctx is cancelled or the timeout
+// passes. Calls waiting for the worker are cancelled with ctx. Native
+// computations can't be interrupted once started, so an abandoned computation
+// keeps the worker busy until it returns instead of more work being piled
+// onto a hung device.
+func (w *proofWorker) call(ctx context.Context, timeout time.Duration, f func() error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	select {
+	case w.busy <- struct{}{}:
+	case <-ctx.Done():
+		return xerrors.Errorf("waiting for %s worker: %w", w.name, ctx.Err())
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		err := f()
+		<-w.busy
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Errorf("abandoning %s after %s, the worker stays busy until it returns: %s", w.name, time.Since(start), ctx.Err())
+
+	go func() {
+		err := <-done
+		log.Warnw("abandoned proof computation finished", "name", w.name, "took", time.Since(start), "error", err)
+	}()
+
+	return xerrors.Errorf("%s: %w", w.name, ctx.Err())
+}
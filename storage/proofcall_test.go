@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+func TestProofWorkerTimeout(t *testing.T) {
+	w := newProofWorker("test")
+
+	release := make(chan struct{})
+	err := w.call(context.Background(), 10*time.Millisecond, func() error {
+		<-release
+		return nil
+	})
+	if !xerrors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+
+	// the abandoned computation keeps the worker busy
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = w.call(ctx, 0, func() error {
+		t.Fatal("ran while the worker was busy")
+		return nil
+	})
+	if !xerrors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled error, got %v", err)
+	}
+
+	close(release)
+
+	ran := false
+	if err := w.call(context.Background(), time.Second, func() error {
+		ran = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !ran {
+		t.Fatal("computation didn't run")
+	}
+}
+
+func TestProofWorkerCancelQueued(t *testing.T) {
+	w := newProofWorker("test")
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- w.call(context.Background(), 0, func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := make(chan error, 1)
+	go func() {
+		queued <- w.call(ctx, 0, func() error {
+			t.Error("queued computation ran")
+			return nil
+		})
+	}()
+	cancel()
+
+	if err := <-queued; !xerrors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled error, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProofWorkerError(t *testing.T) {
+	w := newProofWorker("test")
+
+	expect := xerrors.New("failed")
+	if err := w.call(context.Background(), time.Second, func() error {
+		return expect
+	}); err != expect {
+		t.Fatalf("expected %v, got %v", expect, err)
+	}
+}
@@ -14,8 +14,8 @@ func (m *Miner) AllocatePiece(size uint64) (sectorID uint64, offset uint64, err
 	return m.sealing.AllocatePiece(size)
 }
 
-func (m *Miner) SealPiece(ctx context.Context, size uint64, r io.Reader, sectorID uint64, dealID uint64) error {
-	return m.sealing.SealPiece(ctx, size, r, sectorID, dealID)
+func (m *Miner) SealPiece(ctx context.Context, size uint64, r io.Reader, sectorID uint64, offset uint64, dealID uint64) error {
+	return m.sealing.SealPiece(ctx, size, r, sectorID, offset, dealID)
 }
 
 func (m *Miner) ListSectors() ([]sealing.SectorInfo, error) {
@@ -33,3 +33,15 @@ func (m *Miner) PledgeSector() error {
 func (m *Miner) ForceSectorState(ctx context.Context, id uint64, state api.SectorState) error {
 	return m.sealing.ForceSectorState(ctx, id, state)
 }
+
+func (m *Miner) StartPackingSector(id uint64) error {
+	return m.sealing.StartPacking(id)
+}
+
+func (m *Miner) RetrySector(ctx context.Context, id uint64) error {
+	return m.sealing.RetrySector(ctx, id)
+}
+
+func (m *Miner) AbortSector(ctx context.Context, id uint64) error {
+	return m.sealing.AbortSector(ctx, id)
+}
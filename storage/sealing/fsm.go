@@ -6,10 +6,13 @@ import (
 	"reflect"
 	"time"
 
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/lib/statemachine"
+	"github.com/filecoin-project/lotus/metrics"
 )
 
 func (m *Sealing) Plan(events []statemachine.Event, user interface{}) (interface{}, error) {
@@ -30,8 +33,12 @@ func (m *Sealing) Plan(events []statemachine.Event, user interface{}) (interface
 }
 
 var fsmPlanners = []func(events []statemachine.Event, state *SectorInfo) error{
-	api.UndefinedSectorState: planOne(on(SectorStart{}, api.Packing)),
-	api.Packing:              planOne(on(SectorPacked{}, api.Unsealed)),
+	api.UndefinedSectorState: planOne(
+		on(SectorStart{}, api.Packing),
+		on(SectorStartWaitDeals{}, api.WaitDeals),
+	),
+	api.WaitDeals: planWaitDeals,
+	api.Packing:   planOne(on(SectorPacked{}, api.Unsealed)),
 	api.Unsealed: planOne(
 		on(SectorSealed{}, api.PreCommitting),
 		on(SectorSealFailed{}, api.SealFailed),
@@ -74,12 +81,20 @@ var fsmPlanners = []func(events []statemachine.Event, state *SectorInfo) error{
 		on(SectorFaultReported{}, api.FaultReported),
 	),
 	api.FaultedFinal: final,
+
+	api.Aborted: planOne(),
 }
 
 func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(statemachine.Context, SectorInfo) error, error) {
 	/////
 	// First process all events
 
+	prevState := state.State
+	var enteredAt uint64
+	if len(state.Log) > 0 {
+		enteredAt = state.Log[len(state.Log)-1].Timestamp
+	}
+
 	for _, event := range events {
 		l := Log{
 			Timestamp: uint64(time.Now().Unix()),
@@ -103,6 +118,19 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 		return nil, xerrors.Errorf("running planner for state %s failed: %w", api.SectorStates[state.State], err)
 	}
 
+	if state.State != prevState {
+		state.Log = append(state.Log, Log{
+			Timestamp: uint64(time.Now().Unix()),
+			Message:   fmt.Sprintf("%s -> %s", api.SectorStates[prevState], api.SectorStates[state.State]),
+			Kind:      "state",
+		})
+	}
+
+	if state.State != prevState && enteredAt > 0 {
+		ctx, _ := tag.New(context.TODO(), tag.Insert(metrics.SectorState, api.SectorStates[prevState]))
+		stats.Record(ctx, metrics.SealingStageDuration.M(float64(time.Now().Unix()-int64(enteredAt))))
+	}
+
 	/////
 	// Now decide what to do next
 
@@ -111,6 +139,9 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 		*   Empty
 		|   |
 		|   v
+		*<- WaitDeals <- incoming deals
+		|   |
+		|   v
 		*<- Packing <- incoming
 		|   |
 		|   v
@@ -142,6 +173,8 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 
 	switch state.State {
 	// Happy path
+	case api.WaitDeals:
+		// pieces are added by SealPiece, see packing.go
 	case api.Packing:
 		return m.handlePacking, nil
 	case api.Unsealed:
@@ -169,6 +202,8 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 		log.Warnf("sector %d entered unimplemented state 'SealCommitFailed'", state.SectorID)
 	case api.CommitFailed:
 		log.Warnf("sector %d entered unimplemented state 'CommitFailed'", state.SectorID)
+	case api.Aborted:
+		log.Infof("sector %d was aborted", state.SectorID)
 
 		// Faults
 	case api.Faulty:
@@ -220,6 +255,24 @@ func planCommitting(events []statemachine.Event, state *SectorInfo) error {
 	return nil
 }
 
+func planWaitDeals(events []statemachine.Event, state *SectorInfo) error {
+	for _, event := range events {
+		switch e := event.User.(type) {
+		case globalMutator:
+			if e.applyGlobal(state) {
+				return nil
+			}
+		case SectorAddPieces:
+			e.apply(state)
+		case SectorStartPacking:
+			state.State = api.Packing
+		default:
+			return xerrors.Errorf("planWaitDeals got event of unknown type %T, events: %+v", event.User, events)
+		}
+	}
+	return nil
+}
+
 func (m *Sealing) restartSectors(ctx context.Context) error {
 	trackedSectors, err := m.ListSectors()
 	if err != nil {
@@ -227,6 +280,13 @@ func (m *Sealing) restartSectors(ctx context.Context) error {
 	}
 
 	for _, sector := range trackedSectors {
+		if sector.State == api.WaitDeals {
+			if err := m.restoreOpen(sector); err != nil {
+				log.Errorf("restoring sector %d waiting for deals: %+v", sector.SectorID, err)
+			}
+			continue
+		}
+
 		if err := m.sectors.Send(sector.SectorID, SectorRestart{}); err != nil {
 			log.Errorf("restarting sector %d: %+v", sector.SectorID, err)
 		}
@@ -241,6 +301,51 @@ func (m *Sealing) ForceSectorState(ctx context.Context, id uint64, state api.Sec
 	return m.sectors.Send(id, SectorForceState{state})
 }
 
+// RetrySector re-runs the current step of a sector, see SectorRetry. Only
+// failed sectors and sectors waiting for a message can be retried, steps which
+// are running or send messages are left alone.
+func (m *Sealing) RetrySector(ctx context.Context, id uint64) error {
+	info, err := m.GetSectorInfo(id)
+	if err != nil {
+		return xerrors.Errorf("getting sector info: %w", err)
+	}
+
+	_, failed := retryStates[info.State]
+	if !failed && !retryWaitStates[info.State] {
+		return xerrors.Errorf("can't retry sector %d in state %s", id, api.SectorStates[info.State])
+	}
+
+	return m.sectors.Send(id, SectorRetry{})
+}
+
+// AbortSector moves a sector which isn't committed on chain to the Aborted
+// state. Deals in the sector are not sealed, and if the sector was already
+// precommitted, the precommit deposit is lost.
+func (m *Sealing) AbortSector(ctx context.Context, id uint64) error {
+	info, err := m.GetSectorInfo(id)
+	if err != nil {
+		return xerrors.Errorf("getting sector info: %w", err)
+	}
+
+	switch info.State {
+	case api.CommitWait, api.FinalizeSector, api.Proving, api.Faulty, api.FaultReported, api.FaultedFinal, api.Aborted:
+		return xerrors.Errorf("can't abort sector %d in state %s", id, api.SectorStates[info.State])
+	}
+
+	for _, deal := range info.deals() {
+		if deal != 0 {
+			log.Warnf("aborting sector %d with deal %d", id, deal)
+		}
+	}
+	if info.PreCommitMessage != nil {
+		log.Warnf("aborting precommitted sector %d, the precommit deposit will be lost", id)
+	}
+
+	m.forgetOpen(id)
+
+	return m.sectors.Send(id, SectorAbort{})
+}
+
 func final(events []statemachine.Event, state *SectorInfo) error {
 	return xerrors.Errorf("didn't expect any events in state %s, got %+v", api.SectorStates[state.State], events)
 }
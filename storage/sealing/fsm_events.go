@@ -42,6 +42,40 @@ func (evt SectorForceState) applyGlobal(state *SectorInfo) bool {
 	return true
 }
 
+// retryStates maps failed states to the state which is retried
+var retryStates = map[api.SectorState]api.SectorState{
+	api.SealFailed:       api.Unsealed,
+	api.PreCommitFailed:  api.PreCommitting,
+	api.SealCommitFailed: api.Committing,
+	api.CommitFailed:     api.Committing,
+}
+
+// retryWaitStates are the states waiting for a message on chain, which can be
+// retried without sending messages again
+var retryWaitStates = map[api.SectorState]bool{
+	api.WaitSeed:      true,
+	api.CommitWait:    true,
+	api.FaultReported: true,
+}
+
+// SectorRetry re-runs the handler of the current state, moving failed
+// sectors back to the state which failed first
+type SectorRetry struct{}
+
+func (evt SectorRetry) applyGlobal(state *SectorInfo) bool {
+	if next, ok := retryStates[state.State]; ok {
+		state.State = next
+	}
+	return true
+}
+
+type SectorAbort struct{}
+
+func (evt SectorAbort) applyGlobal(state *SectorInfo) bool {
+	state.State = api.Aborted
+	return true
+}
+
 // Normal path
 
 type SectorStart struct {
@@ -54,6 +88,26 @@ func (evt SectorStart) apply(state *SectorInfo) {
 	state.Pieces = evt.pieces
 }
 
+type SectorStartWaitDeals struct {
+	id     uint64
+	pieces []Piece
+}
+
+func (evt SectorStartWaitDeals) apply(state *SectorInfo) {
+	state.SectorID = evt.id
+	state.Pieces = evt.pieces
+}
+
+type SectorAddPieces struct{ pieces []Piece }
+
+func (evt SectorAddPieces) apply(state *SectorInfo) {
+	state.Pieces = append(state.Pieces, evt.pieces...)
+}
+
+type SectorStartPacking struct{}
+
+func (evt SectorStartPacking) apply(*SectorInfo) {}
+
 type SectorPacked struct{ pieces []Piece }
 
 func (evt SectorPacked) apply(state *SectorInfo) {
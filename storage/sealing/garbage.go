@@ -77,7 +77,7 @@ func (m *Sealing) pledgeSector(ctx context.Context, sectorID uint64, existingPie
 		GasLimit: types.NewInt(1000000),
 		Method:   actors.SMAMethods.PublishStorageDeals,
 		Params:   params,
-	})
+	}, nil)
 	if err != nil {
 		return nil, err
 	}
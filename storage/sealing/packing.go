@@ -0,0 +1,477 @@
+package sealing
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	sectorbuilder "github.com/filecoin-project/go-sectorbuilder"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/lib/padreader"
+)
+
+// WaitDealsDelay is how long a sector accepts new deals before the rest of it
+// is filled with pledge pieces and sealing starts
+var WaitDealsDelay = time.Hour
+
+// allocTimeout is how long an allocated piece can wait to be written once it's
+// its turn. After that its space is given up, and filled with pledge pieces
+// when the pieces allocated after it are written.
+var allocTimeout = 10 * time.Minute
+
+// allocation is space in a sector reserved for a piece by AllocatePiece
+type allocation struct {
+	offset uint64
+	size   uint64
+
+	// turn is closed once the pieces allocated before this one were written
+	// or given up, or the sector can't take pieces anymore
+	turn     chan struct{}
+	turnOnce sync.Once
+	writing  bool
+	timer    *time.Timer
+}
+
+func (a *allocation) startTurn() {
+	a.turnOnce.Do(func() {
+		close(a.turn)
+	})
+}
+
+// openSector is a sector in the WaitDeals state new deal pieces are added to
+type openSector struct {
+	id uint64
+	// started is false until the first pieces of the sector are recorded in
+	// the state machine
+	started bool
+
+	// pieceSizes contains the sizes of all pieces in the sector, including
+	// pledge pieces used for alignment
+	pieceSizes []uint64
+	used       uint64
+
+	// reserved is used plus the space of pending allocations, which are
+	// written in order
+	reserved uint64
+	pending  []*allocation
+
+	// closed sectors take no new allocations, they start sealing once the
+	// pending pieces are written
+	closed bool
+	// aborted sectors can't take any pieces
+	aborted bool
+
+	timer *time.Timer
+}
+
+func (s *openSector) add(pieces ...Piece) {
+	for _, p := range pieces {
+		s.pieceSizes = append(s.pieceSizes, p.Size)
+		s.used += p.Size
+	}
+}
+
+func (s *openSector) isPending(a *allocation) bool {
+	for _, pa := range s.pending {
+		if pa == a {
+			return true
+		}
+	}
+	return false
+}
+
+// alignPadding returns the number of (unpadded) bytes which need to be
+// written after `used` bytes so that a piece of the given size is aligned to
+// its own size in the sector
+func alignPadding(used uint64, size uint64) uint64 {
+	return (size - used%size) % size
+}
+
+// AllocatePiece reserves space for a piece in the open sector, starting a new
+// sector when there is none or the piece doesn't fit. The returned offset is
+// where the piece will start in the unsealed sector.
+//
+// The piece is written by calling SealPiece with the returned sector ID and
+// offset. Pieces are written in the order they were allocated, space which
+// isn't written in time is given up, see allocTimeout.
+func (m *Sealing) AllocatePiece(size uint64) (sectorID uint64, offset uint64, err error) {
+	if padreader.PaddedSize(size) != size {
+		return 0, 0, xerrors.Errorf("cannot allocate unpadded piece")
+	}
+
+	ubytes := sectorbuilder.UserBytesForSectorSize(m.sb.SectorSize())
+	if size > ubytes {
+		return 0, 0, xerrors.Errorf("piece of %d bytes doesn't fit in a sector of %d bytes", size, ubytes)
+	}
+
+	m.unsealedLk.Lock()
+	defer m.unsealedLk.Unlock()
+
+	if m.open != nil {
+		pad := alignPadding(m.open.reserved, size)
+		if m.open.reserved+pad+size <= ubytes {
+			return m.open.id, m.allocate(m.open, m.open.reserved+pad, size), nil
+		}
+
+		log.Infof("piece of %d bytes doesn't fit in sector %d (%d/%d reserved), starting a new sector", size, m.open.id, m.open.reserved, ubytes)
+		if err := m.closeSector(m.open); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	sid, err := m.sb.AcquireSectorId()
+	if err != nil {
+		return 0, 0, xerrors.Errorf("acquiring sector ID: %w", err)
+	}
+
+	m.open = &openSector{id: sid}
+	return sid, m.allocate(m.open, 0, size), nil
+}
+
+// allocate reserves space at offset in the sector. Must be called with
+// unsealedLk held.
+func (m *Sealing) allocate(s *openSector, offset uint64, size uint64) uint64 {
+	a := &allocation{
+		offset: offset,
+		size:   size,
+		turn:   make(chan struct{}),
+	}
+	s.reserved = offset + size
+	s.pending = append(s.pending, a)
+	if len(s.pending) == 1 {
+		m.startTurn(s, a)
+	}
+	return offset
+}
+
+// startTurn lets the piece be written, giving its space up if that doesn't
+// start in time. Must be called with unsealedLk held.
+func (m *Sealing) startTurn(s *openSector, a *allocation) {
+	a.startTurn()
+	a.timer = time.AfterFunc(allocTimeout, func() {
+		m.unsealedLk.Lock()
+		defer m.unsealedLk.Unlock()
+
+		if a.writing || !s.isPending(a) {
+			return
+		}
+
+		log.Warnf("piece of %d bytes at offset %d in sector %d wasn't written in %s, giving up its space", a.size, a.offset, s.id, allocTimeout)
+		if err := m.finishAllocation(s, a); err != nil {
+			log.Errorf("starting sealing of sector %d: %+v", s.id, err)
+		}
+	})
+}
+
+// finishAllocation removes a written or given up piece from the pending
+// pieces of the sector, letting the next one be written, and starts sealing
+// closed sectors once no pieces are pending. Must be called with unsealedLk
+// held.
+func (m *Sealing) finishAllocation(s *openSector, a *allocation) error {
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+
+	for i, pa := range s.pending {
+		if pa != a {
+			continue
+		}
+		s.pending = append(s.pending[:i], s.pending[i+1:]...)
+		if i == 0 && len(s.pending) > 0 {
+			m.startTurn(s, s.pending[0])
+		}
+		break
+	}
+
+	if len(s.pending) > 0 {
+		return nil
+	}
+
+	// space given up at the end of the sector can be allocated again
+	s.reserved = s.used
+
+	if s.closed {
+		return m.closeSector(s)
+	}
+	return nil
+}
+
+// SealPiece writes a piece allocated with AllocatePiece to the sector,
+// preceded by pledge pieces when padding is needed to align it. It waits
+// until the pieces allocated before it were written.
+func (m *Sealing) SealPiece(ctx context.Context, size uint64, r io.Reader, sectorID uint64, offset uint64, dealID uint64) error {
+	m.unsealedLk.Lock()
+	s, a := m.allocation(sectorID, offset)
+	m.unsealedLk.Unlock()
+	if a == nil || a.size != size {
+		return xerrors.Errorf("no piece of %d bytes allocated at offset %d in sector %d", size, offset, sectorID)
+	}
+
+	select {
+	case <-a.turn:
+	case <-ctx.Done():
+		m.unsealedLk.Lock()
+		defer m.unsealedLk.Unlock()
+
+		if s.isPending(a) && !s.aborted {
+			if err := m.finishAllocation(s, a); err != nil {
+				log.Errorf("starting sealing of sector %d: %+v", sectorID, err)
+			}
+		}
+		return ctx.Err()
+	}
+
+	m.unsealedLk.Lock()
+	if s.aborted {
+		m.unsealedLk.Unlock()
+		return xerrors.Errorf("sector %d isn't accepting pieces", sectorID)
+	}
+	if !s.isPending(a) {
+		m.unsealedLk.Unlock()
+		return xerrors.Errorf("piece at offset %d in sector %d wasn't written in %s", offset, sectorID, allocTimeout)
+	}
+	a.writing = true
+	a.timer.Stop()
+
+	// only the first pending piece is written, the written pieces don't
+	// change until it's done
+	used := s.used
+	pieceSizes := append([]uint64(nil), s.pieceSizes...)
+	m.unsealedLk.Unlock()
+
+	log.Infof("Seal piece for deal %d in sector %d", dealID, sectorID)
+
+	pieces, err := m.writePiece(ctx, sectorID, used, pieceSizes, a, r, dealID)
+
+	m.unsealedLk.Lock()
+	defer m.unsealedLk.Unlock()
+
+	if s.aborted {
+		return xerrors.Errorf("sector %d was aborted while writing the piece", sectorID)
+	}
+
+	if len(pieces) > 0 {
+		s.add(pieces...)
+		if rerr := m.recordPieces(s, pieces); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+
+	if ferr := m.finishAllocation(s, a); ferr != nil && err == nil {
+		err = ferr
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.used == sectorbuilder.UserBytesForSectorSize(m.sb.SectorSize()) && !s.closed {
+		log.Infof("sector %d is full, starting sealing", sectorID)
+		return m.closeSector(s)
+	}
+
+	return nil
+}
+
+// writePiece adds pledge pieces filling the space before the allocation, then
+// the piece itself. It returns the pieces written, which only include the
+// piece when there was no error.
+func (m *Sealing) writePiece(ctx context.Context, sectorID uint64, used uint64, pieceSizes []uint64, a *allocation, r io.Reader, dealID uint64) ([]Piece, error) {
+	var pieces []Piece
+	if pad := a.offset - used; pad > 0 {
+		fillerSizes, err := fillersFromRem(pad)
+		if err != nil {
+			return nil, xerrors.Errorf("computing padding: %w", err)
+		}
+
+		pieces, err = m.pledgeSector(ctx, sectorID, pieceSizes, fillerSizes...)
+		if err != nil {
+			return nil, xerrors.Errorf("adding padding pieces (%v): %w", fillerSizes, err)
+		}
+		for _, p := range pieces {
+			pieceSizes = append(pieceSizes, p.Size)
+		}
+	}
+
+	ppi, err := m.sb.AddPiece(ctx, a.size, sectorID, r, pieceSizes)
+	if err != nil {
+		return pieces, xerrors.Errorf("adding piece to sector: %w", err)
+	}
+
+	return append(pieces, Piece{
+		DealID: dealID,
+
+		Size:  ppi.Size,
+		CommP: ppi.CommP[:],
+	}), nil
+}
+
+// filling returns the open or closing sector with the given ID. Must be
+// called with unsealedLk held.
+func (m *Sealing) filling(sectorID uint64) *openSector {
+	if m.open != nil && m.open.id == sectorID {
+		return m.open
+	}
+	return m.closing[sectorID]
+}
+
+// allocation finds the pending piece at offset in the sector. Must be called
+// with unsealedLk held.
+func (m *Sealing) allocation(sectorID uint64, offset uint64) (*openSector, *allocation) {
+	s := m.filling(sectorID)
+	if s == nil {
+		return nil, nil
+	}
+	for _, a := range s.pending {
+		if a.offset == offset {
+			return s, a
+		}
+	}
+	return s, nil
+}
+
+// recordPieces adds pieces to the state of the sector, starting its state
+// machine in the WaitDeals state if needed. Must be called with unsealedLk
+// held.
+func (m *Sealing) recordPieces(s *openSector, pieces []Piece) error {
+	if s.started {
+		return m.sectors.Send(s.id, SectorAddPieces{pieces: pieces})
+	}
+
+	if err := m.sectors.Send(s.id, SectorStartWaitDeals{id: s.id, pieces: pieces}); err != nil {
+		return err
+	}
+	s.started = true
+	if !s.closed {
+		m.startWaitTimer(s)
+	}
+	return nil
+}
+
+func (m *Sealing) startWaitTimer(s *openSector) {
+	s.timer = time.AfterFunc(WaitDealsDelay, func() {
+		m.unsealedLk.Lock()
+		defer m.unsealedLk.Unlock()
+
+		if s.closed || s.aborted {
+			return
+		}
+
+		log.Infof("sector %d waited %s for deals, starting sealing", s.id, WaitDealsDelay)
+		if err := m.closeSector(s); err != nil {
+			log.Errorf("starting sealing of sector %d: %+v", s.id, err)
+		}
+	})
+}
+
+// closeSector stops allocating pieces in the sector and moves it to the
+// Packing state once the pending pieces are written. Must be called with
+// unsealedLk held.
+func (m *Sealing) closeSector(s *openSector) error {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if m.open == s {
+		m.open = nil
+	}
+	s.closed = true
+
+	if len(s.pending) > 0 {
+		if m.closing == nil {
+			m.closing = map[uint64]*openSector{}
+		}
+		m.closing[s.id] = s
+		return nil
+	}
+	delete(m.closing, s.id)
+
+	if !s.started {
+		// nothing was written to the sector
+		return nil
+	}
+
+	return m.sectors.Send(s.id, SectorStartPacking{})
+}
+
+// startPacking stops adding pieces to the sector and moves it to the Packing
+// state, once pending pieces are written. Must be called with unsealedLk
+// held.
+func (m *Sealing) startPacking(sectorID uint64) error {
+	if s := m.filling(sectorID); s != nil {
+		return m.closeSector(s)
+	}
+
+	return m.sectors.Send(sectorID, SectorStartPacking{})
+}
+
+// StartPacking starts sealing a sector in the WaitDeals state without
+// waiting for more deals
+func (m *Sealing) StartPacking(sectorID uint64) error {
+	info, err := m.GetSectorInfo(sectorID)
+	if err != nil {
+		return xerrors.Errorf("getting sector info: %w", err)
+	}
+	if info.State != api.WaitDeals {
+		return xerrors.Errorf("sector %d is in state %s, expected %s", sectorID, api.SectorStates[info.State], api.SectorStates[api.WaitDeals])
+	}
+
+	m.unsealedLk.Lock()
+	defer m.unsealedLk.Unlock()
+
+	return m.startPacking(sectorID)
+}
+
+// forgetOpen stops adding pieces to the sector without changing its state,
+// pieces waiting to be written to it fail
+func (m *Sealing) forgetOpen(sectorID uint64) {
+	m.unsealedLk.Lock()
+	defer m.unsealedLk.Unlock()
+
+	s := m.filling(sectorID)
+	if s == nil {
+		return
+	}
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if m.open == s {
+		m.open = nil
+	}
+	delete(m.closing, sectorID)
+
+	s.aborted = true
+	for _, a := range s.pending {
+		if a.timer != nil {
+			a.timer.Stop()
+		}
+		a.startTurn()
+	}
+}
+
+// restoreOpen makes a sector left in the WaitDeals state by a previous run
+// accept deals again. Only one sector accepts deals at a time, when there are
+// more, the rest starts sealing.
+func (m *Sealing) restoreOpen(sector SectorInfo) error {
+	m.unsealedLk.Lock()
+	defer m.unsealedLk.Unlock()
+
+	if m.open != nil {
+		log.Infof("sector %d is waiting for deals, but sector %d is open already, starting sealing", sector.SectorID, m.open.id)
+		return m.sectors.Send(sector.SectorID, SectorStartPacking{})
+	}
+
+	log.Infow("sector waiting for deals", "sector", sector.SectorID, "pieces", len(sector.Pieces))
+
+	m.open = &openSector{
+		id:      sector.SectorID,
+		started: true,
+	}
+	m.open.add(sector.Pieces...)
+	m.open.reserved = m.open.used
+	m.startWaitTimer(m.open)
+
+	return nil
+}
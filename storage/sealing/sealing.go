@@ -2,7 +2,7 @@ package sealing
 
 import (
 	"context"
-	"io"
+	"sync"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-sectorbuilder"
@@ -17,7 +17,6 @@ import (
 	"github.com/filecoin-project/lotus/chain/events"
 	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
-	"github.com/filecoin-project/lotus/lib/padreader"
 	"github.com/filecoin-project/lotus/lib/statemachine"
 )
 
@@ -40,7 +39,7 @@ type sealingApi interface { // TODO: trim down
 	StateGetReceipt(context.Context, cid.Cid, *types.TipSet) (*types.MessageReceipt, error)
 	StateMarketStorageDeal(context.Context, uint64, *types.TipSet) (*actors.OnChainDeal, error)
 
-	MpoolPushMessage(context.Context, *types.Message) (*types.SignedMessage, error)
+	MpoolPushMessage(context.Context, *types.Message, *api.MessageSendSpec) (*types.SignedMessage, error)
 
 	ChainHead(context.Context) (*types.TipSet, error)
 	ChainNotify(context.Context) (<-chan []*store.HeadChange, error)
@@ -64,6 +63,12 @@ type Sealing struct {
 	sb      sectorbuilder.Interface
 	sectors *statemachine.StateGroup
 	tktFn   TicketFn
+
+	unsealedLk sync.Mutex
+	open       *openSector
+	// closing holds sectors which take no new pieces, but still have pieces
+	// waiting to be written
+	closing map[uint64]*openSector
 }
 
 func New(api sealingApi, events *events.Events, maddr address.Address, worker address.Address, ds datastore.Batching, sb sectorbuilder.Interface, tktFn TicketFn) *Sealing {
@@ -95,31 +100,6 @@ func (m *Sealing) Stop(ctx context.Context) error {
 	return m.sectors.Stop(ctx)
 }
 
-func (m *Sealing) AllocatePiece(size uint64) (sectorID uint64, offset uint64, err error) {
-	if padreader.PaddedSize(size) != size {
-		return 0, 0, xerrors.Errorf("cannot allocate unpadded piece")
-	}
-
-	sid, err := m.sb.AcquireSectorId() // TODO: Put more than one thing in a sector
-	if err != nil {
-		return 0, 0, xerrors.Errorf("acquiring sector ID: %w", err)
-	}
-
-	// offset hard-coded to 0 since we only put one thing in a sector for now
-	return sid, 0, nil
-}
-
-func (m *Sealing) SealPiece(ctx context.Context, size uint64, r io.Reader, sectorID uint64, dealID uint64) error {
-	log.Infof("Seal piece for deal %d", dealID)
-
-	ppi, err := m.sb.AddPiece(ctx, size, sectorID, r, []uint64{})
-	if err != nil {
-		return xerrors.Errorf("adding piece to sector: %w", err)
-	}
-
-	return m.newSector(ctx, sectorID, dealID, ppi)
-}
-
 func (m *Sealing) newSector(ctx context.Context, sid uint64, dealID uint64, ppi sectorbuilder.PublicPieceInfo) error {
 	log.Infof("Start sealing %d", sid)
 	return m.sectors.Send(sid, SectorStart{
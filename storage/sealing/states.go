@@ -118,7 +118,7 @@ func (m *Sealing) handlePreCommitting(ctx statemachine.Context, sector SectorInf
 	}
 
 	log.Info("submitting precommit for sector: ", sector.SectorID)
-	smsg, err := m.api.MpoolPushMessage(ctx.Context(), msg)
+	smsg, err := m.api.MpoolPushMessage(ctx.Context(), msg, nil)
 	if err != nil {
 		return ctx.Send(SectorPreCommitFailed{xerrors.Errorf("pushing message to mpool: %w", err)})
 	}
@@ -204,7 +204,7 @@ func (m *Sealing) handleCommitting(ctx statemachine.Context, sector SectorInfo)
 
 	// TODO: check seed / ticket are up to date
 
-	smsg, err := m.api.MpoolPushMessage(ctx.Context(), msg)
+	smsg, err := m.api.MpoolPushMessage(ctx.Context(), msg, nil)
 	if err != nil {
 		return ctx.Send(SectorCommitFailed{xerrors.Errorf("pushing message to mpool: %w", err)})
 	}
@@ -272,7 +272,7 @@ func (m *Sealing) handleFaulty(ctx statemachine.Context, sector SectorInfo) erro
 		GasPrice: types.NewInt(1),
 	}
 
-	smsg, err := m.api.MpoolPushMessage(ctx.Context(), msg)
+	smsg, err := m.api.MpoolPushMessage(ctx.Context(), msg, nil)
 	if err != nil {
 		return xerrors.Errorf("failed to push declare faults message to network: %w", err)
 	}
@@ -19,6 +19,7 @@ import (
 	"github.com/ipfs/go-unixfs"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-cbor-util"
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/lib/padreader"
@@ -43,8 +44,10 @@ type SectorBlocks struct {
 
 	intermediate blockstore.Blockstore // holds intermediate nodes TODO: consider combining with the staging blockstore
 
-	keys  datastore.Batching
-	keyLk sync.Mutex
+	keys      datastore.Batching
+	pieces    datastore.Batching
+	pieceLocs datastore.Batching
+	keyLk     sync.Mutex
 }
 
 func NewSectorBlocks(miner *storage.Miner, ds dtypes.MetadataDS, sb sectorbuilder.Interface) *SectorBlocks {
@@ -54,12 +57,18 @@ func NewSectorBlocks(miner *storage.Miner, ds dtypes.MetadataDS, sb sectorbuilde
 
 		intermediate: blockstore.NewBlockstore(namespace.Wrap(ds, imBlocksPrefix)),
 
-		keys: namespace.Wrap(ds, dsPrefix),
+		keys:      namespace.Wrap(ds, dsPrefix),
+		pieces:    namespace.Wrap(ds, piecesPrefix),
+		pieceLocs: namespace.Wrap(ds, pieceLocsPrefix),
 	}
 
 	return sbc
 }
 
+// ActorSectorBlocks maps the additional miner actors run by the storage miner
+// to their sector blocks
+type ActorSectorBlocks map[address.Address]*SectorBlocks
+
 type UnixfsReader interface {
 	files.File
 
@@ -152,12 +161,21 @@ func (r *refStorer) Read(p []byte) (n int, err error) {
 	}
 }
 
-func (st *SectorBlocks) AddUnixfsPiece(ctx context.Context, r UnixfsReader, dealID uint64) (sectorID uint64, err error) {
+func (st *SectorBlocks) AddUnixfsPiece(ctx context.Context, r UnixfsReader, dealID uint64, pieceRef []byte) (sectorID uint64, err error) {
 	size, err := r.Size()
 	if err != nil {
 		return 0, err
 	}
 
+	var stored bool
+	switch pi, err := st.GetPiece(pieceRef); err {
+	case nil:
+		stored = len(pi.Deals) > 0
+	case ErrNotFound:
+	default:
+		return 0, xerrors.Errorf("getting piece info: %w", err)
+	}
+
 	sectorID, pieceOffset, err := st.Miner.AllocatePiece(padreader.PaddedSize(uint64(size)))
 	if err != nil {
 		return 0, err
@@ -166,6 +184,11 @@ func (st *SectorBlocks) AddUnixfsPiece(ctx context.Context, r UnixfsReader, deal
 	refst := &refStorer{
 		blockReader: r,
 		writeRef: func(cid cid.Cid, offset uint64, size uint64) error {
+			if stored {
+				// blocks are indexed from the first copy of the piece
+				return nil
+			}
+
 			offset += pieceOffset
 
 			return st.writeRef(cid, sectorID, offset, size)
@@ -175,7 +198,30 @@ func (st *SectorBlocks) AddUnixfsPiece(ctx context.Context, r UnixfsReader, deal
 
 	pr, psize := padreader.New(refst, uint64(size))
 
-	return sectorID, st.Miner.SealPiece(ctx, psize, pr, sectorID, dealID)
+	if err := st.Miner.SealPiece(ctx, psize, pr, sectorID, pieceOffset, dealID); err != nil {
+		if !stored {
+			st.keyLk.Lock()
+			if rerr := st.removeRefs(sectorID, pieceOffset, psize); rerr != nil {
+				log.Errorf("removing block refs of failed piece: %+v", rerr)
+			}
+			st.keyLk.Unlock()
+		}
+		return 0, err
+	}
+
+	if err := st.addPieceDeal(pieceRef, psize, PieceDeal{
+		DealID:   dealID,
+		SectorID: sectorID,
+		Offset:   pieceOffset,
+	}); err != nil {
+		return 0, xerrors.Errorf("indexing piece: %w", err)
+	}
+
+	if stored {
+		log.Infof("deal %d stores piece %x again, block refs point to the first copy", dealID, pieceRef)
+	}
+
+	return sectorID, nil
 }
 
 func (st *SectorBlocks) List() (map[cid.Cid][]api.SealedRef, error) {
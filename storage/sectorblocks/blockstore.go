@@ -89,6 +89,29 @@ func (s *SectorBlockStore) Get(c cid.Cid) (blocks.Block, error) {
 			break
 		}
 	}
+	if bestSi.State == api.UndefinedSectorState {
+		// the piece may be stored for another deal in a sealed sector
+		for _, r := range refs {
+			alt, err := s.sectorBlocks.altRefs(r)
+			if err != nil {
+				return nil, xerrors.Errorf("getting other copies of the piece: %w", err)
+			}
+			for _, ar := range alt {
+				si, err := s.sectorBlocks.Miner.GetSectorInfo(ar.SectorID)
+				if err != nil {
+					return nil, xerrors.Errorf("getting sector info: %w", err)
+				}
+				if si.State == api.Proving {
+					best = ar
+					bestSi = si
+					break
+				}
+			}
+			if bestSi.State != api.UndefinedSectorState {
+				break
+			}
+		}
+	}
 	if bestSi.State == api.UndefinedSectorState {
 		return nil, xerrors.New("no sealed sector found")
 	}
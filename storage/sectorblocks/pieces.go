@@ -0,0 +1,275 @@
+package sectorblocks
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-cbor-util"
+	"github.com/filecoin-project/lotus/api"
+)
+
+var piecesPrefix = datastore.NewKey("/pieces")
+
+// piece copies indexed by location, /<sectorID>/<offset> -> piece ref
+var pieceLocsPrefix = datastore.NewKey("/piecelocs")
+
+// PieceInfo tracks all deals made for a piece. Each deal still gets its own
+// copy of the piece sealed, as the chain computes CommD of a sector from the
+// pieces of its deals, but payload blocks are only indexed once and can be
+// read from any copy.
+type PieceInfo struct {
+	PieceRef []byte
+	Size     uint64
+
+	// Indexed is the copy block refs point to. It stays indexed while any
+	// deal references the piece, even when its own deal is removed.
+	Indexed PieceDeal
+
+	// Deals reference counts the piece, it's removed with the last deal
+	Deals []PieceDeal
+}
+
+// PieceDeal is a copy of a piece stored for a deal
+type PieceDeal struct {
+	DealID   uint64
+	SectorID uint64
+	Offset   uint64
+}
+
+func pieceKey(pieceRef []byte) datastore.Key {
+	return datastore.NewKey(hex.EncodeToString(pieceRef))
+}
+
+func sectorLocsKey(sectorID uint64) datastore.Key {
+	return datastore.NewKey(fmt.Sprint(sectorID))
+}
+
+func pieceLocKey(sectorID uint64, offset uint64) datastore.Key {
+	return sectorLocsKey(sectorID).ChildString(fmt.Sprint(offset))
+}
+
+// GetPiece returns the deals made for a piece
+func (st *SectorBlocks) GetPiece(pieceRef []byte) (*PieceInfo, error) {
+	b, err := st.pieces.Get(pieceKey(pieceRef))
+	if err == datastore.ErrNotFound {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pi PieceInfo
+	if err := json.Unmarshal(b, &pi); err != nil {
+		return nil, xerrors.Errorf("decoding piece info: %w", err)
+	}
+	return &pi, nil
+}
+
+// ListPieces returns all pieces stored by the miner
+func (st *SectorBlocks) ListPieces() ([]PieceInfo, error) {
+	res, err := st.pieces.Query(query.Query{})
+	if err != nil {
+		return nil, err
+	}
+
+	ents, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]PieceInfo, len(ents))
+	for i, ent := range ents {
+		if err := json.Unmarshal(ent.Value, &out[i]); err != nil {
+			return nil, xerrors.Errorf("decoding piece info %s: %w", ent.Key, err)
+		}
+	}
+	return out, nil
+}
+
+// addPieceDeal references the piece from a deal
+func (st *SectorBlocks) addPieceDeal(pieceRef []byte, size uint64, deal PieceDeal) error {
+	st.keyLk.Lock()
+	defer st.keyLk.Unlock()
+
+	pi, err := st.GetPiece(pieceRef)
+	switch err {
+	case nil:
+	case ErrNotFound:
+		pi = &PieceInfo{PieceRef: pieceRef, Size: size, Indexed: deal}
+	default:
+		return xerrors.Errorf("getting piece info: %w", err)
+	}
+
+	pi.Deals = append(pi.Deals, deal)
+
+	if err := st.pieceLocs.Put(pieceLocKey(deal.SectorID, deal.Offset), pieceRef); err != nil {
+		return xerrors.Errorf("indexing piece location: %w", err)
+	}
+
+	return st.putPiece(pi)
+}
+
+func (st *SectorBlocks) putPiece(pi *PieceInfo) error {
+	b, err := json.Marshal(pi)
+	if err != nil {
+		return err
+	}
+	return st.pieces.Put(pieceKey(pi.PieceRef), b)
+}
+
+// RemovePieceDeal drops the reference a deal holds on a piece. Block refs
+// are removed with the last reference.
+func (st *SectorBlocks) RemovePieceDeal(pieceRef []byte, dealID uint64) error {
+	st.keyLk.Lock()
+	defer st.keyLk.Unlock()
+
+	pi, err := st.GetPiece(pieceRef)
+	if err != nil {
+		return xerrors.Errorf("getting piece info: %w", err)
+	}
+
+	var removed *PieceDeal
+	for i, d := range pi.Deals {
+		if d.DealID == dealID {
+			removed = &pi.Deals[i]
+			pi.Deals = append(pi.Deals[:i:i], pi.Deals[i+1:]...)
+			break
+		}
+	}
+	if removed == nil {
+		return xerrors.Errorf("deal %d doesn't reference piece %x", dealID, pieceRef)
+	}
+
+	if len(pi.Deals) > 0 {
+		if *removed == pi.Indexed {
+			// keep the location of the indexed copy, block refs still
+			// translate through it
+			return st.putPiece(pi)
+		}
+		if err := st.pieceLocs.Delete(pieceLocKey(removed.SectorID, removed.Offset)); err != nil {
+			return xerrors.Errorf("removing piece location: %w", err)
+		}
+		return st.putPiece(pi)
+	}
+
+	if err := st.removeRefs(pi.Indexed.SectorID, pi.Indexed.Offset, pi.Size); err != nil {
+		return xerrors.Errorf("removing block refs: %w", err)
+	}
+	if err := st.pieceLocs.Delete(pieceLocKey(removed.SectorID, removed.Offset)); err != nil {
+		return xerrors.Errorf("removing piece location: %w", err)
+	}
+	if err := st.pieceLocs.Delete(pieceLocKey(pi.Indexed.SectorID, pi.Indexed.Offset)); err != nil {
+		return xerrors.Errorf("removing piece location: %w", err)
+	}
+	return st.pieces.Delete(pieceKey(pieceRef))
+}
+
+// removeRefs drops block refs pointing into a range of a sector. This scans
+// all refs, it only runs when the last deal of a piece goes away.
+func (st *SectorBlocks) removeRefs(sectorID uint64, offset uint64, size uint64) error {
+	res, err := st.keys.Query(query.Query{})
+	if err != nil {
+		return err
+	}
+
+	ents, err := res.Rest()
+	if err != nil {
+		return err
+	}
+
+	for _, ent := range ents {
+		var refs api.SealedRefs
+		if err := cborutil.ReadCborRPC(bytes.NewReader(ent.Value), &refs); err != nil {
+			return err
+		}
+
+		kept := refs.Refs[:0]
+		for _, r := range refs.Refs {
+			if r.SectorID == sectorID && r.Offset >= offset && r.Offset < offset+size {
+				continue
+			}
+			kept = append(kept, r)
+		}
+		if len(kept) == len(refs.Refs) {
+			continue
+		}
+
+		k := datastore.RawKey(ent.Key)
+		if len(kept) == 0 {
+			if err := st.keys.Delete(k); err != nil {
+				return err
+			}
+			continue
+		}
+
+		refs.Refs = kept
+		b, err := cborutil.Dump(&refs)
+		if err != nil {
+			return err
+		}
+		if err := st.keys.Put(k, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// altRefs translates a ref into the same data in other copies of the piece
+// containing it
+func (st *SectorBlocks) altRefs(ref api.SealedRef) ([]api.SealedRef, error) {
+	// only the pieces in the sector of the ref need to be checked
+	res, err := st.pieceLocs.Query(query.Query{Prefix: sectorLocsKey(ref.SectorID).String()})
+	if err != nil {
+		return nil, err
+	}
+
+	ents, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ent := range ents {
+		pi, err := st.GetPiece(ent.Value)
+		if err != nil {
+			return nil, xerrors.Errorf("getting piece info: %w", err)
+		}
+
+		var d PieceDeal
+		if pi.Indexed.SectorID == ref.SectorID && pi.Indexed.Offset <= ref.Offset && ref.Offset < pi.Indexed.Offset+pi.Size {
+			d = pi.Indexed
+		} else {
+			var found bool
+			for _, pd := range pi.Deals {
+				if pd.SectorID == ref.SectorID && pd.Offset <= ref.Offset && ref.Offset < pd.Offset+pi.Size {
+					d, found = pd, true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+
+		var out []api.SealedRef
+		for _, other := range pi.Deals {
+			if other.SectorID == d.SectorID && other.Offset == d.Offset {
+				continue
+			}
+			out = append(out, api.SealedRef{
+				SectorID: other.SectorID,
+				Offset:   other.Offset + (ref.Offset - d.Offset),
+				Size:     ref.Size,
+			})
+		}
+		return out, nil
+	}
+
+	return nil, nil
+}
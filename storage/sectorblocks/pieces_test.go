@@ -0,0 +1,89 @@
+package sectorblocks
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	mh "github.com/multiformats/go-multihash"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func TestPieceDeals(t *testing.T) {
+	st := NewSectorBlocks(nil, dssync.MutexWrap(datastore.NewMapDatastore()), nil)
+
+	pieceRef := []byte("piece")
+	blk, err := cid.NewPrefixV1(cid.Raw, mh.IDENTITY).Sum([]byte("block"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first copy is indexed, the second one only referenced
+	if err := st.writeRef(blk, 1, 10+4, 8); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.addPieceDeal(pieceRef, 128, PieceDeal{DealID: 100, SectorID: 1, Offset: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.addPieceDeal(pieceRef, 128, PieceDeal{DealID: 101, SectorID: 2, Offset: 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	ref := api.SealedRef{SectorID: 1, Offset: 14, Size: 8}
+	alt, err := st.altRefs(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alt) != 1 || alt[0].SectorID != 2 || alt[0].Offset != 4 {
+		t.Fatalf("unexpected alternative refs: %+v", alt)
+	}
+
+	// refs in other sectors don't match the piece
+	alt, err = st.altRefs(api.SealedRef{SectorID: 3, Offset: 14, Size: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alt) != 0 {
+		t.Fatalf("unexpected alternative refs: %+v", alt)
+	}
+
+	// removing the deal of the indexed copy keeps refs translating to the
+	// remaining copy
+	if err := st.RemovePieceDeal(pieceRef, 100); err != nil {
+		t.Fatal(err)
+	}
+	alt, err = st.altRefs(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alt) != 1 || alt[0].SectorID != 2 {
+		t.Fatalf("unexpected alternative refs: %+v", alt)
+	}
+	if has, err := st.Has(blk); err != nil || !has {
+		t.Fatalf("block refs should be kept while the piece is referenced: %v %v", has, err)
+	}
+
+	if err := st.RemovePieceDeal(pieceRef, 100); err == nil {
+		t.Fatal("expected an error removing the deal twice")
+	}
+
+	// the last reference removes the piece and its block refs
+	if err := st.RemovePieceDeal(pieceRef, 101); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.GetPiece(pieceRef); err != ErrNotFound {
+		t.Fatalf("expected the piece to be removed, got %v", err)
+	}
+	if has, err := st.Has(blk); err != nil || has {
+		t.Fatalf("block refs should be removed with the piece: %v %v", has, err)
+	}
+	alt, err = st.altRefs(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alt) != 0 {
+		t.Fatalf("unexpected alternative refs: %+v", alt)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"os"
 
 	"contrib.go.opencensus.io/exporter/jaeger"
+	"contrib.go.opencensus.io/exporter/ocagent"
 	logging "github.com/ipfs/go-log/v2"
 	"go.opencensus.io/trace"
+	"golang.org/x/xerrors"
 )
 
 var log = logging.Logger("tracing")
@@ -32,3 +34,76 @@ func SetupJaegerTracing(serviceName string) *jaeger.Exporter {
 	})
 	return je
 }
+
+// Options configure trace exporters set up from the node config
+type Options struct {
+	ServiceName string
+
+	// JaegerAgentEndpoint is the host:port of a jaeger agent accepting spans
+	// over UDP, JaegerCollectorEndpoint the URL of a jaeger collector
+	JaegerAgentEndpoint     string
+	JaegerCollectorEndpoint string
+	// OCAgentAddress is the host:port of an OpenCensus agent, or an
+	// OpenTelemetry collector with the opencensus receiver enabled
+	OCAgentAddress string
+
+	// SampleRate is the fraction of traces recorded, between 0 and 1
+	SampleRate float64
+}
+
+// Setup registers the configured exporters. The returned function flushes
+// and unregisters them.
+func Setup(o Options) (func(), error) {
+	var exporters []trace.Exporter
+	var stops []func()
+
+	if o.JaegerAgentEndpoint != "" || o.JaegerCollectorEndpoint != "" {
+		je, err := jaeger.NewExporter(jaeger.Options{
+			AgentEndpoint:     o.JaegerAgentEndpoint,
+			CollectorEndpoint: o.JaegerCollectorEndpoint,
+			ServiceName:       o.ServiceName,
+		})
+		if err != nil {
+			return nil, xerrors.Errorf("creating jaeger exporter: %w", err)
+		}
+
+		exporters = append(exporters, je)
+		stops = append(stops, je.Flush)
+	}
+
+	if o.OCAgentAddress != "" {
+		oce, err := ocagent.NewExporter(
+			ocagent.WithInsecure(),
+			ocagent.WithAddress(o.OCAgentAddress),
+			ocagent.WithServiceName(o.ServiceName))
+		if err != nil {
+			return nil, xerrors.Errorf("creating opencensus agent exporter: %w", err)
+		}
+
+		exporters = append(exporters, oce)
+		stops = append(stops, func() {
+			if err := oce.Stop(); err != nil {
+				log.Warnf("stopping opencensus agent exporter: %s", err)
+			}
+		})
+	}
+
+	for _, e := range exporters {
+		trace.RegisterExporter(e)
+	}
+
+	if len(exporters) > 0 {
+		trace.ApplyConfig(trace.Config{
+			DefaultSampler: trace.ProbabilitySampler(o.SampleRate),
+		})
+	}
+
+	return func() {
+		for _, e := range exporters {
+			trace.UnregisterExporter(e)
+		}
+		for _, stop := range stops {
+			stop()
+		}
+	}, nil
+}